@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-gateway/public"
+	"net/http"
+)
+
+type AcmeController struct{}
+
+// AcmeRegister acme http-01 验证，注册在 http 代理端口
+func AcmeRegister(group *gin.RouterGroup) {
+	acme := &AcmeController{}
+	group.GET("/acme-challenge/:token", acme.Challenge)
+}
+
+// Challenge 返回 redis 中保存的验证值，集群内任一节点均可应答
+func (acme *AcmeController) Challenge(c *gin.Context) {
+	keyAuth, err := public.GetAcmeChallenge(c.Param("token"))
+	if err != nil {
+		c.String(http.StatusNotFound, "challenge not found")
+		return
+	}
+	c.String(http.StatusOK, keyAuth)
+}
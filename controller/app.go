@@ -67,6 +67,8 @@ func (admin *APPController) APPList(c *gin.Context) {
 			Qps:      item.Qps,
 			RealQpd:  appCounter.TotalCount,
 			RealQps:  appCounter.QPS,
+
+			CertSubject: item.CertSubject,
 		})
 	}
 	output := dto.APPListOutput{
@@ -134,6 +136,7 @@ func (admin *APPController) APPDelete(c *gin.Context) {
 		middleware.ResponseError(c, 2003, err)
 		return
 	}
+	public.PublishConfChange(public.ConfChangeApp)
 	middleware.ResponseSuccess(c, "")
 	return
 }
@@ -174,11 +177,14 @@ func (admin *APPController) AppAdd(c *gin.Context) {
 		WhiteIPS: params.WhiteIPS,
 		Qps:      params.Qps,
 		Qpd:      params.Qpd,
+
+		CertSubject: params.CertSubject,
 	}
 	if err := info.Save(c, tx); err != nil {
 		middleware.ResponseError(c, 2003, err)
 		return
 	}
+	public.PublishConfChange(public.ConfChangeApp)
 	middleware.ResponseSuccess(c, "")
 	return
 }
@@ -215,10 +221,12 @@ func (admin *APPController) AppUpdate(c *gin.Context) {
 	info.WhiteIPS = params.WhiteIPS
 	info.Qps = params.Qps
 	info.Qpd = params.Qpd
+	info.CertSubject = params.CertSubject
 	if err := info.Save(c, lib.GORMDefaultPool); err != nil {
 		middleware.ResponseError(c, 2003, err)
 		return
 	}
+	public.PublishConfChange(public.ConfChangeApp)
 	middleware.ResponseSuccess(c, "")
 	return
 }
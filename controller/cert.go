@@ -0,0 +1,271 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go-gateway/common/lib"
+	"go-gateway/dao"
+	"go-gateway/dto"
+	"go-gateway/middleware"
+	"go-gateway/public"
+	"time"
+)
+
+type CertController struct {
+}
+
+func CertRegister(router *gin.RouterGroup) {
+	cert := CertController{}
+	router.GET("/cert_list", cert.CertList)
+	router.GET("/cert_detail", cert.CertDetail)
+	router.GET("/cert_delete", cert.CertDelete)
+	router.GET("/cert_expiring", cert.CertExpiring)
+	router.GET("/acme_stat", cert.AcmeStat)
+	router.POST("/cert_add", cert.CertAdd)
+	router.POST("/cert_update", cert.CertUpdate)
+}
+
+// CertList godoc
+// @Summary 证书列表
+// @Description 证书列表
+// @Tags 证书管理
+// @ID /cert/cert_list
+// @Accept  json
+// @Produce  json
+// @Param info query string false "关键词"
+// @Param page_size query string true "每页多少条"
+// @Param page_no query string true "页码"
+// @Success 200 {object} middleware.Response{data=dto.CertListOutput} "success"
+// @Router /cert/cert_list [get]
+func (cert *CertController) CertList(c *gin.Context) {
+	params := &dto.CertListInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	info := &dao.Cert{}
+	list, total, err := info.CertList(c, lib.GORMDefaultPool, params)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	warnDays := dao.GetCertExpireWarnDays()
+	outputList := []dto.CertListItemOutput{}
+	for _, item := range list {
+		outputList = append(outputList, certListItem(item, warnDays))
+	}
+	middleware.ResponseSuccess(c, dto.CertListOutput{
+		List:  outputList,
+		Total: total,
+	})
+	return
+}
+
+// CertDetail godoc
+// @Summary 证书详情
+// @Description 证书详情，不返回私钥
+// @Tags 证书管理
+// @ID /cert/cert_detail
+// @Accept  json
+// @Produce  json
+// @Param id query string true "证书ID"
+// @Success 200 {object} middleware.Response{data=dao.Cert} "success"
+// @Router /cert/cert_detail [get]
+func (cert *CertController) CertDetail(c *gin.Context) {
+	params := &dto.CertDetailInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Cert{
+		ID: params.ID,
+	}
+	detail, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	middleware.ResponseSuccess(c, detail)
+	return
+}
+
+// CertDelete godoc
+// @Summary 证书删除
+// @Description 证书删除
+// @Tags 证书管理
+// @ID /cert/cert_delete
+// @Accept  json
+// @Produce  json
+// @Param id query string true "证书ID"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /cert/cert_delete [get]
+func (cert *CertController) CertDelete(c *gin.Context) {
+	params := &dto.CertDetailInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Cert{
+		ID: params.ID,
+	}
+	info, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	info.IsDelete = 1
+	if err := info.Save(c, lib.GORMDefaultPool); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	public.PublishConfChange(public.ConfChangeCert)
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// CertAdd godoc
+// @Summary 证书添加
+// @Description 证书添加，sni_names 为空时取证书中的域名
+// @Tags 证书管理
+// @ID /cert/cert_add
+// @Accept  json
+// @Produce  json
+// @Param body body dto.CertAddInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /cert/cert_add [post]
+func (cert *CertController) CertAdd(c *gin.Context) {
+	params := &dto.CertAddInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	info := &dao.Cert{
+		Name:     params.Name,
+		SniNames: params.SniNames,
+		CertPem:  params.CertPem,
+		KeyPem:   params.KeyPem,
+	}
+	//校验证书与私钥，失败时不入库
+	if _, err := info.Parse(); err != nil {
+		middleware.ResponseError(c, 2002, errors.Wrap(err, "证书或私钥不正确"))
+		return
+	}
+	if err := info.Save(c, lib.GORMDefaultPool); err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	public.PublishConfChange(public.ConfChangeCert)
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// CertUpdate godoc
+// @Summary 证书更新
+// @Description 证书更新，key_pem 为空时沿用原私钥
+// @Tags 证书管理
+// @ID /cert/cert_update
+// @Accept  json
+// @Produce  json
+// @Param body body dto.CertUpdateInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /cert/cert_update [post]
+func (cert *CertController) CertUpdate(c *gin.Context) {
+	params := &dto.CertUpdateInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	search := &dao.Cert{
+		ID: params.ID,
+	}
+	info, err := search.Find(c, lib.GORMDefaultPool, search)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	info.Name = params.Name
+	info.SniNames = params.SniNames
+	info.CertPem = params.CertPem
+	if params.KeyPem != "" {
+		info.KeyPem = params.KeyPem
+	}
+	if _, err := info.Parse(); err != nil {
+		middleware.ResponseError(c, 2003, errors.Wrap(err, "证书或私钥不正确"))
+		return
+	}
+	if err := info.Save(c, lib.GORMDefaultPool); err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	public.PublishConfChange(public.ConfChangeCert)
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// CertExpiring godoc
+// @Summary 即将过期证书
+// @Description 剩余有效天数不足 days 天的证书，包含已过期证书
+// @Tags 证书管理
+// @ID /cert/cert_expiring
+// @Accept  json
+// @Produce  json
+// @Param days query int false "剩余天数, 默认取配置 cert.expire_warn_days"
+// @Success 200 {object} middleware.Response{data=[]dto.CertListItemOutput} "success"
+// @Router /cert/cert_expiring [get]
+func (cert *CertController) CertExpiring(c *gin.Context) {
+	params := &dto.CertExpiringInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	warnDays := params.Days
+	if warnDays <= 0 {
+		warnDays = dao.GetCertExpireWarnDays()
+	}
+	info := &dao.Cert{}
+	list, err := info.ExpiringList(c, lib.GORMDefaultPool, time.Now().AddDate(0, 0, warnDays))
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	outputList := []dto.CertListItemOutput{}
+	for _, item := range list {
+		outputList = append(outputList, certListItem(item, warnDays))
+	}
+	middleware.ResponseSuccess(c, outputList)
+	return
+}
+
+// AcmeStat godoc
+// @Summary acme签发状态
+// @Description 自动签发证书的域名最近一次签发或续期结果
+// @Tags 证书管理
+// @ID /cert/acme_stat
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} middleware.Response{data=[]public.AcmeStatus} "success"
+// @Router /cert/acme_stat [get]
+func (cert *CertController) AcmeStat(c *gin.Context) {
+	statusList, err := public.GetAcmeStatusList()
+	if err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+	middleware.ResponseSuccess(c, statusList)
+	return
+}
+
+func certListItem(item dao.Cert, warnDays int) dto.CertListItemOutput {
+	expireDays := int(time.Until(item.NotAfter).Hours() / 24)
+	return dto.CertListItemOutput{
+		ID:         item.ID,
+		Name:       item.Name,
+		SniNames:   item.SniNames,
+		Source:     item.Source,
+		NotBefore:  item.NotBefore,
+		NotAfter:   item.NotAfter,
+		ExpireDays: expireDays,
+		Expiring:   time.Until(item.NotAfter) < time.Duration(warnDays)*24*time.Hour,
+		UpdatedAt:  item.UpdatedAt,
+	}
+}
@@ -71,15 +71,35 @@ func (service *DashboardController) PanelGroupData(c *gin.Context) {
 		return
 	}
 
-	// 5. 组装返回数据
+	// 5. 查询即将过期的 https 证书，大盘提示及时更换
+	cert := &dao.Cert{}
+	expiringCerts, err := cert.ExpiringList(c, tx, time.Now().AddDate(0, 0, dao.GetCertExpireWarnDays()))
+	if err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+
+	// 6. 统计 acme 签发或续期失败的域名，读取失败时不影响大盘
+	acmeFailNum := int64(0)
+	if statusList, err := public.GetAcmeStatusList(); err == nil {
+		for _, status := range statusList {
+			if status.Status == public.AcmeStatusFailed {
+				acmeFailNum++
+			}
+		}
+	}
+
+	// 7. 组装返回数据
 	out := &dto.PanelGroupDataOutput{
 		ServiceNum:      serviceNum,         // 服务数量
 		AppNum:          appNum,             // APP 数量
 		TodayRequestNum: counter.TotalCount, // 今日总请求数
 		CurrentQPS:      counter.QPS,        // 当前 QPS
+		ExpiringCertNum: int64(len(expiringCerts)),
+		AcmeFailNum:     acmeFailNum,
 	}
 
-	// 6. 返回前端
+	// 8. 返回前端
 	middleware.ResponseSuccess(c, out)
 }
 
@@ -28,6 +28,8 @@ func ServiceRegister(group *gin.RouterGroup) {
 	group.POST("/service_update_tcp", service.ServiceUpdateTcp)
 	group.POST("/service_add_grpc", service.ServiceAddGrpc)
 	group.POST("/service_update_grpc", service.ServiceUpdateGrpc)
+	group.POST("/service_add_udp", service.ServiceAddUdp)
+	group.POST("/service_update_udp", service.ServiceUpdateUdp)
 }
 
 // ServiceList godoc
@@ -73,7 +75,7 @@ func (service *ServiceController) ServiceList(c *gin.Context) {
 		}
 		//1、http后缀接入 clusterIP+clusterPort+path
 		//2、http域名接入 domain
-		//3、tcp、grpc接入 clusterIP+servicePort
+		//3、tcp、grpc、udp接入 clusterIP+servicePort
 		serviceAddr := "unknow"
 		clusterIP := lib.GetStringConf("base.cluster.cluster_ip")
 		clusterPort := lib.GetStringConf("base.cluster.cluster_port")
@@ -94,10 +96,17 @@ func (service *ServiceController) ServiceList(c *gin.Context) {
 		}
 		if serviceDetail.Info.LoadType == public.LoadTypeTCP {
 			serviceAddr = fmt.Sprintf("%s:%d", clusterIP, serviceDetail.TCPRule.Port)
+			//只通过共享sni端口接入
+			if serviceDetail.TCPRule.Port == 0 {
+				serviceAddr = fmt.Sprintf("%s:%s", clusterIP, lib.GetStringConf("base.cluster.cluster_tcp_sni_port"))
+			}
 		}
 		if serviceDetail.Info.LoadType == public.LoadTypeGRPC {
 			serviceAddr = fmt.Sprintf("%s:%d", clusterIP, serviceDetail.GRPCRule.Port)
 		}
+		if serviceDetail.Info.LoadType == public.LoadTypeUDP {
+			serviceAddr = fmt.Sprintf("%s:%d", clusterIP, serviceDetail.UDPRule.Port)
+		}
 		ipList := serviceDetail.LoadBalance.GetIPListByModel()
 		counter, err := public.FlowCounterHandler.GetCounter(public.FlowServicePrefix + listItem.ServiceName)
 		if err != nil {
@@ -159,6 +168,7 @@ func (service *ServiceController) ServiceDelete(c *gin.Context) {
 		middleware.ResponseError(c, 2003, err)
 		return
 	}
+	public.PublishConfChange(public.ConfChangeService)
 	middleware.ResponseSuccess(c, "")
 }
 
@@ -267,10 +277,60 @@ func (service *ServiceController) ServiceStat(c *gin.Context) {
 		yesterdayList = append(yesterdayList, hourData)
 	}
 
-	// 7. 返回统计结果
+	// 7. 统计今天、昨天每小时失败重试次数
+	retryCounter, err := public.FlowCounterHandler.GetCounter(public.FlowRetryPrefix + serviceDetail.Info.ServiceName)
+	if err != nil {
+		middleware.ResponseError(c, 2005, err)
+		return
+	}
+	todayRetryList := []int64{}
+	for i := 0; i <= currentTime.Hour(); i++ {
+		dateTime := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), i, 0, 0, 0, lib.TimeLocation)
+		hourData, _ := retryCounter.GetHourData(dateTime)
+		todayRetryList = append(todayRetryList, hourData)
+	}
+	yesterdayRetryList := []int64{}
+	for i := 0; i <= 23; i++ {
+		dateTime := time.Date(yesterTime.Year(), yesterTime.Month(), yesterTime.Day(), i, 0, 0, 0, lib.TimeLocation)
+		hourData, _ := retryCounter.GetHourData(dateTime)
+		yesterdayRetryList = append(yesterdayRetryList, hourData)
+	}
+
+	// 8. 统计今天、昨天每小时新建的 websocket 连接数
+	wsCounter, err := public.FlowCounterHandler.GetCounter(public.FlowWebsocketPrefix + serviceDetail.Info.ServiceName)
+	if err != nil {
+		middleware.ResponseError(c, 2006, err)
+		return
+	}
+	todayWsList := []int64{}
+	for i := 0; i <= currentTime.Hour(); i++ {
+		dateTime := time.Date(currentTime.Year(), currentTime.Month(), currentTime.Day(), i, 0, 0, 0, lib.TimeLocation)
+		hourData, _ := wsCounter.GetHourData(dateTime)
+		todayWsList = append(todayWsList, hourData)
+	}
+	yesterdayWsList := []int64{}
+	for i := 0; i <= 23; i++ {
+		dateTime := time.Date(yesterTime.Year(), yesterTime.Month(), yesterTime.Day(), i, 0, 0, 0, lib.TimeLocation)
+		hourData, _ := wsCounter.GetHourData(dateTime)
+		yesterdayWsList = append(yesterdayWsList, hourData)
+	}
+
+	// 9. 服务及下游节点的熔断状态，以及最近的状态变更
+	breakerStat, breakerEvent, err := public.GetCircuitBreakerStat(serviceDetail.Info.ServiceName)
+	if err != nil {
+		breakerStat, breakerEvent = []public.CircuitBreakerStat{}, []public.CircuitBreakerEvent{}
+	}
+
+	// 10. 返回统计结果
 	middleware.ResponseSuccess(c, &dto.ServiceStatOutput{
-		Today:     todayList,
-		Yesterday: yesterdayList,
+		Today:               todayList,
+		Yesterday:           yesterdayList,
+		TodayRetry:          todayRetryList,
+		YesterdayRetry:      yesterdayRetryList,
+		TodayWebsocket:      todayWsList,
+		YesterdayWebsocket:  yesterdayWsList,
+		CircuitBreaker:      breakerStat,
+		CircuitBreakerEvent: breakerEvent,
 	})
 }
 
@@ -296,6 +356,13 @@ func (service *ServiceController) ServiceAddHTTP(c *gin.Context) {
 		middleware.ResponseError(c, 2004, errors.New("IP列表与权重列表数量不一致"))
 		return
 	}
+	// 使用上传的描述文件时需能解析出全部依赖
+	if params.GrpcTranscode == public.GrpcTranscodeDescriptor {
+		if _, err := public.ParseGrpcDescriptorSet(params.GrpcDescriptor); err != nil {
+			middleware.ResponseError(c, 2004, errors.New("grpc描述文件无效: "+err.Error()))
+			return
+		}
+	}
 	// 获取数据库连接
 	tx, err := lib.GetGormPool("default")
 	if err != nil {
@@ -342,6 +409,20 @@ func (service *ServiceController) ServiceAddHTTP(c *gin.Context) {
 		NeedWebsocket:  params.NeedWebsocket,
 		UrlRewrite:     params.UrlRewrite,
 		HeaderTransfor: params.HeaderTransfor,
+
+		HttpsPolicy:           params.HttpsPolicy,
+		HttpsRedirectCode:     params.HttpsRedirectCode,
+		HstsMaxAge:            params.HstsMaxAge,
+		HstsIncludeSubdomains: params.HstsIncludeSubdomains,
+		UpstreamScheme:        params.UpstreamScheme,
+		UpstreamProtocol:      params.UpstreamProtocol,
+
+		GrpcTranscode:  params.GrpcTranscode,
+		GrpcDescriptor: params.GrpcDescriptor,
+
+		LegacyErrorResponse: params.LegacyErrorResponse,
+
+		RouteRule: params.RouteRule,
 	}
 	if err := httpRule.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -357,6 +438,8 @@ func (service *ServiceController) ServiceAddHTTP(c *gin.Context) {
 		WhiteList:         params.WhiteList,
 		ClientIPFlowLimit: params.ClientipFlowLimit,
 		ServiceFlowLimit:  params.ServiceFlowLimit,
+
+		ClientCertAuth: params.ClientCertAuth,
 	}
 	if err := accessControl.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -374,6 +457,30 @@ func (service *ServiceController) ServiceAddHTTP(c *gin.Context) {
 		UpstreamHeaderTimeout:  params.UpstreamHeaderTimeout,
 		UpstreamIdleTimeout:    params.UpstreamIdleTimeout,
 		UpstreamMaxIdle:        params.UpstreamMaxIdle,
+		CheckMethod:            params.CheckMethod,
+		CheckTimeout:           params.CheckTimeout,
+		CheckInterval:          params.CheckInterval,
+		CheckPath:              params.CheckPath,
+		CheckHttpMethod:        params.CheckHttpMethod,
+		CheckExpectStatus:      params.CheckExpectStatus,
+		CheckExpectBody:        params.CheckExpectBody,
+		CheckRise:              params.CheckRise,
+		CheckFall:              params.CheckFall,
+		RetryMax:               params.RetryMax,
+		RetryOn:                params.RetryOn,
+		RetryNonIdempotent:     params.RetryNonIdempotent,
+		RetryPerTryTimeout:     params.RetryPerTryTimeout,
+
+		UpstreamTlsCa:         params.UpstreamTlsCa,
+		UpstreamTlsCert:       params.UpstreamTlsCert,
+		UpstreamTlsKey:        params.UpstreamTlsKey,
+		UpstreamTlsServerName: params.UpstreamTlsServerName,
+		UpstreamTlsInsecure:   params.UpstreamTlsInsecure,
+	}
+	if _, err := loadbalance.GetUpstreamTLSConfig(); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2008, errors.Wrap(err, "下游tls配置不正确"))
+		return
 	}
 	if err := loadbalance.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -381,8 +488,29 @@ func (service *ServiceController) ServiceAddHTTP(c *gin.Context) {
 		return
 	}
 
+	// 7. 创建熔断配置 CircuitBreaker
+	circuitBreaker := &dao.CircuitBreaker{
+		ServiceID:        serviceModel.ID,
+		OpenBreaker:      params.OpenBreaker,
+		ErrorPercent:     params.BreakerErrorPercent,
+		SlowCallPercent:  params.BreakerSlowCallPercent,
+		SlowCallDuration: params.BreakerSlowCallDuration,
+		MinRequest:       params.BreakerMinRequest,
+		StatWindow:       params.BreakerStatWindow,
+		OpenDuration:     params.BreakerOpenDuration,
+		HalfOpenRequests: params.BreakerHalfOpenRequests,
+		FallbackStatus:   params.BreakerFallbackStatus,
+		FallbackBody:     params.BreakerFallbackBody,
+	}
+	if err := circuitBreaker.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2009, err)
+		return
+	}
+
 	// 所有写入成功，提交事务
 	tx.Commit()
+	public.PublishConfChange(public.ConfChangeService)
 	middleware.ResponseSuccess(c, "")
 }
 
@@ -410,6 +538,12 @@ func (service *ServiceController) ServiceUpdateHTTP(c *gin.Context) {
 		middleware.ResponseError(c, 2001, errors.New("IP列表与权重列表数量不一致"))
 		return
 	}
+	if params.GrpcTranscode == public.GrpcTranscodeDescriptor {
+		if _, err := public.ParseGrpcDescriptorSet(params.GrpcDescriptor); err != nil {
+			middleware.ResponseError(c, 2001, errors.New("grpc描述文件无效: "+err.Error()))
+			return
+		}
+	}
 
 	// 3. 获取数据库连接
 	tx, err := lib.GetGormPool("default")
@@ -456,6 +590,16 @@ func (service *ServiceController) ServiceUpdateHTTP(c *gin.Context) {
 	httpRule.NeedWebsocket = params.NeedWebsocket
 	httpRule.UrlRewrite = params.UrlRewrite
 	httpRule.HeaderTransfor = params.HeaderTransfor
+	httpRule.HttpsPolicy = params.HttpsPolicy
+	httpRule.HttpsRedirectCode = params.HttpsRedirectCode
+	httpRule.HstsMaxAge = params.HstsMaxAge
+	httpRule.HstsIncludeSubdomains = params.HstsIncludeSubdomains
+	httpRule.UpstreamScheme = params.UpstreamScheme
+	httpRule.UpstreamProtocol = params.UpstreamProtocol
+	httpRule.GrpcTranscode = params.GrpcTranscode
+	httpRule.GrpcDescriptor = params.GrpcDescriptor
+	httpRule.LegacyErrorResponse = params.LegacyErrorResponse
+	httpRule.RouteRule = params.RouteRule
 	if err := httpRule.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2006, err)
@@ -469,6 +613,7 @@ func (service *ServiceController) ServiceUpdateHTTP(c *gin.Context) {
 	accessControl.WhiteList = params.WhiteList
 	accessControl.ClientIPFlowLimit = params.ClientipFlowLimit
 	accessControl.ServiceFlowLimit = params.ServiceFlowLimit
+	accessControl.ClientCertAuth = params.ClientCertAuth
 	if err := accessControl.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2007, err)
@@ -484,16 +629,62 @@ func (service *ServiceController) ServiceUpdateHTTP(c *gin.Context) {
 	loadbalance.UpstreamHeaderTimeout = params.UpstreamHeaderTimeout
 	loadbalance.UpstreamIdleTimeout = params.UpstreamIdleTimeout
 	loadbalance.UpstreamMaxIdle = params.UpstreamMaxIdle
+	loadbalance.CheckMethod = params.CheckMethod
+	loadbalance.CheckTimeout = params.CheckTimeout
+	loadbalance.CheckInterval = params.CheckInterval
+	loadbalance.CheckPath = params.CheckPath
+	loadbalance.CheckHttpMethod = params.CheckHttpMethod
+	loadbalance.CheckExpectStatus = params.CheckExpectStatus
+	loadbalance.CheckExpectBody = params.CheckExpectBody
+	loadbalance.CheckRise = params.CheckRise
+	loadbalance.CheckFall = params.CheckFall
+	loadbalance.RetryMax = params.RetryMax
+	loadbalance.RetryOn = params.RetryOn
+	loadbalance.RetryNonIdempotent = params.RetryNonIdempotent
+	loadbalance.RetryPerTryTimeout = params.RetryPerTryTimeout
+	loadbalance.UpstreamTlsCa = params.UpstreamTlsCa
+	loadbalance.UpstreamTlsCert = params.UpstreamTlsCert
+	loadbalance.UpstreamTlsKey = params.UpstreamTlsKey
+	loadbalance.UpstreamTlsServerName = params.UpstreamTlsServerName
+	loadbalance.UpstreamTlsInsecure = params.UpstreamTlsInsecure
+	if _, err := loadbalance.GetUpstreamTLSConfig(); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2008, errors.Wrap(err, "下游tls配置不正确"))
+		return
+	}
 	if err := loadbalance.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2008, err)
 		return
 	}
 
-	// 11. 所有表更新成功 → 提交事务
+	// 11. 更新熔断配置 circuit_breaker
+	circuitBreaker := &dao.CircuitBreaker{}
+	if serviceDetail.CircuitBreaker != nil {
+		circuitBreaker = serviceDetail.CircuitBreaker
+	}
+	circuitBreaker.ServiceID = info.ID
+	circuitBreaker.OpenBreaker = params.OpenBreaker
+	circuitBreaker.ErrorPercent = params.BreakerErrorPercent
+	circuitBreaker.SlowCallPercent = params.BreakerSlowCallPercent
+	circuitBreaker.SlowCallDuration = params.BreakerSlowCallDuration
+	circuitBreaker.MinRequest = params.BreakerMinRequest
+	circuitBreaker.StatWindow = params.BreakerStatWindow
+	circuitBreaker.OpenDuration = params.BreakerOpenDuration
+	circuitBreaker.HalfOpenRequests = params.BreakerHalfOpenRequests
+	circuitBreaker.FallbackStatus = params.BreakerFallbackStatus
+	circuitBreaker.FallbackBody = params.BreakerFallbackBody
+	if err := circuitBreaker.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2009, err)
+		return
+	}
+
+	// 12. 所有表更新成功 → 提交事务，并通知代理服务器热加载
 	tx.Commit()
+	public.PublishConfChange(public.ConfChangeService)
 
-	// 12. 返回成功
+	// 13. 返回成功
 	middleware.ResponseSuccess(c, "")
 }
 
@@ -525,21 +716,42 @@ func (admin *ServiceController) ServiceAddTcp(c *gin.Context) {
 		return
 	}
 
-	// 3. 检查 TCP 端口是否被占用（避免重复监听同端口）
-	tcpRuleSearch := &dao.TcpRule{
-		Port: params.Port,
-	}
-	if _, err := tcpRuleSearch.Find(c, lib.GORMDefaultPool, tcpRuleSearch); err == nil {
-		middleware.ResponseError(c, 2003, errors.New("服务端口被占用，请重新输入"))
+	// 3. 检查 TCP 端口是否被占用（避免重复监听同端口），只通过共享sni端口接入时不占用端口
+	if params.Port == 0 && params.SniNames == "" {
+		middleware.ResponseError(c, 2003, errors.New("端口与sni域名至少设置一项"))
 		return
 	}
+	if params.Port > 0 {
+		tcpRuleSearch := &dao.TcpRule{
+			Port: params.Port,
+		}
+		if _, err := tcpRuleSearch.Find(c, lib.GORMDefaultPool, tcpRuleSearch); err == nil {
+			middleware.ResponseError(c, 2003, errors.New("服务端口被占用，请重新输入"))
+			return
+		}
 
-	// 4. 检查 GRPC 的端口是否被占用（TCP 和 GRPC 不允许使用同一个端口）
-	grpcRuleSearch := &dao.GrpcRule{
-		Port: params.Port,
+		// 4. 检查 GRPC 的端口是否被占用（TCP 和 GRPC 不允许使用同一个端口）
+		grpcRuleSearch := &dao.GrpcRule{
+			Port: params.Port,
+		}
+		if _, err := grpcRuleSearch.Find(c, lib.GORMDefaultPool, grpcRuleSearch); err == nil {
+			middleware.ResponseError(c, 2004, errors.New("服务端口被占用，请重新输入"))
+			return
+		}
 	}
-	if _, err := grpcRuleSearch.Find(c, lib.GORMDefaultPool, grpcRuleSearch); err == nil {
-		middleware.ResponseError(c, 2004, errors.New("服务端口被占用，请重新输入"))
+	tcpRule := &dao.TcpRule{
+		Port:          params.Port,
+		NeedTls:       params.NeedTls,
+		SniNames:      params.SniNames,
+		ProxyProtocol: params.ProxyProtocol,
+	}
+	usedName, err := tcpRule.SniNameUsed(c, lib.GORMDefaultPool, 0)
+	if err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	if usedName != "" {
+		middleware.ResponseError(c, 2004, errors.New("sni域名被占用: "+usedName))
 		return
 	}
 
@@ -566,11 +778,31 @@ func (admin *ServiceController) ServiceAddTcp(c *gin.Context) {
 
 	// 8. 创建 load_balance（负载均衡设置）
 	loadBalance := &dao.LoadBalance{
-		ServiceID:  info.ID,
-		RoundType:  params.RoundType,
-		IpList:     params.IpList,
-		WeightList: params.WeightList,
-		ForbidList: params.ForbidList,
+		ServiceID:         info.ID,
+		RoundType:         params.RoundType,
+		IpList:            params.IpList,
+		WeightList:        params.WeightList,
+		ForbidList:        params.ForbidList,
+		CheckMethod:       params.CheckMethod,
+		CheckTimeout:      params.CheckTimeout,
+		CheckInterval:     params.CheckInterval,
+		CheckPath:         params.CheckPath,
+		CheckHttpMethod:   params.CheckHttpMethod,
+		CheckExpectStatus: params.CheckExpectStatus,
+		CheckExpectBody:   params.CheckExpectBody,
+		CheckRise:         params.CheckRise,
+		CheckFall:         params.CheckFall,
+
+		UpstreamTlsCa:         params.UpstreamTlsCa,
+		UpstreamTlsCert:       params.UpstreamTlsCert,
+		UpstreamTlsKey:        params.UpstreamTlsKey,
+		UpstreamTlsServerName: params.UpstreamTlsServerName,
+		UpstreamTlsInsecure:   params.UpstreamTlsInsecure,
+	}
+	if _, err := loadBalance.GetUpstreamTLSConfig(); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2007, errors.Wrap(err, "下游tls配置不正确"))
+		return
 	}
 	if err := loadBalance.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -579,11 +811,8 @@ func (admin *ServiceController) ServiceAddTcp(c *gin.Context) {
 	}
 
 	// 9. 创建 tcp_rule（TCP 端口规则）
-	httpRule := &dao.TcpRule{
-		ServiceID: info.ID,
-		Port:      params.Port,
-	}
-	if err := httpRule.Save(c, tx); err != nil {
+	tcpRule.ServiceID = info.ID
+	if err := tcpRule.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2008, err)
 		return
@@ -598,6 +827,8 @@ func (admin *ServiceController) ServiceAddTcp(c *gin.Context) {
 		WhiteHostName:     params.WhiteHostName,
 		ClientIPFlowLimit: params.ClientIPFlowLimit,
 		ServiceFlowLimit:  params.ServiceFlowLimit,
+
+		ClientCertAuth: params.ClientCertAuth,
 	}
 	if err := accessControl.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -605,8 +836,29 @@ func (admin *ServiceController) ServiceAddTcp(c *gin.Context) {
 		return
 	}
 
-	// 11. 全部成功 → 提交事务
+	// 11. 创建 circuit_breaker（熔断配置）
+	circuitBreaker := &dao.CircuitBreaker{
+		ServiceID:        info.ID,
+		OpenBreaker:      params.OpenBreaker,
+		ErrorPercent:     params.BreakerErrorPercent,
+		SlowCallPercent:  params.BreakerSlowCallPercent,
+		SlowCallDuration: params.BreakerSlowCallDuration,
+		MinRequest:       params.BreakerMinRequest,
+		StatWindow:       params.BreakerStatWindow,
+		OpenDuration:     params.BreakerOpenDuration,
+		HalfOpenRequests: params.BreakerHalfOpenRequests,
+		FallbackStatus:   params.BreakerFallbackStatus,
+		FallbackBody:     params.BreakerFallbackBody,
+	}
+	if err := circuitBreaker.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2010, err)
+		return
+	}
+
+	// 12. 全部成功 → 提交事务
 	tx.Commit()
+	public.PublishConfChange(public.ConfChangeService)
 	middleware.ResponseSuccess(c, "")
 	return
 }
@@ -634,6 +886,22 @@ func (admin *ServiceController) ServiceUpdateTcp(c *gin.Context) {
 		middleware.ResponseError(c, 2002, errors.New("ip列表与权重设置不匹配"))
 		return
 	}
+	if params.Port == 0 && params.SniNames == "" {
+		middleware.ResponseError(c, 2002, errors.New("端口与sni域名至少设置一项"))
+		return
+	}
+	sniSearch := &dao.TcpRule{
+		SniNames: params.SniNames,
+	}
+	usedName, err := sniSearch.SniNameUsed(c, lib.GORMDefaultPool, params.ID)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	if usedName != "" {
+		middleware.ResponseError(c, 2002, errors.New("sni域名被占用: "+usedName))
+		return
+	}
 
 	// 3. 开启数据库事务
 	tx := lib.GORMDefaultPool.Begin()
@@ -667,6 +935,25 @@ func (admin *ServiceController) ServiceUpdateTcp(c *gin.Context) {
 	loadBalance.IpList = params.IpList
 	loadBalance.WeightList = params.WeightList
 	loadBalance.ForbidList = params.ForbidList
+	loadBalance.CheckMethod = params.CheckMethod
+	loadBalance.CheckTimeout = params.CheckTimeout
+	loadBalance.CheckInterval = params.CheckInterval
+	loadBalance.CheckPath = params.CheckPath
+	loadBalance.CheckHttpMethod = params.CheckHttpMethod
+	loadBalance.CheckExpectStatus = params.CheckExpectStatus
+	loadBalance.CheckExpectBody = params.CheckExpectBody
+	loadBalance.CheckRise = params.CheckRise
+	loadBalance.CheckFall = params.CheckFall
+	loadBalance.UpstreamTlsCa = params.UpstreamTlsCa
+	loadBalance.UpstreamTlsCert = params.UpstreamTlsCert
+	loadBalance.UpstreamTlsKey = params.UpstreamTlsKey
+	loadBalance.UpstreamTlsServerName = params.UpstreamTlsServerName
+	loadBalance.UpstreamTlsInsecure = params.UpstreamTlsInsecure
+	if _, err := loadBalance.GetUpstreamTLSConfig(); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2004, errors.Wrap(err, "下游tls配置不正确"))
+		return
+	}
 	if err := loadBalance.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2004, err)
@@ -680,6 +967,9 @@ func (admin *ServiceController) ServiceUpdateTcp(c *gin.Context) {
 	}
 	tcpRule.ServiceID = info.ID
 	tcpRule.Port = params.Port
+	tcpRule.NeedTls = params.NeedTls
+	tcpRule.SniNames = params.SniNames
+	tcpRule.ProxyProtocol = params.ProxyProtocol
 	if err := tcpRule.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2005, err)
@@ -698,14 +988,38 @@ func (admin *ServiceController) ServiceUpdateTcp(c *gin.Context) {
 	accessControl.WhiteHostName = params.WhiteHostName
 	accessControl.ClientIPFlowLimit = params.ClientIPFlowLimit
 	accessControl.ServiceFlowLimit = params.ServiceFlowLimit
+	accessControl.ClientCertAuth = params.ClientCertAuth
 	if err := accessControl.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2006, err)
 		return
 	}
 
-	// 9. 提交事务
+	// 9. 更新/保存 circuit_breaker 表（熔断配置）
+	circuitBreaker := &dao.CircuitBreaker{}
+	if detail.CircuitBreaker != nil {
+		circuitBreaker = detail.CircuitBreaker
+	}
+	circuitBreaker.ServiceID = info.ID
+	circuitBreaker.OpenBreaker = params.OpenBreaker
+	circuitBreaker.ErrorPercent = params.BreakerErrorPercent
+	circuitBreaker.SlowCallPercent = params.BreakerSlowCallPercent
+	circuitBreaker.SlowCallDuration = params.BreakerSlowCallDuration
+	circuitBreaker.MinRequest = params.BreakerMinRequest
+	circuitBreaker.StatWindow = params.BreakerStatWindow
+	circuitBreaker.OpenDuration = params.BreakerOpenDuration
+	circuitBreaker.HalfOpenRequests = params.BreakerHalfOpenRequests
+	circuitBreaker.FallbackStatus = params.BreakerFallbackStatus
+	circuitBreaker.FallbackBody = params.BreakerFallbackBody
+	if err := circuitBreaker.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2007, err)
+		return
+	}
+
+	// 10. 提交事务
 	tx.Commit()
+	public.PublishConfChange(public.ConfChangeService)
 	middleware.ResponseSuccess(c, "")
 	return
 }
@@ -752,6 +1066,18 @@ func (admin *ServiceController) ServiceAddGrpc(c *gin.Context) {
 		middleware.ResponseError(c, 2004, errors.New("服务端口被占用，请重新输入"))
 		return
 	}
+	webPrefixSearch := &dao.GrpcRule{
+		GrpcWebPrefix: params.GrpcWebPrefix,
+	}
+	used, err := webPrefixSearch.WebPrefixUsed(c, lib.GORMDefaultPool, 0)
+	if err != nil {
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+	if used {
+		middleware.ResponseError(c, 2004, errors.New("grpc-web路径前缀被占用，请重新输入"))
+		return
+	}
 
 	//ip与权重数量一致
 	if len(strings.Split(params.IpList, ",")) != len(strings.Split(params.WeightList, ",")) {
@@ -772,11 +1098,31 @@ func (admin *ServiceController) ServiceAddGrpc(c *gin.Context) {
 	}
 
 	loadBalance := &dao.LoadBalance{
-		ServiceID:  info.ID,
-		RoundType:  params.RoundType,
-		IpList:     params.IpList,
-		WeightList: params.WeightList,
-		ForbidList: params.ForbidList,
+		ServiceID:         info.ID,
+		RoundType:         params.RoundType,
+		IpList:            params.IpList,
+		WeightList:        params.WeightList,
+		ForbidList:        params.ForbidList,
+		CheckMethod:       params.CheckMethod,
+		CheckTimeout:      params.CheckTimeout,
+		CheckInterval:     params.CheckInterval,
+		CheckPath:         params.CheckPath,
+		CheckHttpMethod:   params.CheckHttpMethod,
+		CheckExpectStatus: params.CheckExpectStatus,
+		CheckExpectBody:   params.CheckExpectBody,
+		CheckRise:         params.CheckRise,
+		CheckFall:         params.CheckFall,
+
+		UpstreamTlsCa:         params.UpstreamTlsCa,
+		UpstreamTlsCert:       params.UpstreamTlsCert,
+		UpstreamTlsKey:        params.UpstreamTlsKey,
+		UpstreamTlsServerName: params.UpstreamTlsServerName,
+		UpstreamTlsInsecure:   params.UpstreamTlsInsecure,
+	}
+	if _, err := loadBalance.GetUpstreamTLSConfig(); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2007, errors.Wrap(err, "下游tls配置不正确"))
+		return
 	}
 	if err := loadBalance.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -788,6 +1134,10 @@ func (admin *ServiceController) ServiceAddGrpc(c *gin.Context) {
 		ServiceID:      info.ID,
 		Port:           params.Port,
 		HeaderTransfor: params.HeaderTransfor,
+		GrpcWeb:        params.GrpcWeb,
+		GrpcWebPrefix:  params.GrpcWebPrefix,
+		GrpcWebOrigins: params.GrpcWebOrigins,
+		MethodRule:     params.MethodRule,
 	}
 	if err := grpcRule.Save(c, tx); err != nil {
 		tx.Rollback()
@@ -803,13 +1153,37 @@ func (admin *ServiceController) ServiceAddGrpc(c *gin.Context) {
 		WhiteHostName:     params.WhiteHostName,
 		ClientIPFlowLimit: params.ClientIPFlowLimit,
 		ServiceFlowLimit:  params.ServiceFlowLimit,
+
+		ClientCertAuth: params.ClientCertAuth,
 	}
 	if err := accessControl.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2009, err)
 		return
 	}
+
+	// 创建熔断配置
+	circuitBreaker := &dao.CircuitBreaker{
+		ServiceID:        info.ID,
+		OpenBreaker:      params.OpenBreaker,
+		ErrorPercent:     params.BreakerErrorPercent,
+		SlowCallPercent:  params.BreakerSlowCallPercent,
+		SlowCallDuration: params.BreakerSlowCallDuration,
+		MinRequest:       params.BreakerMinRequest,
+		StatWindow:       params.BreakerStatWindow,
+		OpenDuration:     params.BreakerOpenDuration,
+		HalfOpenRequests: params.BreakerHalfOpenRequests,
+		FallbackStatus:   params.BreakerFallbackStatus,
+		FallbackBody:     params.BreakerFallbackBody,
+	}
+	if err := circuitBreaker.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2010, err)
+		return
+	}
+
 	tx.Commit()
+	public.PublishConfChange(public.ConfChangeService)
 	middleware.ResponseSuccess(c, "")
 	return
 }
@@ -836,6 +1210,18 @@ func (admin *ServiceController) ServiceUpdateGrpc(c *gin.Context) {
 		middleware.ResponseError(c, 2002, errors.New("ip列表与权重设置不匹配"))
 		return
 	}
+	webPrefixSearch := &dao.GrpcRule{
+		GrpcWebPrefix: params.GrpcWebPrefix,
+	}
+	used, err := webPrefixSearch.WebPrefixUsed(c, lib.GORMDefaultPool, params.ID)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	if used {
+		middleware.ResponseError(c, 2002, errors.New("grpc-web路径前缀被占用，请重新输入"))
+		return
+	}
 
 	tx := lib.GORMDefaultPool.Begin()
 
@@ -865,6 +1251,25 @@ func (admin *ServiceController) ServiceUpdateGrpc(c *gin.Context) {
 	loadBalance.IpList = params.IpList
 	loadBalance.WeightList = params.WeightList
 	loadBalance.ForbidList = params.ForbidList
+	loadBalance.CheckMethod = params.CheckMethod
+	loadBalance.CheckTimeout = params.CheckTimeout
+	loadBalance.CheckInterval = params.CheckInterval
+	loadBalance.CheckPath = params.CheckPath
+	loadBalance.CheckHttpMethod = params.CheckHttpMethod
+	loadBalance.CheckExpectStatus = params.CheckExpectStatus
+	loadBalance.CheckExpectBody = params.CheckExpectBody
+	loadBalance.CheckRise = params.CheckRise
+	loadBalance.CheckFall = params.CheckFall
+	loadBalance.UpstreamTlsCa = params.UpstreamTlsCa
+	loadBalance.UpstreamTlsCert = params.UpstreamTlsCert
+	loadBalance.UpstreamTlsKey = params.UpstreamTlsKey
+	loadBalance.UpstreamTlsServerName = params.UpstreamTlsServerName
+	loadBalance.UpstreamTlsInsecure = params.UpstreamTlsInsecure
+	if _, err := loadBalance.GetUpstreamTLSConfig(); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2005, errors.Wrap(err, "下游tls配置不正确"))
+		return
+	}
 	if err := loadBalance.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2005, err)
@@ -878,6 +1283,10 @@ func (admin *ServiceController) ServiceUpdateGrpc(c *gin.Context) {
 	grpcRule.ServiceID = info.ID
 	//grpcRule.Port = params.Port
 	grpcRule.HeaderTransfor = params.HeaderTransfor
+	grpcRule.GrpcWeb = params.GrpcWeb
+	grpcRule.GrpcWebPrefix = params.GrpcWebPrefix
+	grpcRule.GrpcWebOrigins = params.GrpcWebOrigins
+	grpcRule.MethodRule = params.MethodRule
 	if err := grpcRule.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2006, err)
@@ -895,12 +1304,256 @@ func (admin *ServiceController) ServiceUpdateGrpc(c *gin.Context) {
 	accessControl.WhiteHostName = params.WhiteHostName
 	accessControl.ClientIPFlowLimit = params.ClientIPFlowLimit
 	accessControl.ServiceFlowLimit = params.ServiceFlowLimit
+	accessControl.ClientCertAuth = params.ClientCertAuth
+	if err := accessControl.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2007, err)
+		return
+	}
+
+	// 更新/保存 circuit_breaker 表（熔断配置）
+	circuitBreaker := &dao.CircuitBreaker{}
+	if detail.CircuitBreaker != nil {
+		circuitBreaker = detail.CircuitBreaker
+	}
+	circuitBreaker.ServiceID = info.ID
+	circuitBreaker.OpenBreaker = params.OpenBreaker
+	circuitBreaker.ErrorPercent = params.BreakerErrorPercent
+	circuitBreaker.SlowCallPercent = params.BreakerSlowCallPercent
+	circuitBreaker.SlowCallDuration = params.BreakerSlowCallDuration
+	circuitBreaker.MinRequest = params.BreakerMinRequest
+	circuitBreaker.StatWindow = params.BreakerStatWindow
+	circuitBreaker.OpenDuration = params.BreakerOpenDuration
+	circuitBreaker.HalfOpenRequests = params.BreakerHalfOpenRequests
+	circuitBreaker.FallbackStatus = params.BreakerFallbackStatus
+	circuitBreaker.FallbackBody = params.BreakerFallbackBody
+	if err := circuitBreaker.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2008, err)
+		return
+	}
+
+	tx.Commit()
+	public.PublishConfChange(public.ConfChangeService)
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// ServiceAddUdp godoc
+// @Summary udp服务添加
+// @Description udp服务添加
+// @Tags 服务管理
+// @ID /service/service_add_udp
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ServiceAddUdpInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /service/service_add_udp [post]
+func (admin *ServiceController) ServiceAddUdp(c *gin.Context) {
+	params := &dto.ServiceAddUdpInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+
+	//验证 service_name 是否被占用
+	infoSearch := &dao.ServiceInfo{
+		ServiceName: params.ServiceName,
+		IsDelete:    0,
+	}
+	if _, err := infoSearch.Find(c, lib.GORMDefaultPool, infoSearch); err == nil {
+		middleware.ResponseError(c, 2002, errors.New("服务名被占用，请重新输入"))
+		return
+	}
+
+	//udp 端口与 tcp/grpc 端口互不影响，只检查 udp 服务
+	udpRule := &dao.UdpRule{
+		Port:        params.Port,
+		IdleTimeout: params.IdleTimeout,
+	}
+	used, err := udpRule.PortUsed(c, lib.GORMDefaultPool, 0)
+	if err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+	if used {
+		middleware.ResponseError(c, 2003, errors.New("服务端口被占用，请重新输入"))
+		return
+	}
+
+	//ip与权重数量一致
+	if len(strings.Split(params.IpList, ",")) != len(strings.Split(params.WeightList, ",")) {
+		middleware.ResponseError(c, 2004, errors.New("ip列表与权重设置不匹配"))
+		return
+	}
+
+	tx := lib.GORMDefaultPool.Begin()
+	info := &dao.ServiceInfo{
+		LoadType:    public.LoadTypeUDP,
+		ServiceName: params.ServiceName,
+		ServiceDesc: params.ServiceDesc,
+	}
+	if err := info.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2005, err)
+		return
+	}
+
+	loadBalance := &dao.LoadBalance{
+		ServiceID:         info.ID,
+		RoundType:         params.RoundType,
+		IpList:            params.IpList,
+		WeightList:        params.WeightList,
+		ForbidList:        params.ForbidList,
+		CheckMethod:       params.CheckMethod,
+		CheckTimeout:      params.CheckTimeout,
+		CheckInterval:     params.CheckInterval,
+		CheckPath:         params.CheckPath,
+		CheckHttpMethod:   params.CheckHttpMethod,
+		CheckExpectStatus: params.CheckExpectStatus,
+		CheckExpectBody:   params.CheckExpectBody,
+		CheckRise:         params.CheckRise,
+		CheckFall:         params.CheckFall,
+	}
+	if err := loadBalance.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2006, err)
+		return
+	}
+
+	udpRule.ServiceID = info.ID
+	if err := udpRule.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2007, err)
+		return
+	}
+
+	accessControl := &dao.AccessControl{
+		ServiceID:         info.ID,
+		OpenAuth:          params.OpenAuth,
+		BlackList:         params.BlackList,
+		WhiteList:         params.WhiteList,
+		ClientIPFlowLimit: params.ClientIPFlowLimit,
+		ServiceFlowLimit:  params.ServiceFlowLimit,
+	}
+	if err := accessControl.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2008, err)
+		return
+	}
+	tx.Commit()
+	public.PublishConfChange(public.ConfChangeService)
+	middleware.ResponseSuccess(c, "")
+	return
+}
+
+// ServiceUpdateUdp godoc
+// @Summary udp服务更新
+// @Description udp服务更新
+// @Tags 服务管理
+// @ID /service/service_update_udp
+// @Accept  json
+// @Produce  json
+// @Param body body dto.ServiceUpdateUdpInput true "body"
+// @Success 200 {object} middleware.Response{data=string} "success"
+// @Router /service/service_update_udp [post]
+func (admin *ServiceController) ServiceUpdateUdp(c *gin.Context) {
+	params := &dto.ServiceUpdateUdpInput{}
+	if err := params.GetValidParams(c); err != nil {
+		middleware.ResponseError(c, 2001, err)
+		return
+	}
+
+	//ip与权重数量一致
+	if len(strings.Split(params.IpList, ",")) != len(strings.Split(params.WeightList, ",")) {
+		middleware.ResponseError(c, 2002, errors.New("ip列表与权重设置不匹配"))
+		return
+	}
+	portSearch := &dao.UdpRule{
+		Port: params.Port,
+	}
+	used, err := portSearch.PortUsed(c, lib.GORMDefaultPool, params.ID)
+	if err != nil {
+		middleware.ResponseError(c, 2002, err)
+		return
+	}
+	if used {
+		middleware.ResponseError(c, 2002, errors.New("服务端口被占用，请重新输入"))
+		return
+	}
+
+	service := &dao.ServiceInfo{
+		ID: params.ID,
+	}
+	detail, err := service.ServiceDetail(c, lib.GORMDefaultPool, service)
+	if err != nil {
+		middleware.ResponseError(c, 2003, err)
+		return
+	}
+
+	tx := lib.GORMDefaultPool.Begin()
+	info := detail.Info
+	info.ServiceDesc = params.ServiceDesc
+	if err := info.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2004, err)
+		return
+	}
+
+	loadBalance := &dao.LoadBalance{}
+	if detail.LoadBalance != nil {
+		loadBalance = detail.LoadBalance
+	}
+	loadBalance.ServiceID = info.ID
+	loadBalance.RoundType = params.RoundType
+	loadBalance.IpList = params.IpList
+	loadBalance.WeightList = params.WeightList
+	loadBalance.ForbidList = params.ForbidList
+	loadBalance.CheckMethod = params.CheckMethod
+	loadBalance.CheckTimeout = params.CheckTimeout
+	loadBalance.CheckInterval = params.CheckInterval
+	loadBalance.CheckPath = params.CheckPath
+	loadBalance.CheckHttpMethod = params.CheckHttpMethod
+	loadBalance.CheckExpectStatus = params.CheckExpectStatus
+	loadBalance.CheckExpectBody = params.CheckExpectBody
+	loadBalance.CheckRise = params.CheckRise
+	loadBalance.CheckFall = params.CheckFall
+	if err := loadBalance.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2005, err)
+		return
+	}
+
+	udpRule := &dao.UdpRule{}
+	if detail.UDPRule != nil {
+		udpRule = detail.UDPRule
+	}
+	udpRule.ServiceID = info.ID
+	udpRule.Port = params.Port
+	udpRule.IdleTimeout = params.IdleTimeout
+	if err := udpRule.Save(c, tx); err != nil {
+		tx.Rollback()
+		middleware.ResponseError(c, 2006, err)
+		return
+	}
+
+	accessControl := &dao.AccessControl{}
+	if detail.AccessControl != nil {
+		accessControl = detail.AccessControl
+	}
+	accessControl.ServiceID = info.ID
+	accessControl.OpenAuth = params.OpenAuth
+	accessControl.BlackList = params.BlackList
+	accessControl.WhiteList = params.WhiteList
+	accessControl.ClientIPFlowLimit = params.ClientIPFlowLimit
+	accessControl.ServiceFlowLimit = params.ServiceFlowLimit
 	if err := accessControl.Save(c, tx); err != nil {
 		tx.Rollback()
 		middleware.ResponseError(c, 2007, err)
 		return
 	}
 	tx.Commit()
+	public.PublishConfChange(public.ConfChangeService)
 	middleware.ResponseSuccess(c, "")
 	return
 }
@@ -1,6 +1,7 @@
 package dao
 
 import (
+	"crypto/x509"
 	"github.com/e421083458/gorm"
 	"github.com/gin-gonic/gin"
 	"go-gateway/common/lib"
@@ -22,6 +23,8 @@ type App struct {
 	CreatedAt time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间	"`
 	UpdatedAt time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
 	IsDelete  int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+
+	CertSubject string `json:"cert_subject" gorm:"column:cert_subject" description:"客户端证书主体, 匹配证书CN或SAN, 为空时以app_id匹配CN"`
 }
 
 func (t *App) TableName() string {
@@ -90,31 +93,95 @@ func NewAppManager() *AppManager {
 }
 
 func (s *AppManager) GetAppList() []*App {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
 	return s.AppSlice
 }
 
 func (s *AppManager) LoadOnce() error {
 	s.init.Do(func() {
-		appInfo := &App{}
-		c, _ := gin.CreateTestContext(httptest.NewRecorder())
-		tx, err := lib.GetGormPool("default")
-		if err != nil {
-			s.err = err
-			return
-		}
-		params := &dto.APPListInput{PageNo: 1, PageSize: 99999}
-		list, _, err := appInfo.APPList(c, tx, params)
+		appMap, appSlice, err := s.loadApp()
 		if err != nil {
 			s.err = err
 			return
 		}
 		s.Locker.Lock()
 		defer s.Locker.Unlock()
-		for _, listItem := range list {
-			tmpItem := listItem
-			s.AppMap[listItem.AppID] = &tmpItem
-			s.AppSlice = append(s.AppSlice, &tmpItem)
-		}
+		s.AppMap = appMap
+		s.AppSlice = appSlice
 	})
 	return s.err
 }
+
+// ReLoad 重新从数据库读取全部租户，整体替换 AppMap/AppSlice，
+// 返回配置发生变化或已被删除的 AppID
+func (s *AppManager) ReLoad() ([]string, error) {
+	appMap, appSlice, err := s.loadApp()
+	if err != nil {
+		return nil, err
+	}
+	s.Locker.Lock()
+	oldAppMap := s.AppMap
+	s.AppMap = appMap
+	s.AppSlice = appSlice
+	s.Locker.Unlock()
+
+	changedList := []string{}
+	for appID, oldItem := range oldAppMap {
+		newItem, ok := appMap[appID]
+		if !ok || public.Obj2Json(oldItem) != public.Obj2Json(newItem) {
+			changedList = append(changedList, appID)
+		}
+	}
+	return changedList, nil
+}
+
+func (s *AppManager) loadApp() (map[string]*App, []*App, error) {
+	appInfo := &App{}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return nil, nil, err
+	}
+	params := &dto.APPListInput{PageNo: 1, PageSize: 99999}
+	list, _, err := appInfo.APPList(c, tx, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	appMap := map[string]*App{}
+	appSlice := []*App{}
+	for _, listItem := range list {
+		tmpItem := listItem
+		appMap[listItem.AppID] = &tmpItem
+		appSlice = append(appSlice, &tmpItem)
+	}
+	return appMap, appSlice, nil
+}
+
+// GetAppByCert 客户端证书映射租户：设置了 cert_subject 的租户匹配证书 CN 或 SAN，
+// 未设置的租户以 app_id 匹配证书 CN
+func (s *AppManager) GetAppByCert(cert *x509.Certificate) (*App, bool) {
+	if cert == nil {
+		return nil, false
+	}
+	subjects := []string{cert.Subject.CommonName}
+	subjects = append(subjects, cert.DNSNames...)
+	subjects = append(subjects, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		subjects = append(subjects, uri.String())
+	}
+	for _, appItem := range s.GetAppList() {
+		if appItem.CertSubject == "" {
+			if appItem.AppID == cert.Subject.CommonName {
+				return appItem, true
+			}
+			continue
+		}
+		for _, subject := range subjects {
+			if subject != "" && subject == appItem.CertSubject {
+				return appItem, true
+			}
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,260 @@
+package dao
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"go-gateway/common/lib"
+	"go-gateway/dto"
+	"go-gateway/public"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Cert struct {
+	ID        int64     `json:"id" gorm:"primary_key"`
+	Name      string    `json:"name" gorm:"column:name" description:"证书名称"`
+	SniNames  string    `json:"sni_names" gorm:"column:sni_names" description:"匹配的sni域名, 支持*.开头的通配符, 多个逗号间隔, 为空时取证书中的域名"`
+	CertPem   string    `json:"cert_pem" gorm:"column:cert_pem" description:"证书内容, pem格式, 可包含证书链"`
+	KeyPem    string    `json:"-" gorm:"column:key_pem" description:"私钥内容, pem格式"`
+	NotBefore time.Time `json:"not_before" gorm:"column:not_before" description:"证书生效时间"`
+	NotAfter  time.Time `json:"not_after" gorm:"column:not_after" description:"证书过期时间"`
+	Source    int       `json:"source" gorm:"column:source" description:"证书来源 0=手动上传 1=acme自动签发"`
+	CreatedAt time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间"`
+	UpdatedAt time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
+	IsDelete  int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+}
+
+const (
+	CertSourceManual = 0
+	CertSourceAcme   = 1
+)
+
+func (t *Cert) TableName() string {
+	return "gateway_cert"
+}
+
+func (t *Cert) Find(c *gin.Context, tx *gorm.DB, search *Cert) (*Cert, error) {
+	model := &Cert{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *Cert) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *Cert) CertList(c *gin.Context, tx *gorm.DB, params *dto.CertListInput) ([]Cert, int64, error) {
+	var list []Cert
+	var count int64
+	offset := (params.PageNo - 1) * params.PageSize
+	query := tx.SetCtx(public.GetGinTraceContext(c))
+	query = query.Table(t.TableName()).Select("*")
+	query = query.Where("is_delete=?", 0)
+	if params.Info != "" {
+		query = query.Where(" (name like ? or sni_names like ?)", "%"+params.Info+"%", "%"+params.Info+"%")
+	}
+	err := query.Limit(params.PageSize).Offset(offset).Order("id desc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, 0, err
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+	return list, count, nil
+}
+
+// ExpiringList 查询在 before 之前过期的证书，包含已过期的证书
+func (t *Cert) ExpiringList(c *gin.Context, tx *gorm.DB, before time.Time) ([]Cert, error) {
+	var list []Cert
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Table(t.TableName()).
+		Where("is_delete=? and not_after<?", 0, before).Order("not_after asc").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return list, nil
+}
+
+// defaultCertExpireWarnDays 证书剩余有效天数低于该值时提示即将过期
+const defaultCertExpireWarnDays = 30
+
+func GetCertExpireWarnDays() int {
+	days := lib.GetIntConf("base.cert.expire_warn_days")
+	if days <= 0 {
+		days = defaultCertExpireWarnDays
+	}
+	return days
+}
+
+// Parse 校验证书与私钥是否匹配，补全有效期，未指定 sni 域名时取证书中的域名
+func (t *Cert) Parse() (*tls.Certificate, error) {
+	cert, err := tls.X509KeyPair([]byte(t.CertPem), []byte(t.KeyPem))
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	cert.Leaf = leaf
+	t.NotBefore = leaf.NotBefore
+	t.NotAfter = leaf.NotAfter
+	if strings.TrimSpace(t.SniNames) == "" {
+		names := leaf.DNSNames
+		if len(names) == 0 && leaf.Subject.CommonName != "" {
+			names = []string{leaf.Subject.CommonName}
+		}
+		if len(names) == 0 {
+			return nil, errors.New("certificate has no dns name, sni_names required")
+		}
+		t.SniNames = strings.Join(names, ",")
+	}
+	return &cert, nil
+}
+
+// GetSniNameList sni 域名统一转为小写
+func (t *Cert) GetSniNameList() []string {
+	return splitSniNames(t.SniNames)
+}
+
+func splitSniNames(sniNames string) []string {
+	nameList := []string{}
+	for _, name := range strings.Split(sniNames, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" {
+			nameList = append(nameList, name)
+		}
+	}
+	return nameList
+}
+
+var CertManagerHandler *CertManager
+
+func init() {
+	CertManagerHandler = NewCertManager()
+}
+
+// CertManager 按 sni 域名管理 https 证书，握手时通过 GetCertificate 选择证书
+type CertManager struct {
+	CertMap     map[string]*tls.Certificate
+	DefaultCert *tls.Certificate
+	Locker      sync.RWMutex
+	init        sync.Once
+	err         error
+}
+
+func NewCertManager() *CertManager {
+	return &CertManager{
+		CertMap: map[string]*tls.Certificate{},
+		Locker:  sync.RWMutex{},
+		init:    sync.Once{},
+	}
+}
+
+// SetDefaultCert 未匹配到 sni 域名或客户端未携带 sni 时使用的证书
+func (s *CertManager) SetDefaultCert(certFile, keyFile string) error {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.DefaultCert = &cert
+	return nil
+}
+
+func (s *CertManager) LoadOnce() error {
+	s.init.Do(func() {
+		certMap, err := s.loadCert()
+		if err != nil {
+			s.err = err
+			return
+		}
+		s.Locker.Lock()
+		defer s.Locker.Unlock()
+		s.CertMap = certMap
+	})
+	return s.err
+}
+
+// ReLoad 重新从数据库读取全部证书，整体替换 CertMap，新握手立即使用新证书
+func (s *CertManager) ReLoad() error {
+	certMap, err := s.loadCert()
+	if err != nil {
+		return err
+	}
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.CertMap = certMap
+	return nil
+}
+
+// GetCertificate 用于 tls.Config.GetCertificate，未匹配到证书时使用默认证书
+func (s *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if cert := s.MatchCertificate(hello.ServerName); cert != nil {
+		return cert, nil
+	}
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	if s.DefaultCert != nil {
+		return s.DefaultCert, nil
+	}
+	return nil, errors.New("no certificate for server name " + hello.ServerName)
+}
+
+// MatchCertificate 按 sni 域名查找证书，先精确匹配，再匹配 *. 通配符
+func (s *CertManager) MatchCertificate(serverName string) *tls.Certificate {
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+	if serverName == "" {
+		return nil
+	}
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	if cert, ok := s.CertMap[serverName]; ok {
+		return cert
+	}
+	if index := strings.Index(serverName, "."); index > 0 {
+		if cert, ok := s.CertMap["*"+serverName[index:]]; ok {
+			return cert
+		}
+	}
+	return nil
+}
+
+func (s *CertManager) loadCert() (map[string]*tls.Certificate, error) {
+	certInfo := &Cert{}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return nil, err
+	}
+	params := &dto.CertListInput{PageNo: 1, PageSize: 99999}
+	list, _, err := certInfo.CertList(c, tx, params)
+	if err != nil {
+		return nil, err
+	}
+	//按 id 倒序，同一域名以最新上传的证书为准
+	certMap := map[string]*tls.Certificate{}
+	for _, listItem := range list {
+		tmpItem := listItem
+		cert, err := tmpItem.Parse()
+		if err != nil {
+			log.Printf(" [ERROR] cert_load %v err:%v\n", tmpItem.Name, err)
+			continue
+		}
+		for _, name := range tmpItem.GetSniNameList() {
+			if _, ok := certMap[name]; !ok {
+				certMap[name] = cert
+			}
+		}
+	}
+	return certMap, nil
+}
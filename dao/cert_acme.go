@@ -0,0 +1,335 @@
+package dao
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/e421083458/gorm"
+	"github.com/garyburd/redigo/redis"
+	"github.com/gin-gonic/gin"
+	"go-gateway/common/lib"
+	"go-gateway/public"
+	"golang.org/x/crypto/acme"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultAcmeDirectoryURL    = acme.LetsEncryptURL
+	defaultAcmeRenewBeforeDays = 30
+	defaultAcmeCheckInterval   = 3600
+	defaultAcmeRetryMaxDelay   = 86400
+
+	//单个域名签发的最长时间，同时作为集群锁的过期时间
+	acmeIssueTimeout = 10 * time.Minute
+)
+
+var (
+	acmeDone     = make(chan struct{})
+	acmeStopOnce sync.Once
+)
+
+// AcmeRun 定时检查开启 https 的域名接入服务，没有可用证书或证书即将过期时通过 acme 签发，
+// 签发的证书写入证书表并通知集群热加载
+func AcmeRun() {
+	if !lib.GetBoolConf("proxy.acme.open") {
+		return
+	}
+	interval := lib.GetIntConf("proxy.acme.check_interval")
+	if interval <= 0 {
+		interval = defaultAcmeCheckInterval
+	}
+	log.Printf(" [INFO] acme_run interval:%vs\n", interval)
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		if err := AcmeCheck(); err != nil {
+			log.Printf(" [ERROR] acme_check err:%v\n", err)
+		}
+		select {
+		case <-acmeDone:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func AcmeStop() {
+	acmeStopOnce.Do(func() {
+		close(acmeDone)
+	})
+	log.Printf(" [INFO] acme stopped\n")
+}
+
+// GetAcmeDomainList 需要自动签发证书的域名：http 服务、域名接入且开启 https
+func GetAcmeDomainList() []string {
+	domainList := []string{}
+	exists := map[string]bool{}
+	for _, serviceItem := range ServiceManagerHandler.GetServiceSlice() {
+		if serviceItem.Info.LoadType != public.LoadTypeHTTP ||
+			serviceItem.HTTPRule.RuleType != public.HTTPRuleTypeDomain ||
+			serviceItem.HTTPRule.NeedHttps != 1 {
+			continue
+		}
+		//域名接入可带路径前缀，通配域名无法通过 http-01 验证
+		domain, _ := public.SplitHTTPDomainRule(serviceItem.HTTPRule.Rule)
+		if domain == "" || strings.HasPrefix(domain, "*.") || exists[domain] {
+			continue
+		}
+		exists[domain] = true
+		domainList = append(domainList, domain)
+	}
+	return domainList
+}
+
+// AcmeCheck 检查全部域名，单个域名失败不影响其他域名
+func AcmeCheck() error {
+	domainList := GetAcmeDomainList()
+	renewBefore := lib.GetIntConf("proxy.acme.renew_before_days")
+	if renewBefore <= 0 {
+		renewBefore = defaultAcmeRenewBeforeDays
+	}
+	renewTime := time.Now().AddDate(0, 0, renewBefore)
+
+	//清除已不需要自动签发的域名记录
+	managed := map[string]bool{}
+	for _, domain := range domainList {
+		managed[domain] = true
+	}
+	lastStatus := map[string]public.AcmeStatus{}
+	if statusList, err := public.GetAcmeStatusList(); err == nil {
+		for _, status := range statusList {
+			if !managed[status.Domain] {
+				public.RemoveAcmeStatus(status.Domain)
+				continue
+			}
+			lastStatus[status.Domain] = status
+		}
+	}
+
+	var client *acme.Client
+	issued := false
+	for _, domain := range domainList {
+		if cert := CertManagerHandler.MatchCertificate(domain); cert != nil && cert.Leaf != nil && cert.Leaf.NotAfter.After(renewTime) {
+			continue
+		}
+		//签发失败的域名按退避时间重试，避免触发 acme 服务的频率限制
+		last, ok := lastStatus[domain]
+		if ok && last.Status == public.AcmeStatusFailed && time.Now().Unix() < last.RetryAt {
+			continue
+		}
+		if !public.LockAcmeDomain(domain, acmeIssueTimeout) {
+			continue
+		}
+		if client == nil {
+			newClient, err := newAcmeClient()
+			if err != nil {
+				public.UnlockAcmeDomain(domain)
+				return err
+			}
+			client = newClient
+		}
+		notAfter, err := issueAcmeCert(client, domain)
+		public.UnlockAcmeDomain(domain)
+		status := &public.AcmeStatus{Domain: domain, Status: public.AcmeStatusOk, NotAfter: notAfter.Unix(), Time: time.Now().Unix()}
+		if err != nil {
+			status.Status = public.AcmeStatusFailed
+			status.Error = err.Error()
+			status.NotAfter = 0
+			status.Failures = 1
+			if last.Status == public.AcmeStatusFailed {
+				status.Failures = last.Failures + 1
+			}
+			retryDelay := acmeRetryDelay(status.Failures)
+			status.RetryAt = time.Now().Add(retryDelay).Unix()
+			log.Printf(" [ERROR] acme_issue %v failures:%v retry_after:%v err:%v\n", domain, status.Failures, retryDelay, err)
+		} else {
+			log.Printf(" [INFO] acme_issue %v not_after:%v\n", domain, notAfter)
+			issued = true
+		}
+		if err := public.SetAcmeStatus(status); err != nil {
+			log.Printf(" [ERROR] acme_status %v err:%v\n", domain, err)
+		}
+	}
+	if issued {
+		return public.PublishConfChange(public.ConfChangeCert)
+	}
+	return nil
+}
+
+// acmeRetryDelay 签发失败后的重试等待时间，从检查周期开始按连续失败次数翻倍
+func acmeRetryDelay(failures int) time.Duration {
+	interval := lib.GetIntConf("proxy.acme.check_interval")
+	if interval <= 0 {
+		interval = defaultAcmeCheckInterval
+	}
+	maxDelay := lib.GetIntConf("proxy.acme.retry_max_delay")
+	if maxDelay <= 0 {
+		maxDelay = defaultAcmeRetryMaxDelay
+	}
+	return public.RetryDelay(failures, time.Duration(interval)*time.Second, time.Duration(maxDelay)*time.Second)
+}
+
+// newAcmeClient 账户私钥保存在 redis 中，集群节点共用同一账户
+func newAcmeClient() (*acme.Client, error) {
+	accountKey, err := getAcmeAccountKey()
+	if err != nil {
+		return nil, err
+	}
+	directoryURL := lib.GetStringConf("proxy.acme.directory_url")
+	if directoryURL == "" {
+		directoryURL = defaultAcmeDirectoryURL
+	}
+	client := &acme.Client{Key: accountKey, DirectoryURL: directoryURL}
+	//本地测试服务器(如 pebble)使用自签名证书
+	if lib.GetBoolConf("proxy.acme.insecure_skip_verify") {
+		client.HTTPClient = &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	account := &acme.Account{}
+	if email := lib.GetStringConf("proxy.acme.email"); email != "" {
+		account.Contact = []string{"mailto:" + email}
+	}
+	if _, err := client.Register(ctx, account, acme.AcceptTOS); err != nil && err != acme.ErrAccountAlreadyExists {
+		return nil, err
+	}
+	return client, nil
+}
+
+func getAcmeAccountKey() (crypto.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes})
+	//已有账户私钥时沿用，保证集群节点使用同一账户
+	if _, err := public.RedisConfDo("SETNX", public.RedisAcmeAccountKey, keyPem); err != nil {
+		return nil, err
+	}
+	savedPem, err := redis.Bytes(public.RedisConfDo("GET", public.RedisAcmeAccountKey))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(savedPem)
+	if block == nil {
+		return nil, errors.New("invalid acme account key")
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+// issueAcmeCert 通过 http-01 验证签发证书并写入证书表，返回证书过期时间
+func issueAcmeCert(client *acme.Client, domain string) (time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), acmeIssueTimeout)
+	defer cancel()
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(domain))
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, authzURL := range order.AuthzURLs {
+		authz, err := client.GetAuthorization(ctx, authzURL)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if authz.Status == acme.StatusValid {
+			continue
+		}
+		var challenge *acme.Challenge
+		for _, item := range authz.Challenges {
+			if item.Type == "http-01" {
+				challenge = item
+				break
+			}
+		}
+		if challenge == nil {
+			return time.Time{}, errors.New("no http-01 challenge offered")
+		}
+		keyAuth, err := client.HTTP01ChallengeResponse(challenge.Token)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if err := public.SetAcmeChallenge(challenge.Token, keyAuth); err != nil {
+			return time.Time{}, err
+		}
+		defer public.DelAcmeChallenge(challenge.Token)
+		if _, err := client.Accept(ctx, challenge); err != nil {
+			return time.Time{}, err
+		}
+		if _, err := client.WaitAuthorization(ctx, authz.URI); err != nil {
+			return time.Time{}, err
+		}
+	}
+	if _, err := client.WaitOrder(ctx, order.URI); err != nil {
+		return time.Time{}, err
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return time.Time{}, err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{domain}}, certKey)
+	if err != nil {
+		return time.Time{}, err
+	}
+	derList, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return time.Time{}, err
+	}
+	certPem := []byte{}
+	for _, der := range derList {
+		certPem = append(certPem, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyBytes, err := x509.MarshalECPrivateKey(certKey)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes})
+	return saveAcmeCert(domain, string(certPem), string(keyPem))
+}
+
+// saveAcmeCert 同一域名只保留一条自动签发的证书记录，续期时覆盖
+func saveAcmeCert(domain, certPem, keyPem string) (time.Time, error) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return time.Time{}, err
+	}
+	info := &Cert{}
+	err = tx.SetCtx(public.GetGinTraceContext(c)).
+		Where("source=? and sni_names=? and is_delete=?", CertSourceAcme, domain, 0).
+		First(info).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return time.Time{}, err
+	}
+	info.Name = fmt.Sprintf("acme %s", domain)
+	info.SniNames = domain
+	info.Source = CertSourceAcme
+	info.CertPem = certPem
+	info.KeyPem = keyPem
+	if _, err := info.Parse(); err != nil {
+		return time.Time{}, err
+	}
+	if err := info.Save(c, tx); err != nil {
+		return time.Time{}, err
+	}
+	return info.NotAfter, nil
+}
@@ -0,0 +1,63 @@
+package dao
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"go-gateway/common/lib"
+	"io/ioutil"
+	"sync"
+)
+
+var (
+	clientCAPool *x509.CertPool
+	clientCAErr  error
+	clientCAOnce sync.Once
+)
+
+// GetClientCAPool 校验客户端证书的 ca，取配置 proxy.mtls.client_ca_file，未配置时返回 nil
+func GetClientCAPool() (*x509.CertPool, error) {
+	clientCAOnce.Do(func() {
+		caFile := lib.GetStringConf("proxy.mtls.client_ca_file")
+		if caFile == "" {
+			return
+		}
+		caPem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			clientCAErr = err
+			return
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			clientCAErr = errors.New("invalid client ca file " + caFile)
+			return
+		}
+		clientCAPool = pool
+	})
+	return clientCAPool, clientCAErr
+}
+
+// GetClientAuthTLSConfig tcp/grpc 服务开启客户端证书认证时监听使用的 tls 配置，
+// 服务端证书与 https 一样按 sni 从证书管理中选择
+func GetClientAuthTLSConfig() (*tls.Config, error) {
+	pool, err := GetClientCAPool()
+	if err != nil {
+		return nil, err
+	}
+	if pool == nil {
+		return nil, errors.New("mtls.client_ca_file not set")
+	}
+	return &tls.Config{
+		GetCertificate: CertManagerHandler.GetCertificate,
+		ClientCAs:      pool,
+		ClientAuth:     tls.RequireAndVerifyClientCert,
+	}, nil
+}
+
+// GetVerifiedClientCert 握手时已通过 ca 校验的客户端证书，未携带或未校验时返回 nil
+func GetVerifiedClientCert(state *tls.ConnectionState) *x509.Certificate {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
@@ -0,0 +1,138 @@
+package dao
+
+import (
+	"github.com/garyburd/redigo/redis"
+	"go-gateway/common/lib"
+	"go-gateway/public"
+	"log"
+	"sync"
+	"time"
+)
+
+const defaultReloadInterval = 30
+
+var (
+	reloadDone     = make(chan struct{})
+	reloadStopOnce sync.Once
+	reloadLocker   sync.Mutex
+)
+
+// ReloadConf 重新加载服务、租户及 https 证书配置，并清理变更或已删除服务对应的
+// 负载均衡器、transport、限流器、重试预算以及熔断器缓存，下次请求时按新配置重建
+func ReloadConf() error {
+	reloadLocker.Lock()
+	defer reloadLocker.Unlock()
+
+	changedServices, err := ServiceManagerHandler.ReLoad()
+	if err != nil {
+		return err
+	}
+	for _, serviceName := range changedServices {
+		LoadBalancerHandler.RemoveLoadBalancer(serviceName)
+		TransportorHandler.RemoveTrans(serviceName)
+		public.FlowLimiterHandler.RemoveLimiter(public.FlowServicePrefix + serviceName)
+		public.RetryBudgetHandler.RemoveBudget(serviceName)
+		public.CircuitBreakerHandler.RemoveBreaker(serviceName)
+		log.Printf(" [INFO] conf_reload service %v changed\n", serviceName)
+	}
+	if len(changedServices) > 0 {
+		ServiceManagerHandler.NotifyAllObservers()
+	}
+
+	changedApps, err := AppManagerHandler.ReLoad()
+	if err != nil {
+		return err
+	}
+	for _, appID := range changedApps {
+		public.FlowLimiterHandler.RemoveLimiter(public.FlowAppPrefix + appID)
+		log.Printf(" [INFO] conf_reload app %v changed\n", appID)
+	}
+
+	//https 证书整体替换，新握手即使用新证书
+	if err := CertManagerHandler.ReLoad(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ConfReloadRun 按 proxy.reload.interval 定时热加载配置，
+// 同时订阅 redis 变更通知，后台修改配置后立即生效
+func ConfReloadRun() {
+	interval := lib.GetIntConf("proxy.reload.interval")
+	if interval <= 0 {
+		interval = defaultReloadInterval
+	}
+	changeChan := make(chan struct{}, 1)
+	go watchConfChange(changeChan)
+
+	log.Printf(" [INFO] conf_reload_run interval:%vs\n", interval)
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-reloadDone:
+			return
+		case <-ticker.C:
+		case <-changeChan:
+		}
+		if err := ReloadConf(); err != nil {
+			log.Printf(" [ERROR] conf_reload err:%v\n", err)
+		}
+	}
+}
+
+func ConfReloadStop() {
+	reloadStopOnce.Do(func() {
+		close(reloadDone)
+	})
+	log.Printf(" [INFO] conf_reload stopped\n")
+}
+
+// watchConfChange 订阅 public.RedisConfChangeChannel，连接断开后自动重连
+func watchConfChange(changeChan chan<- struct{}) {
+	for {
+		conn, err := lib.RedisConnFactory("default")
+		if err != nil {
+			log.Printf(" [ERROR] conf_reload subscribe err:%v\n", err)
+			select {
+			case <-reloadDone:
+				return
+			case <-time.After(5 * time.Second):
+				continue
+			}
+		}
+		psc := redis.PubSubConn{Conn: conn}
+		if err := psc.Subscribe(public.RedisConfChangeChannel); err != nil {
+			psc.Close()
+			continue
+		}
+		connDone := make(chan struct{})
+		go func() {
+			select {
+			case <-reloadDone:
+				psc.Close()
+			case <-connDone:
+			}
+		}()
+	receiveLoop:
+		for {
+			switch v := psc.ReceiveWithTimeout(0).(type) {
+			case redis.Message:
+				select {
+				case changeChan <- struct{}{}:
+				default:
+				}
+			case error:
+				log.Printf(" [ERROR] conf_reload receive err:%v\n", v)
+				break receiveLoop
+			}
+		}
+		close(connDone)
+		psc.Close()
+		select {
+		case <-reloadDone:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package dao
+
+import (
+	"go-gateway/public"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// HTTPRoute 路由规则及匹配后使用的服务配置
+type HTTPRoute struct {
+	Rule    *public.HTTPRouteRule
+	Service *ServiceDetail
+}
+
+// HTTPRouter http 服务的路由索引，服务加载或热加载后整体重建且不再修改，与服务的添加顺序无关：
+// 先按服务的接入规则选出服务(精确域名 > 通配域名 > 不限域名，同一域名下最长前缀)，
+// 再在服务下按 精确路径 > 最长前缀 > 正则 > 条件数 选出路由，未匹配时使用服务自身的接入规则
+type HTTPRouter struct {
+	hosts     map[string]*httpRadixNode //精确域名
+	wildcards map[string]*httpRadixNode //通配域名，key 为去掉 * 的后缀，如 .example.com
+	anyHost   *httpRadixNode            //前缀接入，不限域名
+}
+
+type httpServiceRoutes struct {
+	base   *HTTPRoute
+	routes []*HTTPRoute
+}
+
+func NewHTTPRouter(serviceSlice []*ServiceDetail) *HTTPRouter {
+	router := &HTTPRouter{
+		hosts:     map[string]*httpRadixNode{},
+		wildcards: map[string]*httpRadixNode{},
+		anyHost:   &httpRadixNode{},
+	}
+	for _, serviceItem := range serviceSlice {
+		if serviceItem.Info.LoadType != public.LoadTypeHTTP {
+			continue
+		}
+		serviceRoute := public.NewHTTPServiceRoute(serviceItem.HTTPRule.RuleType, serviceItem.HTTPRule.Rule)
+		item := &httpServiceRoutes{
+			base:   &HTTPRoute{Rule: serviceRoute, Service: serviceItem.withRoute(serviceRoute)},
+			routes: []*HTTPRoute{},
+		}
+		//保存时已校验，这里解析失败只记录日志，服务自身的接入规则仍然生效
+		ruleList, err := serviceItem.HTTPRule.GetRouteRuleList()
+		if err != nil {
+			log.Printf(" [ERROR] http_route %v err:%v\n", serviceItem.Info.ServiceName, err)
+		}
+		for _, rule := range ruleList {
+			item.routes = append(item.routes, &HTTPRoute{Rule: rule, Service: serviceItem.withRoute(rule)})
+		}
+		//优先级相同时保持配置顺序
+		sort.SliceStable(item.routes, func(i, j int) bool {
+			return httpRouteLess(item.routes[i].Rule, item.routes[j].Rule)
+		})
+		router.pathIndex(serviceRoute.Host).insert(serviceRoute.Path, item)
+	}
+	return router
+}
+
+func (r *HTTPRouter) pathIndex(host string) *httpRadixNode {
+	if host == "" {
+		return r.anyHost
+	}
+	indexMap := r.hosts
+	if strings.HasPrefix(host, "*.") {
+		indexMap, host = r.wildcards, host[1:]
+	}
+	index, ok := indexMap[host]
+	if !ok {
+		index = &httpRadixNode{}
+		indexMap[host] = index
+	}
+	return index
+}
+
+func httpRouteLess(a, b *public.HTTPRouteRule) bool {
+	if a.HostRank() != b.HostRank() {
+		return a.HostRank() > b.HostRank()
+	}
+	if a.PathRank() != b.PathRank() {
+		return a.PathRank() > b.PathRank()
+	}
+	return a.ConditionCount() > b.ConditionCount()
+}
+
+// Match 返回匹配的服务配置，已应用匹配路由的覆盖配置；当前域名下没有匹配的前缀时依次尝试更宽泛的域名
+func (r *HTTPRouter) Match(req *http.Request) (*ServiceDetail, bool) {
+	host := GetRequestHost(req)
+	path := req.URL.Path
+	item := r.hosts[host].longestMatch(path)
+	//通配域名从最长的后缀开始尝试
+	for pos := 0; item == nil; pos++ {
+		dot := strings.IndexByte(host[pos:], '.')
+		if dot < 0 {
+			break
+		}
+		pos += dot
+		item = r.wildcards[host[pos:]].longestMatch(path)
+	}
+	if item == nil {
+		item = r.anyHost.longestMatch(path)
+	}
+	if item == nil {
+		return nil, false
+	}
+	for _, route := range item.routes {
+		if route.Rule.MatchHost(host) && route.Rule.MatchPath(path) && route.Rule.MatchConditions(req) {
+			return route.Service, true
+		}
+	}
+	return item.base.Service, true
+}
+
+// httpRadixNode 按服务接入前缀构建的压缩前缀树，按字节匹配，与 strings.HasPrefix 语义一致
+type httpRadixNode struct {
+	prefix   string
+	children []*httpRadixNode //首字节互不相同
+	service  *httpServiceRoutes
+}
+
+// insert 前缀相同的服务保留先配置的一个
+func (n *httpRadixNode) insert(path string, item *httpServiceRoutes) {
+	for {
+		if path == "" {
+			if n.service == nil {
+				n.service = item
+			}
+			return
+		}
+		index := n.childIndex(path[0])
+		if index < 0 {
+			n.children = append(n.children, &httpRadixNode{prefix: path, service: item})
+			return
+		}
+		child := n.children[index]
+		common := commonPrefixLen(path, child.prefix)
+		if common < len(child.prefix) {
+			//拆分子节点，公共部分作为新的中间节点
+			split := &httpRadixNode{prefix: child.prefix[:common], children: []*httpRadixNode{child}}
+			child.prefix = child.prefix[common:]
+			n.children[index] = split
+			child = split
+		}
+		n, path = child, path[common:]
+	}
+}
+
+// longestMatch 沿请求路径向下查找，返回前缀最长的服务
+func (n *httpRadixNode) longestMatch(path string) *httpServiceRoutes {
+	var matched *httpServiceRoutes
+	for n != nil {
+		if n.service != nil {
+			matched = n.service
+		}
+		if path == "" {
+			break
+		}
+		index := n.childIndex(path[0])
+		if index < 0 || !strings.HasPrefix(path, n.children[index].prefix) {
+			break
+		}
+		n, path = n.children[index], path[len(n.children[index].prefix):]
+	}
+	return matched
+}
+
+func (n *httpRadixNode) childIndex(c byte) int {
+	for i, child := range n.children {
+		if child.prefix[0] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+func commonPrefixLen(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// GetRequestHost 请求的域名，小写且不带端口
+func GetRequestHost(req *http.Request) string {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// withRoute 路由对应的服务配置，路由覆盖了中间件配置时使用副本，不修改服务本身的配置
+func (s *ServiceDetail) withRoute(rule *public.HTTPRouteRule) *ServiceDetail {
+	detail := *s
+	detail.Route = rule
+	detail.origin = s
+	if rule.StripUri >= 0 {
+		httpRule := *s.HTTPRule
+		httpRule.NeedStripUri = rule.StripUri
+		detail.HTTPRule = &httpRule
+	}
+	if rule.OpenAuth >= 0 || rule.WhiteList != nil || rule.BlackList != nil {
+		accessControl := *s.AccessControl
+		if rule.OpenAuth >= 0 {
+			accessControl.OpenAuth = rule.OpenAuth
+		}
+		if rule.WhiteList != nil {
+			accessControl.WhiteList = strings.Join(rule.WhiteList, ",")
+		}
+		if rule.BlackList != nil {
+			accessControl.BlackList = strings.Join(rule.BlackList, ",")
+		}
+		detail.AccessControl = &accessControl
+	}
+	return &detail
+}
+
+// Origin 未经路由覆盖的服务配置，按服务缓存的资源(如 json 转 grpc 的转换器)以此创建
+func (s *ServiceDetail) Origin() *ServiceDetail {
+	if s.origin != nil {
+		return s.origin
+	}
+	return s
+}
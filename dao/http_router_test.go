@@ -0,0 +1,273 @@
+package dao
+
+import (
+	"fmt"
+	"go-gateway/public"
+	"net/http/httptest"
+	"testing"
+)
+
+// newBenchServiceSlice 一半前缀接入、一半域名接入的 http 服务
+func newBenchServiceSlice(count int) []*ServiceDetail {
+	serviceSlice := []*ServiceDetail{}
+	for i := 0; i < count; i++ {
+		httpRule := &HttpRule{RuleType: public.HTTPRuleTypePrefixURL, Rule: fmt.Sprintf("/service_%d", i)}
+		if i%2 == 1 {
+			httpRule = &HttpRule{RuleType: public.HTTPRuleTypeDomain, Rule: fmt.Sprintf("service-%d.example.com", i)}
+		}
+		serviceSlice = append(serviceSlice, &ServiceDetail{
+			Info:          &ServiceInfo{ServiceName: fmt.Sprintf("service_%d", i), LoadType: public.LoadTypeHTTP},
+			HTTPRule:      httpRule,
+			AccessControl: &AccessControl{},
+		})
+	}
+	return serviceSlice
+}
+
+func BenchmarkHTTPRouterMatch(b *testing.B) {
+	for _, count := range []int{10, 100, 1000, 10000} {
+		router := NewHTTPRouter(newBenchServiceSlice(count))
+		requests := map[string]string{
+			"prefix": fmt.Sprintf("http://127.0.0.1:8080/service_%d/abc", count-2),
+			"domain": fmt.Sprintf("http://service-%d.example.com:8080/abc", count-1),
+			"miss":   "http://127.0.0.1:8080/not_found/abc",
+		}
+		for name, url := range requests {
+			req := httptest.NewRequest("GET", url, nil)
+			b.Run(fmt.Sprintf("services_%d/%s", count, name), func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					router.Match(req)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkNewHTTPRouter(b *testing.B) {
+	for _, count := range []int{100, 1000, 10000} {
+		serviceSlice := newBenchServiceSlice(count)
+		b.Run(fmt.Sprintf("services_%d", count), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				NewHTTPRouter(serviceSlice)
+			}
+		})
+	}
+}
+
+func newTestHTTPService(name string, ruleType int, rule, routeRule string) *ServiceDetail {
+	return &ServiceDetail{
+		Info:          &ServiceInfo{ServiceName: name, LoadType: public.LoadTypeHTTP},
+		HTTPRule:      &HttpRule{RuleType: ruleType, Rule: rule, RouteRule: routeRule},
+		AccessControl: &AccessControl{},
+	}
+}
+
+func TestHTTPRadixNodeInsertSplit(t *testing.T) {
+	root := &httpRadixNode{}
+	items := map[string]*httpServiceRoutes{}
+	for _, path := range []string{"/api/users", "/api/orders", "/api", "/apx", "/static/", "/"} {
+		items[path] = &httpServiceRoutes{}
+		root.insert(path, items[path])
+	}
+	//前缀相同时保留先插入的一个
+	root.insert("/api", &httpServiceRoutes{})
+
+	//根节点下只有 / 一个子节点，/ 之后拆分为 ap 及 static/
+	if len(root.children) != 1 || root.children[0].prefix != "/" || root.children[0].service != items["/"] {
+		t.Fatalf("root children = %+v", root.children)
+	}
+	slash := root.children[0]
+	if len(slash.children) != 2 {
+		t.Fatalf("/ children = %d, want 2", len(slash.children))
+	}
+	ap := slash.children[slash.childIndex('a')]
+	if ap.prefix != "ap" || ap.service != nil || len(ap.children) != 2 {
+		t.Fatalf("ap node = %+v", ap)
+	}
+	api := ap.children[ap.childIndex('i')]
+	if api.prefix != "i" || api.service != items["/api"] {
+		t.Errorf("i node prefix = %v, service is /api = %v", api.prefix, api.service == items["/api"])
+	}
+	if x := ap.children[ap.childIndex('x')]; x.prefix != "x" || x.service != items["/apx"] {
+		t.Errorf("x node prefix = %v", x.prefix)
+	}
+	//子节点首字节互不相同
+	for _, node := range []*httpRadixNode{root, slash, ap, api} {
+		seen := map[byte]bool{}
+		for _, child := range node.children {
+			if seen[child.prefix[0]] {
+				t.Errorf("node %q has children with the same first byte %q", node.prefix, child.prefix[0])
+			}
+			seen[child.prefix[0]] = true
+		}
+	}
+}
+
+func TestHTTPRadixNodeLongestMatch(t *testing.T) {
+	root := &httpRadixNode{}
+	paths := []string{"/api/users", "/api/user", "/api", "/apx", "/static/"}
+	items := map[string]*httpServiceRoutes{}
+	for _, path := range paths {
+		items[path] = &httpServiceRoutes{}
+		root.insert(path, items[path])
+	}
+	tests := map[string]string{
+		"/api/users/1": "/api/users",
+		"/api/users":   "/api/users",
+		"/api/user":    "/api/user",
+		"/api/userx":   "/api/user",
+		"/api/use":     "/api",
+		"/api":         "/api",
+		"/apix":        "/api",
+		"/apx/1":       "/apx",
+		"/ap":          "",
+		"/static":      "",
+		"/static/a.js": "/static/",
+		"/API":         "",
+		"":             "",
+		"/":            "",
+	}
+	for path, want := range tests {
+		got := root.longestMatch(path)
+		if (got == nil) != (want == "") || (want != "" && got != items[want]) {
+			t.Errorf("longestMatch(%q) matched = %v, want %q", path, got != nil, want)
+		}
+	}
+	//与 strings.HasPrefix 语义一致：逐个比较时前缀最长的服务
+	for path := range tests {
+		var want *httpServiceRoutes
+		wantLen := -1
+		for _, prefix := range paths {
+			if len(path) >= len(prefix) && path[:len(prefix)] == prefix && len(prefix) > wantLen {
+				want, wantLen = items[prefix], len(prefix)
+			}
+		}
+		if got := root.longestMatch(path); got != want {
+			t.Errorf("longestMatch(%q) differs from linear prefix scan", path)
+		}
+	}
+	var empty *httpRadixNode
+	if empty.longestMatch("/api") != nil {
+		t.Error("nil node should match nothing")
+	}
+}
+
+func TestHTTPRouterMatchHostFallback(t *testing.T) {
+	router := NewHTTPRouter([]*ServiceDetail{
+		newTestHTTPService("exact_v2", public.HTTPRuleTypeDomain, "api.example.com/v2", ""),
+		newTestHTTPService("exact", public.HTTPRuleTypeDomain, "api.example.com", ""),
+		newTestHTTPService("shop_admin", public.HTTPRuleTypeDomain, "shop.example.com/admin", ""),
+		newTestHTTPService("wildcard", public.HTTPRuleTypeDomain, "*.example.com", ""),
+		newTestHTTPService("wildcard_b", public.HTTPRuleTypeDomain, "*.b.example.com", ""),
+		newTestHTTPService("wildcard_b_v3", public.HTTPRuleTypeDomain, "*.b.example.com/v3", ""),
+		newTestHTTPService("prefix_v2", public.HTTPRuleTypePrefixURL, "/v2", ""),
+		newTestHTTPService("prefix_v3", public.HTTPRuleTypePrefixURL, "/v3", ""),
+		//后配置的相同接入规则不生效
+		newTestHTTPService("exact_dup", public.HTTPRuleTypeDomain, "api.example.com", ""),
+		//非 http 服务不参与匹配
+		{Info: &ServiceInfo{ServiceName: "tcp", LoadType: public.LoadTypeTCP}},
+	})
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"http://api.example.com/v2/users", "exact_v2"},
+		{"http://API.Example.com:8080/v2", "exact_v2"},
+		{"http://api.example.com/v1/users", "exact"},
+		//精确域名下没有匹配的前缀时尝试通配域名
+		{"http://shop.example.com/home", "wildcard"},
+		{"http://shop.example.com/admin/users", "shop_admin"},
+		{"http://x.example.com/v2", "wildcard"},
+		//通配域名从最长的后缀开始尝试
+		{"http://x.b.example.com/any", "wildcard_b"},
+		{"http://a.x.b.example.com/v3/1", "wildcard_b_v3"},
+		{"http://b.example.com/", "wildcard"},
+		{"http://a..example.com/", "wildcard"},
+		//通配域名下没有匹配的前缀时使用不限域名的服务
+		{"http://x.example.org/v2", "prefix_v2"},
+		{"http://localhost/v3/x", "prefix_v3"},
+		{"http://example.com./v2", "prefix_v2"},
+		{"http://example.com/v2", "prefix_v2"},
+		{"http://other.com/v4", ""},
+		{"http://[::1]:8080/v2", "prefix_v2"},
+	}
+	for _, test := range tests {
+		serviceDetail, ok := router.Match(httptest.NewRequest("GET", test.url, nil))
+		got := ""
+		if ok {
+			got = serviceDetail.Info.ServiceName
+		}
+		if got != test.want {
+			t.Errorf("Match(%v) = %q, want %q", test.url, got, test.want)
+		}
+	}
+}
+
+func TestHTTPRouterMatchRoute(t *testing.T) {
+	router := NewHTTPRouter([]*ServiceDetail{
+		newTestHTTPService("api", public.HTTPRuleTypePrefixURL, "/api", `
+/api method=POST
+/api/users/admin
+=/api/users
+~^/api/users/\d+$ open_auth=1
+/api/users header=X-Env:canary
+/api/users host=*.example.com
+/api/users host=admin.example.com strip_uri=1`),
+	})
+	routes, err := router.anyHost.longestMatch("/api").base.Service.HTTPRule.GetRouteRuleList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method string
+		url    string
+		header string
+		want   int //匹配的路由在配置中的序号，-1 为服务自身的接入规则
+	}{
+		//精确域名 > 通配域名 > 不限域名，优先于路径
+		{"GET", "http://admin.example.com/api/users/admin", "", 6},
+		{"GET", "http://www.example.com/api/users/admin", "", 5},
+		//精确路径 > 最长前缀 > 正则
+		{"GET", "http://other.com/api/users", "", 2},
+		{"GET", "http://other.com/api/users/admin/1", "", 1},
+		{"GET", "http://other.com/api/users/42", "", 3},
+		//路径相同时条件多的优先
+		{"GET", "http://other.com/api/users/list", "canary", 4},
+		{"POST", "http://other.com/api/orders", "", 0},
+		{"GET", "http://other.com/api/orders", "", -1},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.url, nil)
+		if test.header != "" {
+			req.Header.Set("X-Env", test.header)
+		}
+		serviceDetail, ok := router.Match(req)
+		if !ok {
+			t.Fatalf("%v %v: no match", test.method, test.url)
+		}
+		wantName := ""
+		if test.want >= 0 {
+			wantName = routes[test.want].Name
+		}
+		if serviceDetail.Route.Name != wantName {
+			t.Errorf("%v %v matched route %q (%v), want %q (%v)", test.method, test.url, serviceDetail.Route.Name,
+				serviceDetail.Route.Path, wantName, test.want)
+		}
+		if serviceDetail.Origin().Route != nil || serviceDetail.Origin().Info != serviceDetail.Info {
+			t.Errorf("%v %v: Origin should be the unrouted service", test.method, test.url)
+		}
+	}
+	//路由覆盖的配置只作用于副本
+	serviceDetail, _ := router.Match(httptest.NewRequest("GET", "http://admin.example.com/api/users", nil))
+	if serviceDetail.HTTPRule.NeedStripUri != 1 || serviceDetail.Origin().HTTPRule.NeedStripUri != 0 {
+		t.Errorf("strip_uri route = %v, origin = %v", serviceDetail.HTTPRule.NeedStripUri,
+			serviceDetail.Origin().HTTPRule.NeedStripUri)
+	}
+	serviceDetail, _ = router.Match(httptest.NewRequest("GET", "http://other.com/api/users/42", nil))
+	if serviceDetail.AccessControl.OpenAuth != 1 || serviceDetail.Origin().AccessControl.OpenAuth != 0 {
+		t.Errorf("open_auth route = %v, origin = %v", serviceDetail.AccessControl.OpenAuth,
+			serviceDetail.Origin().AccessControl.OpenAuth)
+	}
+}
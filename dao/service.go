@@ -7,17 +7,22 @@ import (
 	"go-gateway/dto"
 	"go-gateway/public"
 	"net/http/httptest"
-	"strings"
 	"sync"
+	"sync/atomic"
 )
 
 type ServiceDetail struct {
-	Info          *ServiceInfo   `json:"info" description:"基本信息"`
-	HTTPRule      *HttpRule      `json:"http_rule" description:"http_rule"`
-	TCPRule       *TcpRule       `json:"tcp_rule" description:"tcp_rule"`
-	GRPCRule      *GrpcRule      `json:"grpc_rule" description:"grpc_rule"`
-	LoadBalance   *LoadBalance   `json:"load_balance" description:"load_balance"`
-	AccessControl *AccessControl `json:"access_control" description:"access_control"`
+	Info           *ServiceInfo    `json:"info" description:"基本信息"`
+	HTTPRule       *HttpRule       `json:"http_rule" description:"http_rule"`
+	TCPRule        *TcpRule        `json:"tcp_rule" description:"tcp_rule"`
+	GRPCRule       *GrpcRule       `json:"grpc_rule" description:"grpc_rule"`
+	UDPRule        *UdpRule        `json:"udp_rule" description:"udp_rule"`
+	LoadBalance    *LoadBalance    `json:"load_balance" description:"load_balance"`
+	AccessControl  *AccessControl  `json:"access_control" description:"access_control"`
+	CircuitBreaker *CircuitBreaker `json:"circuit_breaker" description:"circuit_breaker"`
+
+	Route  *public.HTTPRouteRule `json:"-"` //http 请求匹配的路由
+	origin *ServiceDetail
 }
 
 var ServiceManagerHandler *ServiceManager
@@ -32,20 +37,58 @@ type ServiceManager struct {
 	Locker       sync.RWMutex
 	init         sync.Once
 	err          error
+	observers    []ServiceObserver
+
+	httpRouter atomic.Pointer[HTTPRouter] //http 路由索引，加载后整体替换，匹配请求时无需加锁
+}
+
+// ServiceObserver 服务配置热加载后的监听者，例如 tcp/grpc 端口监听管理
+type ServiceObserver interface {
+	Update()
 }
 
 func NewServiceManager() *ServiceManager {
-	return &ServiceManager{
+	manager := &ServiceManager{
 		ServiceMap:   map[string]*ServiceDetail{},
 		ServiceSlice: []*ServiceDetail{},
 		Locker:       sync.RWMutex{},
 		init:         sync.Once{},
 	}
+	manager.httpRouter.Store(NewHTTPRouter(nil))
+	return manager
+}
+
+func (s *ServiceManager) Attach(o ServiceObserver) {
+	s.Locker.Lock()
+	defer s.Locker.Unlock()
+	s.observers = append(s.observers, o)
+}
+
+func (s *ServiceManager) NotifyAllObservers() {
+	s.Locker.RLock()
+	observers := s.observers
+	s.Locker.RUnlock()
+	for _, obs := range observers {
+		obs.Update()
+	}
+}
+
+func (s *ServiceManager) GetServiceSlice() []*ServiceDetail {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	return s.ServiceSlice
+}
+
+func (s *ServiceManager) GetServiceDetail(serviceName string) (*ServiceDetail, bool) {
+	s.Locker.RLock()
+	defer s.Locker.RUnlock()
+	serviceDetail, ok := s.ServiceMap[serviceName]
+	return serviceDetail, ok
 }
 
 func (s *ServiceManager) GetTcpServiceList() []*ServiceDetail {
 	list := []*ServiceDetail{}
-	for _, serverItem := range s.ServiceSlice {
+	for _, serverItem := range s.GetServiceSlice() {
 		tempItem := serverItem
 		if tempItem.Info.LoadType == public.LoadTypeTCP {
 			list = append(list, tempItem)
@@ -56,7 +99,7 @@ func (s *ServiceManager) GetTcpServiceList() []*ServiceDetail {
 
 func (s *ServiceManager) GetGrpcServiceList() []*ServiceDetail {
 	list := []*ServiceDetail{}
-	for _, serverItem := range s.ServiceSlice {
+	for _, serverItem := range s.GetServiceSlice() {
 		tempItem := serverItem
 		if tempItem.Info.LoadType == public.LoadTypeGRPC {
 			list = append(list, tempItem)
@@ -65,61 +108,101 @@ func (s *ServiceManager) GetGrpcServiceList() []*ServiceDetail {
 	return list
 }
 
-func (s *ServiceManager) HTTPAccessMode(c *gin.Context) (*ServiceDetail, error) {
-	//1、前缀匹配 /abc ==> serviceSlice.rule
-	//2、域名匹配 www.test.com ==> serviceSlice.rule
-	//host c.Request.Host
-	//path c.Request.URL.Path
-	host := c.Request.Host
-	host = host[0:strings.Index(host, ":")]
-	path := c.Request.URL.Path
-	for _, serviceItem := range s.ServiceSlice {
-		if serviceItem.Info.LoadType != public.LoadTypeHTTP {
-			continue
-		}
-		if serviceItem.HTTPRule.RuleType == public.HTTPRuleTypeDomain {
-			if serviceItem.HTTPRule.Rule == host {
-				return serviceItem, nil
-			}
-		}
-		if serviceItem.HTTPRule.RuleType == public.HTTPRuleTypePrefixURL {
-			if strings.HasPrefix(path, serviceItem.HTTPRule.Rule) {
-				return serviceItem, nil
-			}
+func (s *ServiceManager) GetUdpServiceList() []*ServiceDetail {
+	list := []*ServiceDetail{}
+	for _, serverItem := range s.GetServiceSlice() {
+		tempItem := serverItem
+		if tempItem.Info.LoadType == public.LoadTypeUDP {
+			list = append(list, tempItem)
 		}
 	}
+	return list
+}
+
+// GetHTTPRouter 当前的 http 路由索引
+func (s *ServiceManager) GetHTTPRouter() *HTTPRouter {
+	return s.httpRouter.Load()
+}
+
+// HTTPAccessMode 按路由索引匹配请求，返回的服务配置已应用匹配路由的覆盖配置
+func (s *ServiceManager) HTTPAccessMode(c *gin.Context) (*ServiceDetail, error) {
+	if serviceDetail, ok := s.GetHTTPRouter().Match(c.Request); ok {
+		return serviceDetail, nil
+	}
 	return nil, errors.New("not matched service")
 }
 
 func (s *ServiceManager) LoadOnce() error {
 	s.init.Do(func() {
-		serviceInfo := &ServiceInfo{}
-		c, _ := gin.CreateTestContext(httptest.NewRecorder())
-		tx, err := lib.GetGormPool("default")
-		if err != nil {
-			s.err = err
-			return
-		}
-		params := &dto.ServiceListInput{PageNo: 1, PageSize: 99999}
-		list, _, err := serviceInfo.PageList(c, tx, params)
+		serviceMap, serviceSlice, err := s.loadServiceDetail()
 		if err != nil {
 			s.err = err
 			return
 		}
 		s.Locker.Lock()
 		defer s.Locker.Unlock()
-		for _, listItem := range list {
-			tmpItem := listItem
-			serviceDetail, err := tmpItem.ServiceDetail(c, tx, &tmpItem)
-			//fmt.Println("serviceDetail")
-			//fmt.Println(public.Obj2Json(serviceDetail))
-			if err != nil {
-				s.err = err
-				return
-			}
-			s.ServiceMap[listItem.ServiceName] = serviceDetail
-			s.ServiceSlice = append(s.ServiceSlice, serviceDetail)
-		}
+		s.ServiceMap = serviceMap
+		s.ServiceSlice = serviceSlice
+		s.httpRouter.Store(NewHTTPRouter(serviceSlice))
 	})
 	return s.err
 }
+
+// ReLoad 重新从数据库读取全部服务，整体替换 ServiceMap/ServiceSlice 及 http 路由索引，
+// 返回新增、配置发生变化或已被删除的服务名，供调用方清理相关缓存
+func (s *ServiceManager) ReLoad() ([]string, error) {
+	serviceMap, serviceSlice, err := s.loadServiceDetail()
+	if err != nil {
+		return nil, err
+	}
+	//索引在替换前构建完成，替换期间的请求仍使用旧索引
+	router := NewHTTPRouter(serviceSlice)
+	s.Locker.Lock()
+	oldServiceMap := s.ServiceMap
+	s.ServiceMap = serviceMap
+	s.ServiceSlice = serviceSlice
+	s.httpRouter.Store(router)
+	s.Locker.Unlock()
+
+	changedList := []string{}
+	for serviceName, oldItem := range oldServiceMap {
+		newItem, ok := serviceMap[serviceName]
+		if !ok || public.Obj2Json(oldItem) != public.Obj2Json(newItem) {
+			changedList = append(changedList, serviceName)
+		}
+	}
+	for serviceName := range serviceMap {
+		if _, ok := oldServiceMap[serviceName]; !ok {
+			changedList = append(changedList, serviceName)
+		}
+	}
+	return changedList, nil
+}
+
+func (s *ServiceManager) loadServiceDetail() (map[string]*ServiceDetail, []*ServiceDetail, error) {
+	serviceInfo := &ServiceInfo{}
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tx, err := lib.GetGormPool("default")
+	if err != nil {
+		return nil, nil, err
+	}
+	params := &dto.ServiceListInput{PageNo: 1, PageSize: 99999}
+	list, _, err := serviceInfo.PageList(c, tx, params)
+	if err != nil {
+		return nil, nil, err
+	}
+	serviceMap := map[string]*ServiceDetail{}
+	serviceSlice := []*ServiceDetail{}
+	for _, listItem := range list {
+		tmpItem := listItem
+		serviceDetail, err := tmpItem.ServiceDetail(c, tx, &tmpItem)
+		//fmt.Println("serviceDetail")
+		//fmt.Println(public.Obj2Json(serviceDetail))
+		if err != nil {
+			return nil, nil, err
+		}
+		serviceMap[listItem.ServiceName] = serviceDetail
+		serviceSlice = append(serviceSlice, serviceDetail)
+	}
+	return serviceMap, serviceSlice, nil
+}
@@ -15,6 +15,8 @@ type AccessControl struct {
 	WhiteHostName     string `json:"white_host_name" gorm:"column:white_host_name" description:"白名单主机	"`
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" gorm:"column:clientip_flow_limit" description:"客户端ip限流	"`
 	ServiceFlowLimit  int    `json:"service_flow_limit" gorm:"column:service_flow_limit" description:"服务端限流	"`
+
+	ClientCertAuth int `json:"client_cert_auth" gorm:"column:client_cert_auth" description:"客户端证书认证 1=开启, 证书须由mtls.client_ca_file签发"`
 }
 
 func (t *AccessControl) TableName() string {
@@ -0,0 +1,132 @@
+package dao
+
+import (
+	"fmt"
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"go-gateway/public"
+	"go-gateway/reverse_proxy/load_balance"
+	"net/http"
+	"time"
+)
+
+type CircuitBreaker struct {
+	ID               int64  `json:"id" gorm:"primary_key"`
+	ServiceID        int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	OpenBreaker      int    `json:"open_breaker" gorm:"column:open_breaker" description:"是否开启熔断 1=开启"`
+	ErrorPercent     int    `json:"error_percent" gorm:"column:error_percent" description:"失败率达到后熔断, 百分比"`
+	SlowCallPercent  int    `json:"slow_call_percent" gorm:"column:slow_call_percent" description:"慢调用率达到后熔断, 百分比"`
+	SlowCallDuration int    `json:"slow_call_duration" gorm:"column:slow_call_duration" description:"慢调用耗时, 单位ms, 0为不统计"`
+	MinRequest       int    `json:"min_request" gorm:"column:min_request" description:"统计窗口内最少请求数"`
+	StatWindow       int    `json:"stat_window" gorm:"column:stat_window" description:"统计窗口, 单位s"`
+	OpenDuration     int    `json:"open_duration" gorm:"column:open_duration" description:"熔断持续时间, 单位s"`
+	HalfOpenRequests int    `json:"half_open_requests" gorm:"column:half_open_requests" description:"半开状态探测请求数"`
+	FallbackStatus   int    `json:"fallback_status" gorm:"column:fallback_status" description:"熔断时http降级响应状态码"`
+	FallbackBody     string `json:"fallback_body" gorm:"column:fallback_body" description:"熔断时降级响应内容"`
+}
+
+func (t *CircuitBreaker) TableName() string {
+	return "gateway_service_circuit_breaker"
+}
+
+func (t *CircuitBreaker) Find(c *gin.Context, tx *gorm.DB, search *CircuitBreaker) (*CircuitBreaker, error) {
+	model := &CircuitBreaker{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *CircuitBreaker) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *CircuitBreaker) GetBreakerConf() public.CircuitBreakerConf {
+	return public.CircuitBreakerConf{
+		ErrorPercent:     t.ErrorPercent,
+		SlowCallPercent:  t.SlowCallPercent,
+		SlowCallDuration: time.Duration(t.SlowCallDuration) * time.Millisecond,
+		MinRequest:       t.MinRequest,
+		StatWindow:       time.Duration(t.StatWindow) * time.Second,
+		OpenDuration:     time.Duration(t.OpenDuration) * time.Second,
+		HalfOpenRequests: t.HalfOpenRequests,
+	}
+}
+
+// GetFallback 熔断时的降级响应，未设置时返回 503
+func (t *CircuitBreaker) GetFallback() (int, string) {
+	status, body := t.FallbackStatus, t.FallbackBody
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+	if body == "" {
+		body = "service circuit breaker open"
+	}
+	return status, body
+}
+
+// GetServiceBreaker 服务级别熔断器，未开启熔断时返回 nil
+func (s *ServiceDetail) GetServiceBreaker() *public.CircuitBreaker {
+	if s.CircuitBreaker == nil || s.CircuitBreaker.OpenBreaker != 1 {
+		return nil
+	}
+	return public.CircuitBreakerHandler.GetBreaker(s.Info.ServiceName, "", s.CircuitBreaker.GetBreakerConf())
+}
+
+// breakerPickMax 从负载均衡中挑选未熔断节点的最大次数
+const breakerPickMax = 5
+
+var errAllNodeBreakerOpen = fmt.Errorf("all upstream %w", public.ErrCircuitBreakerOpen)
+
+// breakerBalance 跳过已熔断的下游节点，请求结果同时上报服务及节点熔断器；
+// 服务熔断器在选择节点时才放行，被中间件拦截、未请求下游的请求不占用半开状态的探测名额
+type breakerBalance struct {
+	load_balance.LoadBalance
+	serviceName string
+	conf        public.CircuitBreakerConf
+	service     *public.CircuitBreaker
+}
+
+func newBreakerBalance(lb load_balance.LoadBalance, service *ServiceDetail) load_balance.LoadBalance {
+	return &breakerBalance{
+		LoadBalance: lb,
+		serviceName: service.Info.ServiceName,
+		conf:        service.CircuitBreaker.GetBreakerConf(),
+		service:     service.GetServiceBreaker(),
+	}
+}
+
+func (b *breakerBalance) node(addr string) *public.CircuitBreaker {
+	return public.CircuitBreakerHandler.GetBreaker(b.serviceName, addr, b.conf)
+}
+
+func (b *breakerBalance) Get(key string) (string, error) {
+	if !b.service.Allow() {
+		return "", public.ErrCircuitBreakerOpen
+	}
+	for i := 0; i < breakerPickMax; i++ {
+		pickKey := key
+		if i > 0 {
+			//一致性hash对同一key总返回同一节点，重选时变换key
+			pickKey = fmt.Sprintf("%s#breaker#%d", key, i)
+		}
+		addr, err := b.LoadBalance.Get(pickKey)
+		if err != nil || addr == "" {
+			//未选出节点同样结束本次放行
+			b.service.Report(0, fmt.Errorf("get next addr fail: %v", err))
+			return addr, err
+		}
+		if b.node(addr).Allow() {
+			return addr, nil
+		}
+	}
+	b.service.Report(0, errAllNodeBreakerOpen)
+	return "", errAllNodeBreakerOpen
+}
+
+func (b *breakerBalance) OnRequestFinish(addr string, cost time.Duration, err error) {
+	b.LoadBalance.OnRequestFinish(addr, cost, err)
+	b.node(addr).Report(cost, err)
+	b.service.Report(cost, err)
+}
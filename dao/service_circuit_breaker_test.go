@@ -0,0 +1,66 @@
+package dao
+
+import (
+	"errors"
+	"go-gateway/public"
+	"go-gateway/reverse_proxy/load_balance"
+	"testing"
+)
+
+var errBreakerBalanceTest = errors.New("connection refused")
+
+func newTestBreakerBalance(t *testing.T, serviceName string) *breakerBalance {
+	rb := &load_balance.RoundRobinBalance{}
+	rb.Add("127.0.0.1:2001")
+	rb.Add("127.0.0.1:2002")
+	service := &ServiceDetail{
+		Info: &ServiceInfo{ServiceName: serviceName},
+		CircuitBreaker: &CircuitBreaker{
+			OpenBreaker:  1,
+			MinRequest:   1,
+			StatWindow:   10,
+			OpenDuration: 30,
+		},
+	}
+	t.Cleanup(func() { public.CircuitBreakerHandler.RemoveBreaker(serviceName) })
+	return newBreakerBalance(rb, service).(*breakerBalance)
+}
+
+func TestBreakerBalanceSkipOpenNode(t *testing.T) {
+	b := newTestBreakerBalance(t, "test_breaker_balance_skip")
+	b.node("127.0.0.1:2001").Report(0, errBreakerBalanceTest)
+	for i := 0; i < 10; i++ {
+		addr, err := b.Get("")
+		if err != nil || addr != "127.0.0.1:2002" {
+			t.Fatalf("Get() = %v, %v, want 127.0.0.1:2002", addr, err)
+		}
+	}
+
+	//全部节点熔断时本次放行计为失败，服务熔断器随之熔断
+	b.node("127.0.0.1:2002").Report(0, errBreakerBalanceTest)
+	if _, err := b.Get(""); err != errAllNodeBreakerOpen || !errors.Is(err, public.ErrCircuitBreakerOpen) {
+		t.Fatalf("Get() err = %v, want %v", err, errAllNodeBreakerOpen)
+	}
+	if b.service.State() != public.BreakerStateOpen {
+		t.Errorf("service breaker state = %v, want open", public.BreakerStateMap[b.service.State()])
+	}
+}
+
+func TestBreakerBalanceServiceOpen(t *testing.T) {
+	b := newTestBreakerBalance(t, "test_breaker_balance_service")
+	addr, err := b.Get("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//请求结果同时上报节点及服务熔断器
+	b.OnRequestFinish(addr, 0, errBreakerBalanceTest)
+	if b.node(addr).State() != public.BreakerStateOpen {
+		t.Errorf("node breaker state = %v, want open", public.BreakerStateMap[b.node(addr).State()])
+	}
+	if b.service.State() != public.BreakerStateOpen {
+		t.Fatalf("service breaker state = %v, want open", public.BreakerStateMap[b.service.State()])
+	}
+	if addr, err := b.Get(""); err != public.ErrCircuitBreakerOpen || addr != "" {
+		t.Errorf("Get() = %v, %v, want %v", addr, err, public.ErrCircuitBreakerOpen)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/e421083458/gorm"
 	"github.com/gin-gonic/gin"
 	"go-gateway/public"
+	"strings"
 )
 
 type GrpcRule struct {
@@ -11,6 +12,12 @@ type GrpcRule struct {
 	ServiceID      int64  `json:"service_id" gorm:"column:service_id" description:"服务id	"`
 	Port           int    `json:"port" gorm:"column:port" description:"端口	"`
 	HeaderTransfor string `json:"header_transfor" gorm:"column:header_transfor" description:"header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue"`
+
+	GrpcWeb        int    `json:"grpc_web" gorm:"column:grpc_web" description:"接入grpc-web 1=开启, 服务端口同时接受grpc-web请求"`
+	GrpcWebPrefix  string `json:"grpc_web_prefix" gorm:"column:grpc_web_prefix" description:"通过http端口接入grpc-web的路径前缀, 为空时只通过服务端口接入"`
+	GrpcWebOrigins string `json:"grpc_web_origins" gorm:"column:grpc_web_origins" description:"grpc-web允许跨域的来源, 多个逗号间隔, *为允许全部来源, 为空时只允许同源"`
+
+	MethodRule string `json:"method_rule" gorm:"column:method_rule" description:"方法级规则, 多条逗号间隔, 格式: 方法 选项..., 选项支持allow、deny、qps、timeout、max_timeout、ip_list、weight_list"`
 }
 
 func (t *GrpcRule) TableName() string {
@@ -46,3 +53,35 @@ func (t *GrpcRule) ListByServiceID(c *gin.Context, tx *gorm.DB, serviceID int64)
 	}
 	return list, count, nil
 }
+
+// GetWebOriginList grpc-web 允许跨域的来源
+func (t *GrpcRule) GetWebOriginList() []string {
+	originList := []string{}
+	for _, origin := range strings.Split(t.GrpcWebOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			originList = append(originList, origin)
+		}
+	}
+	return originList
+}
+
+// WebPrefixUsed 检查 grpc-web 路径前缀是否已被其他未删除的 grpc 服务使用
+func (t *GrpcRule) WebPrefixUsed(c *gin.Context, tx *gorm.DB, serviceID int64) (bool, error) {
+	if t.GrpcWebPrefix == "" {
+		return false, nil
+	}
+	var count int64
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Table(t.TableName()+" r").
+		Joins("join "+(&ServiceInfo{}).TableName()+" s on s.id=r.service_id").
+		Where("s.is_delete=? and r.grpc_web_prefix=? and r.service_id<>?", 0, t.GrpcWebPrefix, serviceID).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetMethodRuleList 解析方法级规则，格式见 public.ParseGrpcMethodRules
+func (t *GrpcRule) GetMethodRuleList() (public.GrpcMethodRuleList, error) {
+	return public.ParseGrpcMethodRules(t.MethodRule)
+}
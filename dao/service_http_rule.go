@@ -1,27 +1,86 @@
 package dao
 
 import (
+	"fmt"
 	"github.com/e421083458/gorm"
 	"github.com/gin-gonic/gin"
 	"go-gateway/public"
+	"net/http"
 )
 
 type HttpRule struct {
 	ID             int64  `json:"id" gorm:"primary_key"`
 	ServiceID      int64  `json:"service_id" gorm:"column:service_id" description:"服务id"`
 	RuleType       int    `json:"rule_type" gorm:"column:rule_type" description:"匹配类型 domain=域名, url_prefix=url前缀"`
-	Rule           string `json:"rule" gorm:"column:rule" description:"type=domain表示域名，type=url_prefix时表示url前缀"`
+	Rule           string `json:"rule" gorm:"column:rule" description:"type=domain表示域名(可带路径前缀, 支持*.example.com)，type=url_prefix时表示url前缀"`
 	NeedHttps      int    `json:"need_https" gorm:"column:need_https" description:"type=支持https 1=支持"`
 	NeedWebsocket  int    `json:"need_websocket" gorm:"column:need_websocket" description:"启用websocket 1=启用"`
 	NeedStripUri   int    `json:"need_strip_uri" gorm:"column:need_strip_uri" description:"启用strip_uri 1=启用"`
 	UrlRewrite     string `json:"url_rewrite" gorm:"column:url_rewrite" description:"url重写功能，每行一个	"`
 	HeaderTransfor string `json:"header_transfor" gorm:"column:header_transfor" description:"header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue	"`
+
+	HttpsPolicy           int `json:"https_policy" gorm:"column:https_policy" description:"开启https后明文http请求的处理 0=均允许 1=跳转https 2=拒绝"`
+	HttpsRedirectCode     int `json:"https_redirect_code" gorm:"column:https_redirect_code" description:"跳转https的状态码, 默认301"`
+	HstsMaxAge            int `json:"hsts_max_age" gorm:"column:hsts_max_age" description:"https响应的HSTS有效期, 单位s, 0为不返回"`
+	HstsIncludeSubdomains int `json:"hsts_include_subdomains" gorm:"column:hsts_include_subdomains" description:"HSTS是否包含子域名 1=包含"`
+	UpstreamScheme        int `json:"upstream_scheme" gorm:"column:upstream_scheme" description:"下游协议 0=与need_https一致 1=http 2=https"`
+
+	UpstreamProtocol int `json:"upstream_protocol" gorm:"column:upstream_protocol" description:"下游http协议版本 0=自动 1=http/1.1 2=http2(tls) 3=h2c"`
+
+	GrpcTranscode  int    `json:"grpc_transcode" gorm:"column:grpc_transcode" description:"json转grpc 0=关闭 1=上传描述文件 2=下游server reflection"`
+	GrpcDescriptor string `json:"grpc_descriptor" gorm:"column:grpc_descriptor" description:"protobuf描述文件(FileDescriptorSet), base64编码"`
+
+	LegacyErrorResponse int `json:"legacy_error_response" gorm:"column:legacy_error_response" description:"网关拒绝及下游失败时的响应 0=对应http状态码 1=保留旧格式http 200+errno"`
+
+	RouteRule string `json:"route_rule" gorm:"column:route_rule" description:"服务下的路由规则, 每行一条, 格式: 路径 选项..., 可按请求方法、请求头、查询参数、cookie匹配并覆盖下游节点及中间件配置"`
 }
 
 func (t *HttpRule) TableName() string {
 	return "gateway_service_http_rule"
 }
 
+// UpstreamUseHttps 下游是否使用 https，http2 与 h2c 协议决定下游 scheme，其余未单独设置时与 need_https 一致
+func (t *HttpRule) UpstreamUseHttps() bool {
+	switch t.UpstreamProtocol {
+	case public.UpstreamProtocolHttp2:
+		return true
+	case public.UpstreamProtocolH2c:
+		return false
+	}
+	switch t.UpstreamScheme {
+	case public.UpstreamSchemeHttp:
+		return false
+	case public.UpstreamSchemeHttps:
+		return true
+	}
+	return t.NeedHttps == 1
+}
+
+// GetRedirectCode 跳转 https 的状态码，未设置时为 301
+func (t *HttpRule) GetRedirectCode() int {
+	if t.HttpsRedirectCode == 0 {
+		return http.StatusMovedPermanently
+	}
+	return t.HttpsRedirectCode
+}
+
+// GetHstsHeader Strict-Transport-Security 响应头，未开启时返回空
+func (t *HttpRule) GetHstsHeader() string {
+	if t.HstsMaxAge <= 0 {
+		return ""
+	}
+	header := fmt.Sprintf("max-age=%d", t.HstsMaxAge)
+	if t.HstsIncludeSubdomains == 1 {
+		header += "; includeSubDomains"
+	}
+	return header
+}
+
+// GetRouteRuleList 解析服务下的路由规则，格式见 public.ParseHTTPRouteRules
+func (t *HttpRule) GetRouteRuleList() ([]*public.HTTPRouteRule, error) {
+	return public.ParseHTTPRouteRules(t.RouteRule)
+}
+
 func (t *HttpRule) Find(c *gin.Context, tx *gorm.DB, search *HttpRule) (*HttpRule, error) {
 	model := &HttpRule{}
 	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
@@ -5,12 +5,13 @@ import (
 	"github.com/gin-gonic/gin"
 	"go-gateway/dto"
 	"go-gateway/public"
+	"log"
 	"time"
 )
 
 type ServiceInfo struct {
 	ID          int64     `json:"id" gorm:"primary_key"`
-	LoadType    int       `json:"load_type" gorm:"column:load_type" description:"负载类型 0=http 1=tcp 2=grpc"`
+	LoadType    int       `json:"load_type" gorm:"column:load_type" description:"负载类型 0=http 1=tcp 2=grpc 3=udp"`
 	ServiceName string    `json:"service_name" gorm:"column:service_name" description:"服务名称"`
 	ServiceDesc string    `json:"service_desc" gorm:"column:service_desc" description:"服务描述"`
 	UpdatedAt   time.Time `json:"create_at" gorm:"column:create_at" description:"更新时间"`
@@ -23,7 +24,7 @@ func (t *ServiceInfo) TableName() string {
 }
 
 // ServiceDetail 读取某个服务的完整配置信息。
-// 包含：基础信息、HTTP/TCP/GRPC 规则、负载均衡、访问控制等。
+// 包含：基础信息、HTTP/TCP/GRPC/UDP 规则、负载均衡、访问控制等。
 // search 可只填 ServiceID 或 ServiceName，不完整时会自动补全。
 func (t *ServiceInfo) ServiceDetail(c *gin.Context, tx *gorm.DB, search *ServiceInfo) (*ServiceDetail, error) {
 
@@ -58,6 +59,13 @@ func (t *ServiceInfo) ServiceDetail(c *gin.Context, tx *gorm.DB, search *Service
 		return nil, err
 	}
 
+	// 读取 UDP 规则（可选）
+	udpRule := &UdpRule{ServiceID: search.ID}
+	udpRule, err = udpRule.Find(c, tx, udpRule)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
 	// 读取访问控制配置（可选）
 	accessControl := &AccessControl{ServiceID: search.ID}
 	accessControl, err = accessControl.Find(c, tx, accessControl)
@@ -72,14 +80,24 @@ func (t *ServiceInfo) ServiceDetail(c *gin.Context, tx *gorm.DB, search *Service
 		return nil, err
 	}
 
+	// 读取熔断配置（可选），未升级熔断表等读取失败时按不开启熔断处理，不影响服务加载
+	circuitBreaker := &CircuitBreaker{ServiceID: search.ID}
+	circuitBreaker, err = circuitBreaker.Find(c, tx, circuitBreaker)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		log.Printf(" [ERROR] circuit_breaker service_id:%v err:%v\n", search.ID, err)
+		circuitBreaker = &CircuitBreaker{ServiceID: search.ID}
+	}
+
 	// 聚合所有配置，形成完整服务详情
 	detail := &ServiceDetail{
-		Info:          search,
-		HTTPRule:      httpRule,
-		TCPRule:       tcpRule,
-		GRPCRule:      grpcRule,
-		LoadBalance:   loadBalance,
-		AccessControl: accessControl,
+		Info:           search,
+		HTTPRule:       httpRule,
+		TCPRule:        tcpRule,
+		GRPCRule:       grpcRule,
+		UDPRule:        udpRule,
+		LoadBalance:    loadBalance,
+		AccessControl:  accessControl,
+		CircuitBreaker: circuitBreaker,
 	}
 
 	return detail, nil
@@ -1,7 +1,11 @@
 package dao
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"go-gateway/common/lib"
 	"go-gateway/public"
 	"go-gateway/reverse_proxy/load_balance"
 
@@ -17,18 +21,36 @@ import (
 type LoadBalance struct {
 	ID            int64  `json:"id" gorm:"primary_key"`
 	ServiceID     int64  `json:"service_id" gorm:"column:service_id" description:"服务id	"`
-	CheckMethod   int    `json:"check_method" gorm:"column:check_method" description:"检查方法 tcpchk=检测端口是否握手成功	"`
+	CheckMethod   int    `json:"check_method" gorm:"column:check_method" description:"检查方法 0=tcpchk 1=httpchk 2=grpc health"`
 	CheckTimeout  int    `json:"check_timeout" gorm:"column:check_timeout" description:"check超时时间	"`
 	CheckInterval int    `json:"check_interval" gorm:"column:check_interval" description:"检查间隔, 单位s		"`
-	RoundType     int    `json:"round_type" gorm:"column:round_type" description:"轮询方式 round/weight_round/random/ip_hash"`
+	RoundType     int    `json:"round_type" gorm:"column:round_type" description:"轮询方式 random/round/weight_round/ip_hash/least_conn/peak_ewma"`
 	IpList        string `json:"ip_list" gorm:"column:ip_list" description:"ip列表"`
 	WeightList    string `json:"weight_list" gorm:"column:weight_list" description:"权重列表"`
 	ForbidList    string `json:"forbid_list" gorm:"column:forbid_list" description:"禁用ip列表"`
 
+	CheckPath         string `json:"check_path" gorm:"column:check_path" description:"http检查路径, grpc检查时为服务名"`
+	CheckHttpMethod   string `json:"check_http_method" gorm:"column:check_http_method" description:"http检查请求方法"`
+	CheckExpectStatus string `json:"check_expect_status" gorm:"column:check_expect_status" description:"http检查期望状态码, 如200-399"`
+	CheckExpectBody   string `json:"check_expect_body" gorm:"column:check_expect_body" description:"http检查响应体需包含的内容"`
+	CheckRise         int    `json:"check_rise" gorm:"column:check_rise" description:"连续成功次数达到后节点恢复"`
+	CheckFall         int    `json:"check_fall" gorm:"column:check_fall" description:"连续失败次数达到后节点摘除"`
+
 	UpstreamConnectTimeout int `json:"upstream_connect_timeout" gorm:"column:upstream_connect_timeout" description:"下游建立连接超时, 单位s"`
 	UpstreamHeaderTimeout  int `json:"upstream_header_timeout" gorm:"column:upstream_header_timeout" description:"下游获取header超时, 单位s	"`
 	UpstreamIdleTimeout    int `json:"upstream_idle_timeout" gorm:"column:upstream_idle_timeout" description:"下游链接最大空闲时间, 单位s	"`
 	UpstreamMaxIdle        int `json:"upstream_max_idle" gorm:"column:upstream_max_idle" description:"下游最大空闲链接数"`
+
+	RetryMax           int    `json:"retry_max" gorm:"column:retry_max" description:"失败后换节点重试次数, 0为不重试"`
+	RetryOn            string `json:"retry_on" gorm:"column:retry_on" description:"重试条件, 如connect-failure,reset,timeout,5xx,503"`
+	RetryNonIdempotent int    `json:"retry_non_idempotent" gorm:"column:retry_non_idempotent" description:"是否重试非幂等请求 1=是"`
+	RetryPerTryTimeout int    `json:"retry_per_try_timeout" gorm:"column:retry_per_try_timeout" description:"单次尝试超时, 单位s"`
+
+	UpstreamTlsCa         string `json:"upstream_tls_ca" gorm:"column:upstream_tls_ca" description:"校验下游证书的ca, pem格式, 为空时使用系统根证书"`
+	UpstreamTlsCert       string `json:"upstream_tls_cert" gorm:"column:upstream_tls_cert" description:"连接下游的客户端证书, pem格式"`
+	UpstreamTlsKey        string `json:"upstream_tls_key" gorm:"column:upstream_tls_key" description:"连接下游的客户端私钥, pem格式"`
+	UpstreamTlsServerName string `json:"upstream_tls_server_name" gorm:"column:upstream_tls_server_name" description:"校验下游证书的域名, 为空时取下游地址"`
+	UpstreamTlsInsecure   int    `json:"upstream_tls_insecure" gorm:"column:upstream_tls_insecure" description:"不校验下游证书 1=不校验"`
 }
 
 func (t *LoadBalance) TableName() string {
@@ -56,6 +78,34 @@ func (t *LoadBalance) GetWeightListByModel() []string {
 	return strings.Split(t.WeightList, ",")
 }
 
+// UseUpstreamTLS 是否设置了下游 tls 参数，tcp/grpc 服务据此决定是否以 tls 连接下游
+func (t *LoadBalance) UseUpstreamTLS() bool {
+	return t.UpstreamTlsCa != "" || t.UpstreamTlsCert != "" || t.UpstreamTlsServerName != "" || t.UpstreamTlsInsecure == 1
+}
+
+// GetUpstreamTLSConfig 连接下游使用的 tls 配置，设置了客户端证书时为双向 tls
+func (t *LoadBalance) GetUpstreamTLSConfig() (*tls.Config, error) {
+	conf := &tls.Config{
+		ServerName:         t.UpstreamTlsServerName,
+		InsecureSkipVerify: t.UpstreamTlsInsecure == 1,
+	}
+	if t.UpstreamTlsCa != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(t.UpstreamTlsCa)) {
+			return nil, errors.New("invalid upstream tls ca")
+		}
+		conf.RootCAs = pool
+	}
+	if t.UpstreamTlsCert != "" || t.UpstreamTlsKey != "" {
+		cert, err := tls.X509KeyPair([]byte(t.UpstreamTlsCert), []byte(t.UpstreamTlsKey))
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
 var LoadBalancerHandler *LoadBalancer
 
 type LoadBalancer struct {
@@ -67,6 +117,7 @@ type LoadBalancer struct {
 type LoadBalancerItem struct {
 	LoadBanlance load_balance.LoadBalance
 	ServiceName  string
+	CheckConf    *load_balance.LoadBalanceCheckConf
 }
 
 func NewLoadBalancer() *LoadBalancer {
@@ -81,43 +132,150 @@ func init() {
 	LoadBalancerHandler = NewLoadBalancer()
 }
 
+// GetLoadBalancer 服务的负载均衡器，http 请求匹配的路由单独设置了下游节点时使用该路由的负载均衡器
 func (lbr *LoadBalancer) GetLoadBalancer(service *ServiceDetail) (load_balance.LoadBalance, error) {
-	for _, lbrItem := range lbr.LoadBanlanceSlice {
-		if lbrItem.ServiceName == service.Info.ServiceName {
-			return lbrItem.LoadBanlance, nil
+	if route := service.Route; route != nil && len(route.IpList) > 0 {
+		lbrItem, err := lbr.getLoadBalancerItem(service, service.Info.ServiceName+route.Name, route.IpList, route.WeightList)
+		if err != nil {
+			return nil, err
 		}
+		return lbrItem.LoadBanlance, nil
+	}
+	lbrItem, err := lbr.getLoadBalancerItem(service, service.Info.ServiceName,
+		service.LoadBalance.GetIPListByModel(), service.LoadBalance.GetWeightListByModel())
+	if err != nil {
+		return nil, err
+	}
+	return lbrItem.LoadBanlance, nil
+}
+
+// GetMethodLoadBalancer grpc 方法级规则单独设置下游节点时使用的负载均衡器，轮询方式及探活配置与服务一致，
+// 返回的探活配置用于挂载该方法的连接池
+func (lbr *LoadBalancer) GetMethodLoadBalancer(service *ServiceDetail, rule *public.GrpcMethodRule) (load_balance.LoadBalance, load_balance.LoadBalanceConf, error) {
+	lbrItem, err := lbr.getLoadBalancerItem(service, service.Info.ServiceName+rule.Upstream, rule.IpList, rule.WeightList)
+	if err != nil {
+		return nil, nil, err
+	}
+	return lbrItem.LoadBanlance, lbrItem.CheckConf, nil
+}
+
+func (lbr *LoadBalancer) getLoadBalancerItem(service *ServiceDetail, key string, ipList, weightList []string) (*LoadBalancerItem, error) {
+	lbr.Locker.RLock()
+	lbrItem, ok := lbr.LoadBanlanceMap[key]
+	lbr.Locker.RUnlock()
+	if ok {
+		return lbrItem, nil
+	}
+
+	lbr.Locker.Lock()
+	defer lbr.Locker.Unlock()
+	//并发请求时可能已被其他协程创建
+	if lbrItem, ok := lbr.LoadBanlanceMap[key]; ok {
+		return lbrItem, nil
 	}
 	schema := "http://"
-	if service.HTTPRule.NeedHttps == 1 {
+	if service.HTTPRule.UpstreamUseHttps() {
 		schema = "https://"
 	}
-	if service.Info.LoadType == public.LoadTypeTCP || service.Info.LoadType == public.LoadTypeGRPC {
+	if service.Info.LoadType == public.LoadTypeTCP || service.Info.LoadType == public.LoadTypeGRPC ||
+		service.Info.LoadType == public.LoadTypeUDP {
+		schema = ""
+	}
+	checkScheme := strings.TrimSuffix(schema, "://")
+	//开启 json 转 grpc 的 http 服务由 grpc 连接池连接下游，节点地址不带 scheme
+	if service.Info.LoadType == public.LoadTypeHTTP && service.HTTPRule.GrpcTranscode != public.GrpcTranscodeOff {
 		schema = ""
 	}
-	ipList := service.LoadBalance.GetIPListByModel()
-	weightList := service.LoadBalance.GetWeightListByModel()
 	ipConf := map[string]string{}
 	for ipIndex, ipItem := range ipList {
 		ipConf[ipItem] = weightList[ipIndex]
 	}
 	//fmt.Println("ipConf", ipConf)
-	mConf, err := load_balance.NewLoadBalanceCheckConf(fmt.Sprintf("%s%s", schema, "%s"), ipConf)
+	//udp 服务默认的端口检查使用 udp 探测
+	checkMethod := service.LoadBalance.CheckMethod
+	if service.Info.LoadType == public.LoadTypeUDP && checkMethod == load_balance.CheckMethodTcp {
+		checkMethod = load_balance.CheckMethodUdp
+	}
+	checkConf, err := load_balance.NewHealthCheckConf(load_balance.HealthCheckConf{
+		Method:       checkMethod,
+		Timeout:      time.Duration(service.LoadBalance.CheckTimeout) * time.Second,
+		Interval:     time.Duration(service.LoadBalance.CheckInterval) * time.Second,
+		Path:         service.LoadBalance.CheckPath,
+		HttpMethod:   service.LoadBalance.CheckHttpMethod,
+		ExpectStatus: service.LoadBalance.CheckExpectStatus,
+		ExpectBody:   service.LoadBalance.CheckExpectBody,
+		Rise:         service.LoadBalance.CheckRise,
+		Fall:         service.LoadBalance.CheckFall,
+		Outlier:      getOutlierConf(),
+	}, checkScheme)
+	if err != nil {
+		return nil, err
+	}
+	mConf, err := load_balance.NewLoadBalanceCheckConf(fmt.Sprintf("%s%s", schema, "%s"), ipConf, checkConf)
 	if err != nil {
 		return nil, err
 	}
 	lb := load_balance.LoadBanlanceFactorWithConf(load_balance.LbType(service.LoadBalance.RoundType), mConf)
+	if service.CircuitBreaker != nil && service.CircuitBreaker.OpenBreaker == 1 {
+		lb = newBreakerBalance(lb, service)
+	}
 
 	//save to map and slice
 	lbItem := &LoadBalancerItem{
 		LoadBanlance: lb,
 		ServiceName:  service.Info.ServiceName,
+		CheckConf:    mConf,
 	}
 	lbr.LoadBanlanceSlice = append(lbr.LoadBanlanceSlice, lbItem)
+	lbr.LoadBanlanceMap[key] = lbItem
+	return lbItem, nil
+}
 
+// getOutlierConf 被动健康检查配置，未设置的项使用默认值
+func getOutlierConf() load_balance.OutlierConf {
+	return load_balance.OutlierConf{
+		Open:               lib.GetBoolConf("proxy.outlier.open"),
+		ConsecutiveErrors:  lib.GetIntConf("proxy.outlier.consecutive_errors"),
+		ErrorPercent:       lib.GetIntConf("proxy.outlier.error_percent"),
+		MinRequest:         lib.GetIntConf("proxy.outlier.min_request"),
+		Interval:           time.Duration(lib.GetIntConf("proxy.outlier.interval")) * time.Second,
+		BaseEjectionTime:   time.Duration(lib.GetIntConf("proxy.outlier.base_ejection_time")) * time.Second,
+		MaxEjectionTime:    time.Duration(lib.GetIntConf("proxy.outlier.max_ejection_time")) * time.Second,
+		MaxEjectionPercent: lib.GetIntConf("proxy.outlier.max_ejection_percent"),
+	}
+}
+
+// GetLoadBalanceConf 获取服务负载均衡的探活配置，用于挂载其他观察者(如 grpc 连接池)
+func (lbr *LoadBalancer) GetLoadBalanceConf(service *ServiceDetail) (load_balance.LoadBalanceConf, error) {
+	if _, err := lbr.GetLoadBalancer(service); err != nil {
+		return nil, err
+	}
+	lbr.Locker.RLock()
+	defer lbr.Locker.RUnlock()
+	lbrItem, ok := lbr.LoadBanlanceMap[service.Info.ServiceName]
+	if !ok {
+		return nil, errors.New("load balancer not found")
+	}
+	return lbrItem.CheckConf, nil
+}
+
+// RemoveLoadBalancer 服务变更或删除时移除缓存的负载均衡器(包含 grpc 方法级及 http 路由的负载均衡器)，并停止其探活协程
+func (lbr *LoadBalancer) RemoveLoadBalancer(serviceName string) {
 	lbr.Locker.Lock()
 	defer lbr.Locker.Unlock()
-	lbr.LoadBanlanceMap[service.Info.ServiceName] = lbItem
-	return lb, nil
+	for key, lbItem := range lbr.LoadBanlanceMap {
+		if lbItem.ServiceName == serviceName {
+			lbItem.CheckConf.CloseWatch()
+			delete(lbr.LoadBanlanceMap, key)
+		}
+	}
+	lbSlice := []*LoadBalancerItem{}
+	for _, item := range lbr.LoadBanlanceSlice {
+		if item.ServiceName != serviceName {
+			lbSlice = append(lbSlice, item)
+		}
+	}
+	lbr.LoadBanlanceSlice = lbSlice
 }
 
 var TransportorHandler *Transportor
@@ -146,47 +304,100 @@ func init() {
 }
 
 func (t *Transportor) GetTrans(service *ServiceDetail) (*http.Transport, error) {
-	for _, transItem := range t.TransportSlice {
-		if transItem.ServiceName == service.Info.ServiceName {
-			return transItem.Trans, nil
-		}
+	t.Locker.RLock()
+	transItem, ok := t.TransportMap[service.Info.ServiceName]
+	t.Locker.RUnlock()
+	if ok {
+		return transItem.Trans, nil
+	}
+
+	t.Locker.Lock()
+	defer t.Locker.Unlock()
+	if transItem, ok := t.TransportMap[service.Info.ServiceName]; ok {
+		return transItem.Trans, nil
 	}
 
 	//todo 优化点5
-	if service.LoadBalance.UpstreamConnectTimeout == 0 {
-		service.LoadBalance.UpstreamConnectTimeout = 30
+	//默认值只作用于 transport，不回写 service，避免热加载时误判配置变更
+	connectTimeout := service.LoadBalance.UpstreamConnectTimeout
+	if connectTimeout == 0 {
+		connectTimeout = 30
 	}
-	if service.LoadBalance.UpstreamMaxIdle == 0 {
-		service.LoadBalance.UpstreamMaxIdle = 100
+	maxIdle := service.LoadBalance.UpstreamMaxIdle
+	if maxIdle == 0 {
+		maxIdle = 100
 	}
-	if service.LoadBalance.UpstreamIdleTimeout == 0 {
-		service.LoadBalance.UpstreamIdleTimeout = 90
+	idleTimeout := service.LoadBalance.UpstreamIdleTimeout
+	if idleTimeout == 0 {
+		idleTimeout = 90
 	}
-	if service.LoadBalance.UpstreamHeaderTimeout == 0 {
-		service.LoadBalance.UpstreamHeaderTimeout = 30
+	headerTimeout := service.LoadBalance.UpstreamHeaderTimeout
+	if headerTimeout == 0 {
+		headerTimeout = 30
 	}
 	trans := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
-			Timeout:   time.Duration(service.LoadBalance.UpstreamConnectTimeout) * time.Second,
+			Timeout:   time.Duration(connectTimeout) * time.Second,
 			KeepAlive: 30 * time.Second,
 			DualStack: true,
 		}).DialContext,
 		ForceAttemptHTTP2:     true,
-		MaxIdleConns:          service.LoadBalance.UpstreamMaxIdle,
-		IdleConnTimeout:       time.Duration(service.LoadBalance.UpstreamIdleTimeout) * time.Second,
+		MaxIdleConns:          maxIdle,
+		IdleConnTimeout:       time.Duration(idleTimeout) * time.Second,
 		TLSHandshakeTimeout:   10 * time.Second,
-		ResponseHeaderTimeout: time.Duration(service.LoadBalance.UpstreamHeaderTimeout) * time.Second,
+		ResponseHeaderTimeout: time.Duration(headerTimeout) * time.Second,
+	}
+	tlsConf, err := service.LoadBalance.GetUpstreamTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	trans.TLSClientConfig = tlsConf
+	if protocols := getUpstreamProtocols(service.HTTPRule.UpstreamProtocol); protocols != nil {
+		trans.Protocols = protocols
 	}
 
 	//save to map and slice
-	transItem := &TransportItem{
+	transItem = &TransportItem{
 		Trans:       trans,
 		ServiceName: service.Info.ServiceName,
 	}
 	t.TransportSlice = append(t.TransportSlice, transItem)
-	t.Locker.Lock()
-	defer t.Locker.Unlock()
 	t.TransportMap[service.Info.ServiceName] = transItem
 	return trans, nil
 }
+
+// getUpstreamProtocols 自动协商时返回 nil，使用 ForceAttemptHTTP2 的默认行为
+func getUpstreamProtocols(upstreamProtocol int) *http.Protocols {
+	protocols := &http.Protocols{}
+	switch upstreamProtocol {
+	case public.UpstreamProtocolHttp1:
+		protocols.SetHTTP1(true)
+	case public.UpstreamProtocolHttp2:
+		protocols.SetHTTP2(true)
+	case public.UpstreamProtocolH2c:
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		return nil
+	}
+	return protocols
+}
+
+// RemoveTrans 服务变更或删除时移除缓存的 transport，并关闭其空闲连接
+func (t *Transportor) RemoveTrans(serviceName string) {
+	t.Locker.Lock()
+	defer t.Locker.Unlock()
+	transItem, ok := t.TransportMap[serviceName]
+	if !ok {
+		return
+	}
+	transItem.Trans.CloseIdleConnections()
+	delete(t.TransportMap, serviceName)
+	transSlice := []*TransportItem{}
+	for _, item := range t.TransportSlice {
+		if item.ServiceName != serviceName {
+			transSlice = append(transSlice, item)
+		}
+	}
+	t.TransportSlice = transSlice
+}
@@ -10,6 +10,11 @@ type TcpRule struct {
 	ID        int64 `json:"id" gorm:"primary_key"`
 	ServiceID int64 `json:"service_id" gorm:"column:service_id" description:"服务id	"`
 	Port      int   `json:"port" gorm:"column:port" description:"端口	"`
+
+	NeedTls  int    `json:"need_tls" gorm:"column:need_tls" description:"在网关终止tls 1=开启, 证书按sni从证书管理中选择"`
+	SniNames string `json:"sni_names" gorm:"column:sni_names" description:"共享tls端口按sni路由到本服务, 支持*.开头的通配符, 多个逗号间隔"`
+
+	ProxyProtocol int `json:"proxy_protocol" gorm:"column:proxy_protocol" description:"向下游发送PROXY协议头 0=不发送 1=v1 2=v2"`
 }
 
 func (t *TcpRule) TableName() string {
@@ -45,3 +50,32 @@ func (t *TcpRule) ListByServiceID(c *gin.Context, tx *gorm.DB, serviceID int64)
 	}
 	return list, count, nil
 }
+
+// GetSniNameList sni 域名统一转为小写
+func (t *TcpRule) GetSniNameList() []string {
+	return splitSniNames(t.SniNames)
+}
+
+// SniNameUsed 检查 sni 域名是否已被其他未删除的 tcp 服务使用，返回被占用的域名
+func (t *TcpRule) SniNameUsed(c *gin.Context, tx *gorm.DB, serviceID int64) (string, error) {
+	var list []TcpRule
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Table(t.TableName()+" r").
+		Joins("join "+(&ServiceInfo{}).TableName()+" s on s.id=r.service_id").
+		Where("s.is_delete=? and r.sni_names<>? and r.service_id<>?", 0, "", serviceID).
+		Select("r.*").Find(&list).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return "", err
+	}
+	usedNames := map[string]bool{}
+	for _, item := range list {
+		for _, name := range item.GetSniNameList() {
+			usedNames[name] = true
+		}
+	}
+	for _, name := range t.GetSniNameList() {
+		if usedNames[name] {
+			return name, nil
+		}
+	}
+	return "", nil
+}
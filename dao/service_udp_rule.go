@@ -0,0 +1,44 @@
+package dao
+
+import (
+	"github.com/e421083458/gorm"
+	"github.com/gin-gonic/gin"
+	"go-gateway/public"
+)
+
+type UdpRule struct {
+	ID          int64 `json:"id" gorm:"primary_key"`
+	ServiceID   int64 `json:"service_id" gorm:"column:service_id" description:"服务id"`
+	Port        int   `json:"port" gorm:"column:port" description:"端口"`
+	IdleTimeout int   `json:"idle_timeout" gorm:"column:idle_timeout" description:"会话空闲超时, 单位s, 0为使用全局配置"`
+}
+
+func (t *UdpRule) TableName() string {
+	return "gateway_service_udp_rule"
+}
+
+func (t *UdpRule) Find(c *gin.Context, tx *gorm.DB, search *UdpRule) (*UdpRule, error) {
+	model := &UdpRule{}
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Where(search).Find(model).Error
+	return model, err
+}
+
+func (t *UdpRule) Save(c *gin.Context, tx *gorm.DB) error {
+	if err := tx.SetCtx(public.GetGinTraceContext(c)).Save(t).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// PortUsed 检查端口是否已被其他未删除的 udp 服务使用
+func (t *UdpRule) PortUsed(c *gin.Context, tx *gorm.DB, serviceID int64) (bool, error) {
+	var count int64
+	err := tx.SetCtx(public.GetGinTraceContext(c)).Table(t.TableName()+" r").
+		Joins("join "+(&ServiceInfo{}).TableName()+" s on s.id=r.service_id").
+		Where("s.is_delete=? and r.port=? and r.service_id<>?", 0, t.Port, serviceID).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -34,6 +34,8 @@ type APPListItemOutput struct {
 	UpdatedAt time.Time `json:"create_at" gorm:"column:create_at" description:"添加时间	"`
 	CreatedAt time.Time `json:"update_at" gorm:"column:update_at" description:"更新时间"`
 	IsDelete  int8      `json:"is_delete" gorm:"column:is_delete" description:"是否已删除；0：否；1：是"`
+
+	CertSubject string `json:"cert_subject" gorm:"column:cert_subject" description:"客户端证书主体"`
 }
 
 type APPDetailInput struct {
@@ -56,6 +58,8 @@ type APPAddHttpInput struct {
 	WhiteIPS string `json:"white_ips" form:"white_ips" comment:"ip白名单，支持前缀匹配"`
 	Qpd      int64  `json:"qpd" form:"qpd" comment:"日请求量限制" validate:""`
 	Qps      int64  `json:"qps" form:"qps" comment:"每秒请求量限制" validate:""`
+
+	CertSubject string `json:"cert_subject" form:"cert_subject" comment:"客户端证书主体, 匹配证书CN或SAN, 为空时以app_id匹配CN" validate:"max=255"`
 }
 
 func (params *APPAddHttpInput) GetValidParams(c *gin.Context) error {
@@ -70,6 +74,8 @@ type APPUpdateHttpInput struct {
 	WhiteIPS string `json:"white_ips" form:"white_ips" gorm:"column:white_ips" comment:"ip白名单，支持前缀匹配		"`
 	Qpd      int64  `json:"qpd" form:"qpd" gorm:"column:qpd" comment:"日请求量限制"`
 	Qps      int64  `json:"qps" form:"qps" gorm:"column:qps" comment:"每秒请求量限制"`
+
+	CertSubject string `json:"cert_subject" form:"cert_subject" gorm:"column:cert_subject" comment:"客户端证书主体, 匹配证书CN或SAN, 为空时以app_id匹配CN" validate:"max=255"`
 }
 
 func (params *APPUpdateHttpInput) GetValidParams(c *gin.Context) error {
@@ -0,0 +1,73 @@
+package dto
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-gateway/public"
+	"time"
+)
+
+type CertListInput struct {
+	Info     string `json:"info" form:"info" comment:"查找信息" validate:""`
+	PageSize int    `json:"page_size" form:"page_size" comment:"页数" validate:"required,min=1,max=999"`
+	PageNo   int    `json:"page_no" form:"page_no" comment:"页码" validate:"required,min=1,max=999"`
+}
+
+func (params *CertListInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type CertListOutput struct {
+	List  []CertListItemOutput `json:"list" form:"list" comment:"证书列表"`
+	Total int64                `json:"total" form:"total" comment:"证书总数"`
+}
+
+type CertListItemOutput struct {
+	ID         int64     `json:"id"`
+	Name       string    `json:"name" description:"证书名称"`
+	SniNames   string    `json:"sni_names" description:"匹配的sni域名"`
+	Source     int       `json:"source" description:"证书来源 0=手动上传 1=acme自动签发"`
+	NotBefore  time.Time `json:"not_before" description:"证书生效时间"`
+	NotAfter   time.Time `json:"not_after" description:"证书过期时间"`
+	ExpireDays int       `json:"expire_days" description:"剩余有效天数"`
+	Expiring   bool      `json:"expiring" description:"是否即将过期或已过期"`
+	UpdatedAt  time.Time `json:"update_at" description:"更新时间"`
+}
+
+type CertDetailInput struct {
+	ID int64 `json:"id" form:"id" comment:"证书ID" validate:"required"`
+}
+
+func (params *CertDetailInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type CertAddInput struct {
+	Name     string `json:"name" form:"name" comment:"证书名称" validate:"required"`
+	SniNames string `json:"sni_names" form:"sni_names" comment:"sni域名" example:"www.example.com,*.example.com" validate:"valid_sni_names"` //为空时取证书中的域名
+	CertPem  string `json:"cert_pem" form:"cert_pem" comment:"证书内容" validate:"required"`                                                   //pem格式, 可包含证书链
+	KeyPem   string `json:"key_pem" form:"key_pem" comment:"私钥内容" validate:"required"`                                                     //pem格式
+}
+
+func (params *CertAddInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type CertUpdateInput struct {
+	ID       int64  `json:"id" form:"id" comment:"证书ID" validate:"required"`
+	Name     string `json:"name" form:"name" comment:"证书名称" validate:"required"`
+	SniNames string `json:"sni_names" form:"sni_names" comment:"sni域名" example:"www.example.com,*.example.com" validate:"valid_sni_names"` //为空时取证书中的域名
+	CertPem  string `json:"cert_pem" form:"cert_pem" comment:"证书内容" validate:"required"`                                                   //pem格式, 可包含证书链
+	KeyPem   string `json:"key_pem" form:"key_pem" comment:"私钥内容" validate:""`                                                             //为空时沿用原私钥
+}
+
+func (params *CertUpdateInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type CertExpiringInput struct {
+	Days int `json:"days" form:"days" comment:"剩余天数" validate:"min=0,max=3650"` //为0时使用默认值
+}
+
+func (params *CertExpiringInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
@@ -5,6 +5,9 @@ type PanelGroupDataOutput struct {
 	AppNum          int64 `json:"appNum"`
 	CurrentQPS      int64 `json:"currentQps"`
 	TodayRequestNum int64 `json:"todayRequestNum"`
+
+	ExpiringCertNum int64 `json:"expiringCertNum"` //即将过期或已过期的https证书数
+	AcmeFailNum     int64 `json:"acmeFailNum"`     //acme签发或续期失败的域名数
 }
 
 type DashServiceStatItemOutput struct {
@@ -15,26 +15,71 @@ type ServiceUpdateHTTPInput struct {
 	ServiceDesc string `json:"service_desc" form:"service_desc" comment:"服务描述" example:"test_http_service_indb" validate:"required,max=255,min=1"`     //服务描述
 
 	RuleType       int    `json:"rule_type" form:"rule_type" comment:"接入类型" example:"" validate:"max=1,min=0"`                             //接入类型
-	Rule           string `json:"rule" form:"rule" comment:"接入路径：域名或者前缀" example:"/test_http_service_indb" validate:"required,valid_rule"` //域名或者前缀
+	Rule           string `json:"rule" form:"rule" comment:"接入路径：域名(可带路径前缀, 支持*.example.com)或者前缀" example:"/test_http_service_indb" validate:"required,valid_rule"` //域名或者前缀
 	NeedHttps      int    `json:"need_https" form:"need_https" comment:"支持https" example:"" validate:"max=1,min=0"`                        //支持https
 	NeedStripUri   int    `json:"need_strip_uri" form:"need_strip_uri" comment:"启用strip_uri" example:"" validate:"max=1,min=0"`            //启用strip_uri
 	NeedWebsocket  int    `json:"need_websocket" form:"need_websocket" comment:"是否支持websocket" example:"" validate:"max=1,min=0"`          //是否支持websocket
 	UrlRewrite     string `json:"url_rewrite" form:"url_rewrite" comment:"url重写功能" example:"" validate:"valid_url_rewrite"`                //url重写功能
 	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header转换" example:"" validate:"valid_header_transfor"`   //header转换
 
+	HttpsPolicy           int `json:"https_policy" form:"https_policy" comment:"http请求处理方式" example:"" validate:"max=2,min=0"`                         //开启https后明文http请求的处理 0=均允许 1=跳转https 2=拒绝
+	HttpsRedirectCode     int `json:"https_redirect_code" form:"https_redirect_code" comment:"跳转状态码" example:"301" validate:"oneof=0 301 302 307 308"` //跳转https的状态码, 默认301
+	HstsMaxAge            int `json:"hsts_max_age" form:"hsts_max_age" comment:"HSTS有效期, 单位s" example:"" validate:"min=0"`                             //https响应的HSTS有效期, 0为不返回
+	HstsIncludeSubdomains int `json:"hsts_include_subdomains" form:"hsts_include_subdomains" comment:"HSTS包含子域名" example:"" validate:"max=1,min=0"`    //HSTS是否包含子域名
+	UpstreamScheme        int `json:"upstream_scheme" form:"upstream_scheme" comment:"下游协议" example:"" validate:"max=2,min=0"`                         //下游协议 0=与need_https一致 1=http 2=https
+	UpstreamProtocol      int `json:"upstream_protocol" form:"upstream_protocol" comment:"下游http协议版本" example:"" validate:"max=3,min=0"`               //下游http协议版本 0=自动 1=http/1.1 2=http2(tls) 3=h2c
+
+	GrpcTranscode  int    `json:"grpc_transcode" form:"grpc_transcode" comment:"json转grpc" example:"" validate:"max=2,min=0"` //json转grpc 0=关闭 1=上传描述文件 2=下游server reflection
+	GrpcDescriptor string `json:"grpc_descriptor" form:"grpc_descriptor" comment:"grpc描述文件" example:"" validate:""`           //protobuf描述文件(FileDescriptorSet), base64编码
+
+	LegacyErrorResponse int `json:"legacy_error_response" form:"legacy_error_response" comment:"保留旧错误格式" example:"" validate:"max=1,min=0"` //网关拒绝及下游失败时的响应 0=对应http状态码 1=保留旧格式http 200+errno
+
+	RouteRule string `json:"route_rule" form:"route_rule" comment:"路由规则, 每行一条, 格式: 路径 选项..., 如 /api/v2 method=GET header=X-Env:canary ip_list=127.0.0.1:8001" example:"" validate:"max=5000,valid_http_route_rule"` //服务下的路由规则
+
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限" example:"" validate:"max=1,min=0"`                  //关键词
 	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip" example:"" validate:""`                            //黑名单ip
 	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip" example:"" validate:""`                            //白名单ip
 	ClientipFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端ip限流	" example:"" validate:"min=0"` //客户端ip限流
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" example:"" validate:"min=0"`       //服务端限流
 
-	RoundType              int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"" validate:"max=3,min=0"`                                //轮询方式
-	IpList                 string `json:"ip_list" form:"ip_list" comment:"ip列表" example:"127.0.0.1:80" validate:"required,valid_ipportlist"`            //ip列表
-	WeightList             string `json:"weight_list" form:"weight_list" comment:"权重列表" example:"50" validate:"required,valid_weightlist"`             //权重列表
-	UpstreamConnectTimeout int    `json:"upstream_connect_timeout" form:"upstream_connect_timeout" comment:"建立连接超时, 单位s" example:"" validate:"min=0"`   //建立连接超时, 单位s
-	UpstreamHeaderTimeout  int    `json:"upstream_header_timeout" form:"upstream_header_timeout" comment:"获取header超时, 单位s" example:"" validate:"min=0"` //获取header超时, 单位s
-	UpstreamIdleTimeout    int    `json:"upstream_idle_timeout" form:"upstream_idle_timeout" comment:"链接最大空闲时间, 单位s" example:"" validate:"min=0"`       //链接最大空闲时间, 单位s
-	UpstreamMaxIdle        int    `json:"upstream_max_idle" form:"upstream_max_idle" comment:"最大空闲链接数" example:"" validate:"min=0"`                     //最大空闲链接数
+	RoundType              int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"" validate:"max=5,min=0"`                                      //轮询方式
+	IpList                 string `json:"ip_list" form:"ip_list" comment:"ip列表" example:"127.0.0.1:80" validate:"required,valid_ipportlist"`                  //ip列表
+	WeightList             string `json:"weight_list" form:"weight_list" comment:"权重列表" example:"50" validate:"required,valid_weightlist"`                   //权重列表
+	UpstreamConnectTimeout int    `json:"upstream_connect_timeout" form:"upstream_connect_timeout" comment:"建立连接超时, 单位s" example:"" validate:"min=0"`         //建立连接超时, 单位s
+	UpstreamHeaderTimeout  int    `json:"upstream_header_timeout" form:"upstream_header_timeout" comment:"获取header超时, 单位s" example:"" validate:"min=0"`       //获取header超时, 单位s
+	UpstreamIdleTimeout    int    `json:"upstream_idle_timeout" form:"upstream_idle_timeout" comment:"链接最大空闲时间, 单位s" example:"" validate:"min=0"`             //链接最大空闲时间, 单位s
+	UpstreamMaxIdle        int    `json:"upstream_max_idle" form:"upstream_max_idle" comment:"最大空闲链接数" example:"" validate:"min=0"`                           //最大空闲链接数
+	CheckMethod            int    `json:"check_method" form:"check_method" comment:"健康检查方式" example:"" validate:"max=2,min=0"`                                //健康检查方式 0=tcp 1=http 2=grpc
+	CheckTimeout           int    `json:"check_timeout" form:"check_timeout" comment:"健康检查超时, 单位s" example:"" validate:"min=0"`                               //健康检查超时, 单位s
+	CheckInterval          int    `json:"check_interval" form:"check_interval" comment:"健康检查间隔, 单位s" example:"" validate:"min=0"`                             //健康检查间隔, 单位s
+	CheckPath              string `json:"check_path" form:"check_path" comment:"健康检查路径" example:"/ping" validate:"max=255"`                                   //http检查路径, grpc检查时为服务名
+	CheckHttpMethod        string `json:"check_http_method" form:"check_http_method" comment:"健康检查请求方法" example:"GET" validate:"max=10"`                      //http检查请求方法
+	CheckExpectStatus      string `json:"check_expect_status" form:"check_expect_status" comment:"健康检查期望状态码" example:"200-399" validate:"valid_status_range"` //http检查期望状态码
+	CheckExpectBody        string `json:"check_expect_body" form:"check_expect_body" comment:"健康检查响应体匹配" example:"" validate:"max=255"`                       //http检查响应体需包含的内容
+	CheckRise              int    `json:"check_rise" form:"check_rise" comment:"恢复所需连续成功次数" example:"" validate:"min=0"`                                      //恢复所需连续成功次数
+	CheckFall              int    `json:"check_fall" form:"check_fall" comment:"摘除所需连续失败次数" example:"" validate:"min=0"`                                      //摘除所需连续失败次数
+	RetryMax               int    `json:"retry_max" form:"retry_max" comment:"失败重试次数" example:"" validate:"max=10,min=0"`                                     //失败后换节点重试次数, 0为不重试
+	RetryOn                string `json:"retry_on" form:"retry_on" comment:"重试条件" example:"connect-failure,reset,502,503,504" validate:"valid_retry_on"`      //重试条件
+	RetryNonIdempotent     int    `json:"retry_non_idempotent" form:"retry_non_idempotent" comment:"是否重试非幂等请求" example:"" validate:"max=1,min=0"`             //是否重试非幂等请求
+	RetryPerTryTimeout     int    `json:"retry_per_try_timeout" form:"retry_per_try_timeout" comment:"单次尝试超时, 单位s" example:"" validate:"min=0"`               //单次尝试超时, 单位s
+
+	OpenBreaker             int    `json:"open_breaker" form:"open_breaker" comment:"是否开启熔断" example:"" validate:"max=1,min=0"`                                  //是否开启熔断 1=开启
+	BreakerErrorPercent     int    `json:"breaker_error_percent" form:"breaker_error_percent" comment:"熔断失败率, 百分比" example:"" validate:"max=100,min=0"`          //失败率达到后熔断, 百分比
+	BreakerSlowCallPercent  int    `json:"breaker_slow_call_percent" form:"breaker_slow_call_percent" comment:"熔断慢调用率, 百分比" example:"" validate:"max=100,min=0"` //慢调用率达到后熔断, 百分比
+	BreakerSlowCallDuration int    `json:"breaker_slow_call_duration" form:"breaker_slow_call_duration" comment:"慢调用耗时, 单位ms" example:"" validate:"min=0"`       //超过该耗时视为慢调用, 0为不统计
+	BreakerMinRequest       int    `json:"breaker_min_request" form:"breaker_min_request" comment:"熔断最少请求数" example:"" validate:"min=0"`                         //统计窗口内请求数不足时不熔断
+	BreakerStatWindow       int    `json:"breaker_stat_window" form:"breaker_stat_window" comment:"熔断统计窗口, 单位s" example:"" validate:"min=0"`                     //熔断统计窗口, 单位s
+	BreakerOpenDuration     int    `json:"breaker_open_duration" form:"breaker_open_duration" comment:"熔断持续时间, 单位s" example:"" validate:"min=0"`                 //熔断持续时间, 单位s
+	BreakerHalfOpenRequests int    `json:"breaker_half_open_requests" form:"breaker_half_open_requests" comment:"半开探测请求数" example:"" validate:"min=0"`           //半开状态探测请求数
+	BreakerFallbackStatus   int    `json:"breaker_fallback_status" form:"breaker_fallback_status" comment:"降级响应状态码" example:"" validate:"max=599,min=0"`         //熔断时http降级响应状态码
+	BreakerFallbackBody     string `json:"breaker_fallback_body" form:"breaker_fallback_body" comment:"降级响应内容" example:"" validate:"max=2000"`                   //熔断时降级响应内容
+
+	UpstreamTlsCa         string `json:"upstream_tls_ca" form:"upstream_tls_ca" comment:"下游ca证书" example:"" validate:"max=20000"`                 //校验下游证书的ca, pem格式, 为空时使用系统根证书
+	UpstreamTlsCert       string `json:"upstream_tls_cert" form:"upstream_tls_cert" comment:"下游客户端证书" example:"" validate:"max=20000"`            //连接下游的客户端证书, pem格式
+	UpstreamTlsKey        string `json:"upstream_tls_key" form:"upstream_tls_key" comment:"下游客户端私钥" example:"" validate:"max=20000"`              //连接下游的客户端私钥, pem格式
+	UpstreamTlsServerName string `json:"upstream_tls_server_name" form:"upstream_tls_server_name" comment:"下游证书域名" example:"" validate:"max=255"` //校验下游证书的域名, 为空时取下游地址
+	UpstreamTlsInsecure   int    `json:"upstream_tls_insecure" form:"upstream_tls_insecure" comment:"不校验下游证书" example:"" validate:"max=1,min=0"`  //不校验下游证书 1=不校验
+	ClientCertAuth        int    `json:"client_cert_auth" form:"client_cert_auth" comment:"客户端证书认证" example:"" validate:"max=1,min=0"`            //客户端证书认证 1=开启
 }
 
 func (param *ServiceAddHTTPInput) BindValidParam(c *gin.Context) error {
@@ -46,26 +91,71 @@ type ServiceAddHTTPInput struct {
 	ServiceDesc string `json:"service_desc" form:"service_desc" comment:"服务描述" example:"" validate:"required,max=255,min=1"`     //服务描述
 
 	RuleType       int    `json:"rule_type" form:"rule_type" comment:"接入类型" example:"" validate:"max=1,min=0"`                           //接入类型
-	Rule           string `json:"rule" form:"rule" comment:"接入路径：域名或者前缀" example:"" validate:"required,valid_rule"`                      //域名或者前缀
+	Rule           string `json:"rule" form:"rule" comment:"接入路径：域名(可带路径前缀, 支持*.example.com)或者前缀" example:"" validate:"required,valid_rule"`                      //域名或者前缀
 	NeedHttps      int    `json:"need_https" form:"need_https" comment:"支持https" example:"" validate:"max=1,min=0"`                      //支持https
 	NeedStripUri   int    `json:"need_strip_uri" form:"need_strip_uri" comment:"启用strip_uri" example:"" validate:"max=1,min=0"`          //启用strip_uri
 	NeedWebsocket  int    `json:"need_websocket" form:"need_websocket" comment:"是否支持websocket" example:"" validate:"max=1,min=0"`        //是否支持websocket
 	UrlRewrite     string `json:"url_rewrite" form:"url_rewrite" comment:"url重写功能" example:"" validate:"valid_url_rewrite"`              //url重写功能
 	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header转换" example:"" validate:"valid_header_transfor"` //header转换
 
+	HttpsPolicy           int `json:"https_policy" form:"https_policy" comment:"http请求处理方式" example:"" validate:"max=2,min=0"`                         //开启https后明文http请求的处理 0=均允许 1=跳转https 2=拒绝
+	HttpsRedirectCode     int `json:"https_redirect_code" form:"https_redirect_code" comment:"跳转状态码" example:"301" validate:"oneof=0 301 302 307 308"` //跳转https的状态码, 默认301
+	HstsMaxAge            int `json:"hsts_max_age" form:"hsts_max_age" comment:"HSTS有效期, 单位s" example:"" validate:"min=0"`                             //https响应的HSTS有效期, 0为不返回
+	HstsIncludeSubdomains int `json:"hsts_include_subdomains" form:"hsts_include_subdomains" comment:"HSTS包含子域名" example:"" validate:"max=1,min=0"`    //HSTS是否包含子域名
+	UpstreamScheme        int `json:"upstream_scheme" form:"upstream_scheme" comment:"下游协议" example:"" validate:"max=2,min=0"`                         //下游协议 0=与need_https一致 1=http 2=https
+	UpstreamProtocol      int `json:"upstream_protocol" form:"upstream_protocol" comment:"下游http协议版本" example:"" validate:"max=3,min=0"`               //下游http协议版本 0=自动 1=http/1.1 2=http2(tls) 3=h2c
+
+	GrpcTranscode  int    `json:"grpc_transcode" form:"grpc_transcode" comment:"json转grpc" example:"" validate:"max=2,min=0"` //json转grpc 0=关闭 1=上传描述文件 2=下游server reflection
+	GrpcDescriptor string `json:"grpc_descriptor" form:"grpc_descriptor" comment:"grpc描述文件" example:"" validate:""`           //protobuf描述文件(FileDescriptorSet), base64编码
+
+	LegacyErrorResponse int `json:"legacy_error_response" form:"legacy_error_response" comment:"保留旧错误格式" example:"" validate:"max=1,min=0"` //网关拒绝及下游失败时的响应 0=对应http状态码 1=保留旧格式http 200+errno
+
+	RouteRule string `json:"route_rule" form:"route_rule" comment:"路由规则, 每行一条, 格式: 路径 选项..., 如 /api/v2 method=GET header=X-Env:canary ip_list=127.0.0.1:8001" example:"" validate:"max=5000,valid_http_route_rule"` //服务下的路由规则
+
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限" example:"" validate:"max=1,min=0"`                  //关键词
 	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单ip" example:"" validate:""`                            //黑名单ip
 	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单ip" example:"" validate:""`                            //白名单ip
 	ClientipFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端ip限流	" example:"" validate:"min=0"` //客户端ip限流
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" example:"" validate:"min=0"`       //服务端限流
 
-	RoundType              int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"" validate:"max=3,min=0"`                                //轮询方式
-	IpList                 string `json:"ip_list" form:"ip_list" comment:"ip列表" example:"" validate:"required,valid_ipportlist"`                        //ip列表
-	WeightList             string `json:"weight_list" form:"weight_list" comment:"权重列表" example:"" validate:"required,valid_weightlist"`               //权重列表
-	UpstreamConnectTimeout int    `json:"upstream_connect_timeout" form:"upstream_connect_timeout" comment:"建立连接超时, 单位s" example:"" validate:"min=0"`   //建立连接超时, 单位s
-	UpstreamHeaderTimeout  int    `json:"upstream_header_timeout" form:"upstream_header_timeout" comment:"获取header超时, 单位s" example:"" validate:"min=0"` //获取header超时, 单位s
-	UpstreamIdleTimeout    int    `json:"upstream_idle_timeout" form:"upstream_idle_timeout" comment:"链接最大空闲时间, 单位s" example:"" validate:"min=0"`       //链接最大空闲时间, 单位s
-	UpstreamMaxIdle        int    `json:"upstream_max_idle" form:"upstream_max_idle" comment:"最大空闲链接数" example:"" validate:"min=0"`                     //最大空闲链接数
+	RoundType              int    `json:"round_type" form:"round_type" comment:"轮询方式" example:"" validate:"max=5,min=0"`                                      //轮询方式
+	IpList                 string `json:"ip_list" form:"ip_list" comment:"ip列表" example:"" validate:"required,valid_ipportlist"`                              //ip列表
+	WeightList             string `json:"weight_list" form:"weight_list" comment:"权重列表" example:"" validate:"required,valid_weightlist"`                     //权重列表
+	UpstreamConnectTimeout int    `json:"upstream_connect_timeout" form:"upstream_connect_timeout" comment:"建立连接超时, 单位s" example:"" validate:"min=0"`         //建立连接超时, 单位s
+	UpstreamHeaderTimeout  int    `json:"upstream_header_timeout" form:"upstream_header_timeout" comment:"获取header超时, 单位s" example:"" validate:"min=0"`       //获取header超时, 单位s
+	UpstreamIdleTimeout    int    `json:"upstream_idle_timeout" form:"upstream_idle_timeout" comment:"链接最大空闲时间, 单位s" example:"" validate:"min=0"`             //链接最大空闲时间, 单位s
+	UpstreamMaxIdle        int    `json:"upstream_max_idle" form:"upstream_max_idle" comment:"最大空闲链接数" example:"" validate:"min=0"`                           //最大空闲链接数
+	CheckMethod            int    `json:"check_method" form:"check_method" comment:"健康检查方式" example:"" validate:"max=2,min=0"`                                //健康检查方式 0=tcp 1=http 2=grpc
+	CheckTimeout           int    `json:"check_timeout" form:"check_timeout" comment:"健康检查超时, 单位s" example:"" validate:"min=0"`                               //健康检查超时, 单位s
+	CheckInterval          int    `json:"check_interval" form:"check_interval" comment:"健康检查间隔, 单位s" example:"" validate:"min=0"`                             //健康检查间隔, 单位s
+	CheckPath              string `json:"check_path" form:"check_path" comment:"健康检查路径" example:"/ping" validate:"max=255"`                                   //http检查路径, grpc检查时为服务名
+	CheckHttpMethod        string `json:"check_http_method" form:"check_http_method" comment:"健康检查请求方法" example:"GET" validate:"max=10"`                      //http检查请求方法
+	CheckExpectStatus      string `json:"check_expect_status" form:"check_expect_status" comment:"健康检查期望状态码" example:"200-399" validate:"valid_status_range"` //http检查期望状态码
+	CheckExpectBody        string `json:"check_expect_body" form:"check_expect_body" comment:"健康检查响应体匹配" example:"" validate:"max=255"`                       //http检查响应体需包含的内容
+	CheckRise              int    `json:"check_rise" form:"check_rise" comment:"恢复所需连续成功次数" example:"" validate:"min=0"`                                      //恢复所需连续成功次数
+	CheckFall              int    `json:"check_fall" form:"check_fall" comment:"摘除所需连续失败次数" example:"" validate:"min=0"`                                      //摘除所需连续失败次数
+	RetryMax               int    `json:"retry_max" form:"retry_max" comment:"失败重试次数" example:"" validate:"max=10,min=0"`                                     //失败后换节点重试次数, 0为不重试
+	RetryOn                string `json:"retry_on" form:"retry_on" comment:"重试条件" example:"connect-failure,reset,502,503,504" validate:"valid_retry_on"`      //重试条件
+	RetryNonIdempotent     int    `json:"retry_non_idempotent" form:"retry_non_idempotent" comment:"是否重试非幂等请求" example:"" validate:"max=1,min=0"`             //是否重试非幂等请求
+	RetryPerTryTimeout     int    `json:"retry_per_try_timeout" form:"retry_per_try_timeout" comment:"单次尝试超时, 单位s" example:"" validate:"min=0"`               //单次尝试超时, 单位s
+
+	OpenBreaker             int    `json:"open_breaker" form:"open_breaker" comment:"是否开启熔断" example:"" validate:"max=1,min=0"`                                  //是否开启熔断 1=开启
+	BreakerErrorPercent     int    `json:"breaker_error_percent" form:"breaker_error_percent" comment:"熔断失败率, 百分比" example:"" validate:"max=100,min=0"`          //失败率达到后熔断, 百分比
+	BreakerSlowCallPercent  int    `json:"breaker_slow_call_percent" form:"breaker_slow_call_percent" comment:"熔断慢调用率, 百分比" example:"" validate:"max=100,min=0"` //慢调用率达到后熔断, 百分比
+	BreakerSlowCallDuration int    `json:"breaker_slow_call_duration" form:"breaker_slow_call_duration" comment:"慢调用耗时, 单位ms" example:"" validate:"min=0"`       //超过该耗时视为慢调用, 0为不统计
+	BreakerMinRequest       int    `json:"breaker_min_request" form:"breaker_min_request" comment:"熔断最少请求数" example:"" validate:"min=0"`                         //统计窗口内请求数不足时不熔断
+	BreakerStatWindow       int    `json:"breaker_stat_window" form:"breaker_stat_window" comment:"熔断统计窗口, 单位s" example:"" validate:"min=0"`                     //熔断统计窗口, 单位s
+	BreakerOpenDuration     int    `json:"breaker_open_duration" form:"breaker_open_duration" comment:"熔断持续时间, 单位s" example:"" validate:"min=0"`                 //熔断持续时间, 单位s
+	BreakerHalfOpenRequests int    `json:"breaker_half_open_requests" form:"breaker_half_open_requests" comment:"半开探测请求数" example:"" validate:"min=0"`           //半开状态探测请求数
+	BreakerFallbackStatus   int    `json:"breaker_fallback_status" form:"breaker_fallback_status" comment:"降级响应状态码" example:"" validate:"max=599,min=0"`         //熔断时http降级响应状态码
+	BreakerFallbackBody     string `json:"breaker_fallback_body" form:"breaker_fallback_body" comment:"降级响应内容" example:"" validate:"max=2000"`                   //熔断时降级响应内容
+
+	UpstreamTlsCa         string `json:"upstream_tls_ca" form:"upstream_tls_ca" comment:"下游ca证书" example:"" validate:"max=20000"`                 //校验下游证书的ca, pem格式, 为空时使用系统根证书
+	UpstreamTlsCert       string `json:"upstream_tls_cert" form:"upstream_tls_cert" comment:"下游客户端证书" example:"" validate:"max=20000"`            //连接下游的客户端证书, pem格式
+	UpstreamTlsKey        string `json:"upstream_tls_key" form:"upstream_tls_key" comment:"下游客户端私钥" example:"" validate:"max=20000"`              //连接下游的客户端私钥, pem格式
+	UpstreamTlsServerName string `json:"upstream_tls_server_name" form:"upstream_tls_server_name" comment:"下游证书域名" example:"" validate:"max=255"` //校验下游证书的域名, 为空时取下游地址
+	UpstreamTlsInsecure   int    `json:"upstream_tls_insecure" form:"upstream_tls_insecure" comment:"不校验下游证书" example:"" validate:"max=1,min=0"`  //不校验下游证书 1=不校验
+	ClientCertAuth        int    `json:"client_cert_auth" form:"client_cert_auth" comment:"客户端证书认证" example:"" validate:"max=1,min=0"`            //客户端证书认证 1=开启
 }
 
 type ServiceDeleteInput struct {
@@ -105,6 +195,15 @@ type ServiceListOutput struct {
 type ServiceStatOutput struct {
 	Today     []int64 `json:"today" form:"today" comment:"今日流量" example:"" validate:""`         //列表
 	Yesterday []int64 `json:"yesterday" form:"yesterday" comment:"昨日流量" example:"" validate:""` //列表
+
+	TodayRetry     []int64 `json:"today_retry" form:"today_retry" comment:"今日重试次数" example:"" validate:""`         //列表
+	YesterdayRetry []int64 `json:"yesterday_retry" form:"yesterday_retry" comment:"昨日重试次数" example:"" validate:""` //列表
+
+	TodayWebsocket     []int64 `json:"today_websocket" form:"today_websocket" comment:"今日websocket连接数" example:"" validate:""`         //列表
+	YesterdayWebsocket []int64 `json:"yesterday_websocket" form:"yesterday_websocket" comment:"昨日websocket连接数" example:"" validate:""` //列表
+
+	CircuitBreaker      []public.CircuitBreakerStat  `json:"circuit_breaker" form:"circuit_breaker" comment:"熔断状态" example:"" validate:""`               //服务及下游节点熔断状态
+	CircuitBreakerEvent []public.CircuitBreakerEvent `json:"circuit_breaker_event" form:"circuit_breaker_event" comment:"熔断状态变更" example:"" validate:""` //最近的熔断状态变更
 }
 
 type ServiceAddGrpcInput struct {
@@ -118,10 +217,43 @@ type ServiceAddGrpcInput struct {
 	WhiteHostName     string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
-	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:"max=5,min=0"`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
 	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	CheckMethod       int    `json:"check_method" form:"check_method" comment:"健康检查方式 0=tcp 1=http 2=grpc" validate:"max=2,min=0"`
+	CheckTimeout      int    `json:"check_timeout" form:"check_timeout" comment:"健康检查超时, 单位s" validate:"min=0"`
+	CheckInterval     int    `json:"check_interval" form:"check_interval" comment:"健康检查间隔, 单位s" validate:"min=0"`
+	CheckPath         string `json:"check_path" form:"check_path" comment:"健康检查路径，grpc检查时为服务名" validate:"max=255"`
+	CheckHttpMethod   string `json:"check_http_method" form:"check_http_method" comment:"健康检查请求方法" validate:"max=10"`
+	CheckExpectStatus string `json:"check_expect_status" form:"check_expect_status" comment:"健康检查期望状态码，如200-399" validate:"valid_status_range"`
+	CheckExpectBody   string `json:"check_expect_body" form:"check_expect_body" comment:"健康检查响应体匹配" validate:"max=255"`
+	CheckRise         int    `json:"check_rise" form:"check_rise" comment:"恢复所需连续成功次数" validate:"min=0"`
+	CheckFall         int    `json:"check_fall" form:"check_fall" comment:"摘除所需连续失败次数" validate:"min=0"`
+
+	OpenBreaker             int    `json:"open_breaker" form:"open_breaker" comment:"是否开启熔断" validate:"max=1,min=0"`
+	BreakerErrorPercent     int    `json:"breaker_error_percent" form:"breaker_error_percent" comment:"熔断失败率, 百分比" validate:"max=100,min=0"`
+	BreakerSlowCallPercent  int    `json:"breaker_slow_call_percent" form:"breaker_slow_call_percent" comment:"熔断慢调用率, 百分比" validate:"max=100,min=0"`
+	BreakerSlowCallDuration int    `json:"breaker_slow_call_duration" form:"breaker_slow_call_duration" comment:"慢调用耗时, 单位ms" validate:"min=0"`
+	BreakerMinRequest       int    `json:"breaker_min_request" form:"breaker_min_request" comment:"熔断最少请求数" validate:"min=0"`
+	BreakerStatWindow       int    `json:"breaker_stat_window" form:"breaker_stat_window" comment:"熔断统计窗口, 单位s" validate:"min=0"`
+	BreakerOpenDuration     int    `json:"breaker_open_duration" form:"breaker_open_duration" comment:"熔断持续时间, 单位s" validate:"min=0"`
+	BreakerHalfOpenRequests int    `json:"breaker_half_open_requests" form:"breaker_half_open_requests" comment:"半开探测请求数" validate:"min=0"`
+	BreakerFallbackStatus   int    `json:"breaker_fallback_status" form:"breaker_fallback_status" comment:"降级响应状态码" validate:"max=599,min=0"`
+	BreakerFallbackBody     string `json:"breaker_fallback_body" form:"breaker_fallback_body" comment:"降级响应内容" validate:"max=2000"`
+
+	UpstreamTlsCa         string `json:"upstream_tls_ca" form:"upstream_tls_ca" comment:"校验下游证书的ca, pem格式, 设置任一下游tls参数即以tls连接下游" validate:"max=20000"`
+	UpstreamTlsCert       string `json:"upstream_tls_cert" form:"upstream_tls_cert" comment:"连接下游的客户端证书, pem格式" validate:"max=20000"`
+	UpstreamTlsKey        string `json:"upstream_tls_key" form:"upstream_tls_key" comment:"连接下游的客户端私钥, pem格式" validate:"max=20000"`
+	UpstreamTlsServerName string `json:"upstream_tls_server_name" form:"upstream_tls_server_name" comment:"校验下游证书的域名, 为空时取下游地址" validate:"max=255"`
+	UpstreamTlsInsecure   int    `json:"upstream_tls_insecure" form:"upstream_tls_insecure" comment:"不校验下游证书 1=不校验" validate:"max=1,min=0"`
+	ClientCertAuth        int    `json:"client_cert_auth" form:"client_cert_auth" comment:"客户端证书认证 1=开启, 监听端使用tls并要求客户端证书" validate:"max=1,min=0"`
+
+	GrpcWeb        int    `json:"grpc_web" form:"grpc_web" comment:"接入grpc-web 1=开启, 服务端口同时接受grpc-web请求" validate:"max=1,min=0"`
+	GrpcWebPrefix  string `json:"grpc_web_prefix" form:"grpc_web_prefix" comment:"通过http端口接入grpc-web的路径前缀, 为空时只通过服务端口接入" validate:"valid_grpc_web_prefix"`
+	GrpcWebOrigins string `json:"grpc_web_origins" form:"grpc_web_origins" comment:"grpc-web允许跨域的来源, 多个逗号间隔, *为允许全部来源, 为空时只允许同源" validate:"max=1000"`
+
+	MethodRule string `json:"method_rule" form:"method_rule" comment:"方法级规则, 多条逗号间隔, 格式: 方法 选项..., 如 /pkg.Service/* allow qps=100 timeout=2s" validate:"max=5000,valid_grpc_method_rule"`
 }
 
 func (params *ServiceAddGrpcInput) GetValidParams(c *gin.Context) error {
@@ -140,10 +272,43 @@ type ServiceUpdateGrpcInput struct {
 	WhiteHostName     string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
-	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:"max=5,min=0"`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
 	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	CheckMethod       int    `json:"check_method" form:"check_method" comment:"健康检查方式 0=tcp 1=http 2=grpc" validate:"max=2,min=0"`
+	CheckTimeout      int    `json:"check_timeout" form:"check_timeout" comment:"健康检查超时, 单位s" validate:"min=0"`
+	CheckInterval     int    `json:"check_interval" form:"check_interval" comment:"健康检查间隔, 单位s" validate:"min=0"`
+	CheckPath         string `json:"check_path" form:"check_path" comment:"健康检查路径，grpc检查时为服务名" validate:"max=255"`
+	CheckHttpMethod   string `json:"check_http_method" form:"check_http_method" comment:"健康检查请求方法" validate:"max=10"`
+	CheckExpectStatus string `json:"check_expect_status" form:"check_expect_status" comment:"健康检查期望状态码，如200-399" validate:"valid_status_range"`
+	CheckExpectBody   string `json:"check_expect_body" form:"check_expect_body" comment:"健康检查响应体匹配" validate:"max=255"`
+	CheckRise         int    `json:"check_rise" form:"check_rise" comment:"恢复所需连续成功次数" validate:"min=0"`
+	CheckFall         int    `json:"check_fall" form:"check_fall" comment:"摘除所需连续失败次数" validate:"min=0"`
+
+	OpenBreaker             int    `json:"open_breaker" form:"open_breaker" comment:"是否开启熔断" validate:"max=1,min=0"`
+	BreakerErrorPercent     int    `json:"breaker_error_percent" form:"breaker_error_percent" comment:"熔断失败率, 百分比" validate:"max=100,min=0"`
+	BreakerSlowCallPercent  int    `json:"breaker_slow_call_percent" form:"breaker_slow_call_percent" comment:"熔断慢调用率, 百分比" validate:"max=100,min=0"`
+	BreakerSlowCallDuration int    `json:"breaker_slow_call_duration" form:"breaker_slow_call_duration" comment:"慢调用耗时, 单位ms" validate:"min=0"`
+	BreakerMinRequest       int    `json:"breaker_min_request" form:"breaker_min_request" comment:"熔断最少请求数" validate:"min=0"`
+	BreakerStatWindow       int    `json:"breaker_stat_window" form:"breaker_stat_window" comment:"熔断统计窗口, 单位s" validate:"min=0"`
+	BreakerOpenDuration     int    `json:"breaker_open_duration" form:"breaker_open_duration" comment:"熔断持续时间, 单位s" validate:"min=0"`
+	BreakerHalfOpenRequests int    `json:"breaker_half_open_requests" form:"breaker_half_open_requests" comment:"半开探测请求数" validate:"min=0"`
+	BreakerFallbackStatus   int    `json:"breaker_fallback_status" form:"breaker_fallback_status" comment:"降级响应状态码" validate:"max=599,min=0"`
+	BreakerFallbackBody     string `json:"breaker_fallback_body" form:"breaker_fallback_body" comment:"降级响应内容" validate:"max=2000"`
+
+	UpstreamTlsCa         string `json:"upstream_tls_ca" form:"upstream_tls_ca" comment:"校验下游证书的ca, pem格式, 设置任一下游tls参数即以tls连接下游" validate:"max=20000"`
+	UpstreamTlsCert       string `json:"upstream_tls_cert" form:"upstream_tls_cert" comment:"连接下游的客户端证书, pem格式" validate:"max=20000"`
+	UpstreamTlsKey        string `json:"upstream_tls_key" form:"upstream_tls_key" comment:"连接下游的客户端私钥, pem格式" validate:"max=20000"`
+	UpstreamTlsServerName string `json:"upstream_tls_server_name" form:"upstream_tls_server_name" comment:"校验下游证书的域名, 为空时取下游地址" validate:"max=255"`
+	UpstreamTlsInsecure   int    `json:"upstream_tls_insecure" form:"upstream_tls_insecure" comment:"不校验下游证书 1=不校验" validate:"max=1,min=0"`
+	ClientCertAuth        int    `json:"client_cert_auth" form:"client_cert_auth" comment:"客户端证书认证 1=开启, 监听端使用tls并要求客户端证书" validate:"max=1,min=0"`
+
+	GrpcWeb        int    `json:"grpc_web" form:"grpc_web" comment:"接入grpc-web 1=开启, 服务端口同时接受grpc-web请求" validate:"max=1,min=0"`
+	GrpcWebPrefix  string `json:"grpc_web_prefix" form:"grpc_web_prefix" comment:"通过http端口接入grpc-web的路径前缀, 为空时只通过服务端口接入" validate:"valid_grpc_web_prefix"`
+	GrpcWebOrigins string `json:"grpc_web_origins" form:"grpc_web_origins" comment:"grpc-web允许跨域的来源, 多个逗号间隔, *为允许全部来源, 为空时只允许同源" validate:"max=1000"`
+
+	MethodRule string `json:"method_rule" form:"method_rule" comment:"方法级规则, 多条逗号间隔, 格式: 方法 选项..., 如 /pkg.Service/* allow qps=100 timeout=2s" validate:"max=5000,valid_grpc_method_rule"`
 }
 
 func (params *ServiceUpdateGrpcInput) GetValidParams(c *gin.Context) error {
@@ -151,21 +316,52 @@ func (params *ServiceUpdateGrpcInput) GetValidParams(c *gin.Context) error {
 }
 
 type ServiceAddTcpInput struct {
-	ServiceName    string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
-	ServiceDesc    string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
-	Port           int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
-	HeaderTransfor string `json:"header_transfor" form:"header_transfor" comment:"header头转换" validate:"
-"`
+	ServiceName       string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
+	ServiceDesc       string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
+	Port              int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内, 只通过共享sni端口接入时为0" validate:"omitempty,min=8001,max=8999"`
+	HeaderTransfor    string `json:"header_transfor" form:"header_transfor" comment:"header头转换" validate:""`
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
 	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
 	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
 	WhiteHostName     string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
-	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:"max=5,min=0"`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
 	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	CheckMethod       int    `json:"check_method" form:"check_method" comment:"健康检查方式 0=tcp 1=http 2=grpc" validate:"max=2,min=0"`
+	CheckTimeout      int    `json:"check_timeout" form:"check_timeout" comment:"健康检查超时, 单位s" validate:"min=0"`
+	CheckInterval     int    `json:"check_interval" form:"check_interval" comment:"健康检查间隔, 单位s" validate:"min=0"`
+	CheckPath         string `json:"check_path" form:"check_path" comment:"健康检查路径，grpc检查时为服务名" validate:"max=255"`
+	CheckHttpMethod   string `json:"check_http_method" form:"check_http_method" comment:"健康检查请求方法" validate:"max=10"`
+	CheckExpectStatus string `json:"check_expect_status" form:"check_expect_status" comment:"健康检查期望状态码，如200-399" validate:"valid_status_range"`
+	CheckExpectBody   string `json:"check_expect_body" form:"check_expect_body" comment:"健康检查响应体匹配" validate:"max=255"`
+	CheckRise         int    `json:"check_rise" form:"check_rise" comment:"恢复所需连续成功次数" validate:"min=0"`
+	CheckFall         int    `json:"check_fall" form:"check_fall" comment:"摘除所需连续失败次数" validate:"min=0"`
+
+	OpenBreaker             int    `json:"open_breaker" form:"open_breaker" comment:"是否开启熔断" validate:"max=1,min=0"`
+	BreakerErrorPercent     int    `json:"breaker_error_percent" form:"breaker_error_percent" comment:"熔断失败率, 百分比" validate:"max=100,min=0"`
+	BreakerSlowCallPercent  int    `json:"breaker_slow_call_percent" form:"breaker_slow_call_percent" comment:"熔断慢调用率, 百分比" validate:"max=100,min=0"`
+	BreakerSlowCallDuration int    `json:"breaker_slow_call_duration" form:"breaker_slow_call_duration" comment:"慢调用耗时, 单位ms" validate:"min=0"`
+	BreakerMinRequest       int    `json:"breaker_min_request" form:"breaker_min_request" comment:"熔断最少请求数" validate:"min=0"`
+	BreakerStatWindow       int    `json:"breaker_stat_window" form:"breaker_stat_window" comment:"熔断统计窗口, 单位s" validate:"min=0"`
+	BreakerOpenDuration     int    `json:"breaker_open_duration" form:"breaker_open_duration" comment:"熔断持续时间, 单位s" validate:"min=0"`
+	BreakerHalfOpenRequests int    `json:"breaker_half_open_requests" form:"breaker_half_open_requests" comment:"半开探测请求数" validate:"min=0"`
+	BreakerFallbackStatus   int    `json:"breaker_fallback_status" form:"breaker_fallback_status" comment:"降级响应状态码" validate:"max=599,min=0"`
+	BreakerFallbackBody     string `json:"breaker_fallback_body" form:"breaker_fallback_body" comment:"降级响应内容" validate:"max=2000"`
+
+	UpstreamTlsCa         string `json:"upstream_tls_ca" form:"upstream_tls_ca" comment:"校验下游证书的ca, pem格式, 设置任一下游tls参数即以tls连接下游" validate:"max=20000"`
+	UpstreamTlsCert       string `json:"upstream_tls_cert" form:"upstream_tls_cert" comment:"连接下游的客户端证书, pem格式" validate:"max=20000"`
+	UpstreamTlsKey        string `json:"upstream_tls_key" form:"upstream_tls_key" comment:"连接下游的客户端私钥, pem格式" validate:"max=20000"`
+	UpstreamTlsServerName string `json:"upstream_tls_server_name" form:"upstream_tls_server_name" comment:"校验下游证书的域名, 为空时取下游地址" validate:"max=255"`
+	UpstreamTlsInsecure   int    `json:"upstream_tls_insecure" form:"upstream_tls_insecure" comment:"不校验下游证书 1=不校验" validate:"max=1,min=0"`
+	ClientCertAuth        int    `json:"client_cert_auth" form:"client_cert_auth" comment:"客户端证书认证 1=开启, 监听端使用tls并要求客户端证书" validate:"max=1,min=0"`
+
+	NeedTls  int    `json:"need_tls" form:"need_tls" comment:"在网关终止tls 1=开启, 证书按sni从证书管理中选择" validate:"max=1,min=0"`
+	SniNames string `json:"sni_names" form:"sni_names" comment:"共享sni端口上匹配的域名, 支持*.开头的通配符, 多个逗号间隔" validate:"valid_sni_names"`
+
+	ProxyProtocol int `json:"proxy_protocol" form:"proxy_protocol" comment:"向下游发送PROXY协议头 0=不发送 1=v1 2=v2" validate:"max=2,min=0"`
 }
 
 func (params *ServiceAddTcpInput) GetValidParams(c *gin.Context) error {
@@ -176,19 +372,110 @@ type ServiceUpdateTcpInput struct {
 	ID                int64  `json:"id" form:"id" comment:"服务ID" validate:"required"`
 	ServiceName       string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
 	ServiceDesc       string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
-	Port              int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
+	Port              int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内, 只通过共享sni端口接入时为0" validate:"omitempty,min=8001,max=8999"`
 	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
 	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
 	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
 	WhiteHostName     string `json:"white_host_name" form:"white_host_name" comment:"白名单主机，以逗号间隔" validate:"valid_iplist"`
 	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流" validate:""`
 	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流" validate:""`
-	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:""`
+	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:"max=5,min=0"`
 	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
 	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
 	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	CheckMethod       int    `json:"check_method" form:"check_method" comment:"健康检查方式 0=tcp 1=http 2=grpc" validate:"max=2,min=0"`
+	CheckTimeout      int    `json:"check_timeout" form:"check_timeout" comment:"健康检查超时, 单位s" validate:"min=0"`
+	CheckInterval     int    `json:"check_interval" form:"check_interval" comment:"健康检查间隔, 单位s" validate:"min=0"`
+	CheckPath         string `json:"check_path" form:"check_path" comment:"健康检查路径，grpc检查时为服务名" validate:"max=255"`
+	CheckHttpMethod   string `json:"check_http_method" form:"check_http_method" comment:"健康检查请求方法" validate:"max=10"`
+	CheckExpectStatus string `json:"check_expect_status" form:"check_expect_status" comment:"健康检查期望状态码，如200-399" validate:"valid_status_range"`
+	CheckExpectBody   string `json:"check_expect_body" form:"check_expect_body" comment:"健康检查响应体匹配" validate:"max=255"`
+	CheckRise         int    `json:"check_rise" form:"check_rise" comment:"恢复所需连续成功次数" validate:"min=0"`
+	CheckFall         int    `json:"check_fall" form:"check_fall" comment:"摘除所需连续失败次数" validate:"min=0"`
+
+	OpenBreaker             int    `json:"open_breaker" form:"open_breaker" comment:"是否开启熔断" validate:"max=1,min=0"`
+	BreakerErrorPercent     int    `json:"breaker_error_percent" form:"breaker_error_percent" comment:"熔断失败率, 百分比" validate:"max=100,min=0"`
+	BreakerSlowCallPercent  int    `json:"breaker_slow_call_percent" form:"breaker_slow_call_percent" comment:"熔断慢调用率, 百分比" validate:"max=100,min=0"`
+	BreakerSlowCallDuration int    `json:"breaker_slow_call_duration" form:"breaker_slow_call_duration" comment:"慢调用耗时, 单位ms" validate:"min=0"`
+	BreakerMinRequest       int    `json:"breaker_min_request" form:"breaker_min_request" comment:"熔断最少请求数" validate:"min=0"`
+	BreakerStatWindow       int    `json:"breaker_stat_window" form:"breaker_stat_window" comment:"熔断统计窗口, 单位s" validate:"min=0"`
+	BreakerOpenDuration     int    `json:"breaker_open_duration" form:"breaker_open_duration" comment:"熔断持续时间, 单位s" validate:"min=0"`
+	BreakerHalfOpenRequests int    `json:"breaker_half_open_requests" form:"breaker_half_open_requests" comment:"半开探测请求数" validate:"min=0"`
+	BreakerFallbackStatus   int    `json:"breaker_fallback_status" form:"breaker_fallback_status" comment:"降级响应状态码" validate:"max=599,min=0"`
+	BreakerFallbackBody     string `json:"breaker_fallback_body" form:"breaker_fallback_body" comment:"降级响应内容" validate:"max=2000"`
+
+	UpstreamTlsCa         string `json:"upstream_tls_ca" form:"upstream_tls_ca" comment:"校验下游证书的ca, pem格式, 设置任一下游tls参数即以tls连接下游" validate:"max=20000"`
+	UpstreamTlsCert       string `json:"upstream_tls_cert" form:"upstream_tls_cert" comment:"连接下游的客户端证书, pem格式" validate:"max=20000"`
+	UpstreamTlsKey        string `json:"upstream_tls_key" form:"upstream_tls_key" comment:"连接下游的客户端私钥, pem格式" validate:"max=20000"`
+	UpstreamTlsServerName string `json:"upstream_tls_server_name" form:"upstream_tls_server_name" comment:"校验下游证书的域名, 为空时取下游地址" validate:"max=255"`
+	UpstreamTlsInsecure   int    `json:"upstream_tls_insecure" form:"upstream_tls_insecure" comment:"不校验下游证书 1=不校验" validate:"max=1,min=0"`
+	ClientCertAuth        int    `json:"client_cert_auth" form:"client_cert_auth" comment:"客户端证书认证 1=开启, 监听端使用tls并要求客户端证书" validate:"max=1,min=0"`
+
+	NeedTls  int    `json:"need_tls" form:"need_tls" comment:"在网关终止tls 1=开启, 证书按sni从证书管理中选择" validate:"max=1,min=0"`
+	SniNames string `json:"sni_names" form:"sni_names" comment:"共享sni端口上匹配的域名, 支持*.开头的通配符, 多个逗号间隔" validate:"valid_sni_names"`
+
+	ProxyProtocol int `json:"proxy_protocol" form:"proxy_protocol" comment:"向下游发送PROXY协议头 0=不发送 1=v1 2=v2" validate:"max=2,min=0"`
 }
 
 func (params *ServiceUpdateTcpInput) GetValidParams(c *gin.Context) error {
 	return public.DefaultGetValidParams(c, params)
 }
+
+type ServiceAddUdpInput struct {
+	ServiceName       string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
+	ServiceDesc       string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
+	Port              int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
+	IdleTimeout       int    `json:"idle_timeout" form:"idle_timeout" comment:"会话空闲超时, 单位s, 0为使用全局配置" validate:"min=0"`
+	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
+	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流, 每秒新建会话数" validate:""`
+	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流, 每秒新建会话数" validate:""`
+	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:"max=5,min=0"`
+	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
+	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
+	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	CheckMethod       int    `json:"check_method" form:"check_method" comment:"健康检查方式 0=udp 1=http 2=grpc" validate:"max=2,min=0"`
+	CheckTimeout      int    `json:"check_timeout" form:"check_timeout" comment:"健康检查超时, 单位s" validate:"min=0"`
+	CheckInterval     int    `json:"check_interval" form:"check_interval" comment:"健康检查间隔, 单位s" validate:"min=0"`
+	CheckPath         string `json:"check_path" form:"check_path" comment:"健康检查路径，grpc检查时为服务名" validate:"max=255"`
+	CheckHttpMethod   string `json:"check_http_method" form:"check_http_method" comment:"健康检查请求方法" validate:"max=10"`
+	CheckExpectStatus string `json:"check_expect_status" form:"check_expect_status" comment:"健康检查期望状态码，如200-399" validate:"valid_status_range"`
+	CheckExpectBody   string `json:"check_expect_body" form:"check_expect_body" comment:"健康检查响应体匹配" validate:"max=255"`
+	CheckRise         int    `json:"check_rise" form:"check_rise" comment:"恢复所需连续成功次数" validate:"min=0"`
+	CheckFall         int    `json:"check_fall" form:"check_fall" comment:"摘除所需连续失败次数" validate:"min=0"`
+}
+
+func (params *ServiceAddUdpInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
+
+type ServiceUpdateUdpInput struct {
+	ID                int64  `json:"id" form:"id" comment:"服务ID" validate:"required"`
+	ServiceName       string `json:"service_name" form:"service_name" comment:"服务名称" validate:"required,valid_service_name"`
+	ServiceDesc       string `json:"service_desc" form:"service_desc" comment:"服务描述" validate:"required"`
+	Port              int    `json:"port" form:"port" comment:"端口，需要设置8001-8999范围内" validate:"required,min=8001,max=8999"`
+	IdleTimeout       int    `json:"idle_timeout" form:"idle_timeout" comment:"会话空闲超时, 单位s, 0为使用全局配置" validate:"min=0"`
+	OpenAuth          int    `json:"open_auth" form:"open_auth" comment:"是否开启权限验证" validate:""`
+	BlackList         string `json:"black_list" form:"black_list" comment:"黑名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	WhiteList         string `json:"white_list" form:"white_list" comment:"白名单IP，以逗号间隔，白名单优先级高于黑名单" validate:"valid_iplist"`
+	ClientIPFlowLimit int    `json:"clientip_flow_limit" form:"clientip_flow_limit" comment:"客户端IP限流, 每秒新建会话数" validate:""`
+	ServiceFlowLimit  int    `json:"service_flow_limit" form:"service_flow_limit" comment:"服务端限流, 每秒新建会话数" validate:""`
+	RoundType         int    `json:"round_type" form:"round_type" comment:"轮询策略" validate:"max=5,min=0"`
+	IpList            string `json:"ip_list" form:"ip_list" comment:"IP列表" validate:"required,valid_ipportlist"`
+	WeightList        string `json:"weight_list" form:"weight_list" comment:"权重列表" validate:"required,valid_weightlist"`
+	ForbidList        string `json:"forbid_list" form:"forbid_list" comment:"禁用IP列表" validate:"valid_iplist"`
+	CheckMethod       int    `json:"check_method" form:"check_method" comment:"健康检查方式 0=udp 1=http 2=grpc" validate:"max=2,min=0"`
+	CheckTimeout      int    `json:"check_timeout" form:"check_timeout" comment:"健康检查超时, 单位s" validate:"min=0"`
+	CheckInterval     int    `json:"check_interval" form:"check_interval" comment:"健康检查间隔, 单位s" validate:"min=0"`
+	CheckPath         string `json:"check_path" form:"check_path" comment:"健康检查路径，grpc检查时为服务名" validate:"max=255"`
+	CheckHttpMethod   string `json:"check_http_method" form:"check_http_method" comment:"健康检查请求方法" validate:"max=10"`
+	CheckExpectStatus string `json:"check_expect_status" form:"check_expect_status" comment:"健康检查期望状态码，如200-399" validate:"valid_status_range"`
+	CheckExpectBody   string `json:"check_expect_body" form:"check_expect_body" comment:"健康检查响应体匹配" validate:"max=255"`
+	CheckRise         int    `json:"check_rise" form:"check_rise" comment:"恢复所需连续成功次数" validate:"min=0"`
+	CheckFall         int    `json:"check_fall" form:"check_fall" comment:"摘除所需连续失败次数" validate:"min=0"`
+}
+
+func (params *ServiceUpdateUdpInput) GetValidParams(c *gin.Context) error {
+	return public.DefaultGetValidParams(c, params)
+}
@@ -61,7 +61,8 @@ CREATE TABLE `gateway_app` (
   `qps` bigint(20) NOT NULL DEFAULT '0' COMMENT '每秒请求量限制',
   `create_at` datetime NOT NULL COMMENT '添加时间',
   `update_at` datetime NOT NULL COMMENT '更新时间',
-  `is_delete` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否删除 1=删除'
+  `is_delete` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否删除 1=删除',
+  `cert_subject` varchar(255) NOT NULL DEFAULT '' COMMENT '客户端证书主体, 匹配证书CN或SAN, 为空时以app_id匹配CN'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关租户表';
 
 --
@@ -76,6 +77,26 @@ INSERT INTO `gateway_app` (`id`, `app_id`, `name`, `secret`, `white_ips`, `qpd`,
 
 -- --------------------------------------------------------
 
+--
+-- 表的结构 `gateway_cert`
+--
+
+CREATE TABLE `gateway_cert` (
+  `id` bigint(20) UNSIGNED NOT NULL COMMENT '自增id',
+  `name` varchar(255) NOT NULL DEFAULT '' COMMENT '证书名称',
+  `sni_names` varchar(1000) NOT NULL DEFAULT '' COMMENT '匹配的sni域名, 支持*.开头的通配符, 多个逗号间隔',
+  `cert_pem` text NOT NULL COMMENT '证书内容, pem格式, 可包含证书链',
+  `key_pem` text NOT NULL COMMENT '私钥内容, pem格式',
+  `not_before` datetime NOT NULL COMMENT '证书生效时间',
+  `not_after` datetime NOT NULL COMMENT '证书过期时间',
+  `source` tinyint(4) NOT NULL DEFAULT '0' COMMENT '证书来源 0=手动上传 1=acme自动签发',
+  `create_at` datetime NOT NULL COMMENT '添加时间',
+  `update_at` datetime NOT NULL COMMENT '更新时间',
+  `is_delete` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否删除 1=删除'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关https证书表';
+
+-- --------------------------------------------------------
+
 --
 -- 表的结构 `gateway_service_access_control`
 --
@@ -88,7 +109,8 @@ CREATE TABLE `gateway_service_access_control` (
   `white_list` varchar(1000) NOT NULL DEFAULT '' COMMENT '白名单ip',
   `white_host_name` varchar(1000) NOT NULL DEFAULT '' COMMENT '白名单主机',
   `clientip_flow_limit` int(11) NOT NULL DEFAULT '0' COMMENT '客户端ip限流',
-  `service_flow_limit` int(20) NOT NULL DEFAULT '0' COMMENT '服务端限流'
+  `service_flow_limit` int(20) NOT NULL DEFAULT '0' COMMENT '服务端限流',
+  `client_cert_auth` tinyint(4) NOT NULL DEFAULT '0' COMMENT '客户端证书认证 1=开启, 证书须由mtls.client_ca_file签发'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关权限控制表';
 
 --
@@ -124,6 +146,27 @@ INSERT INTO `gateway_service_access_control` (`id`, `service_id`, `open_auth`, `
 
 -- --------------------------------------------------------
 
+--
+-- 表的结构 `gateway_service_circuit_breaker`
+--
+
+CREATE TABLE `gateway_service_circuit_breaker` (
+  `id` bigint(20) NOT NULL COMMENT '自增主键',
+  `service_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '服务id',
+  `open_breaker` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否开启熔断 1=开启',
+  `error_percent` int(11) NOT NULL DEFAULT '0' COMMENT '统计窗口内失败率达到后熔断, 百分比, 默认50',
+  `slow_call_percent` int(11) NOT NULL DEFAULT '0' COMMENT '统计窗口内慢调用率达到后熔断, 百分比, 默认50',
+  `slow_call_duration` int(11) NOT NULL DEFAULT '0' COMMENT '超过该耗时视为慢调用, 单位ms, 0为不统计慢调用',
+  `min_request` int(11) NOT NULL DEFAULT '0' COMMENT '统计窗口内请求数不足时不熔断, 默认20',
+  `stat_window` int(11) NOT NULL DEFAULT '0' COMMENT '统计窗口, 单位s, 默认10',
+  `open_duration` int(11) NOT NULL DEFAULT '0' COMMENT '熔断持续时间, 之后进入半开, 单位s, 默认30',
+  `half_open_requests` int(11) NOT NULL DEFAULT '0' COMMENT '半开状态放行的探测请求数, 默认3',
+  `fallback_status` int(11) NOT NULL DEFAULT '0' COMMENT '熔断时http降级响应状态码, 默认503',
+  `fallback_body` varchar(2000) NOT NULL DEFAULT '' COMMENT '熔断时降级响应内容'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关熔断表';
+
+-- --------------------------------------------------------
+
 --
 -- 表的结构 `gateway_service_grpc_rule`
 --
@@ -132,7 +175,11 @@ CREATE TABLE `gateway_service_grpc_rule` (
   `id` bigint(20) NOT NULL COMMENT '自增主键',
   `service_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '服务id',
   `port` int(5) NOT NULL DEFAULT '0' COMMENT '端口',
-  `header_transfor` varchar(5000) NOT NULL DEFAULT '' COMMENT 'header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue 多个逗号间隔'
+  `header_transfor` varchar(5000) NOT NULL DEFAULT '' COMMENT 'header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue 多个逗号间隔',
+  `grpc_web` tinyint(4) NOT NULL DEFAULT '0' COMMENT '接入grpc-web 1=开启, 服务端口同时接受grpc-web请求',
+  `grpc_web_prefix` varchar(255) NOT NULL DEFAULT '' COMMENT '通过http端口接入grpc-web的路径前缀, 为空时只通过服务端口接入',
+  `grpc_web_origins` varchar(1000) NOT NULL DEFAULT '' COMMENT 'grpc-web允许跨域的来源, 多个逗号间隔, *为允许全部来源, 为空时只允许同源',
+  `method_rule` varchar(5000) NOT NULL DEFAULT '' COMMENT '方法级规则, 多条逗号间隔, 格式: 方法 选项..., 选项支持allow、deny、qps、timeout、max_timeout、ip_list、weight_list'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关路由匹配表';
 
 --
@@ -159,7 +206,17 @@ CREATE TABLE `gateway_service_http_rule` (
   `need_strip_uri` tinyint(4) NOT NULL DEFAULT '0' COMMENT '启用strip_uri 1=启用',
   `need_websocket` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否支持websocket 1=支持',
   `url_rewrite` varchar(5000) NOT NULL DEFAULT '' COMMENT 'url重写功能 格式：^/gatekeeper/test_service(.*) $1 多个逗号间隔',
-  `header_transfor` varchar(5000) NOT NULL DEFAULT '' COMMENT 'header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue 多个逗号间隔'
+  `header_transfor` varchar(5000) NOT NULL DEFAULT '' COMMENT 'header转换支持增加(add)、删除(del)、修改(edit) 格式: add headname headvalue 多个逗号间隔',
+  `https_policy` tinyint(4) NOT NULL DEFAULT '0' COMMENT '开启https后明文http请求的处理 0=均允许 1=跳转https 2=拒绝',
+  `https_redirect_code` int(11) NOT NULL DEFAULT '0' COMMENT '跳转https的状态码, 默认301',
+  `hsts_max_age` int(11) NOT NULL DEFAULT '0' COMMENT 'https响应的HSTS有效期, 单位s, 0为不返回',
+  `hsts_include_subdomains` tinyint(4) NOT NULL DEFAULT '0' COMMENT 'HSTS是否包含子域名 1=包含',
+  `upstream_scheme` tinyint(4) NOT NULL DEFAULT '0' COMMENT '下游协议 0=与need_https一致 1=http 2=https',
+  `upstream_protocol` tinyint(4) NOT NULL DEFAULT '0' COMMENT '下游http协议版本 0=自动 1=http/1.1 2=http2(tls) 3=h2c',
+  `grpc_transcode` tinyint(4) NOT NULL DEFAULT '0' COMMENT 'json转grpc 0=关闭 1=上传描述文件 2=下游server reflection',
+  `grpc_descriptor` mediumtext NOT NULL COMMENT 'protobuf描述文件(FileDescriptorSet), base64编码',
+  `legacy_error_response` tinyint(4) NOT NULL DEFAULT '0' COMMENT '网关拒绝及下游失败时的响应 0=对应http状态码 1=保留旧格式http 200+errno',
+  `route_rule` varchar(5000) NOT NULL DEFAULT '' COMMENT '服务下的路由规则, 每行一条, 格式: 路径 选项..., 可按请求方法、请求头、查询参数、cookie匹配并覆盖下游节点及中间件配置'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关路由匹配表';
 
 --
@@ -189,7 +246,7 @@ INSERT INTO `gateway_service_http_rule` (`id`, `service_id`, `rule_type`, `rule`
 
 CREATE TABLE `gateway_service_info` (
   `id` bigint(20) UNSIGNED NOT NULL COMMENT '自增主键',
-  `load_type` tinyint(4) NOT NULL DEFAULT '0' COMMENT '负载类型 0=http 1=tcp 2=grpc',
+  `load_type` tinyint(4) NOT NULL DEFAULT '0' COMMENT '负载类型 0=http 1=tcp 2=grpc 3=udp',
   `service_name` varchar(255) NOT NULL DEFAULT '' COMMENT '服务名称 6-128 数字字母下划线',
   `service_desc` varchar(255) NOT NULL DEFAULT '' COMMENT '服务描述',
   `create_at` datetime NOT NULL DEFAULT '1971-01-01 00:00:00' COMMENT '添加时间',
@@ -237,17 +294,32 @@ INSERT INTO `gateway_service_info` (`id`, `load_type`, `service_name`, `service_
 CREATE TABLE `gateway_service_load_balance` (
   `id` bigint(20) NOT NULL COMMENT '自增主键',
   `service_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '服务id',
-  `check_method` tinyint(20) NOT NULL DEFAULT '0' COMMENT '检查方法 0=tcpchk,检测端口是否握手成功',
+  `check_method` tinyint(20) NOT NULL DEFAULT '0' COMMENT '检查方法 0=tcpchk,检测端口是否握手成功 1=httpchk,检测http状态码及响应体 2=grpc health',
   `check_timeout` int(10) NOT NULL DEFAULT '0' COMMENT 'check超时时间,单位s',
   `check_interval` int(11) NOT NULL DEFAULT '0' COMMENT '检查间隔, 单位s',
-  `round_type` tinyint(4) NOT NULL DEFAULT '2' COMMENT '轮询方式 0=random 1=round-robin 2=weight_round-robin 3=ip_hash',
+  `round_type` tinyint(4) NOT NULL DEFAULT '2' COMMENT '轮询方式 0=random 1=round-robin 2=weight_round-robin 3=ip_hash 4=least_conn 5=peak_ewma',
   `ip_list` varchar(2000) NOT NULL DEFAULT '' COMMENT 'ip列表',
   `weight_list` varchar(2000) NOT NULL DEFAULT '' COMMENT '权重列表',
   `forbid_list` varchar(2000) NOT NULL DEFAULT '' COMMENT '禁用ip列表',
   `upstream_connect_timeout` int(11) NOT NULL DEFAULT '0' COMMENT '建立连接超时, 单位s',
   `upstream_header_timeout` int(11) NOT NULL DEFAULT '0' COMMENT '获取header超时, 单位s',
   `upstream_idle_timeout` int(10) NOT NULL DEFAULT '0' COMMENT '链接最大空闲时间, 单位s',
-  `upstream_max_idle` int(11) NOT NULL DEFAULT '0' COMMENT '最大空闲链接数'
+  `upstream_max_idle` int(11) NOT NULL DEFAULT '0' COMMENT '最大空闲链接数',
+  `check_path` varchar(255) NOT NULL DEFAULT '' COMMENT 'http检查路径, grpc检查时为服务名',
+  `check_http_method` varchar(10) NOT NULL DEFAULT '' COMMENT 'http检查请求方法, 默认GET',
+  `check_expect_status` varchar(255) NOT NULL DEFAULT '' COMMENT 'http检查期望状态码, 如200-399,404, 默认200-399',
+  `check_expect_body` varchar(255) NOT NULL DEFAULT '' COMMENT 'http检查响应体需包含的内容',
+  `check_rise` int(11) NOT NULL DEFAULT '0' COMMENT '连续成功次数达到后节点恢复, 默认2',
+  `check_fall` int(11) NOT NULL DEFAULT '0' COMMENT '连续失败次数达到后节点摘除, 默认2',
+  `retry_max` int(11) NOT NULL DEFAULT '0' COMMENT '失败后换节点重试次数, 0为不重试',
+  `retry_on` varchar(255) NOT NULL DEFAULT '' COMMENT '重试条件 connect-failure,reset,timeout,5xx或具体状态码, 默认connect-failure,reset,502,503,504',
+  `retry_non_idempotent` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否重试非幂等请求 1=是',
+  `retry_per_try_timeout` int(11) NOT NULL DEFAULT '0' COMMENT '单次尝试超时, 单位s, 0为不限制',
+  `upstream_tls_ca` text NOT NULL COMMENT '校验下游证书的ca, pem格式, 为空时使用系统根证书',
+  `upstream_tls_cert` text NOT NULL COMMENT '连接下游的客户端证书, pem格式',
+  `upstream_tls_key` text NOT NULL COMMENT '连接下游的客户端私钥, pem格式',
+  `upstream_tls_server_name` varchar(255) NOT NULL DEFAULT '' COMMENT '校验下游证书的域名, 为空时取下游地址',
+  `upstream_tls_insecure` tinyint(4) NOT NULL DEFAULT '0' COMMENT '不校验下游证书 1=不校验'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关负载表';
 
 --
@@ -255,9 +327,9 @@ CREATE TABLE `gateway_service_load_balance` (
 --
 
 INSERT INTO `gateway_service_load_balance` (`id`, `service_id`, `check_method`, `check_timeout`, `check_interval`, `round_type`, `ip_list`, `weight_list`, `forbid_list`, `upstream_connect_timeout`, `upstream_header_timeout`, `upstream_idle_timeout`, `upstream_max_idle`) VALUES
-(162, 35, 0, 2000, 5000, 2, '127.0.0.1:50051', '100', '', 10000, 0, 0, 0),
-(165, 34, 0, 2000, 5000, 2, '100.90.164.31:8072,100.90.163.51:8072,100.90.163.52:8072,100.90.165.32:8072', '50,50,50,80', '', 20000, 20000, 10000, 100),
-(167, 36, 0, 2000, 5000, 2, '100.90.164.31:8072,100.90.163.51:8072,100.90.163.52:8072,100.90.165.32:8072', '50,50,50,80', '100.90.164.31:8072,100.90.163.51:8072', 10000, 10000, 10000, 100),
+(162, 35, 0, 2, 5, 2, '127.0.0.1:50051', '100', '', 10000, 0, 0, 0),
+(165, 34, 0, 2, 5, 2, '100.90.164.31:8072,100.90.163.51:8072,100.90.163.52:8072,100.90.165.32:8072', '50,50,50,80', '', 20000, 20000, 10000, 100),
+(167, 36, 0, 2, 5, 2, '100.90.164.31:8072,100.90.163.51:8072,100.90.163.52:8072,100.90.165.32:8072', '50,50,50,80', '100.90.164.31:8072,100.90.163.51:8072', 10000, 10000, 10000, 100),
 (168, 38, 0, 0, 0, 1, '111:111,22:111', '11,11', '111', 1111, 111, 222, 333),
 (169, 41, 0, 0, 0, 1, '111:111,22:111', '11,11', '111', 0, 0, 0, 0),
 (170, 42, 0, 0, 0, 1, '111:111,22:111', '11,11', '111', 0, 0, 0, 0),
@@ -290,7 +362,10 @@ INSERT INTO `gateway_service_load_balance` (`id`, `service_id`, `check_method`,
 CREATE TABLE `gateway_service_tcp_rule` (
   `id` bigint(20) NOT NULL COMMENT '自增主键',
   `service_id` bigint(20) NOT NULL COMMENT '服务id',
-  `port` int(5) NOT NULL DEFAULT '0' COMMENT '端口号'
+  `port` int(5) NOT NULL DEFAULT '0' COMMENT '端口号',
+  `need_tls` tinyint(4) NOT NULL DEFAULT '0' COMMENT '在网关终止tls 1=开启',
+  `sni_names` varchar(1000) NOT NULL DEFAULT '' COMMENT '共享tls端口按sni路由的域名, 多个逗号间隔',
+  `proxy_protocol` tinyint(4) NOT NULL DEFAULT '0' COMMENT '向下游发送PROXY协议头 0=不发送 1=v1 2=v2'
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关路由匹配表';
 
 --
@@ -310,6 +385,19 @@ INSERT INTO `gateway_service_tcp_rule` (`id`, `service_id`, `port`) VALUES
 (180, 55, 8010),
 (181, 57, 8011);
 
+-- --------------------------------------------------------
+
+--
+-- 表的结构 `gateway_service_udp_rule`
+--
+
+CREATE TABLE `gateway_service_udp_rule` (
+  `id` bigint(20) NOT NULL COMMENT '自增主键',
+  `service_id` bigint(20) NOT NULL COMMENT '服务id',
+  `port` int(5) NOT NULL DEFAULT '0' COMMENT '端口号',
+  `idle_timeout` int(11) NOT NULL DEFAULT '0' COMMENT '会话空闲超时, 单位s, 0为使用全局配置'
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关udp路由匹配表';
+
 --
 -- Indexes for dumped tables
 --
@@ -326,12 +414,25 @@ ALTER TABLE `gateway_admin`
 ALTER TABLE `gateway_app`
   ADD PRIMARY KEY (`id`);
 
+--
+-- Indexes for table `gateway_cert`
+--
+ALTER TABLE `gateway_cert`
+  ADD PRIMARY KEY (`id`),
+  ADD KEY `idx_not_after` (`not_after`);
+
 --
 -- Indexes for table `gateway_service_access_control`
 --
 ALTER TABLE `gateway_service_access_control`
   ADD PRIMARY KEY (`id`);
 
+--
+-- Indexes for table `gateway_service_circuit_breaker`
+--
+ALTER TABLE `gateway_service_circuit_breaker`
+  ADD PRIMARY KEY (`id`);
+
 --
 -- Indexes for table `gateway_service_grpc_rule`
 --
@@ -362,6 +463,12 @@ ALTER TABLE `gateway_service_load_balance`
 ALTER TABLE `gateway_service_tcp_rule`
   ADD PRIMARY KEY (`id`);
 
+--
+-- Indexes for table `gateway_service_udp_rule`
+--
+ALTER TABLE `gateway_service_udp_rule`
+  ADD PRIMARY KEY (`id`);
+
 --
 -- 在导出的表使用AUTO_INCREMENT
 --
@@ -377,11 +484,21 @@ ALTER TABLE `gateway_admin`
 ALTER TABLE `gateway_app`
   MODIFY `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '自增id', AUTO_INCREMENT=35;
 --
+-- 使用表AUTO_INCREMENT `gateway_cert`
+--
+ALTER TABLE `gateway_cert`
+  MODIFY `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '自增id';
+--
 -- 使用表AUTO_INCREMENT `gateway_service_access_control`
 --
 ALTER TABLE `gateway_service_access_control`
   MODIFY `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键', AUTO_INCREMENT=190;
 --
+-- 使用表AUTO_INCREMENT `gateway_service_circuit_breaker`
+--
+ALTER TABLE `gateway_service_circuit_breaker`
+  MODIFY `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键';
+--
 -- 使用表AUTO_INCREMENT `gateway_service_grpc_rule`
 --
 ALTER TABLE `gateway_service_grpc_rule`
@@ -405,7 +522,12 @@ ALTER TABLE `gateway_service_load_balance`
 -- 使用表AUTO_INCREMENT `gateway_service_tcp_rule`
 --
 ALTER TABLE `gateway_service_tcp_rule`
-  MODIFY `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键', AUTO_INCREMENT=182;COMMIT;
+  MODIFY `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键', AUTO_INCREMENT=182;
+--
+-- 使用表AUTO_INCREMENT `gateway_service_udp_rule`
+--
+ALTER TABLE `gateway_service_udp_rule`
+  MODIFY `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键';COMMIT;
 
 /*!40101 SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT */;
 /*!40101 SET CHARACTER_SET_RESULTS=@OLD_CHARACTER_SET_RESULTS */;
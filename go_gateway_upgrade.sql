@@ -0,0 +1,170 @@
+-- 已有部署的数据迁移，go_gateway.sql 为全新安装使用，升级时在已有库上执行本文件
+-- 其中 ADD COLUMN 语句只能执行一次，从中间版本升级时跳过已执行的部分
+
+--
+-- 健康检查超时、检查间隔的单位由毫秒改为秒，旧数据按毫秒换算，
+-- 只换算不小于 1000 的值，重复执行不影响已换算的数据
+--
+
+UPDATE `gateway_service_load_balance` SET `check_timeout` = CEIL(`check_timeout` / 1000) WHERE `check_timeout` >= 1000;
+UPDATE `gateway_service_load_balance` SET `check_interval` = CEIL(`check_interval` / 1000) WHERE `check_interval` >= 1000;
+
+--
+-- 健康检查支持 http 路径、状态码、响应体及连续成功/失败次数
+--
+
+ALTER TABLE `gateway_service_load_balance`
+  ADD `check_path` varchar(255) NOT NULL DEFAULT '' COMMENT 'http检查路径, grpc检查时为服务名',
+  ADD `check_http_method` varchar(10) NOT NULL DEFAULT '' COMMENT 'http检查请求方法, 默认GET',
+  ADD `check_expect_status` varchar(255) NOT NULL DEFAULT '' COMMENT 'http检查期望状态码, 如200-399,404, 默认200-399',
+  ADD `check_expect_body` varchar(255) NOT NULL DEFAULT '' COMMENT 'http检查响应体需包含的内容',
+  ADD `check_rise` int(11) NOT NULL DEFAULT '0' COMMENT '连续成功次数达到后节点恢复, 默认2',
+  ADD `check_fall` int(11) NOT NULL DEFAULT '0' COMMENT '连续失败次数达到后节点摘除, 默认2';
+
+--
+-- 失败重试配置
+--
+
+ALTER TABLE `gateway_service_load_balance`
+  ADD `retry_max` int(11) NOT NULL DEFAULT '0' COMMENT '失败后换节点重试次数, 0为不重试',
+  ADD `retry_on` varchar(255) NOT NULL DEFAULT '' COMMENT '重试条件 connect-failure,reset,timeout,5xx或具体状态码, 默认connect-failure,reset,502,503,504',
+  ADD `retry_non_idempotent` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否重试非幂等请求 1=是',
+  ADD `retry_per_try_timeout` int(11) NOT NULL DEFAULT '0' COMMENT '单次尝试超时, 单位s, 0为不限制';
+
+--
+-- 熔断配置表，服务没有熔断配置时不开启熔断
+--
+
+CREATE TABLE IF NOT EXISTS `gateway_service_circuit_breaker` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键',
+  `service_id` bigint(20) NOT NULL DEFAULT '0' COMMENT '服务id',
+  `open_breaker` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否开启熔断 1=开启',
+  `error_percent` int(11) NOT NULL DEFAULT '0' COMMENT '统计窗口内失败率达到后熔断, 百分比, 默认50',
+  `slow_call_percent` int(11) NOT NULL DEFAULT '0' COMMENT '统计窗口内慢调用率达到后熔断, 百分比, 默认50',
+  `slow_call_duration` int(11) NOT NULL DEFAULT '0' COMMENT '超过该耗时视为慢调用, 单位ms, 0为不统计慢调用',
+  `min_request` int(11) NOT NULL DEFAULT '0' COMMENT '统计窗口内请求数不足时不熔断, 默认20',
+  `stat_window` int(11) NOT NULL DEFAULT '0' COMMENT '统计窗口, 单位s, 默认10',
+  `open_duration` int(11) NOT NULL DEFAULT '0' COMMENT '熔断持续时间, 之后进入半开, 单位s, 默认30',
+  `half_open_requests` int(11) NOT NULL DEFAULT '0' COMMENT '半开状态放行的探测请求数, 默认3',
+  `fallback_status` int(11) NOT NULL DEFAULT '0' COMMENT '熔断时http降级响应状态码, 默认503',
+  `fallback_body` varchar(2000) NOT NULL DEFAULT '' COMMENT '熔断时降级响应内容',
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关熔断表';
+
+--
+-- https 证书表
+--
+
+CREATE TABLE IF NOT EXISTS `gateway_cert` (
+  `id` bigint(20) UNSIGNED NOT NULL AUTO_INCREMENT COMMENT '自增id',
+  `name` varchar(255) NOT NULL DEFAULT '' COMMENT '证书名称',
+  `sni_names` varchar(1000) NOT NULL DEFAULT '' COMMENT '匹配的sni域名, 支持*.开头的通配符, 多个逗号间隔',
+  `cert_pem` text NOT NULL COMMENT '证书内容, pem格式, 可包含证书链',
+  `key_pem` text NOT NULL COMMENT '私钥内容, pem格式',
+  `not_before` datetime NOT NULL COMMENT '证书生效时间',
+  `not_after` datetime NOT NULL COMMENT '证书过期时间',
+  `source` tinyint(4) NOT NULL DEFAULT '0' COMMENT '证书来源 0=手动上传 1=acme自动签发',
+  `create_at` datetime NOT NULL COMMENT '添加时间',
+  `update_at` datetime NOT NULL COMMENT '更新时间',
+  `is_delete` tinyint(4) NOT NULL DEFAULT '0' COMMENT '是否删除 1=删除',
+  PRIMARY KEY (`id`),
+  KEY `idx_not_after` (`not_after`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关https证书表';
+
+--
+-- http 服务的 https 跳转、HSTS 及下游协议
+--
+
+ALTER TABLE `gateway_service_http_rule`
+  ADD `https_policy` tinyint(4) NOT NULL DEFAULT '0' COMMENT '开启https后明文http请求的处理 0=均允许 1=跳转https 2=拒绝',
+  ADD `https_redirect_code` int(11) NOT NULL DEFAULT '0' COMMENT '跳转https的状态码, 默认301',
+  ADD `hsts_max_age` int(11) NOT NULL DEFAULT '0' COMMENT 'https响应的HSTS有效期, 单位s, 0为不返回',
+  ADD `hsts_include_subdomains` tinyint(4) NOT NULL DEFAULT '0' COMMENT 'HSTS是否包含子域名 1=包含',
+  ADD `upstream_scheme` tinyint(4) NOT NULL DEFAULT '0' COMMENT '下游协议 0=与need_https一致 1=http 2=https';
+
+--
+-- 下游 tls 及客户端证书认证
+--
+
+ALTER TABLE `gateway_service_load_balance`
+  ADD `upstream_tls_ca` text NOT NULL COMMENT '校验下游证书的ca, pem格式, 为空时使用系统根证书',
+  ADD `upstream_tls_cert` text NOT NULL COMMENT '连接下游的客户端证书, pem格式',
+  ADD `upstream_tls_key` text NOT NULL COMMENT '连接下游的客户端私钥, pem格式',
+  ADD `upstream_tls_server_name` varchar(255) NOT NULL DEFAULT '' COMMENT '校验下游证书的域名, 为空时取下游地址',
+  ADD `upstream_tls_insecure` tinyint(4) NOT NULL DEFAULT '0' COMMENT '不校验下游证书 1=不校验';
+ALTER TABLE `gateway_app`
+  ADD `cert_subject` varchar(255) NOT NULL DEFAULT '' COMMENT '客户端证书主体, 匹配证书CN或SAN, 为空时以app_id匹配CN';
+ALTER TABLE `gateway_service_access_control`
+  ADD `client_cert_auth` tinyint(4) NOT NULL DEFAULT '0' COMMENT '客户端证书认证 1=开启, 证书须由mtls.client_ca_file签发';
+
+--
+-- tcp 服务在网关终止 tls 及按 sni 共享端口
+--
+
+ALTER TABLE `gateway_service_tcp_rule`
+  ADD `need_tls` tinyint(4) NOT NULL DEFAULT '0' COMMENT '在网关终止tls 1=开启',
+  ADD `sni_names` varchar(1000) NOT NULL DEFAULT '' COMMENT '共享tls端口按sni路由的域名, 多个逗号间隔';
+
+--
+-- tcp 服务向下游发送 PROXY 协议头
+--
+
+ALTER TABLE `gateway_service_tcp_rule`
+  ADD `proxy_protocol` tinyint(4) NOT NULL DEFAULT '0' COMMENT '向下游发送PROXY协议头 0=不发送 1=v1 2=v2';
+
+--
+-- udp 路由匹配表
+--
+
+CREATE TABLE IF NOT EXISTS `gateway_service_udp_rule` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT COMMENT '自增主键',
+  `service_id` bigint(20) NOT NULL COMMENT '服务id',
+  `port` int(5) NOT NULL DEFAULT '0' COMMENT '端口号',
+  `idle_timeout` int(11) NOT NULL DEFAULT '0' COMMENT '会话空闲超时, 单位s, 0为使用全局配置',
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8 COMMENT='网关udp路由匹配表';
+
+--
+-- 下游 http 协议版本
+--
+
+ALTER TABLE `gateway_service_http_rule`
+  ADD `upstream_protocol` tinyint(4) NOT NULL DEFAULT '0' COMMENT '下游http协议版本 0=自动 1=http/1.1 2=http2(tls) 3=h2c';
+
+--
+-- grpc 服务接入 grpc-web，跨域来源为空时只允许同源，需要跨域访问的服务配置具体来源或 *
+--
+
+ALTER TABLE `gateway_service_grpc_rule`
+  ADD `grpc_web` tinyint(4) NOT NULL DEFAULT '0' COMMENT '接入grpc-web 1=开启, 服务端口同时接受grpc-web请求',
+  ADD `grpc_web_prefix` varchar(255) NOT NULL DEFAULT '' COMMENT '通过http端口接入grpc-web的路径前缀, 为空时只通过服务端口接入',
+  ADD `grpc_web_origins` varchar(1000) NOT NULL DEFAULT '' COMMENT 'grpc-web允许跨域的来源, 多个逗号间隔, *为允许全部来源, 为空时只允许同源';
+
+--
+-- http 服务 json 转 grpc
+--
+
+ALTER TABLE `gateway_service_http_rule`
+  ADD `grpc_transcode` tinyint(4) NOT NULL DEFAULT '0' COMMENT 'json转grpc 0=关闭 1=上传描述文件 2=下游server reflection',
+  ADD `grpc_descriptor` mediumtext NOT NULL COMMENT 'protobuf描述文件(FileDescriptorSet), base64编码';
+
+--
+-- grpc 方法级规则
+--
+
+ALTER TABLE `gateway_service_grpc_rule`
+  ADD `method_rule` varchar(5000) NOT NULL DEFAULT '' COMMENT '方法级规则, 多条逗号间隔, 格式: 方法 选项..., 选项支持allow、deny、qps、timeout、max_timeout、ip_list、weight_list';
+
+--
+-- 网关拒绝及下游失败时改为返回对应 http 状态码，需要保留旧格式 http 200+errno 的服务开启 legacy_error_response
+--
+
+ALTER TABLE `gateway_service_http_rule`
+  ADD `legacy_error_response` tinyint(4) NOT NULL DEFAULT '0' COMMENT '网关拒绝及下游失败时的响应 0=对应http状态码 1=保留旧格式http 200+errno';
+
+--
+-- http 服务下的路由规则
+--
+
+ALTER TABLE `gateway_service_http_rule`
+  ADD `route_rule` varchar(5000) NOT NULL DEFAULT '' COMMENT '服务下的路由规则, 每行一条, 格式: 路径 选项..., 可按请求方法、请求头、查询参数、cookie匹配并覆盖下游节点及中间件配置';
@@ -1,12 +1,12 @@
 package grpc_proxy_middleware
 
 import (
-	"fmt"
-	"github.com/pkg/errors"
 	"go-gateway/dao"
 	"go-gateway/public"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 	"log"
 	"strings"
 )
@@ -48,7 +48,7 @@ func GrpcBlackListMiddleware(serviceDetail *dao.ServiceDetail) func(
 		peerCtx, ok := peer.FromContext(ss.Context())
 		if !ok {
 			// 如果上下文中拿不到 peer，说明此请求异常，直接拒绝
-			return errors.New("peer not found with context")
+			return status.Error(codes.Internal, "peer not found with context")
 		}
 
 		// peerCtx.Addr 形如 "192.168.1.10:54321"
@@ -85,9 +85,9 @@ func GrpcBlackListMiddleware(serviceDetail *dao.ServiceDetail) func(
 			// 判断当前客户端 IP 是否在黑名单中
 			if public.InStringSlice(blackIpList, clientIP) {
 				// 命中黑名单，直接拒绝请求
-				return errors.New(fmt.Sprintf(
+				return status.Errorf(codes.PermissionDenied,
 					"%s in black ip list", clientIP,
-				))
+				)
 			}
 		}
 
@@ -0,0 +1,20 @@
+package grpc_proxy_middleware
+
+import (
+	"go-gateway/dao"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// GrpcCircuitBreakerMiddleware 服务熔断时直接返回 Unavailable，降级内容作为错误信息
+func GrpcCircuitBreakerMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		breaker := serviceDetail.GetServiceBreaker()
+		if breaker != nil && breaker.Blocked() {
+			_, body := serviceDetail.CircuitBreaker.GetFallback()
+			return status.Error(codes.Unavailable, body)
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,60 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"crypto/tls"
+	"go-gateway/dao"
+	"go-gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GrpcClientCertAuthMiddleware gRPC 客户端证书认证中间件
+// 服务开启客户端证书认证时监听已要求并校验客户端证书，这里将证书映射为租户写入 Metadata，
+// 之后与 jwt 租户一样参与鉴权、统计及限流；客户端自带的 app 信息一律丢弃，避免伪造租户
+func GrpcClientCertAuthMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, ok := metadata.FromIncomingContext(ss.Context())
+		if !ok {
+			return status.Error(codes.Internal, "miss metadata from context")
+		}
+		md.Delete("app")
+
+		var tlsState *tls.ConnectionState
+		if p, ok := peer.FromContext(ss.Context()); ok {
+			if tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+				tlsState = &tlsInfo.State
+			}
+		}
+		clientCert := dao.GetVerifiedClientCert(tlsState)
+		if clientCert == nil && serviceDetail.AccessControl.ClientCertAuth == 1 {
+			return status.Error(codes.Unauthenticated, "client certificate required")
+		}
+		if appInfo, ok := dao.AppManagerHandler.GetAppByCert(clientCert); ok {
+			md.Set("app", public.Obj2Json(appInfo))
+		}
+		return handler(srv, newMetadataStream(ss, md))
+	}
+}
+
+// metadataStream 替换 stream 的 Metadata
+// FromIncomingContext 返回的是副本，修改后需重新放入 context 才能被后续中间件读取
+type metadataStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func newMetadataStream(ss grpc.ServerStream, md metadata.MD) grpc.ServerStream {
+	return &metadataStream{
+		ServerStream: ss,
+		ctx:          metadata.NewIncomingContext(ss.Context(), md),
+	}
+}
+
+func (s *metadataStream) Context() context.Context {
+	return s.ctx
+}
@@ -1,14 +1,15 @@
 package grpc_proxy_middleware
 
 import (
-	"fmt"
-	"github.com/pkg/errors"
 	"go-gateway/dao"
 	"go-gateway/public"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 	"log"
 	"strings"
+	"time"
 )
 
 // GrpcFlowLimitMiddleware gRPC 流量限流中间件
@@ -40,17 +41,15 @@ func GrpcFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv interfac
 			// 判断是否允许当前请求通过
 			if !serviceLimiter.Allow() {
 				// 超过服务级限流阈值，直接拒绝
-				return errors.New(
-					fmt.Sprintf("service flow limit %v",
-						serviceDetail.AccessControl.ServiceFlowLimit),
-				)
+				return grpcRateLimitError(ss, time.Second, "service flow limit %v",
+					serviceDetail.AccessControl.ServiceFlowLimit)
 			}
 		}
 
 		// ===================== ② 获取客户端 IP =====================
 		peerCtx, ok := peer.FromContext(ss.Context())
 		if !ok {
-			return errors.New("peer not found with context")
+			return status.Error(codes.Internal, "peer not found with context")
 		}
 
 		// peer 地址格式一般为：IP:PORT
@@ -74,11 +73,8 @@ func GrpcFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv interfac
 
 			// 判断当前 IP 是否超限
 			if !clientLimiter.Allow() {
-				return errors.New(
-					fmt.Sprintf("%v flow limit %v",
-						clientIP,
-						serviceDetail.AccessControl.ClientIPFlowLimit),
-				)
+				return grpcRateLimitError(ss, time.Second, "%v flow limit %v",
+					clientIP, serviceDetail.AccessControl.ClientIPFlowLimit)
 			}
 		}
 
@@ -4,7 +4,9 @@ import (
 	"github.com/pkg/errors"
 	"go-gateway/dao"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"log"
 	"strings"
 )
@@ -41,7 +43,7 @@ func GrpcHeaderTransferMiddleware(serviceDetail *dao.ServiceDetail) func(
 		md, ok := metadata.FromIncomingContext(ss.Context())
 		if !ok {
 			// 如果上下文中未携带 Metadata，说明请求不完整或异常
-			return errors.New("miss metadata from context")
+			return status.Error(codes.Internal, "miss metadata from context")
 		}
 
 		// -----------------------------
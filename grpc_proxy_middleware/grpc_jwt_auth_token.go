@@ -1,11 +1,12 @@
 package grpc_proxy_middleware
 
 import (
-	"github.com/pkg/errors"
 	"go-gateway/dao"
 	"go-gateway/public"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"log"
 	"strings"
 )
@@ -29,7 +30,7 @@ func GrpcJwtAuthTokenMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 		// ===================== ① 从 Context 中提取 gRPC Metadata =====================
 		md, ok := metadata.FromIncomingContext(ss.Context())
 		if !ok {
-			return errors.New("miss metadata from context")
+			return status.Error(codes.Internal, "miss metadata from context")
 		}
 
 		// ===================== ② 读取 Authorization 头 =====================
@@ -42,15 +43,15 @@ func GrpcJwtAuthTokenMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 		// 去除 "Bearer " 前缀，提取纯 Token
 		token := strings.ReplaceAll(authToken, "Bearer ", "")
 
-		// 标记是否匹配到合法 App
-		appMatched := false
+		// 标记是否匹配到合法 App，客户端证书已映射到租户时不再解析 Token
+		appMatched := len(md.Get("app")) > 0
 
 		// ===================== ③ 解析并验证 JWT Token =====================
-		if token != "" {
+		if !appMatched && token != "" {
 			// 解析 JWT，获取 Claims
 			claims, err := public.JwtDecode(token)
 			if err != nil {
-				return errors.WithMessage(err, "JwtDecode")
+				return status.Errorf(codes.Unauthenticated, "JwtDecode: %v", err)
 			}
 
 			// 获取系统中所有已注册的 App 列表
@@ -73,11 +74,12 @@ func GrpcJwtAuthTokenMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 		// ===================== ④ 是否开启鉴权校验 =====================
 		// 如果服务开启了鉴权，并且 Token 未匹配到合法 App，则拒绝请求
 		if serviceDetail.AccessControl.OpenAuth == 1 && !appMatched {
-			return errors.New("not match valid app")
+			return status.Error(codes.Unauthenticated, "not match valid app")
 		}
 
 		// ===================== ⑤ 放行执行业务 RPC =====================
-		if err := handler(srv, ss); err != nil {
+		// Metadata 为副本，需替换 stream 的 context 后续中间件才能读到 App 信息
+		if err := handler(srv, newMetadataStream(ss, md)); err != nil {
 			log.Printf("GrpcJwtAuthTokenMiddleware failed with error %v\n", err)
 			return err
 		}
@@ -2,13 +2,14 @@ package grpc_proxy_middleware
 
 import (
 	"encoding/json"
-	"fmt"
-	"github.com/pkg/errors"
 	"go-gateway/dao"
 	"go-gateway/public"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"log"
+	"time"
 )
 
 // GrpcJwtFlowCountMiddleware 基于 JWT 的租户流量统计 & 日请求量限流中间件
@@ -28,7 +29,7 @@ func GrpcJwtFlowCountMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 		// ===================== ① 从 Context 中获取 Metadata =====================
 		md, ok := metadata.FromIncomingContext(ss.Context())
 		if !ok {
-			return errors.New("miss metadata from context")
+			return status.Error(codes.Internal, "miss metadata from context")
 		}
 
 		// ===================== ② 读取上游 JWT 鉴权中设置的 App 信息 =====================
@@ -64,12 +65,10 @@ func GrpcJwtFlowCountMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 		// ===================== ⑤ 租户日请求量（QPD）限流 =====================
 		// 如果配置了 QPD 且已超过当日最大请求量
 		if appInfo.Qpd > 0 && appCounter.TotalCount > appInfo.Qpd {
-			return errors.New(
-				fmt.Sprintf(
-					"租户日请求量限流 limit:%v current:%v",
-					appInfo.Qpd,
-					appCounter.TotalCount,
-				),
+			return grpcRateLimitError(ss, public.UntilNextDay(time.Now()),
+				"租户日请求量限流 limit:%v current:%v",
+				appInfo.Qpd,
+				appCounter.TotalCount,
 			)
 		}
 
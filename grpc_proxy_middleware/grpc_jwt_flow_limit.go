@@ -2,15 +2,16 @@ package grpc_proxy_middleware
 
 import (
 	"encoding/json"
-	"fmt"
-	"github.com/pkg/errors"
 	"go-gateway/dao"
 	"go-gateway/public"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 	"log"
 	"strings"
+	"time"
 )
 
 // GrpcJwtFlowLimitMiddleware 基于 JWT 的租户级实时 QPS 限流中间件
@@ -30,7 +31,7 @@ func GrpcJwtFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 		// ===================== ① 从 Context 中获取 Metadata =====================
 		md, ok := metadata.FromIncomingContext(ss.Context())
 		if !ok {
-			return errors.New("miss metadata from context")
+			return status.Error(codes.Internal, "miss metadata from context")
 		}
 
 		// ===================== ② 读取上游 JWT 鉴权中写入的 App 信息 =====================
@@ -55,7 +56,7 @@ func GrpcJwtFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 		// ===================== ④ 获取客户端 IP 地址 =====================
 		peerCtx, ok := peer.FromContext(ss.Context())
 		if !ok {
-			return errors.New("peer not found with context")
+			return status.Error(codes.Internal, "peer not found with context")
 		}
 		peerAddr := peerCtx.Addr.String()
 		addrPos := strings.LastIndex(peerAddr, ":")
@@ -76,9 +77,7 @@ func GrpcJwtFlowLimitMiddleware(serviceDetail *dao.ServiceDetail) func(srv inter
 
 			// 判断当前请求是否超过 QPS 限制
 			if !clientLimiter.Allow() {
-				return errors.New(
-					fmt.Sprintf("%v flow limit %v", clientIP, appInfo.Qps),
-				)
+				return grpcRateLimitError(ss, time.Second, "%v flow limit %v", clientIP, appInfo.Qps)
 			}
 		}
 
@@ -0,0 +1,74 @@
+package grpc_proxy_middleware
+
+import (
+	"context"
+	"go-gateway/dao"
+	"go-gateway/public"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"time"
+)
+
+// GrpcMethodRuleMiddleware 按 info.FullMethod 匹配服务的方法级规则：
+// 拒绝未开放的方法，按规则限流，并设置默认超时及客户端 deadline 上限；方法级下游节点由代理处理器选择；
+// 位于客户端证书及 jwt 鉴权之后，未通过鉴权的请求不占用方法的限流额度
+func GrpcMethodRuleMiddleware(serviceDetail *dao.ServiceDetail) func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	//服务配置变更时 grpc 监听会重建，规则只需解析一次；保存时已校验，这里解析失败只记录日志
+	ruleList, err := serviceDetail.GRPCRule.GetMethodRuleList()
+	if err != nil {
+		log.Printf(" [ERROR] grpc_method_rule %v err:%v\n", serviceDetail.Info.ServiceName, err)
+	}
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if len(ruleList) == 0 {
+			return handler(srv, ss)
+		}
+		rule := ruleList.Match(info.FullMethod)
+		if !rule.Allow() {
+			return status.Errorf(codes.PermissionDenied, "method %v not allowed", info.FullMethod)
+		}
+
+		if rule.QPS > 0 {
+			//按规则而不是客户端传入的方法名创建限流器，随意构造的方法名既不能绕过通配规则的限流，也不会无限创建限流器
+			limiter, err := public.FlowLimiterHandler.GetLimiter(
+				public.FlowServicePrefix+serviceDetail.Info.ServiceName+"/method:"+rule.Limit,
+				float64(rule.QPS),
+			)
+			if err != nil {
+				return err
+			}
+			if !limiter.Allow() {
+				return grpcRateLimitError(ss, time.Second, "method %v flow limit %v", info.FullMethod, rule.QPS)
+			}
+		}
+
+		var timeout time.Duration
+		if deadline, ok := ss.Context().Deadline(); ok {
+			if rule.MaxTimeout > 0 && time.Until(deadline) > rule.MaxTimeout {
+				timeout = rule.MaxTimeout
+			}
+		} else {
+			timeout = rule.Timeout
+			if rule.MaxTimeout > 0 && (timeout == 0 || timeout > rule.MaxTimeout) {
+				timeout = rule.MaxTimeout
+			}
+		}
+		if timeout <= 0 {
+			return handler(srv, ss)
+		}
+		ctx, cancel := context.WithTimeout(ss.Context(), timeout)
+		defer cancel()
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// contextStream 替换 stream 的 context，代理处理器以该 context 请求下游，超时随之传递给下游
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,24 @@
+package grpc_proxy_middleware
+
+import (
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"math"
+	"strconv"
+	"time"
+)
+
+// grpcRateLimitError 限流拒绝返回 ResourceExhausted，附带 RetryInfo 详情及 retry-after 尾部元数据(秒)
+func grpcRateLimitError(ss grpc.ServerStream, retryAfter time.Duration, format string, a ...interface{}) error {
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf(format, a...))
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)}); err == nil {
+		st = detailed
+	}
+	ss.SetTrailer(metadata.Pairs("retry-after", strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))))
+	return st.Err()
+}
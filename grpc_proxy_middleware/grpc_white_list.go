@@ -1,12 +1,12 @@
 package grpc_proxy_middleware
 
 import (
-	"fmt"
-	"github.com/pkg/errors"
 	"go-gateway/dao"
 	"go-gateway/public"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 	"log"
 	"strings"
 )
@@ -49,7 +49,7 @@ func GrpcWhiteListMiddleware(serviceDetail *dao.ServiceDetail) func(
 		peerCtx, ok := peer.FromContext(ss.Context())
 		if !ok {
 			// 如果未能从上下文中获取到客户端信息，说明连接异常，直接返回错误
-			return errors.New("peer not found with context")
+			return status.Error(codes.Internal, "peer not found with context")
 		}
 
 		// 获取客户端的远程地址，格式一般为 "IP:端口"
@@ -71,9 +71,9 @@ func GrpcWhiteListMiddleware(serviceDetail *dao.ServiceDetail) func(
 
 			// 如果当前客户端 IP 不在白名单中，则拒绝访问
 			if !public.InStringSlice(iplist, clientIP) {
-				return errors.New(fmt.Sprintf(
+				return status.Errorf(codes.PermissionDenied,
 					"%s not in white ip list", clientIP,
-				))
+				)
 			}
 		}
 
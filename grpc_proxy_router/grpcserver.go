@@ -1,68 +1,331 @@
 package grpc_proxy_router
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
-	"github.com/e421083458/grpc-proxy/proxy"
+	"go-gateway/common/lib"
 	"go-gateway/dao"
 	"go-gateway/grpc_proxy_middleware"
+	"go-gateway/public"
 	"go-gateway/reverse_proxy"
+	"go-gateway/tcp_server"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 	"log"
 	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
-var grpcServerList = []*warpGrpcServer{}
+// defaultDrainTimeout 服务下线或重启时，等待存量 stream 结束的最长时间
+const defaultDrainTimeout = 30
+
+var (
+	grpcServerMap    = map[string]*warpGrpcServer{}
+	grpcServerLocker sync.Mutex
+)
 
 type warpGrpcServer struct {
 	Addr string
 	*grpc.Server
+	listener      net.Listener
+	connPool      *reverse_proxy.GrpcConnPool
+	serviceDetail *dao.ServiceDetail
+
+	methodConnPools []*reverse_proxy.GrpcConnPool //方法级规则单独设置下游节点时的连接池
+
+	httpServer *http.Server //开启 grpc-web 时服务端口由 http server 监听
+	webHandler *reverse_proxy.GrpcWebHandler
+}
+
+// grpcServerSupervisor 服务配置热加载后对比并调整 grpc 监听
+type grpcServerSupervisor struct{}
+
+func (g *grpcServerSupervisor) Update() {
+	GrpcServerSync()
 }
 
 func GrpcServerRun() {
-	serviceList := dao.ServiceManagerHandler.GetGrpcServiceList()
-	for _, serviceItem := range serviceList {
-		tempItem := serviceItem
-		go func(serviceDetail *dao.ServiceDetail) {
-			addr := fmt.Sprintf(":%d", serviceDetail.GRPCRule.Port)
-			rb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
-			if err != nil {
-				log.Fatalf(" [INFO] GetTcpLoadBalancer %v err:%v\n", addr, err)
-				return
-			}
-			lis, err := net.Listen("tcp", addr)
-			if err != nil {
-				log.Fatalf(" [INFO] GrpcListen %v err:%v\n", addr, err)
-			}
-			grpcHandler := reverse_proxy.NewGrpcLoadBalanceHandler(rb)
-			s := grpc.NewServer(
-				grpc.ChainStreamInterceptor(
-					grpc_proxy_middleware.GrpcFlowCountMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcFlowLimitMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcJwtAuthTokenMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcJwtFlowCountMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcJwtFlowLimitMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcWhiteListMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcBlackListMiddleware(serviceDetail),
-					grpc_proxy_middleware.GrpcHeaderTransferMiddleware(serviceDetail),
-				),
-				grpc.CustomCodec(proxy.Codec()),
-				grpc.UnknownServiceHandler(grpcHandler))
-
-			grpcServerList = append(grpcServerList, &warpGrpcServer{
-				Addr:   addr,
-				Server: s,
-			})
-			log.Printf(" [INFO] grpc_proxy_run %v\n", addr)
-			if err := s.Serve(lis); err != nil {
-				log.Fatalf(" [INFO] grpc_proxy_run %v err:%v\n", addr, err)
+	GrpcServerSync()
+	dao.ServiceManagerHandler.Attach(&grpcServerSupervisor{})
+}
+
+// GrpcServerSync 对比运行中的监听与当前 grpc 服务列表：
+// 新增服务开启监听，删除服务排空后关闭，配置或端口变更的服务重新绑定，其余服务不受影响
+func GrpcServerSync() {
+	grpcServerLocker.Lock()
+	defer grpcServerLocker.Unlock()
+
+	serviceMap := map[string]*dao.ServiceDetail{}
+	for _, serviceItem := range dao.ServiceManagerHandler.GetGrpcServiceList() {
+		serviceMap[serviceItem.Info.ServiceName] = serviceItem
+	}
+
+	for serviceName, grpcServer := range grpcServerMap {
+		serviceDetail, ok := serviceMap[serviceName]
+		if ok && public.Obj2Json(serviceDetail) == public.Obj2Json(grpcServer.serviceDetail) {
+			continue
+		}
+		delete(grpcServerMap, serviceName)
+		drainGrpcServer(grpcServer)
+	}
+
+	for serviceName, serviceDetail := range serviceMap {
+		if _, ok := grpcServerMap[serviceName]; ok {
+			continue
+		}
+		if grpcServer := startGrpcServer(serviceDetail); grpcServer != nil {
+			grpcServerMap[serviceName] = grpcServer
+		}
+	}
+	syncGrpcWebRoute()
+}
+
+func startGrpcServer(serviceDetail *dao.ServiceDetail) *warpGrpcServer {
+	addr := fmt.Sprintf(":%d", serviceDetail.GRPCRule.Port)
+	rb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
+	if err != nil {
+		log.Printf(" [ERROR] GetGrpcLoadBalancer %v err:%v\n", addr, err)
+		return nil
+	}
+	lbConf, err := dao.LoadBalancerHandler.GetLoadBalanceConf(serviceDetail)
+	if err != nil {
+		log.Printf(" [ERROR] GetGrpcLoadBalanceConf %v err:%v\n", addr, err)
+		return nil
+	}
+	//设置了下游 tls 参数时以 tls 连接下游
+	var upstreamTLS *tls.Config
+	if serviceDetail.LoadBalance.UseUpstreamTLS() {
+		if upstreamTLS, err = serviceDetail.LoadBalance.GetUpstreamTLSConfig(); err != nil {
+			log.Printf(" [ERROR] GetGrpcUpstreamTLSConfig %v err:%v\n", addr, err)
+			return nil
+		}
+	}
+	grpcWeb := serviceDetail.GRPCRule.GrpcWeb == 1
+	serverOpts := []grpc.ServerOption{}
+	//开启客户端证书认证时监听端使用 tls，并要求客户端证书
+	var serverTLS *tls.Config
+	if serviceDetail.AccessControl.ClientCertAuth == 1 {
+		if serverTLS, err = dao.GetClientAuthTLSConfig(); err != nil {
+			log.Printf(" [ERROR] GetGrpcClientAuthTLSConfig %v err:%v\n", addr, err)
+			return nil
+		}
+		//开启 grpc-web 时 tls 由 http server 处理
+		if !grpcWeb {
+			serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(serverTLS)))
+		}
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		//监听失败时不记录，下次同步时重试
+		log.Printf(" [ERROR] GrpcListen %v err:%v\n", addr, err)
+		return nil
+	}
+	if conf := public.GetProxyProtoConfig("grpc"); conf != nil {
+		lis = tcp_server.NewProxyProtoListener(lis, conf)
+	}
+	connPool := reverse_proxy.NewGrpcConnPool(lbConf, upstreamTLS)
+	grpcHandler, methodConnPools, err := newGrpcProxyHandler(serviceDetail,
+		reverse_proxy.NewGrpcLoadBalanceHandler(rb, connPool), upstreamTLS)
+	if err != nil {
+		log.Printf(" [ERROR] GetGrpcMethodLoadBalancer %v err:%v\n", addr, err)
+		lis.Close()
+		connPool.Close()
+		return nil
+	}
+	s := grpc.NewServer(append(serverOpts,
+		grpc.ChainStreamInterceptor(
+			grpc_proxy_middleware.GrpcFlowCountMiddleware(serviceDetail),
+			grpc_proxy_middleware.GrpcFlowLimitMiddleware(serviceDetail),
+			grpc_proxy_middleware.GrpcClientCertAuthMiddleware(serviceDetail),
+			grpc_proxy_middleware.GrpcJwtAuthTokenMiddleware(serviceDetail),
+			grpc_proxy_middleware.GrpcMethodRuleMiddleware(serviceDetail),
+			grpc_proxy_middleware.GrpcJwtFlowCountMiddleware(serviceDetail),
+			grpc_proxy_middleware.GrpcJwtFlowLimitMiddleware(serviceDetail),
+			grpc_proxy_middleware.GrpcWhiteListMiddleware(serviceDetail),
+			grpc_proxy_middleware.GrpcBlackListMiddleware(serviceDetail),
+			grpc_proxy_middleware.GrpcHeaderTransferMiddleware(serviceDetail),
+			grpc_proxy_middleware.GrpcCircuitBreakerMiddleware(serviceDetail),
+		),
+		grpc.CustomCodec(reverse_proxy.GrpcCodec()),
+		grpc.UnknownServiceHandler(grpcHandler))...)
+
+	grpcServer := &warpGrpcServer{
+		Addr:          addr,
+		Server:        s,
+		listener:      lis,
+		connPool:      connPool,
+		serviceDetail: serviceDetail,
+
+		methodConnPools: methodConnPools,
+	}
+	if grpcWeb {
+		grpcServer.webHandler = reverse_proxy.NewGrpcWebHandler(s, serviceDetail.GRPCRule.GetWebOriginList())
+		grpcServer.httpServer = newGrpcWebServer(grpcServer.webHandler, serverTLS)
+	}
+	go func() {
+		log.Printf(" [INFO] grpc_proxy_run %v\n", addr)
+		if err := grpcServer.serve(); err != nil {
+			log.Printf(" [INFO] grpc_proxy_run %v err:%v\n", addr, err)
+		}
+	}()
+	return grpcServer
+}
+
+// newGrpcProxyHandler 方法级规则单独设置了下游节点时，匹配的方法使用单独的负载均衡器及连接池，其余方法使用服务的下游节点
+func newGrpcProxyHandler(serviceDetail *dao.ServiceDetail, defaultHandler grpc.StreamHandler,
+	upstreamTLS *tls.Config) (grpc.StreamHandler, []*reverse_proxy.GrpcConnPool, error) {
+	ruleList, err := serviceDetail.GRPCRule.GetMethodRuleList()
+	if err != nil {
+		return nil, nil, err
+	}
+	handlers := map[string]grpc.StreamHandler{}
+	connPools := []*reverse_proxy.GrpcConnPool{}
+	for _, rule := range ruleList {
+		if rule.Upstream == "" {
+			continue
+		}
+		lb, lbConf, err := dao.LoadBalancerHandler.GetMethodLoadBalancer(serviceDetail, rule)
+		if err != nil {
+			for _, connPool := range connPools {
+				connPool.Close()
 			}
-		}(tempItem)
+			return nil, nil, err
+		}
+		connPool := reverse_proxy.NewGrpcConnPool(lbConf, upstreamTLS)
+		connPools = append(connPools, connPool)
+		handlers[rule.Upstream] = reverse_proxy.NewGrpcLoadBalanceHandler(lb, connPool)
+	}
+	if len(handlers) == 0 {
+		return defaultHandler, nil, nil
+	}
+	return func(srv interface{}, stream grpc.ServerStream) error {
+		fullMethod, _ := grpc.MethodFromServerStream(stream)
+		if handler, ok := handlers[ruleList.Match(fullMethod).Upstream]; ok {
+			return handler(srv, stream)
+		}
+		return defaultHandler(srv, stream)
+	}, connPools, nil
+}
+
+// newGrpcWebServer 开启 grpc-web 的服务端口同时接受 http/1.1 的 grpc-web 请求及原生 grpc 请求，
+// 两者都交给 grpc-web 处理器，由同一个 grpc.Server 经过相同的拦截器处理
+func newGrpcWebServer(handler http.Handler, serverTLS *tls.Config) *http.Server {
+	srv := &http.Server{
+		Handler:   handler,
+		Protocols: &http.Protocols{},
+	}
+	srv.Protocols.SetHTTP1(true)
+	if serverTLS != nil {
+		srv.TLSConfig = serverTLS.Clone()
+		srv.Protocols.SetHTTP2(true)
+	} else {
+		srv.Protocols.SetUnencryptedHTTP2(true)
+	}
+	return srv
+}
+
+func (g *warpGrpcServer) serve() error {
+	if g.httpServer == nil {
+		return g.Server.Serve(g.listener)
+	}
+	if g.httpServer.TLSConfig != nil {
+		return g.httpServer.ServeTLS(g.listener, "", "")
+	}
+	return g.httpServer.Serve(g.listener)
+}
+
+// stop 等待存量 stream 结束，超时后强制关闭；
+// 开启 grpc-web 时请求经 ServeHTTP 处理，不能使用 GracefulStop，改为等待 grpc-web 处理器中的请求结束
+func (g *warpGrpcServer) stop(timeout time.Duration) {
+	if g.httpServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		g.httpServer.Shutdown(ctx)
+		if err := g.webHandler.Wait(ctx); err != nil {
+			log.Printf(" [ERROR] grpc_proxy_drain %v timeout\n", g.Addr)
+		}
+		g.Server.Stop()
+		g.httpServer.Close()
+	} else {
+		stopped := make(chan struct{})
+		go func() {
+			g.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(timeout):
+			log.Printf(" [ERROR] grpc_proxy_drain %v timeout\n", g.Addr)
+			g.Stop()
+		}
 	}
+	g.connPool.Close()
+	for _, connPool := range g.methodConnPools {
+		connPool.Close()
+	}
+}
+
+// drainGrpcServer 立即关闭监听以释放端口，存量 stream 在后台排空，超时后强制关闭
+func drainGrpcServer(grpcServer *warpGrpcServer) {
+	grpcServer.listener.Close()
+	go func() {
+		grpcServer.stop(drainTimeout())
+		log.Printf(" [INFO] grpc_proxy_stop %v stopped\n", grpcServer.Addr)
+	}()
+}
+
+func drainTimeout() time.Duration {
+	timeout := lib.GetIntConf("proxy.reload.drain_timeout")
+	if timeout <= 0 {
+		timeout = defaultDrainTimeout
+	}
+	return time.Duration(timeout) * time.Second
 }
 
 func GrpcServerStop() {
-	for _, grpcServer := range grpcServerList {
-		grpcServer.GracefulStop()
+	grpcServerLocker.Lock()
+	defer grpcServerLocker.Unlock()
+	for _, grpcServer := range grpcServerMap {
+		grpcServer.stop(drainTimeout())
 		log.Printf(" [INFO] grpc_proxy_stop %v stopped\n", grpcServer.Addr)
 	}
 }
+
+var (
+	grpcWebRouteList   []*warpGrpcServer
+	grpcWebRouteLocker sync.RWMutex
+)
+
+// syncGrpcWebRoute 按 grpc-web 路径前缀由长到短排序，http 端口优先匹配更长的前缀
+func syncGrpcWebRoute() {
+	routeList := []*warpGrpcServer{}
+	for _, grpcServer := range grpcServerMap {
+		if grpcServer.webHandler != nil && grpcServer.serviceDetail.GRPCRule.GrpcWebPrefix != "" {
+			routeList = append(routeList, grpcServer)
+		}
+	}
+	sort.Slice(routeList, func(i, j int) bool {
+		return len(routeList[i].serviceDetail.GRPCRule.GrpcWebPrefix) > len(routeList[j].serviceDetail.GRPCRule.GrpcWebPrefix)
+	})
+	grpcWebRouteLocker.Lock()
+	defer grpcWebRouteLocker.Unlock()
+	grpcWebRouteList = routeList
+}
+
+// MatchGrpcWebRoute 按路径前缀匹配通过 http 端口接入的 grpc-web 服务，返回处理器及去掉前缀后的 grpc 方法路径
+func MatchGrpcWebRoute(path string) (http.Handler, string, bool) {
+	grpcWebRouteLocker.RLock()
+	defer grpcWebRouteLocker.RUnlock()
+	for _, grpcServer := range grpcWebRouteList {
+		prefix := grpcServer.serviceDetail.GRPCRule.GrpcWebPrefix
+		if strings.HasPrefix(path, prefix+"/") {
+			return grpcServer.webHandler, path[len(prefix):], true
+		}
+	}
+	return nil, "", false
+}
@@ -1,11 +1,10 @@
 package http_proxy_middleware
 
 import (
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"go-gateway/dao"
 	"go-gateway/middleware"
-	"go-gateway/public"
+	"net/http"
 )
 
 // HTTPAccessModeMiddleware 是一个基于 HTTP 请求信息匹配服务接入方式的中间件。
@@ -26,16 +25,14 @@ func HTTPAccessModeMiddleware() gin.HandlerFunc {
 		service, err := dao.ServiceManagerHandler.HTTPAccessMode(c)
 		if err != nil {
 			// 若匹配失败，返回错误并终止请求
-			middleware.ResponseError(c, 1001, err)
+			middleware.ResponseProxyError(c, 1001, http.StatusNotFound, err)
 			c.Abort()
 			return
 		}
 
-		// 打印调试信息：当前匹配到的 service 配置
-		fmt.Println("matched service", public.Obj2Json(service))
-
 		// 将服务配置写入 Context，供后续处理中间件或 handler 使用
 		c.Set("service", service)
+		c.Set(middleware.LegacyErrorResponseKey, service.HTTPRule.LegacyErrorResponse == 1)
 
 		// 继续执行下一个中间件/handler
 		c.Next()
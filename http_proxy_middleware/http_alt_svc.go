@@ -0,0 +1,86 @@
+package http_proxy_middleware
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go-gateway/common/lib"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// HTTPAltSvcMiddleware 开启 http3 时在 https 响应中返回 Alt-Svc，客户端后续请求可切换到 quic；
+// 在写响应头时设置，覆盖下游返回的 Alt-Svc，避免出现重复的头
+func HTTPAltSvcMiddleware() gin.HandlerFunc {
+	altSvc := http3AltSvcHeader()
+	return func(c *gin.Context) {
+		if altSvc != "" && c.Request.TLS != nil && c.Request.ProtoMajor < 3 {
+			c.Writer = &altSvcWriter{ResponseWriter: c.Writer, altSvc: altSvc}
+		}
+		c.Next()
+	}
+}
+
+// altSvcWriter 首次写出响应头前设置 Alt-Svc
+type altSvcWriter struct {
+	gin.ResponseWriter
+	altSvc string
+}
+
+func (w *altSvcWriter) setHeader() {
+	if !w.Written() {
+		w.Header().Set("Alt-Svc", w.altSvc)
+	}
+}
+
+func (w *altSvcWriter) WriteHeader(code int) {
+	w.setHeader()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *altSvcWriter) WriteHeaderNow() {
+	w.setHeader()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *altSvcWriter) Write(data []byte) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *altSvcWriter) WriteString(s string) (int, error) {
+	w.setHeader()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *altSvcWriter) Flush() {
+	w.setHeader()
+	w.ResponseWriter.Flush()
+}
+
+// Unwrap 供 http.ResponseController 设置读写超时
+func (w *altSvcWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// http3AltSvcHeader 端口优先取 proxy.http3.advertise_port，未设置时取监听端口
+func http3AltSvcHeader() string {
+	if !lib.GetBoolConf("proxy.http3.open") {
+		return ""
+	}
+	port := lib.GetIntConf("proxy.http3.advertise_port")
+	if port <= 0 {
+		_, portStr, err := net.SplitHostPort(lib.GetStringConf("proxy.http3.addr"))
+		if err != nil {
+			return ""
+		}
+		if port, err = strconv.Atoi(portStr); err != nil {
+			return ""
+		}
+	}
+	maxAge := lib.GetIntConf("proxy.http3.max_age")
+	if maxAge <= 0 {
+		maxAge = 86400
+	}
+	return fmt.Sprintf(`h3=":%d"; ma=%d`, port, maxAge)
+}
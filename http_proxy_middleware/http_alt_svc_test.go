@@ -0,0 +1,28 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAltSvcWriterReplacesUpstreamHeader(t *testing.T) {
+	gateway := `h3=":4433"; ma=86400`
+	tests := map[string]func(w gin.ResponseWriter){
+		"write_header": func(w gin.ResponseWriter) { w.WriteHeader(http.StatusOK) },
+		"write":        func(w gin.ResponseWriter) { w.Write([]byte("ok")) },
+		"flush":        func(w gin.ResponseWriter) { w.Flush() },
+	}
+	for name, write := range tests {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		w := &altSvcWriter{ResponseWriter: c.Writer, altSvc: gateway}
+		//下游响应头先复制到响应中
+		w.Header().Add("Alt-Svc", `h2=":8443"`)
+		write(w)
+		if got := rec.Header().Values("Alt-Svc"); len(got) != 1 || got[0] != gateway {
+			t.Errorf("%v: Alt-Svc = %q, want only %q", name, got, gateway)
+		}
+	}
+}
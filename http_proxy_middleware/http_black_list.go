@@ -7,6 +7,7 @@ import (
 	"go-gateway/dao"
 	"go-gateway/middleware"
 	"go-gateway/public"
+	"net/http"
 	"strings"
 )
 
@@ -23,7 +24,7 @@ func HTTPBlackListMiddleware() gin.HandlerFunc {
 		serverInterface, ok := c.Get("service")
 		if !ok {
 			// 未获取到服务信息，返回错误并终止请求
-			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
 			c.Abort()
 			return
 		}
@@ -63,9 +64,10 @@ func HTTPBlackListMiddleware() gin.HandlerFunc {
 			if public.InStringSlice(blackIpList, c.ClientIP()) {
 
 				// 命中黑名单，直接拒绝访问
-				middleware.ResponseError(
+				middleware.ResponseProxyError(
 					c,
 					3001,
+					http.StatusForbidden,
 					errors.New(fmt.Sprintf("%s in black ip list", c.ClientIP())),
 				)
 				c.Abort()
@@ -0,0 +1,38 @@
+package http_proxy_middleware
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go-gateway/dao"
+	"go-gateway/middleware"
+	"net/http"
+)
+
+// HTTPCircuitBreakerMiddleware 服务熔断中间件
+// 服务熔断时直接返回配置的降级响应，不再请求下游；
+// 请求下游前由负载均衡占用放行名额，请求结束时上报熔断器
+func HTTPCircuitBreakerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		breaker := serviceDetail.GetServiceBreaker()
+		if breaker != nil && breaker.Blocked() {
+			status, body := serviceDetail.CircuitBreaker.GetFallback()
+			contentType := "text/plain; charset=utf-8"
+			if json.Valid([]byte(body)) {
+				contentType = "application/json; charset=utf-8"
+			}
+			c.Data(status, contentType, []byte(body))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
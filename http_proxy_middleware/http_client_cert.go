@@ -0,0 +1,39 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go-gateway/dao"
+	"go-gateway/middleware"
+	"net/http"
+)
+
+// HTTPClientCertAuthMiddleware 客户端证书认证
+// https 握手时已按 mtls.client_ca_file 校验客户端证书，这里将证书映射为租户写入上下文，
+// 之后与 jwt 租户一样参与鉴权、统计及限流；服务开启客户端证书认证时拒绝未携带有效证书的请求
+func HTTPClientCertAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		clientCert := dao.GetVerifiedClientCert(c.Request.TLS)
+		if clientCert == nil {
+			if serviceDetail.AccessControl.ClientCertAuth == 1 {
+				middleware.ResponseProxyError(c, 1004, http.StatusUnauthorized, errors.New("client certificate required"))
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		if appInfo, ok := dao.AppManagerHandler.GetAppByCert(clientCert); ok {
+			c.Set("app", appInfo)
+		}
+		c.Next()
+	}
+}
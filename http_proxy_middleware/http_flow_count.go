@@ -6,6 +6,7 @@ import (
 	"go-gateway/dao"
 	"go-gateway/middleware"
 	"go-gateway/public"
+	"net/http"
 )
 
 // HTTPFlowCountMiddleware 流量统计中间件
@@ -20,7 +21,7 @@ func HTTPFlowCountMiddleware() gin.HandlerFunc {
 		serverInterface, ok := c.Get("service")
 		if !ok {
 			// 未获取到服务信息，直接返回错误并中断请求
-			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
 			c.Abort()
 			return
 		}
@@ -35,7 +36,7 @@ func HTTPFlowCountMiddleware() gin.HandlerFunc {
 		totalCounter, err := public.FlowCounterHandler.GetCounter(public.FlowTotal)
 		if err != nil {
 			// 获取计数器失败，返回错误并终止请求
-			middleware.ResponseError(c, 4001, err)
+			middleware.ResponseProxyError(c, 4001, http.StatusInternalServerError, err)
 			c.Abort()
 			return
 		}
@@ -56,7 +57,7 @@ func HTTPFlowCountMiddleware() gin.HandlerFunc {
 		)
 		if err != nil {
 			// 获取服务级计数器失败，返回错误并中断请求
-			middleware.ResponseError(c, 4001, err)
+			middleware.ResponseProxyError(c, 4001, http.StatusInternalServerError, err)
 			c.Abort()
 			return
 		}
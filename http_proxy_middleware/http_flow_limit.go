@@ -7,12 +7,15 @@ import (
 	"go-gateway/dao"
 	"go-gateway/middleware"
 	"go-gateway/public"
+	"net/http"
+	"time"
 )
 
 // HTTPFlowLimitMiddleware 服务级与客户端 IP 级限流中间件
 // 功能：
 // 1. 对单个服务进行 QPS 限流
 // 2. 对单个客户端 IP + 服务 维度进行 QPS 限流
+// 3. 对请求匹配的路由进行 QPS 限流
 func HTTPFlowLimitMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
@@ -20,7 +23,7 @@ func HTTPFlowLimitMiddleware() gin.HandlerFunc {
 		serverInterface, ok := c.Get("service")
 		if !ok {
 			// 未获取到服务信息，直接返回错误并中断请求
-			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
 			c.Abort()
 			return
 		}
@@ -40,16 +43,17 @@ func HTTPFlowLimitMiddleware() gin.HandlerFunc {
 			)
 			if err != nil {
 				// 获取限流器失败，返回错误并中断请求
-				middleware.ResponseError(c, 5001, err)
+				middleware.ResponseProxyError(c, 5001, http.StatusInternalServerError, err)
 				c.Abort()
 				return
 			}
 
 			// 判断当前请求是否被限流
 			if !serviceLimiter.Allow() {
-				middleware.ResponseError(
+				middleware.ResponseRateLimit(
 					c,
 					5002,
+					time.Second,
 					errors.New(fmt.Sprintf(
 						"service flow limit %v",
 						serviceDetail.AccessControl.ServiceFlowLimit,
@@ -74,16 +78,17 @@ func HTTPFlowLimitMiddleware() gin.HandlerFunc {
 			)
 			if err != nil {
 				// 获取客户端限流器失败
-				middleware.ResponseError(c, 5003, err)
+				middleware.ResponseProxyError(c, 5003, http.StatusInternalServerError, err)
 				c.Abort()
 				return
 			}
 
 			// 判断当前客户端是否被限流
 			if !clientLimiter.Allow() {
-				middleware.ResponseError(
+				middleware.ResponseRateLimit(
 					c,
 					5002,
+					time.Second,
 					errors.New(fmt.Sprintf(
 						"%v flow limit %v",
 						c.ClientIP(),
@@ -95,6 +100,27 @@ func HTTPFlowLimitMiddleware() gin.HandlerFunc {
 			}
 		}
 
+		// =====================================================
+		// 3️⃣ 路由级限流（按 Service + 路由 维度）
+		// =====================================================
+		if route := serviceDetail.Route; route != nil && route.QPS > 0 {
+			routeLimiter, err := public.FlowLimiterHandler.GetLimiter(
+				public.FlowServicePrefix+serviceDetail.Info.ServiceName+route.Name,
+				float64(route.QPS),
+			)
+			if err != nil {
+				middleware.ResponseProxyError(c, 5001, http.StatusInternalServerError, err)
+				c.Abort()
+				return
+			}
+			if !routeLimiter.Allow() {
+				middleware.ResponseRateLimit(c, 5002, time.Second,
+					errors.New(fmt.Sprintf("route %v flow limit %v", route.Path, route.QPS)))
+				c.Abort()
+				return
+			}
+		}
+
 		// 未触发任何限流规则，放行请求
 		c.Next()
 	}
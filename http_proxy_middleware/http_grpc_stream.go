@@ -0,0 +1,46 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-gateway/common/lib"
+	"go-gateway/dao"
+	"go-gateway/public"
+	"net/http"
+	"time"
+)
+
+// DefaultGrpcStreamMaxDuration 未配置 grpc_stream.max_duration 时 grpc 请求的最长读写时长
+const DefaultGrpcStreamMaxDuration = time.Hour
+
+// HTTPGrpcStreamMiddleware 下游为 http2/h2c 的服务收到 grpc 请求时，读写超时放宽到 grpc_stream.max_duration，
+// 服务端流式调用可持续超过 write_timeout；须在服务匹配之后，未匹配服务或普通 http 服务的请求仍受端口超时限制
+func HTTPGrpcStreamMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok || !public.IsGrpcRequest(c.Request) {
+			c.Next()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		switch serviceDetail.HTTPRule.UpstreamProtocol {
+		case public.UpstreamProtocolHttp2, public.UpstreamProtocolH2c:
+			extendGrpcStreamDeadline(c.Writer, grpcStreamMaxDuration())
+		}
+		c.Next()
+	}
+}
+
+func grpcStreamMaxDuration() time.Duration {
+	if maxDuration := lib.GetIntConf("proxy.grpc_stream.max_duration"); maxDuration > 0 {
+		return time.Duration(maxDuration) * time.Second
+	}
+	return DefaultGrpcStreamMaxDuration
+}
+
+// extendGrpcStreamDeadline 以当前时间重设读写截止时间，http/1.1 及 http2 连接均支持，不支持时沿用端口超时
+func extendGrpcStreamDeadline(w http.ResponseWriter, maxDuration time.Duration) {
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(maxDuration)
+	_ = rc.SetReadDeadline(deadline)
+	_ = rc.SetWriteDeadline(deadline)
+}
@@ -0,0 +1,103 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"go-gateway/common/lib"
+	"go-gateway/dao"
+	"go-gateway/public"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newGrpcStreamTestServer 处理时长 300ms，超过端口的 WriteTimeout
+func newGrpcStreamTestServer(handlers ...gin.HandlerFunc) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(handlers...)
+	router.Any("/*path", func(c *gin.Context) {
+		time.Sleep(300 * time.Millisecond)
+		c.String(http.StatusOK, "done")
+	})
+	server := httptest.NewUnstartedServer(router)
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	return server
+}
+
+func grpcStreamRequestCompleted(url, contentType string) bool {
+	req, _ := http.NewRequest(http.MethodPost, url+"/svc/Method", strings.NewReader("x"))
+	req.Header.Set("Content-Type", contentType)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return err == nil && string(body) == "done"
+}
+
+// setTestProxyConf 设置 proxy 配置，返回后恢复
+func setTestProxyConf(t *testing.T, key string, value interface{}) {
+	v := viper.New()
+	v.Set(key, value)
+	oldConfMap := lib.ViperConfMap
+	lib.ViperConfMap = map[string]*viper.Viper{"proxy": v}
+	t.Cleanup(func() { lib.ViperConfMap = oldConfMap })
+}
+
+func TestGrpcStreamMiddleware(t *testing.T) {
+	setTestProxyConf(t, "grpc_stream.max_duration", 1)
+	tests := []struct {
+		name        string
+		protocol    int
+		noService   bool
+		contentType string
+		want        bool
+	}{
+		{"h2c_grpc", public.UpstreamProtocolH2c, false, "application/grpc", true},
+		{"http2_grpc", public.UpstreamProtocolHttp2, false, "application/grpc+proto", true},
+		{"h2c_json", public.UpstreamProtocolH2c, false, "application/json", false},
+		{"http1_grpc", public.UpstreamProtocolHttp1, false, "application/grpc", false},
+		{"auto_grpc", public.UpstreamProtocolAuto, false, "application/grpc", false},
+		//未匹配服务时请求头不能放宽超时
+		{"no_service_grpc", 0, true, "application/grpc", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newGrpcStreamTestServer(func(c *gin.Context) {
+				if !test.noService {
+					c.Set("service", &dao.ServiceDetail{HTTPRule: &dao.HttpRule{UpstreamProtocol: test.protocol}})
+				}
+			}, HTTPGrpcStreamMiddleware())
+			defer server.Close()
+			if got := grpcStreamRequestCompleted(server.URL, test.contentType); got != test.want {
+				t.Errorf("completed = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExtendGrpcStreamDeadline(t *testing.T) {
+	tests := []struct {
+		maxDuration time.Duration
+		want        bool
+	}{
+		{time.Second, true},
+		//放宽后仍有上限
+		{150 * time.Millisecond, false},
+	}
+	for _, test := range tests {
+		server := newGrpcStreamTestServer(func(c *gin.Context) {
+			extendGrpcStreamDeadline(c.Writer, test.maxDuration)
+		})
+		if got := grpcStreamRequestCompleted(server.URL, "application/grpc"); got != test.want {
+			t.Errorf("max duration %v: completed = %v, want %v", test.maxDuration, got, test.want)
+		}
+		server.Close()
+	}
+}
@@ -0,0 +1,245 @@
+package http_proxy_middleware
+
+import (
+	"context"
+	"crypto/tls"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go-gateway/dao"
+	"go-gateway/middleware"
+	"go-gateway/public"
+	"go-gateway/reverse_proxy"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultGrpcTranscodeTimeout 单次调用超时，未设置下游响应头超时时使用，单位s
+	defaultGrpcTranscodeTimeout = 30
+	// grpcReflectionTimeout 通过 server reflection 获取描述的超时时间
+	grpcReflectionTimeout = 10 * time.Second
+	// grpcTranscoderRetryDelay 创建失败后首次重试的等待时间，连续失败时翻倍，不超过 grpcTranscoderRetryMaxDelay
+	grpcTranscoderRetryDelay    = time.Second
+	grpcTranscoderRetryMaxDelay = time.Minute
+)
+
+// HTTPGrpcTranscodeMiddleware 开启 json 转 grpc 的服务，请求按描述文件中的 google.api.http 映射转换为 grpc 调用；
+// 位于鉴权、限流、熔断等中间件之后，原生 grpc 请求仍交给反向代理透传
+func HTTPGrpcTranscodeMiddleware() gin.HandlerFunc {
+	GrpcTranscoderHandler.attach.Do(func() {
+		dao.ServiceManagerHandler.Attach(GrpcTranscoderHandler)
+	})
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		if serviceDetail.HTTPRule.GrpcTranscode == public.GrpcTranscodeOff || public.IsGrpcRequest(c.Request) {
+			c.Next()
+			return
+		}
+
+		//转换器按服务缓存，使用未经路由覆盖的服务配置
+		transcoder, release, err := GrpcTranscoderHandler.GetTranscoder(serviceDetail.Origin())
+		if err != nil {
+			middleware.ResponseProxyError(c, 2006, http.StatusBadGateway, err)
+			c.Abort()
+			return
+		}
+		defer release()
+		transcoder.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
+
+var GrpcTranscoderHandler *GrpcTranscoderManager
+
+func init() {
+	GrpcTranscoderHandler = NewGrpcTranscoderManager()
+}
+
+type grpcTranscoderItem struct {
+	transcoder *reverse_proxy.GrpcTranscoder
+	connPool   *reverse_proxy.GrpcConnPool
+	conf       string         //创建时的服务配置，热加载后据此判断是否需要重建
+	active     sync.WaitGroup //处理中的请求，移除后等待结束再关闭连接池
+}
+
+// grpcTranscoderFailure 创建失败的记录，重试时间之前直接返回该错误，避免每个请求都访问下游获取描述
+type grpcTranscoderFailure struct {
+	err      error
+	failures int
+	retryAt  time.Time
+	conf     string
+}
+
+// GrpcTranscoderManager 按服务缓存 json 转 grpc 的转换器及下游连接池，服务配置变更后关闭并在下次请求时重建
+type GrpcTranscoderManager struct {
+	TranscoderMap map[string]*grpcTranscoderItem
+	FailureMap    map[string]*grpcTranscoderFailure
+	Locker        sync.RWMutex
+	attach        sync.Once
+}
+
+func NewGrpcTranscoderManager() *GrpcTranscoderManager {
+	return &GrpcTranscoderManager{
+		TranscoderMap: map[string]*grpcTranscoderItem{},
+		FailureMap:    map[string]*grpcTranscoderFailure{},
+		Locker:        sync.RWMutex{},
+	}
+}
+
+// GetTranscoder 返回服务的转换器，请求处理结束后需调用 release
+func (g *GrpcTranscoderManager) GetTranscoder(serviceDetail *dao.ServiceDetail) (*reverse_proxy.GrpcTranscoder, func(), error) {
+	serviceName := serviceDetail.Info.ServiceName
+	g.Locker.RLock()
+	item, ok := g.TranscoderMap[serviceName]
+	if ok {
+		item.active.Add(1)
+	}
+	var failure grpcTranscoderFailure
+	if exist, failed := g.FailureMap[serviceName]; failed {
+		failure = *exist
+	}
+	g.Locker.RUnlock()
+	if ok {
+		return item.transcoder, item.active.Done, nil
+	}
+	conf := public.Obj2Json(serviceDetail)
+	if failure.conf == conf && time.Now().Before(failure.retryAt) {
+		return nil, nil, failure.err
+	}
+
+	//通过 reflection 获取描述需要请求下游，不持锁创建，并发创建时保留先完成的一个
+	item, err := newGrpcTranscoderItem(serviceDetail, conf)
+	g.Locker.Lock()
+	defer g.Locker.Unlock()
+	if err != nil {
+		g.addFailure(serviceName, conf, err)
+		return nil, nil, err
+	}
+	delete(g.FailureMap, serviceName)
+	if exist, ok := g.TranscoderMap[serviceName]; ok {
+		item.connPool.Close()
+		item = exist
+	} else {
+		g.TranscoderMap[serviceName] = item
+	}
+	item.active.Add(1)
+	return item.transcoder, item.active.Done, nil
+}
+
+func (g *GrpcTranscoderManager) addFailure(serviceName, conf string, err error) {
+	failure, ok := g.FailureMap[serviceName]
+	if !ok || failure.conf != conf {
+		failure = &grpcTranscoderFailure{conf: conf}
+		g.FailureMap[serviceName] = failure
+	}
+	//并发创建失败时只按先完成的一次计数
+	if time.Now().Before(failure.retryAt) {
+		return
+	}
+	failure.failures++
+	failure.err = err
+	delay := public.RetryDelay(failure.failures, grpcTranscoderRetryDelay, grpcTranscoderRetryMaxDelay)
+	failure.retryAt = time.Now().Add(delay)
+	log.Printf(" [ERROR] grpc_transcoder %v err:%v, retry after %v\n", serviceName, err, delay)
+}
+
+// Update 服务配置热加载后移除配置变更或已删除服务的转换器，处理中的请求结束后再关闭连接池
+func (g *GrpcTranscoderManager) Update() {
+	g.Locker.Lock()
+	defer g.Locker.Unlock()
+	for serviceName, failure := range g.FailureMap {
+		serviceDetail, ok := dao.ServiceManagerHandler.GetServiceDetail(serviceName)
+		if !ok || public.Obj2Json(serviceDetail) != failure.conf {
+			delete(g.FailureMap, serviceName)
+		}
+	}
+	for serviceName, item := range g.TranscoderMap {
+		serviceDetail, ok := dao.ServiceManagerHandler.GetServiceDetail(serviceName)
+		if ok && public.Obj2Json(serviceDetail) == item.conf {
+			continue
+		}
+		delete(g.TranscoderMap, serviceName)
+		//已从缓存移除，不会再有新的请求使用
+		go func(item *grpcTranscoderItem) {
+			item.active.Wait()
+			item.connPool.Close()
+		}(item)
+		log.Printf(" [INFO] grpc_transcoder %v removed\n", serviceName)
+	}
+}
+
+func newGrpcTranscoderItem(serviceDetail *dao.ServiceDetail, conf string) (*grpcTranscoderItem, error) {
+	lb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
+	if err != nil {
+		return nil, err
+	}
+	lbConf, err := dao.LoadBalancerHandler.GetLoadBalanceConf(serviceDetail)
+	if err != nil {
+		return nil, err
+	}
+	//下游 scheme 为 https 时以 tls 连接下游，设置了下游 tls 参数时使用该参数
+	var upstreamTLS *tls.Config
+	if serviceDetail.LoadBalance.UseUpstreamTLS() {
+		if upstreamTLS, err = serviceDetail.LoadBalance.GetUpstreamTLSConfig(); err != nil {
+			return nil, err
+		}
+	} else if serviceDetail.HTTPRule.UpstreamUseHttps() {
+		upstreamTLS = &tls.Config{}
+	}
+	connPool := reverse_proxy.NewGrpcConnPool(lbConf, upstreamTLS)
+	files, err := loadGrpcDescriptor(serviceDetail, lb.Get, connPool)
+	if err != nil {
+		connPool.Close()
+		return nil, err
+	}
+	transcoder, err := reverse_proxy.NewGrpcTranscoder(files, lb, connPool)
+	if err != nil {
+		connPool.Close()
+		return nil, err
+	}
+	timeout := serviceDetail.LoadBalance.UpstreamHeaderTimeout
+	if timeout == 0 {
+		timeout = defaultGrpcTranscodeTimeout
+	}
+	transcoder.Timeout = time.Duration(timeout) * time.Second
+	return &grpcTranscoderItem{
+		transcoder: transcoder,
+		connPool:   connPool,
+		conf:       conf,
+	}, nil
+}
+
+// loadGrpcDescriptor 使用上传的描述文件，或从负载均衡选出的下游节点通过 server reflection 获取
+func loadGrpcDescriptor(serviceDetail *dao.ServiceDetail, next func(string) (string, error),
+	connPool *reverse_proxy.GrpcConnPool) (*protoregistry.Files, error) {
+	if serviceDetail.HTTPRule.GrpcTranscode == public.GrpcTranscodeDescriptor {
+		return public.ParseGrpcDescriptorSet(serviceDetail.HTTPRule.GrpcDescriptor)
+	}
+	addr, err := next("")
+	if err != nil {
+		return nil, err
+	}
+	if addr == "" {
+		return nil, errors.New("get next addr fail")
+	}
+	conn, err := connPool.Get(addr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), grpcReflectionTimeout)
+	defer cancel()
+	files, err := reverse_proxy.FetchGrpcDescriptorByReflection(ctx, conn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "fetch grpc descriptor from %v", addr)
+	}
+	return files, nil
+}
@@ -0,0 +1,29 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"go-gateway/grpc_proxy_router"
+	"go-gateway/reverse_proxy"
+)
+
+// HTTPGrpcWebMiddleware grpc-web 请求的路径匹配 grpc 服务的 grpc_web_prefix 时，去掉前缀后交给该服务的 grpc-web 处理器，
+// 经过 grpc 服务的鉴权、黑白名单及限流拦截器，不再匹配 http 服务；流式调用的读写超时同 HTTPGrpcStreamMiddleware
+func HTTPGrpcWebMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !reverse_proxy.IsGrpcWebRequest(c.Request) && !reverse_proxy.IsGrpcWebPreflight(c.Request) {
+			c.Next()
+			return
+		}
+		handler, path, ok := grpc_proxy_router.MatchGrpcWebRoute(c.Request.URL.Path)
+		if !ok {
+			c.Next()
+			return
+		}
+		//只有已配置前缀的 grpc-web 请求放宽读写超时
+		extendGrpcStreamDeadline(c.Writer, grpcStreamMaxDuration())
+		c.Request.URL.Path = path
+		c.Request.URL.RawPath = ""
+		handler.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/pkg/errors"
 	"go-gateway/dao"
 	"go-gateway/middleware"
+	"net/http"
 	"strings"
 )
 
@@ -17,7 +18,7 @@ func HTTPHeaderTransferMiddleware() gin.HandlerFunc {
 		serverInterface, ok := c.Get("service")
 		if !ok {
 			// 未找到服务信息，直接返回错误并中断请求
-			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
 			c.Abort()
 			return
 		}
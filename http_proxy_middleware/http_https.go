@@ -0,0 +1,67 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go-gateway/common/lib"
+	"go-gateway/dao"
+	"go-gateway/middleware"
+	"go-gateway/public"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// HTTPHttpsMiddleware 开启 https 的服务按策略处理明文 http 请求，https 请求按配置返回 HSTS
+func HTTPHttpsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+		httpRule := serviceDetail.HTTPRule
+		if httpRule.NeedHttps != 1 {
+			c.Next()
+			return
+		}
+
+		if c.Request.TLS != nil {
+			//HSTS 只能在 https 响应中返回
+			if hsts := httpRule.GetHstsHeader(); hsts != "" {
+				c.Header("Strict-Transport-Security", hsts)
+			}
+			c.Next()
+			return
+		}
+
+		switch httpRule.HttpsPolicy {
+		case public.HttpsPolicyRedirect:
+			c.Redirect(httpRule.GetRedirectCode(), httpsRedirectURL(c))
+			c.Abort()
+			return
+		case public.HttpsPolicyReject:
+			middleware.ResponseProxyError(c, 1003, http.StatusForbidden, errors.New("service requires https"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// httpsRedirectURL 跳转地址使用 proxy.https.addr 的端口，443 时省略
+func httpsRedirectURL(c *gin.Context) string {
+	host := c.Request.Host
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if _, port, err := net.SplitHostPort(lib.GetStringConf("proxy.https.addr")); err == nil && port != "" && port != "443" {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+	}
+	return "https://" + host + c.Request.URL.RequestURI()
+}
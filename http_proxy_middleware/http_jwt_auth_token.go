@@ -6,6 +6,7 @@ import (
 	"go-gateway/dao"
 	"go-gateway/middleware"
 	"go-gateway/public"
+	"net/http"
 	"strings"
 )
 
@@ -22,7 +23,7 @@ func HTTPJwtAuthTokenMiddleware() gin.HandlerFunc {
 		// 获取当前请求匹配的服务信息
 		serverInterface, ok := c.Get("service")
 		if !ok {
-			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
 			c.Abort()
 			return
 		}
@@ -32,15 +33,15 @@ func HTTPJwtAuthTokenMiddleware() gin.HandlerFunc {
 		// 格式示例：Authorization: Bearer xxxxx.yyyyy.zzzzz
 		token := strings.ReplaceAll(c.GetHeader("Authorization"), "Bearer ", "")
 
-		// 标识是否成功匹配到合法 App
-		appMatched := false
+		// 标识是否成功匹配到合法 App，客户端证书已映射到租户时不再解析 Token
+		_, appMatched := c.Get("app")
 
-		if token != "" {
+		if !appMatched && token != "" {
 			// 解析 JWT Token
 			claims, err := public.JwtDecode(token)
 			if err != nil {
 				// Token 非法或过期
-				middleware.ResponseError(c, 2002, err)
+				middleware.ResponseProxyError(c, 2002, http.StatusUnauthorized, err)
 				c.Abort()
 				return
 			}
@@ -59,7 +60,7 @@ func HTTPJwtAuthTokenMiddleware() gin.HandlerFunc {
 
 		// 若服务开启鉴权但未匹配到合法 App，则直接拒绝访问
 		if serviceDetail.AccessControl.OpenAuth == 1 && !appMatched {
-			middleware.ResponseError(c, 2003, errors.New("not match valid app"))
+			middleware.ResponseProxyError(c, 2003, http.StatusUnauthorized, errors.New("not match valid app"))
 			c.Abort()
 			return
 		}
@@ -7,6 +7,8 @@ import (
 	"go-gateway/dao"
 	"go-gateway/middleware"
 	"go-gateway/public"
+	"net/http"
+	"time"
 )
 
 // HTTPJwtFlowCountMiddleware 租户（JWT 应用）流量统计与日限流中间件
@@ -35,7 +37,7 @@ func HTTPJwtFlowCountMiddleware() gin.HandlerFunc {
 		)
 		if err != nil {
 			// 获取租户计数器失败，返回错误并中断请求
-			middleware.ResponseError(c, 2002, err)
+			middleware.ResponseProxyError(c, 2002, http.StatusInternalServerError, err)
 			c.Abort()
 			return
 		}
@@ -49,9 +51,10 @@ func HTTPJwtFlowCountMiddleware() gin.HandlerFunc {
 		if appInfo.Qpd > 0 && appCounter.TotalCount > appInfo.Qpd {
 
 			// 超出每日请求上限，触发限流
-			middleware.ResponseError(
+			middleware.ResponseRateLimit(
 				c,
 				2003,
+				public.UntilNextDay(time.Now()),
 				errors.New(fmt.Sprintf(
 					"租户日请求量限流 limit:%v current:%v",
 					appInfo.Qpd,
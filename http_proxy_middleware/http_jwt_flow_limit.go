@@ -7,6 +7,8 @@ import (
 	"go-gateway/dao"
 	"go-gateway/middleware"
 	"go-gateway/public"
+	"net/http"
+	"time"
 )
 
 // HTTPJwtFlowLimitMiddleware 租户级 QPS 限流中间件（基于 JWT 解析出的 App）
@@ -39,16 +41,17 @@ func HTTPJwtFlowLimitMiddleware() gin.HandlerFunc {
 			)
 			if err != nil {
 				// 获取限流器失败
-				middleware.ResponseError(c, 5001, err)
+				middleware.ResponseProxyError(c, 5001, http.StatusInternalServerError, err)
 				c.Abort()
 				return
 			}
 
 			// 判断当前请求是否超过租户 QPS 限制
 			if !clientLimiter.Allow() {
-				middleware.ResponseError(
+				middleware.ResponseRateLimit(
 					c,
 					5002,
+					time.Second,
 					errors.New(fmt.Sprintf(
 						"%v flow limit %v",
 						c.ClientIP(),
@@ -3,9 +3,13 @@ package http_proxy_middleware
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"go-gateway/common/lib"
 	"go-gateway/dao"
 	"go-gateway/middleware"
+	"go-gateway/public"
 	"go-gateway/reverse_proxy"
+	"net/http"
+	"time"
 )
 
 // HTTPReverseProxyMiddleware 是 HTTP 反向代理中间件。
@@ -14,8 +18,9 @@ import (
 //  1. 从上下文中读取已匹配的服务信息（ServiceDetail）。
 //  2. 根据服务配置选择对应的负载均衡器实例。
 //  3. 根据服务配置创建或获取 HTTP 传输代理（Transport）。
-//  4. 创建基于负载均衡算法的 ReverseProxy。
-//  5. 将当前请求转发到后端服务节点，并直接返回响应。
+//  4. 根据服务配置生成失败重试策略。
+//  5. 创建基于负载均衡算法的 ReverseProxy，websocket 升级请求交给 websocket 代理。
+//  6. 将当前请求转发到后端服务节点，并直接返回响应。
 //
 // 注意：
 //   - ReverseProxy 直接对 c.Writer 写响应，因此本中间件执行后需要 Abort，
@@ -27,7 +32,7 @@ func HTTPReverseProxyMiddleware() gin.HandlerFunc {
 		// 1. 从 Gin Context 中读取匹配的服务详情
 		serverInterface, ok := c.Get("service")
 		if !ok {
-			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
 			c.Abort()
 			return
 		}
@@ -36,7 +41,7 @@ func HTTPReverseProxyMiddleware() gin.HandlerFunc {
 		// 2. 获取负载均衡器（随机、轮询、加权等策略）
 		lb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
 		if err != nil {
-			middleware.ResponseError(c, 2002, err)
+			middleware.ResponseProxyError(c, 2002, http.StatusInternalServerError, err)
 			c.Abort()
 			return
 		}
@@ -44,19 +49,75 @@ func HTTPReverseProxyMiddleware() gin.HandlerFunc {
 		// 3. 获取服务的 Transport（HTTP Client 代理）用于转发请求
 		trans, err := dao.TransportorHandler.GetTrans(serviceDetail)
 		if err != nil {
-			middleware.ResponseError(c, 2003, err)
+			middleware.ResponseProxyError(c, 2003, http.StatusInternalServerError, err)
 			c.Abort()
 			return
 		}
 
-		// 4. 创建一个基于负载均衡的反向代理，并将请求转发到后端服务
-		proxy := reverse_proxy.NewLoadBalanceReverseProxy(c, lb, trans)
+		// websocket 升级请求由 websocket 代理接管连接，不参与重试
+		if reverse_proxy.IsWebsocketRequest(c.Request) {
+			wsConf, err := newWebsocketConf(serviceDetail)
+			if err != nil {
+				middleware.ResponseProxyError(c, 2005, http.StatusInternalServerError, err)
+				c.Abort()
+				return
+			}
+			reverse_proxy.NewWebsocketReverseProxy(c, lb, trans, wsConf).ServeHTTP(c.Writer, c.Request)
+			c.Abort()
+			return
+		}
+
+		// 4. 生成失败重试策略，重试受服务重试预算限制，重试次数计入服务统计
+		retry, err := newRetryPolicy(serviceDetail)
+		if err != nil {
+			middleware.ResponseProxyError(c, 2004, http.StatusInternalServerError, err)
+			c.Abort()
+			return
+		}
+
+		// 5. 创建一个基于负载均衡的反向代理，并将请求转发到后端服务
+		proxy := reverse_proxy.NewLoadBalanceReverseProxy(c, lb, trans, retry)
 
 		// proxy 会直接写响应，因此这里不再调用 c.Next()
 		proxy.ServeHTTP(c.Writer, c.Request)
 
-		// 5. 终止后续中间件，防止重复写响应
+		// 6. 终止后续中间件，防止重复写响应
 		c.Abort()
 		return
 	}
 }
+
+const (
+	defaultRetryBudgetPercent      = 20
+	defaultRetryBudgetMinPerSecond = 3
+)
+
+// newRetryPolicy 服务未开启重试时返回 nil
+func newRetryPolicy(serviceDetail *dao.ServiceDetail) (*reverse_proxy.RetryPolicy, error) {
+	if serviceDetail.LoadBalance.RetryMax <= 0 {
+		return nil, nil
+	}
+	retry, err := reverse_proxy.NewRetryPolicy(
+		serviceDetail.LoadBalance.RetryMax,
+		serviceDetail.LoadBalance.RetryOn,
+		serviceDetail.LoadBalance.RetryNonIdempotent == 1,
+		time.Duration(serviceDetail.LoadBalance.RetryPerTryTimeout)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	percent := lib.GetIntConf("proxy.retry.budget_percent")
+	if percent <= 0 {
+		percent = defaultRetryBudgetPercent
+	}
+	minPerSecond := lib.GetIntConf("proxy.retry.min_retries_per_second")
+	if minPerSecond <= 0 {
+		minPerSecond = defaultRetryBudgetMinPerSecond
+	}
+	retry.Budget = public.RetryBudgetHandler.GetBudget(serviceDetail.Info.ServiceName, percent, minPerSecond)
+	retryCounter, err := public.FlowCounterHandler.GetCounter(public.FlowRetryPrefix + serviceDetail.Info.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	retry.OnRetry = retryCounter.Increase
+	return retry, nil
+}
@@ -5,7 +5,7 @@ import (
 	"github.com/pkg/errors"
 	"go-gateway/dao"
 	"go-gateway/middleware"
-	"go-gateway/public"
+	"net/http"
 	"strings"
 )
 
@@ -14,18 +14,21 @@ func HTTPStripUriMiddleware() gin.HandlerFunc {
 		// 获取服务信息
 		serverInterface, ok := c.Get("service")
 		if !ok {
-			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
 			c.Abort()
 			return
 		}
 		serviceDetail := serverInterface.(*dao.ServiceDetail)
 
 		// 判断是否需要剥离URI
-		if serviceDetail.HTTPRule.RuleType == public.HTTPRuleTypePrefixURL && serviceDetail.HTTPRule.NeedStripUri == 1 {
-			//fmt.Println("c.Request.URL.Path",c.Request.URL.Path)
-			// 剥离URI前缀
-			c.Request.URL.Path = strings.Replace(c.Request.URL.Path, serviceDetail.HTTPRule.Rule, "", 1)
-			//fmt.Println("c.Request.URL.Path",c.Request.URL.Path)
+		// 剥离匹配路由的路径前缀，域名接入未带路径前缀及精确、正则匹配的路由不剥离
+		if serviceDetail.HTTPRule.NeedStripUri == 1 && serviceDetail.Route != nil {
+			if prefix := serviceDetail.Route.StripPrefix(); prefix != "" {
+				//fmt.Println("c.Request.URL.Path",c.Request.URL.Path)
+				// 剥离URI前缀
+				c.Request.URL.Path = strings.Replace(c.Request.URL.Path, prefix, "", 1)
+				//fmt.Println("c.Request.URL.Path",c.Request.URL.Path)
+			}
 		}
 		//http://127.0.0.1:8080/test_http_string/abbb
 		//http://127.0.0.1:2004/abbb
@@ -5,6 +5,7 @@ import (
 	"github.com/pkg/errors"
 	"go-gateway/dao"
 	"go-gateway/middleware"
+	"net/http"
 	"regexp"
 	"strings"
 )
@@ -21,7 +22,7 @@ func HTTPUrlRewriteMiddleware() gin.HandlerFunc {
 		// 从上下文中获取当前请求对应的服务信息
 		serverInterface, ok := c.Get("service")
 		if !ok {
-			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
 			c.Abort()
 			return
 		}
@@ -0,0 +1,51 @@
+package http_proxy_middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go-gateway/common/lib"
+	"go-gateway/dao"
+	"go-gateway/middleware"
+	"go-gateway/public"
+	"go-gateway/reverse_proxy"
+	"net/http"
+	"time"
+)
+
+// HTTPWebsocketMiddleware websocket 升级请求校验
+// 服务未开启 websocket 时拒绝升级请求，避免升级请求被当作普通请求转发
+func HTTPWebsocketMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverInterface, ok := c.Get("service")
+		if !ok {
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		if reverse_proxy.IsWebsocketRequest(c.Request) && serviceDetail.HTTPRule.NeedWebsocket != 1 {
+			middleware.ResponseProxyError(c, 1002, http.StatusBadRequest, errors.New("service not support websocket"))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// newWebsocketConf websocket 超时及消息大小限制取全局配置，每个连接计入服务统计
+func newWebsocketConf(serviceDetail *dao.ServiceDetail) (reverse_proxy.WebsocketConf, error) {
+	conf := reverse_proxy.WebsocketConf{
+		IdleTimeout:    time.Duration(lib.GetIntConf("proxy.websocket.idle_timeout")) * time.Second,
+		MaxLifetime:    time.Duration(lib.GetIntConf("proxy.websocket.max_lifetime")) * time.Second,
+		MaxMessageSize: int64(lib.GetIntConf("proxy.websocket.max_message_size")),
+		CloseGrace:     time.Duration(lib.GetIntConf("proxy.websocket.close_grace")) * time.Second,
+		WriteTimeout:   time.Duration(lib.GetIntConf("proxy.websocket.write_timeout")) * time.Second,
+	}
+	wsCounter, err := public.FlowCounterHandler.GetCounter(public.FlowWebsocketPrefix + serviceDetail.Info.ServiceName)
+	if err != nil {
+		return conf, err
+	}
+	conf.OnConnect = wsCounter.Increase
+	return conf, nil
+}
@@ -7,6 +7,7 @@ import (
 	"go-gateway/dao"
 	"go-gateway/middleware"
 	"go-gateway/public"
+	"net/http"
 	"strings"
 )
 
@@ -23,7 +24,7 @@ func HTTPWhiteListMiddleware() gin.HandlerFunc {
 		serverInterface, ok := c.Get("service")
 		if !ok {
 			// 未找到服务信息，返回错误并中断请求
-			middleware.ResponseError(c, 2001, errors.New("service not found"))
+			middleware.ResponseProxyError(c, 2001, http.StatusInternalServerError, errors.New("service not found"))
 			c.Abort()
 			return
 		}
@@ -50,9 +51,10 @@ func HTTPWhiteListMiddleware() gin.HandlerFunc {
 
 			// 当前客户端 IP 不在白名单内，拒绝访问
 			if !public.InStringSlice(iplist, c.ClientIP()) {
-				middleware.ResponseError(
+				middleware.ResponseProxyError(
 					c,
 					3001,
+					http.StatusForbidden,
 					errors.New(fmt.Sprintf("%s not in white ip list", c.ClientIP())),
 				)
 				c.Abort()
@@ -0,0 +1,68 @@
+package http_proxy_router
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"go-gateway/common/lib"
+	"go-gateway/middleware"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	Http3SrvHandler *http3.Server
+	http3SrvLocker  sync.Mutex //Http3SrvHandler 在启动协程中赋值，退出时在主协程读取
+	http3SrvStopped bool
+)
+
+// Http3ServerRun 开启 proxy.http3.open 时在 udp 端口提供 http3 服务，证书及路由与 https 相同
+func Http3ServerRun() {
+	if !lib.GetBoolConf("proxy.http3.open") {
+		return
+	}
+	gin.SetMode(lib.GetStringConf("proxy.base.debug_mode"))
+	r := InitRouter(middleware.RecoveryMiddleware(),
+		middleware.RequestLog())
+	quicConf := &quic.Config{}
+	if idleTimeout := lib.GetIntConf("proxy.http3.idle_timeout"); idleTimeout > 0 {
+		quicConf.MaxIdleTimeout = time.Duration(idleTimeout) * time.Second
+	}
+	srv := &http3.Server{
+		Addr:           lib.GetStringConf("proxy.http3.addr"),
+		Handler:        r,
+		TLSConfig:      newHttpsTLSConfig(),
+		QUICConfig:     quicConf,
+		MaxHeaderBytes: 1 << uint(lib.GetIntConf("proxy.http3.max_header_bytes")),
+	}
+	http3SrvLocker.Lock()
+	if http3SrvStopped {
+		http3SrvLocker.Unlock()
+		return
+	}
+	Http3SrvHandler = srv
+	http3SrvLocker.Unlock()
+	log.Printf(" [INFO] http3_proxy_run %s\n", lib.GetStringConf("proxy.http3.addr"))
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf(" [ERROR] http3_proxy_run %s err:%v\n", lib.GetStringConf("proxy.http3.addr"), err)
+	}
+}
+
+func Http3ServerStop() {
+	http3SrvLocker.Lock()
+	http3SrvStopped = true
+	srv := Http3SrvHandler
+	http3SrvLocker.Unlock()
+	if srv == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf(" [ERROR] http3_proxy_stop err:%v\n", err)
+	}
+	log.Printf(" [INFO] http3_proxy_stop %v stopped\n", lib.GetStringConf("proxy.http3.addr"))
+}
@@ -2,10 +2,17 @@ package http_proxy_router
 
 import (
 	"context"
+	"crypto/tls"
 	"github.com/gin-gonic/gin"
 	"go-gateway/common/lib"
+	"go-gateway/dao"
+	"go-gateway/http_proxy_middleware"
 	"go-gateway/middleware"
+	"go-gateway/public"
+	"go-gateway/reverse_proxy"
+	"go-gateway/tcp_server"
 	"log"
+	"net"
 	"net/http"
 	"time"
 )
@@ -26,8 +33,18 @@ func HttpServerRun() {
 		WriteTimeout:   time.Duration(lib.GetIntConf("proxy.http.write_timeout")) * time.Second,
 		MaxHeaderBytes: 1 << uint(lib.GetIntConf("proxy.http.max_header_bytes")),
 	}
+	//明文端口同时接受 h2c(prior knowledge) 连接，grpc 客户端可直接访问 http 端口
+	HttpSrvHandler.Protocols = &http.Protocols{}
+	HttpSrvHandler.Protocols.SetHTTP1(true)
+	HttpSrvHandler.Protocols.SetUnencryptedHTTP2(true)
+	//websocket 连接已被接管，Shutdown 不会等待，需单独发送关闭帧
+	HttpSrvHandler.RegisterOnShutdown(reverse_proxy.CloseWebsocketSessions)
 	log.Printf(" [INFO] http_proxy_run %s\n", lib.GetStringConf("proxy.http.addr"))
-	if err := HttpSrvHandler.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	ln, err := listenProxyProto(HttpSrvHandler.Addr)
+	if err != nil {
+		log.Fatalf(" [ERROR] http_proxy_run %s err:%v\n", lib.GetStringConf("proxy.http.addr"), err)
+	}
+	if err := HttpSrvHandler.Serve(ln); err != nil && err != http.ErrServerClosed {
 		log.Fatalf(" [ERROR] http_proxy_run %s err:%v\n", lib.GetStringConf("proxy.http.addr"), err)
 	}
 }
@@ -35,7 +52,8 @@ func HttpServerRun() {
 func HttpsServerRun() {
 	gin.SetMode(lib.GetStringConf("proxy.base.debug_mode"))
 	r := InitRouter(middleware.RecoveryMiddleware(),
-		middleware.RequestLog())
+		middleware.RequestLog(),
+		http_proxy_middleware.HTTPAltSvcMiddleware())
 	HttpsSrvHandler = &http.Server{
 		Addr:           lib.GetStringConf("proxy.https.addr"),
 		Handler:        r,
@@ -43,20 +61,61 @@ func HttpsServerRun() {
 		WriteTimeout:   time.Duration(lib.GetIntConf("proxy.https.write_timeout")) * time.Second,
 		MaxHeaderBytes: 1 << uint(lib.GetIntConf("proxy.https.max_header_bytes")),
 	}
+	//websocket 连接已被接管，Shutdown 不会等待，需单独发送关闭帧
+	HttpsSrvHandler.RegisterOnShutdown(reverse_proxy.CloseWebsocketSessions)
+	HttpsSrvHandler.TLSConfig = newHttpsTLSConfig()
 	log.Printf(" [INFO] https_proxy_run %s\n", lib.GetStringConf("proxy.https.addr"))
-	//todo 以下命令只在编译机有效，如果是交叉编译情况下需要单独设置路径
-	//if err := HttpsSrvHandler.ListenAndServeTLS(cert_file.Path("server.crt"), cert_file.Path("server.key")); err != nil && err!=http.ErrServerClosed {
-	if err := HttpsSrvHandler.ListenAndServeTLS("./cert_file/server.crt", "./cert_file/server.key"); err != nil && err != http.ErrServerClosed {
+	ln, err := listenProxyProto(HttpsSrvHandler.Addr)
+	if err != nil {
+		log.Fatalf(" [ERROR] https_proxy_run %s err:%v\n", lib.GetStringConf("proxy.https.addr"), err)
+	}
+	if err := HttpsSrvHandler.ServeTLS(ln, "", ""); err != nil && err != http.ErrServerClosed {
 		log.Fatalf(" [ERROR] https_proxy_run %s err:%v\n", lib.GetStringConf("proxy.https.addr"), err)
 	}
 }
 
+// newHttpsTLSConfig https 与 http3 监听共用：按 sni 域名选择证书，未匹配时使用默认证书；证书在后台上传后热加载
+func newHttpsTLSConfig() *tls.Config {
+	//todo 以下路径只在编译机有效，如果是交叉编译情况下需要单独设置路径
+	if err := dao.CertManagerHandler.SetDefaultCert("./cert_file/server.crt", "./cert_file/server.key"); err != nil {
+		log.Printf(" [ERROR] https_proxy_run default cert err:%v\n", err)
+	}
+	tlsConfig := &tls.Config{
+		GetCertificate: dao.CertManagerHandler.GetCertificate,
+	}
+	//配置了客户端 ca 时校验客户端携带的证书，是否必须携带由各服务的客户端证书认证决定
+	clientCAPool, err := dao.GetClientCAPool()
+	if err != nil {
+		log.Printf(" [ERROR] https_proxy_run client ca err:%v\n", err)
+	}
+	if clientCAPool != nil {
+		tlsConfig.ClientCAs = clientCAPool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig
+}
+
+// listenProxyProto 开启 proxy_protocol.http_open 时解析 PROXY 协议头，请求的 RemoteAddr 为协议头中的客户端地址
+func listenProxyProto(addr string) (net.Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if conf := public.GetProxyProtoConfig("http"); conf != nil {
+		ln = tcp_server.NewProxyProtoListener(ln, conf)
+	}
+	return ln, nil
+}
+
 func HttpServerStop() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := HttpSrvHandler.Shutdown(ctx); err != nil {
 		log.Printf(" [ERROR] http_proxy_stop err:%v\n", err)
 	}
+	if err := reverse_proxy.WaitWebsocketSessions(ctx); err != nil {
+		log.Printf(" [ERROR] http_proxy_stop websocket err:%v\n", err)
+	}
 	log.Printf(" [INFO] http_proxy_stop %v stopped\n", lib.GetStringConf("proxy.http.addr"))
 }
 
@@ -66,5 +125,8 @@ func HttpsServerStop() {
 	if err := HttpsSrvHandler.Shutdown(ctx); err != nil {
 		log.Fatalf(" [ERROR] https_proxy_stop err:%v\n", err)
 	}
+	if err := reverse_proxy.WaitWebsocketSessions(ctx); err != nil {
+		log.Printf(" [ERROR] https_proxy_stop websocket err:%v\n", err)
+	}
 	log.Printf(" [INFO] https_proxy_stop %v stopped\n", lib.GetStringConf("proxy.https.addr"))
 }
@@ -24,10 +24,23 @@ func InitRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
 		controller.OAuthRegister(oauth)
 	}
 
+	//acme 签发证书时的 http-01 验证，不经过服务匹配
+	acme := router.Group("/.well-known")
+	{
+		controller.AcmeRegister(acme)
+	}
+
+	//grpc-web 路径前缀优先于 http 服务匹配
+	router.Use(http_proxy_middleware.HTTPGrpcWebMiddleware())
+
 	router.Use(
 		http_proxy_middleware.HTTPAccessModeMiddleware(),
+		http_proxy_middleware.HTTPGrpcStreamMiddleware(),
+		http_proxy_middleware.HTTPHttpsMiddleware(),
+		http_proxy_middleware.HTTPWebsocketMiddleware(),
 		http_proxy_middleware.HTTPFlowCountMiddleware(),
 		http_proxy_middleware.HTTPFlowLimitMiddleware(),
+		http_proxy_middleware.HTTPClientCertAuthMiddleware(),
 		http_proxy_middleware.HTTPJwtAuthTokenMiddleware(),
 		http_proxy_middleware.HTTPJwtFlowCountMiddleware(),
 		http_proxy_middleware.HTTPJwtFlowLimitMiddleware(),
@@ -36,6 +49,8 @@ func InitRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
 		http_proxy_middleware.HTTPHeaderTransferMiddleware(),
 		http_proxy_middleware.HTTPStripUriMiddleware(),
 		http_proxy_middleware.HTTPUrlRewriteMiddleware(),
+		http_proxy_middleware.HTTPCircuitBreakerMiddleware(),
+		http_proxy_middleware.HTTPGrpcTranscodeMiddleware(),
 		http_proxy_middleware.HTTPReverseProxyMiddleware())
 	
 	return router
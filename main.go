@@ -8,6 +8,7 @@ import (
 	"go-gateway/http_proxy_router"
 	"go-gateway/router"
 	"go-gateway/tcp_proxy_router"
+	"go-gateway/udp_proxy_router"
 	"os"
 	"os/signal"
 	"syscall"
@@ -37,7 +38,7 @@ func main() {
 		defer lib.Destroy()
 		router.HttpServerRun()
 
-		quit := make(chan os.Signal)
+		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		<-quit
 		router.HttpServerStop()
@@ -46,6 +47,7 @@ func main() {
 		defer lib.Destroy()
 		dao.ServiceManagerHandler.LoadOnce()
 		dao.AppManagerHandler.LoadOnce()
+		dao.CertManagerHandler.LoadOnce()
 
 		go func() {
 			http_proxy_router.HttpServerRun()
@@ -53,20 +55,36 @@ func main() {
 		go func() {
 			http_proxy_router.HttpsServerRun()
 		}()
+		go func() {
+			http_proxy_router.Http3ServerRun()
+		}()
 		go func() {
 			tcp_proxy_router.TcpServerRun()
 		}()
 		go func() {
 			grpc_proxy_router.GrpcServerRun()
 		}()
+		go func() {
+			udp_proxy_router.UdpServerRun()
+		}()
+		go func() {
+			dao.ConfReloadRun()
+		}()
+		go func() {
+			dao.AcmeRun()
+		}()
 
-		quit := make(chan os.Signal)
+		quit := make(chan os.Signal, 1)
 		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 		<-quit
 
+		dao.ConfReloadStop()
+		dao.AcmeStop()
 		tcp_proxy_router.TcpServerStop()
 		grpc_proxy_router.GrpcServerStop()
+		udp_proxy_router.UdpServerStop()
 		http_proxy_router.HttpServerStop()
 		http_proxy_router.HttpsServerStop()
+		http_proxy_router.Http3ServerStop()
 	}
 }
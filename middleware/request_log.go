@@ -22,8 +22,12 @@ func RequestInLog(c *gin.Context) {
 	c.Set("startExecTime", time.Now())
 	c.Set("trace", traceContext)
 
-	bodyBytes, _ := ioutil.ReadAll(c.Request.Body)
-	c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes)) // Write body back
+	//grpc 请求体为流式数据，读取会阻塞转发，不记录请求体
+	bodyBytes := []byte{}
+	if !public.IsGrpcRequest(c.Request) {
+		bodyBytes, _ = ioutil.ReadAll(c.Request.Body)
+		c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes)) // Write body back
+	}
 
 	lib.Log.TagInfo(traceContext, "_com_request_in", map[string]interface{}{
 		"uri":    c.Request.RequestURI,
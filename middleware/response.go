@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"go-gateway/common/lib"
+	"math"
+	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type ResponseCode int
@@ -51,6 +55,44 @@ func ResponseError(c *gin.Context, code ResponseCode, err error) {
 	c.AbortWithError(200, err)
 }
 
+// LegacyErrorResponseKey 代理请求匹配的服务保留旧错误格式时置为 true
+const LegacyErrorResponseKey = "legacy_error_response"
+
+type ProxyErrorResponse struct {
+	Code    ResponseCode `json:"code"`
+	Message string       `json:"message"`
+	TraceId string       `json:"trace_id"`
+}
+
+// ResponseProxyError 代理请求被网关拒绝或下游失败时以对应的 http 状态码返回，
+// 服务保留旧错误格式时仍返回 http 200 及 errno 结构
+func ResponseProxyError(c *gin.Context, code ResponseCode, httpStatus int, err error) {
+	if c.GetBool(LegacyErrorResponseKey) {
+		ResponseError(c, code, err)
+		return
+	}
+	trace, _ := c.Get("trace")
+	traceContext, _ := trace.(*lib.TraceContext)
+	traceId := ""
+	if traceContext != nil {
+		traceId = traceContext.TraceId
+	}
+
+	resp := &ProxyErrorResponse{Code: code, Message: err.Error(), TraceId: traceId}
+	c.JSON(httpStatus, resp)
+	response, _ := json.Marshal(resp)
+	c.Set("response", string(response))
+	c.AbortWithError(httpStatus, err)
+}
+
+// ResponseRateLimit 限流拒绝返回 429 及 Retry-After(秒)
+func ResponseRateLimit(c *gin.Context, code ResponseCode, retryAfter time.Duration, err error) {
+	if !c.GetBool(LegacyErrorResponseKey) {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	ResponseProxyError(c, code, http.StatusTooManyRequests, err)
+}
+
 func ResponseSuccess(c *gin.Context, data interface{}) {
 	trace, _ := c.Get("trace")
 	traceContext, _ := trace.(*lib.TraceContext)
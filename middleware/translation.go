@@ -110,6 +110,46 @@ func TranslationMiddleware() gin.HandlerFunc {
 				}
 				return true
 			})
+			val.RegisterValidation("valid_status_range", func(fl validator.FieldLevel) bool {
+				if fl.Field().String() == "" {
+					return true
+				}
+				matched, _ := regexp.Match(`^[1-5]\d{2}(-[1-5]\d{2})?(,[1-5]\d{2}(-[1-5]\d{2})?)*$`, []byte(fl.Field().String()))
+				return matched
+			})
+			val.RegisterValidation("valid_retry_on", func(fl validator.FieldLevel) bool {
+				if fl.Field().String() == "" {
+					return true
+				}
+				matched, _ := regexp.Match(`^(connect-failure|reset|timeout|5xx|[1-5]\d{2})(,(connect-failure|reset|timeout|5xx|[1-5]\d{2}))*$`, []byte(fl.Field().String()))
+				return matched
+			})
+			val.RegisterValidation("valid_sni_names", func(fl validator.FieldLevel) bool {
+				if fl.Field().String() == "" {
+					return true
+				}
+				for _, name := range strings.Split(fl.Field().String(), ",") {
+					if matched, _ := regexp.Match(`^(\*\.)?[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)*$`, []byte(strings.TrimSpace(name))); !matched {
+						return false
+					}
+				}
+				return true
+			})
+			val.RegisterValidation("valid_grpc_web_prefix", func(fl validator.FieldLevel) bool {
+				if fl.Field().String() == "" {
+					return true
+				}
+				matched, _ := regexp.Match(`^(/[a-zA-Z0-9_.~-]+)+$`, []byte(fl.Field().String()))
+				return matched
+			})
+			val.RegisterValidation("valid_grpc_method_rule", func(fl validator.FieldLevel) bool {
+				_, err := public.ParseGrpcMethodRules(fl.Field().String())
+				return err == nil
+			})
+			val.RegisterValidation("valid_http_route_rule", func(fl validator.FieldLevel) bool {
+				_, err := public.ParseHTTPRouteRules(fl.Field().String())
+				return err == nil
+			})
 
 			//自定义翻译器
 			//https://github.com/go-playground/validator/blob/v9/_examples/translations/main.go
@@ -161,6 +201,42 @@ func TranslationMiddleware() gin.HandlerFunc {
 				t, _ := ut.T("valid_weightlist", fe.Field())
 				return t
 			})
+			val.RegisterTranslation("valid_status_range", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_status_range", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_status_range", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_retry_on", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_retry_on", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_retry_on", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_sni_names", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_sni_names", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_sni_names", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_grpc_method_rule", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_grpc_method_rule", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_grpc_method_rule", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_http_route_rule", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_http_route_rule", "{0} 不符合输入格式", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_http_route_rule", fe.Field())
+				return t
+			})
+			val.RegisterTranslation("valid_grpc_web_prefix", trans, func(ut ut.Translator) error {
+				return ut.Add("valid_grpc_web_prefix", "{0} 需以/开头且不以/结尾", true)
+			}, func(ut ut.Translator, fe validator.FieldError) string {
+				t, _ := ut.T("valid_grpc_web_prefix", fe.Field())
+				return t
+			})
 			break
 		}
 		c.Set(public.TranslatorKey, trans)
@@ -0,0 +1,85 @@
+package public
+
+import (
+	"encoding/json"
+	"github.com/garyburd/redigo/redis"
+	"sort"
+	"time"
+)
+
+const (
+	AcmeStatusOk     = "ok"
+	AcmeStatusFailed = "failed"
+
+	//http-01 验证值在 redis 中的保留时间, 单位s
+	acmeChallengeExpire = 600
+)
+
+// AcmeStatus 域名最近一次 acme 签发或续期的结果
+type AcmeStatus struct {
+	Domain   string `json:"domain"`
+	Status   string `json:"status"`
+	Error    string `json:"error"`
+	NotAfter int64  `json:"not_after"`
+	Time     int64  `json:"time"`
+	Failures int    `json:"failures"` //连续失败次数
+	RetryAt  int64  `json:"retry_at"` //失败后下次允许重试的时间
+}
+
+// SetAcmeChallenge 保存 http-01 验证值，集群内任一节点收到验证请求都可以应答
+func SetAcmeChallenge(token, keyAuth string) error {
+	_, err := RedisConfDo("SET", RedisAcmeChallengeKey+token, keyAuth, "EX", acmeChallengeExpire)
+	return err
+}
+
+func GetAcmeChallenge(token string) (string, error) {
+	return redis.String(RedisConfDo("GET", RedisAcmeChallengeKey+token))
+}
+
+func DelAcmeChallenge(token string) error {
+	_, err := RedisConfDo("DEL", RedisAcmeChallengeKey+token)
+	return err
+}
+
+// LockAcmeDomain 集群内同一域名同时只由一个节点签发
+func LockAcmeDomain(domain string, expire time.Duration) bool {
+	reply, err := redis.String(RedisConfDo("SET", RedisAcmeLockKey+domain, 1, "NX", "EX", int64(expire/time.Second)))
+	return err == nil && reply == "OK"
+}
+
+func UnlockAcmeDomain(domain string) error {
+	_, err := RedisConfDo("DEL", RedisAcmeLockKey+domain)
+	return err
+}
+
+// SetAcmeStatus 记录签发结果，供后台大盘查看
+func SetAcmeStatus(status *AcmeStatus) error {
+	value, _ := json.Marshal(status)
+	_, err := RedisConfDo("HSET", RedisAcmeStatusKey, status.Domain, value)
+	return err
+}
+
+func GetAcmeStatusList() ([]AcmeStatus, error) {
+	statusMap, err := redis.StringMap(RedisConfDo("HGETALL", RedisAcmeStatusKey))
+	if err != nil {
+		return nil, err
+	}
+	statusList := []AcmeStatus{}
+	for _, item := range statusMap {
+		status := AcmeStatus{}
+		if err := json.Unmarshal([]byte(item), &status); err != nil {
+			continue
+		}
+		statusList = append(statusList, status)
+	}
+	sort.Slice(statusList, func(i, j int) bool {
+		return statusList[i].Domain < statusList[j].Domain
+	})
+	return statusList, nil
+}
+
+// RemoveAcmeStatus 域名不再需要自动签发时清除记录
+func RemoveAcmeStatus(domain string) error {
+	_, err := RedisConfDo("HDEL", RedisAcmeStatusKey, domain)
+	return err
+}
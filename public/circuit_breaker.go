@@ -0,0 +1,338 @@
+package public
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/garyburd/redigo/redis"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	BreakerStateClosed   = 0 //正常放行，统计失败率与慢调用率
+	BreakerStateOpen     = 1 //熔断，直接返回降级响应
+	BreakerStateHalfOpen = 2 //放行少量探测请求，全部成功后恢复
+
+	//default circuit breaker setting
+	DefaultBreakerErrorPercent     = 50
+	DefaultBreakerSlowCallPercent  = 50
+	DefaultBreakerMinRequest       = 20
+	DefaultBreakerStatWindow       = 10 * time.Second
+	DefaultBreakerOpenDuration     = 30 * time.Second
+	DefaultBreakerHalfOpenRequests = 3
+
+	//redis 中保留的熔断状态变更记录数
+	breakerEventMaxNum = 50
+)
+
+// ErrCircuitBreakerOpen 熔断器拒绝请求下游
+var ErrCircuitBreakerOpen = errors.New("circuit breaker open")
+
+var BreakerStateMap = map[int]string{
+	BreakerStateClosed:   "closed",
+	BreakerStateOpen:     "open",
+	BreakerStateHalfOpen: "half_open",
+}
+
+// CircuitBreakerConf 熔断配置，服务及其下游节点共用
+type CircuitBreakerConf struct {
+	ErrorPercent     int           //统计窗口内失败率达到后熔断, 百分比
+	SlowCallPercent  int           //统计窗口内慢调用率达到后熔断, 百分比
+	SlowCallDuration time.Duration //超过该耗时视为慢调用，0为不统计慢调用
+	MinRequest       int           //统计窗口内请求数不足时不熔断
+	StatWindow       time.Duration //统计窗口
+	OpenDuration     time.Duration //熔断持续时间，之后进入半开
+	HalfOpenRequests int           //半开状态放行的探测请求数
+}
+
+func (c *CircuitBreakerConf) setDefault() {
+	if c.ErrorPercent <= 0 {
+		c.ErrorPercent = DefaultBreakerErrorPercent
+	}
+	if c.SlowCallPercent <= 0 {
+		c.SlowCallPercent = DefaultBreakerSlowCallPercent
+	}
+	if c.MinRequest <= 0 {
+		c.MinRequest = DefaultBreakerMinRequest
+	}
+	if c.StatWindow <= 0 {
+		c.StatWindow = DefaultBreakerStatWindow
+	}
+	if c.OpenDuration <= 0 {
+		c.OpenDuration = DefaultBreakerOpenDuration
+	}
+	if c.HalfOpenRequests <= 0 {
+		c.HalfOpenRequests = DefaultBreakerHalfOpenRequests
+	}
+}
+
+// CircuitBreakerStat 熔断器当前状态，Node 为空表示服务级别
+type CircuitBreakerStat struct {
+	Node  string `json:"node"`
+	State string `json:"state"`
+	Since int64  `json:"since"`
+}
+
+// CircuitBreakerEvent 熔断器状态变更记录
+type CircuitBreakerEvent struct {
+	Node string `json:"node"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	Time int64  `json:"time"`
+}
+
+var CircuitBreakerHandler *CircuitBreakerManager
+
+type CircuitBreakerManager struct {
+	CircuitBreakerMap   map[string]*CircuitBreaker
+	CircuitBreakerSlice []*CircuitBreaker
+	Locker              sync.RWMutex
+}
+
+func NewCircuitBreakerManager() *CircuitBreakerManager {
+	return &CircuitBreakerManager{
+		CircuitBreakerMap:   map[string]*CircuitBreaker{},
+		CircuitBreakerSlice: []*CircuitBreaker{},
+		Locker:              sync.RWMutex{},
+	}
+}
+
+func init() {
+	CircuitBreakerHandler = NewCircuitBreakerManager()
+}
+
+func breakerKey(serviceName, node string) string {
+	if node == "" {
+		return serviceName
+	}
+	return serviceName + "_" + node
+}
+
+// GetBreaker 获取熔断器，node 为空时为服务级别熔断器，否则为该下游节点的熔断器
+func (m *CircuitBreakerManager) GetBreaker(serviceName, node string, conf CircuitBreakerConf) *CircuitBreaker {
+	key := breakerKey(serviceName, node)
+	m.Locker.RLock()
+	breaker, ok := m.CircuitBreakerMap[key]
+	m.Locker.RUnlock()
+	if ok {
+		return breaker
+	}
+
+	m.Locker.Lock()
+	defer m.Locker.Unlock()
+	if breaker, ok := m.CircuitBreakerMap[key]; ok {
+		return breaker
+	}
+	breaker = NewCircuitBreaker(serviceName, node, conf)
+	m.CircuitBreakerSlice = append(m.CircuitBreakerSlice, breaker)
+	m.CircuitBreakerMap[key] = breaker
+	return breaker
+}
+
+// RemoveBreaker 移除服务及其下游节点的熔断器，并清除 redis 中的状态
+func (m *CircuitBreakerManager) RemoveBreaker(serviceName string) {
+	m.Locker.Lock()
+	breakerSlice := []*CircuitBreaker{}
+	removed := false
+	for _, item := range m.CircuitBreakerSlice {
+		if item.ServiceName == serviceName {
+			delete(m.CircuitBreakerMap, breakerKey(item.ServiceName, item.Node))
+			removed = true
+			continue
+		}
+		breakerSlice = append(breakerSlice, item)
+	}
+	m.CircuitBreakerSlice = breakerSlice
+	m.Locker.Unlock()
+	if removed {
+		if _, err := RedisConfDo("DEL", RedisBreakerStateKey+serviceName); err != nil {
+			log.Printf(" [ERROR] circuit_breaker remove %v state err:%v\n", serviceName, err)
+		}
+	}
+}
+
+// CircuitBreaker 熔断器，按失败率或慢调用率从 closed 进入 open，
+// open 持续 OpenDuration 后进入 half_open，探测请求全部成功则恢复 closed，否则重新 open
+type CircuitBreaker struct {
+	ServiceName string
+	Node        string
+
+	mux         sync.Mutex
+	conf        CircuitBreakerConf
+	state       int
+	stateSince  time.Time
+	windowStart time.Time
+	total       int
+	failed      int
+	slow        int
+	probing     int //半开状态已放行的探测请求
+	probeOk     int //半开状态成功的探测请求
+}
+
+func NewCircuitBreaker(serviceName, node string, conf CircuitBreakerConf) *CircuitBreaker {
+	conf.setDefault()
+	now := time.Now()
+	return &CircuitBreaker{
+		ServiceName: serviceName,
+		Node:        node,
+		conf:        conf,
+		state:       BreakerStateClosed,
+		stateSince:  now,
+		windowStart: now,
+	}
+}
+
+func (b *CircuitBreaker) State() int {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.state
+}
+
+// Blocked 熔断器当前是否拒绝请求，不占用半开状态的探测名额，用于请求下游前提前返回降级响应
+func (b *CircuitBreaker) Blocked() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	switch b.state {
+	case BreakerStateClosed:
+		return false
+	case BreakerStateOpen:
+		return time.Since(b.stateSince) < b.conf.OpenDuration
+	}
+	return b.probing >= b.conf.HalfOpenRequests && time.Since(b.stateSince) < b.conf.OpenDuration
+}
+
+// Allow 是否放行本次请求，放行后必须调用 Report 上报结果，因此只在即将请求下游时调用
+func (b *CircuitBreaker) Allow() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	now := time.Now()
+	switch b.state {
+	case BreakerStateClosed:
+		return true
+	case BreakerStateOpen:
+		if now.Sub(b.stateSince) < b.conf.OpenDuration {
+			return false
+		}
+		b.setStateLocked(BreakerStateHalfOpen, now)
+	}
+	//探测请求长时间没有结果时重新放行探测
+	if now.Sub(b.stateSince) >= b.conf.OpenDuration {
+		b.stateSince = now
+		b.probing, b.probeOk = 0, 0
+	}
+	if b.probing >= b.conf.HalfOpenRequests {
+		return false
+	}
+	b.probing++
+	return true
+}
+
+// Report 上报请求结果，err 不为空视为失败，超过 SlowCallDuration 视为慢调用
+func (b *CircuitBreaker) Report(cost time.Duration, err error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	now := time.Now()
+	isSlow := b.conf.SlowCallDuration > 0 && cost > b.conf.SlowCallDuration
+	switch b.state {
+	case BreakerStateOpen:
+		return
+	case BreakerStateHalfOpen:
+		if err != nil || isSlow {
+			b.setStateLocked(BreakerStateOpen, now)
+			return
+		}
+		b.probeOk++
+		if b.probeOk >= b.conf.HalfOpenRequests {
+			b.setStateLocked(BreakerStateClosed, now)
+		}
+		return
+	}
+
+	if now.Sub(b.windowStart) >= b.conf.StatWindow {
+		b.windowStart = now
+		b.total, b.failed, b.slow = 0, 0, 0
+	}
+	b.total++
+	if err != nil {
+		b.failed++
+	}
+	if isSlow {
+		b.slow++
+	}
+	if b.total < b.conf.MinRequest {
+		return
+	}
+	if b.failed*100 >= b.total*b.conf.ErrorPercent ||
+		(b.conf.SlowCallDuration > 0 && b.slow*100 >= b.total*b.conf.SlowCallPercent) {
+		b.setStateLocked(BreakerStateOpen, now)
+	}
+}
+
+func (b *CircuitBreaker) setStateLocked(state int, now time.Time) {
+	from := b.state
+	b.state = state
+	b.stateSince = now
+	b.windowStart = now
+	b.total, b.failed, b.slow = 0, 0, 0
+	b.probing, b.probeOk = 0, 0
+	log.Printf(" [INFO] circuit_breaker %v %v -> %v\n", breakerKey(b.ServiceName, b.Node), BreakerStateMap[from], BreakerStateMap[state])
+	go b.publishState(from, state, now)
+}
+
+// publishState 状态写入 redis，供后台服务统计查看
+func (b *CircuitBreaker) publishState(from, to int, now time.Time) {
+	stat, _ := json.Marshal(&CircuitBreakerStat{Node: b.Node, State: BreakerStateMap[to], Since: now.Unix()})
+	event, _ := json.Marshal(&CircuitBreakerEvent{Node: b.Node, From: BreakerStateMap[from], To: BreakerStateMap[to], Time: now.Unix()})
+	stateKey := RedisBreakerStateKey + b.ServiceName
+	eventKey := RedisBreakerEventKey + b.ServiceName
+	if err := RedisConfPipline(func(c redis.Conn) {
+		c.Send("HSET", stateKey, breakerField(b.Node), stat)
+		c.Send("EXPIRE", stateKey, 86400*2)
+		c.Send("LPUSH", eventKey, event)
+		c.Send("LTRIM", eventKey, 0, breakerEventMaxNum-1)
+		c.Send("EXPIRE", eventKey, 86400*2)
+	}); err != nil {
+		log.Printf(" [ERROR] circuit_breaker publish %v err:%v\n", breakerKey(b.ServiceName, b.Node), err)
+	}
+}
+
+func breakerField(node string) string {
+	if node == "" {
+		return "service"
+	}
+	return node
+}
+
+// GetCircuitBreakerStat 读取服务及其下游节点的熔断状态和最近的状态变更记录
+func GetCircuitBreakerStat(serviceName string) ([]CircuitBreakerStat, []CircuitBreakerEvent, error) {
+	stateMap, err := redis.StringMap(RedisConfDo("HGETALL", RedisBreakerStateKey+serviceName))
+	if err != nil {
+		return nil, nil, err
+	}
+	statList := []CircuitBreakerStat{}
+	for _, item := range stateMap {
+		stat := CircuitBreakerStat{}
+		if err := json.Unmarshal([]byte(item), &stat); err != nil {
+			continue
+		}
+		statList = append(statList, stat)
+	}
+	sort.Slice(statList, func(i, j int) bool {
+		return statList[i].Node < statList[j].Node
+	})
+	eventStrs, err := redis.Strings(RedisConfDo("LRANGE", RedisBreakerEventKey+serviceName, 0, breakerEventMaxNum-1))
+	if err != nil {
+		return nil, nil, err
+	}
+	eventList := []CircuitBreakerEvent{}
+	for _, item := range eventStrs {
+		event := CircuitBreakerEvent{}
+		if err := json.Unmarshal([]byte(item), &event); err != nil {
+			continue
+		}
+		eventList = append(eventList, event)
+	}
+	return statList, eventList, nil
+}
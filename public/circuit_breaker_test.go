@@ -0,0 +1,155 @@
+package public
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const (
+	testBreakerWindow = 50 * time.Millisecond
+	testBreakerSlow   = 10 * time.Millisecond
+)
+
+var errBreakerTest = errors.New("connection refused")
+
+type breakerStep func(t *testing.T, b *CircuitBreaker)
+
+func breakerReport(n int, cost time.Duration, err error) breakerStep {
+	return func(t *testing.T, b *CircuitBreaker) {
+		for i := 0; i < n; i++ {
+			b.Report(cost, err)
+		}
+	}
+}
+
+func breakerWait() breakerStep {
+	return func(t *testing.T, b *CircuitBreaker) {
+		time.Sleep(testBreakerWindow + 10*time.Millisecond)
+	}
+}
+
+func breakerAllow(want bool) breakerStep {
+	return func(t *testing.T, b *CircuitBreaker) {
+		t.Helper()
+		if got := b.Allow(); got != want {
+			t.Fatalf("Allow() = %v, want %v, state %v", got, want, BreakerStateMap[b.State()])
+		}
+	}
+}
+
+func breakerState(want int) breakerStep {
+	return func(t *testing.T, b *CircuitBreaker) {
+		t.Helper()
+		if got := b.State(); got != want {
+			t.Fatalf("State() = %v, want %v", BreakerStateMap[got], BreakerStateMap[want])
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	conf := CircuitBreakerConf{
+		ErrorPercent:     50,
+		SlowCallPercent:  50,
+		SlowCallDuration: testBreakerSlow,
+		MinRequest:       4,
+		StatWindow:       testBreakerWindow,
+		OpenDuration:     testBreakerWindow,
+		HalfOpenRequests: 2,
+	}
+	open := breakerReport(4, 0, errBreakerTest)
+	tests := []struct {
+		name  string
+		conf  *CircuitBreakerConf
+		steps []breakerStep
+	}{
+		{"error_percent_open", nil, []breakerStep{
+			breakerReport(2, 0, nil), breakerReport(1, 0, errBreakerTest), breakerState(BreakerStateClosed),
+			breakerReport(1, 0, errBreakerTest), breakerState(BreakerStateOpen), breakerAllow(false),
+		}},
+		{"error_percent_below", nil, []breakerStep{
+			breakerReport(3, 0, nil), breakerReport(1, 0, errBreakerTest), breakerState(BreakerStateClosed),
+			breakerAllow(true),
+		}},
+		{"slow_percent_open", nil, []breakerStep{
+			breakerReport(2, 0, nil), breakerReport(2, 2*testBreakerSlow, nil), breakerState(BreakerStateOpen),
+		}},
+		{"slow_call_off", &CircuitBreakerConf{MinRequest: 4, StatWindow: testBreakerWindow}, []breakerStep{
+			breakerReport(4, time.Second, nil), breakerState(BreakerStateClosed),
+		}},
+		{"min_request", nil, []breakerStep{
+			breakerReport(3, 0, errBreakerTest), breakerState(BreakerStateClosed), breakerAllow(true),
+			breakerReport(1, 0, errBreakerTest), breakerState(BreakerStateOpen),
+		}},
+		{"window_reset", nil, []breakerStep{
+			breakerReport(3, 0, errBreakerTest), breakerWait(),
+			breakerReport(1, 0, errBreakerTest), breakerState(BreakerStateClosed),
+			breakerReport(2, 0, errBreakerTest), breakerState(BreakerStateClosed),
+			breakerReport(1, 0, errBreakerTest), breakerState(BreakerStateOpen),
+		}},
+		{"half_open_close", nil, []breakerStep{
+			open, breakerAllow(false),
+			//熔断期间的结果不统计
+			breakerReport(4, 0, nil), breakerState(BreakerStateOpen),
+			breakerWait(), breakerAllow(true), breakerState(BreakerStateHalfOpen),
+			//探测请求数达到后不再放行
+			breakerAllow(true), breakerAllow(false),
+			breakerReport(1, 0, nil), breakerState(BreakerStateHalfOpen), breakerAllow(false),
+			breakerReport(1, 0, nil), breakerState(BreakerStateClosed), breakerAllow(true),
+		}},
+		{"half_open_error_reopen", nil, []breakerStep{
+			open, breakerWait(), breakerAllow(true),
+			breakerReport(1, 0, errBreakerTest), breakerState(BreakerStateOpen), breakerAllow(false),
+			breakerWait(), breakerAllow(true), breakerState(BreakerStateHalfOpen),
+		}},
+		{"half_open_slow_reopen", nil, []breakerStep{
+			open, breakerWait(), breakerAllow(true), breakerAllow(true),
+			breakerReport(1, 0, nil), breakerReport(1, 2*testBreakerSlow, nil), breakerState(BreakerStateOpen),
+		}},
+		{"half_open_stalled_probe", nil, []breakerStep{
+			open, breakerWait(), breakerAllow(true), breakerAllow(true), breakerAllow(false),
+			//探测请求长时间没有结果时重新放行探测
+			breakerWait(), breakerState(BreakerStateHalfOpen), breakerAllow(true), breakerAllow(true), breakerAllow(false),
+			breakerReport(2, 0, nil), breakerState(BreakerStateClosed),
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := conf
+			if test.conf != nil {
+				c = *test.conf
+			}
+			b := NewCircuitBreaker("test_service", "", c)
+			for _, step := range test.steps {
+				step(t, b)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerBlocked(t *testing.T) {
+	b := NewCircuitBreaker("test_service", "", CircuitBreakerConf{
+		MinRequest:       1,
+		StatWindow:       testBreakerWindow,
+		OpenDuration:     testBreakerWindow,
+		HalfOpenRequests: 1,
+	})
+	if b.Blocked() {
+		t.Fatal("closed breaker blocked")
+	}
+	b.Report(0, errBreakerTest)
+	if !b.Blocked() {
+		t.Fatal("open breaker not blocked")
+	}
+	time.Sleep(testBreakerWindow + 10*time.Millisecond)
+	//熔断到期后不占用探测名额
+	if b.Blocked() || b.Blocked() {
+		t.Fatal("breaker blocked after open duration")
+	}
+	if !b.Allow() {
+		t.Fatal("probe not allowed")
+	}
+	if !b.Blocked() {
+		t.Error("half open breaker with all probes in flight not blocked")
+	}
+}
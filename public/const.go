@@ -8,16 +8,50 @@ const (
 	LoadTypeHTTP = 0
 	LoadTypeTCP  = 1
 	LoadTypeGRPC = 2
+	LoadTypeUDP  = 3
 
 	HTTPRuleTypePrefixURL = 0
 	HTTPRuleTypeDomain    = 1
 
+	HttpsPolicyAllow    = 0 //http、https 均允许
+	HttpsPolicyRedirect = 1 //http 请求跳转 https
+	HttpsPolicyReject   = 2 //拒绝 http 请求
+
+	UpstreamSchemeDefault = 0 //与 need_https 一致
+	UpstreamSchemeHttp    = 1
+	UpstreamSchemeHttps   = 2
+
+	UpstreamProtocolAuto  = 0 //https 下游通过 alpn 协商 http2，http 下游使用 http/1.1
+	UpstreamProtocolHttp1 = 1 //只使用 http/1.1
+	UpstreamProtocolHttp2 = 2 //基于 tls 的 http2，下游固定使用 https
+	UpstreamProtocolH2c   = 3 //明文 http2(prior knowledge)，下游固定使用 http，用于 grpc 等 h2c 服务
+
+	GrpcTranscodeOff        = 0 //不转换，按 http 转发
+	GrpcTranscodeDescriptor = 1 //使用上传的 protobuf 描述文件(FileDescriptorSet)
+	GrpcTranscodeReflection = 2 //通过下游的 grpc server reflection 获取描述
+
 	RedisFlowDayKey  = "flow_day_count"
 	RedisFlowHourKey = "flow_hour_count"
 
+	RedisBreakerStateKey = "circuit_breaker_state_"
+	RedisBreakerEventKey = "circuit_breaker_event_"
+
+	RedisAcmeChallengeKey = "acme_challenge_"
+	RedisAcmeAccountKey   = "acme_account_key"
+	RedisAcmeLockKey      = "acme_lock_"
+	RedisAcmeStatusKey    = "acme_status"
+
+	RedisConfChangeChannel = "gateway_conf_change"
+	ConfChangeService      = "service"
+	ConfChangeApp          = "app"
+	ConfChangeCert         = "cert"
+
 	FlowTotal         = "flow_total"
 	FlowServicePrefix = "flow_service_"
 	FlowAppPrefix     = "flow_app_"
+	FlowRetryPrefix   = "flow_retry_"
+
+	FlowWebsocketPrefix = "flow_websocket_"
 
 	JwtSignKey = "my_sign_key"
 	JwtExpires = 60 * 60
@@ -28,5 +62,6 @@ var (
 		LoadTypeHTTP: "HTTP",
 		LoadTypeTCP:  "TCP",
 		LoadTypeGRPC: "GRPC",
+		LoadTypeUDP:  "UDP",
 	}
 )
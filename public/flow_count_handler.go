@@ -26,16 +26,20 @@ func init() {
 }
 
 func (counter *FlowCounter) GetCounter(serverName string) (*RedisFlowCountService, error) {
-	for _, item := range counter.RedisFlowCountSlice {
-		if item.AppID == serverName {
-			return item, nil
-		}
+	counter.Locker.RLock()
+	item, ok := counter.RedisFlowCountMap[serverName]
+	counter.Locker.RUnlock()
+	if ok {
+		return item, nil
 	}
 
-	newCounter:=NewRedisFlowCountService(serverName,1*time.Second)
-	counter.RedisFlowCountSlice = append(counter.RedisFlowCountSlice, newCounter)
 	counter.Locker.Lock()
 	defer counter.Locker.Unlock()
+	if item, ok := counter.RedisFlowCountMap[serverName]; ok {
+		return item, nil
+	}
+	newCounter := NewRedisFlowCountService(serverName, 1*time.Second)
+	counter.RedisFlowCountSlice = append(counter.RedisFlowCountSlice, newCounter)
 	counter.RedisFlowCountMap[serverName] = newCounter
 	return newCounter, nil
 }
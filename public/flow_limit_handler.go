@@ -2,6 +2,8 @@ package public
 
 import (
 	"golang.org/x/time/rate"
+	"net"
+	"strings"
 	"sync"
 )
 
@@ -31,20 +33,42 @@ func init() {
 }
 
 func (counter *FlowLimiter) GetLimiter(serverName string, qps float64) (*rate.Limiter, error) {
-	for _, item := range counter.FlowLmiterSlice {
-		if item.ServiceName == serverName {
-			return item.Limter, nil
-		}
+	counter.Locker.RLock()
+	item, ok := counter.FlowLmiterMap[serverName]
+	counter.Locker.RUnlock()
+	if ok {
+		return item.Limter, nil
 	}
 
+	counter.Locker.Lock()
+	defer counter.Locker.Unlock()
+	if item, ok := counter.FlowLmiterMap[serverName]; ok {
+		return item.Limter, nil
+	}
 	newLimiter := rate.NewLimiter(rate.Limit(qps), int(qps*3))
-	item := &FlowLimiterItem{
+	item = &FlowLimiterItem{
 		ServiceName: serverName,
 		Limter:      newLimiter,
 	}
 	counter.FlowLmiterSlice = append(counter.FlowLmiterSlice, item)
-	counter.Locker.Lock()
-	defer counter.Locker.Unlock()
 	counter.FlowLmiterMap[serverName] = item
 	return newLimiter, nil
 }
+
+// RemoveLimiter 移除 serverName 本身及其按客户端 IP 派生的限流器（serverName_ip），
+// 以及 grpc 按方法规则派生的限流器（serverName/method:规则）、http 按路由派生的限流器（serverName/route_摘要）
+func (counter *FlowLimiter) RemoveLimiter(serverName string) {
+	counter.Locker.Lock()
+	defer counter.Locker.Unlock()
+	limiterSlice := []*FlowLimiterItem{}
+	for _, item := range counter.FlowLmiterSlice {
+		if item.ServiceName == serverName || strings.HasPrefix(item.ServiceName, serverName+"/") ||
+			(strings.HasPrefix(item.ServiceName, serverName+"_") &&
+				net.ParseIP(strings.TrimPrefix(item.ServiceName, serverName+"_")) != nil) {
+			delete(counter.FlowLmiterMap, item.ServiceName)
+			continue
+		}
+		limiterSlice = append(limiterSlice, item)
+	}
+	counter.FlowLmiterSlice = limiterSlice
+}
@@ -0,0 +1,32 @@
+package public
+
+import (
+	"encoding/base64"
+	"errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"strings"
+)
+
+// ParseGrpcDescriptorSet 解析 base64 编码的 FileDescriptorSet(protoc --descriptor_set_out --include_imports 生成)，
+// 依赖的文件需一并包含在描述文件中
+func ParseGrpcDescriptorSet(encoded string) (*protoregistry.Files, error) {
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, errors.New("grpc descriptor required")
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	fdSet := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, fdSet); err != nil {
+		return nil, err
+	}
+	if len(fdSet.GetFile()) == 0 {
+		return nil, errors.New("grpc descriptor has no file")
+	}
+	return protodesc.NewFiles(fdSet)
+}
@@ -0,0 +1,172 @@
+package public
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	GrpcMethodAllow = "allow"
+	GrpcMethodDeny  = "deny"
+)
+
+var grpcMethodPattern = regexp.MustCompile(`^(\*|/[a-zA-Z0-9_.]+/(\*|[a-zA-Z0-9_]+))$`)
+
+// GrpcMethodRule grpc 方法级规则，Method 为 /包名.服务名/方法名，支持 /包名.服务名/* 及 * 通配
+type GrpcMethodRule struct {
+	Method     string
+	Access     string        //allow 或 deny，为空时不限制
+	QPS        int           //单个方法的限流，0 为不限制
+	Timeout    time.Duration //客户端未设置 deadline 时使用的超时
+	MaxTimeout time.Duration //客户端 deadline 的上限
+	IpList     []string      //单独的下游节点，为空时使用服务的下游节点
+	WeightList []string
+
+	Upstream string //设置了下游节点的规则的 Method，合并后为实际使用的下游节点所属的规则
+	Limit    string //设置了限流的规则的 Method，合并后为实际生效的限流所属的规则，通配规则下的方法共用限流
+}
+
+// specificity 精确方法 > 服务通配 > 全部通配
+func (r *GrpcMethodRule) specificity() int {
+	switch {
+	case r.Method == "*":
+		return 0
+	case strings.HasSuffix(r.Method, "/*"):
+		return 1
+	}
+	return 2
+}
+
+func (r *GrpcMethodRule) match(fullMethod string) bool {
+	switch r.specificity() {
+	case 0:
+		return true
+	case 1:
+		return strings.HasPrefix(fullMethod, strings.TrimSuffix(r.Method, "*"))
+	}
+	return r.Method == fullMethod
+}
+
+// GrpcMethodRuleList 服务下的全部方法规则
+type GrpcMethodRuleList []*GrpcMethodRule
+
+// ParseGrpcMethodRules 多条规则逗号间隔，每条规则格式：方法 选项...，选项空格间隔：
+// allow、deny、qps=100、timeout=2s、max_timeout=10s、ip_list=127.0.0.1:50051;127.0.0.1:50052、weight_list=50;50，
+// 通配规则的 qps 由匹配的方法共用
+func ParseGrpcMethodRules(rules string) (GrpcMethodRuleList, error) {
+	ruleList := GrpcMethodRuleList{}
+	for _, item := range strings.Split(rules, ",") {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		if !grpcMethodPattern.MatchString(fields[0]) {
+			return nil, fmt.Errorf("invalid grpc method %v", fields[0])
+		}
+		rule := &GrpcMethodRule{Method: fields[0]}
+		for _, option := range fields[1:] {
+			if err := rule.setOption(option); err != nil {
+				return nil, fmt.Errorf("%v: %v", rule.Method, err)
+			}
+		}
+		if len(rule.WeightList) == 0 {
+			for range rule.IpList {
+				rule.WeightList = append(rule.WeightList, "50")
+			}
+		}
+		if len(rule.WeightList) != len(rule.IpList) {
+			return nil, fmt.Errorf("%v: ip_list and weight_list length mismatch", rule.Method)
+		}
+		if len(rule.IpList) > 0 {
+			rule.Upstream = rule.Method
+		}
+		if rule.QPS > 0 {
+			rule.Limit = rule.Method
+		}
+		ruleList = append(ruleList, rule)
+	}
+	return ruleList, nil
+}
+
+func (r *GrpcMethodRule) setOption(option string) error {
+	if option == GrpcMethodAllow || option == GrpcMethodDeny {
+		r.Access = option
+		return nil
+	}
+	items := strings.SplitN(option, "=", 2)
+	if len(items) != 2 || items[1] == "" {
+		return fmt.Errorf("invalid option %v", option)
+	}
+	var err error
+	switch items[0] {
+	case "qps":
+		if r.QPS, err = strconv.Atoi(items[1]); err == nil && r.QPS < 0 {
+			err = fmt.Errorf("invalid qps %v", items[1])
+		}
+	case "timeout":
+		r.Timeout, err = time.ParseDuration(items[1])
+	case "max_timeout":
+		r.MaxTimeout, err = time.ParseDuration(items[1])
+	case "ip_list":
+		r.IpList = strings.Split(items[1], ";")
+		for _, ip := range r.IpList {
+			if matched, _ := regexp.MatchString(`^\S+:\d+$`, ip); !matched {
+				return fmt.Errorf("invalid ip_list %v", items[1])
+			}
+		}
+	case "weight_list":
+		r.WeightList = strings.Split(items[1], ";")
+		for _, weight := range r.WeightList {
+			if _, err := strconv.Atoi(weight); err != nil {
+				return fmt.Errorf("invalid weight_list %v", items[1])
+			}
+		}
+	default:
+		return fmt.Errorf("unknown option %v", option)
+	}
+	return err
+}
+
+// Match 按从宽到窄的顺序合并匹配的规则，更具体的规则覆盖已设置的选项；
+// 存在 allow 规则时为白名单模式，未匹配到 allow 的方法一律拒绝
+func (l GrpcMethodRuleList) Match(fullMethod string) *GrpcMethodRule {
+	merged := &GrpcMethodRule{Method: fullMethod}
+	allowMode := false
+	for level := 0; level <= 2; level++ {
+		for _, rule := range l {
+			if rule.Access == GrpcMethodAllow {
+				allowMode = true
+			}
+			if rule.specificity() != level || !rule.match(fullMethod) {
+				continue
+			}
+			if rule.Access != "" {
+				merged.Access = rule.Access
+			}
+			if rule.QPS > 0 {
+				merged.QPS, merged.Limit = rule.QPS, rule.Limit
+			}
+			if rule.Timeout > 0 {
+				merged.Timeout = rule.Timeout
+			}
+			if rule.MaxTimeout > 0 {
+				merged.MaxTimeout = rule.MaxTimeout
+			}
+			if len(rule.IpList) > 0 {
+				merged.IpList, merged.WeightList, merged.Upstream = rule.IpList, rule.WeightList, rule.Upstream
+			}
+		}
+	}
+	if allowMode && merged.Access != GrpcMethodAllow {
+		merged.Access = GrpcMethodDeny
+	}
+	return merged
+}
+
+// Allow 方法是否允许访问
+func (r *GrpcMethodRule) Allow() bool {
+	return r.Access != GrpcMethodDeny
+}
@@ -0,0 +1,113 @@
+package public
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseGrpcMethodRules(t *testing.T) {
+	rules, err := ParseGrpcMethodRules(" * qps=100 timeout=2s ,\n/pkg.Svc/* deny max_timeout=10s, ,/pkg.Svc/Get allow ip_list=127.0.0.1:50051;127.0.0.1:50052")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("len(rules) = %d, want 3", len(rules))
+	}
+	if r := rules[0]; r.Method != "*" || r.QPS != 100 || r.Timeout != 2*time.Second || r.Limit != "*" {
+		t.Errorf("rules[0] = %+v", r)
+	}
+	if r := rules[1]; r.Access != GrpcMethodDeny || r.MaxTimeout != 10*time.Second || r.Limit != "" {
+		t.Errorf("rules[1] = %+v", r)
+	}
+	r := rules[2]
+	if r.Access != GrpcMethodAllow || r.Upstream != "/pkg.Svc/Get" || len(r.IpList) != 2 {
+		t.Errorf("rules[2] = %+v", r)
+	}
+	//未设置权重时平分
+	if len(r.WeightList) != 2 || r.WeightList[0] != "50" || r.WeightList[1] != "50" {
+		t.Errorf("rules[2].WeightList = %v, want [50 50]", r.WeightList)
+	}
+}
+
+func TestParseGrpcMethodRulesInvalid(t *testing.T) {
+	for _, rules := range []string{
+		"pkg.Svc/Get",
+		"/pkg.Svc",
+		"/pkg.Svc/Get*",
+		"/pkg.Svc/Get qps=-1",
+		"/pkg.Svc/Get qps=abc",
+		"/pkg.Svc/Get timeout=2",
+		"/pkg.Svc/Get unknown=1",
+		"/pkg.Svc/Get qps=",
+		"/pkg.Svc/Get permit",
+		"/pkg.Svc/Get ip_list=127.0.0.1",
+		"/pkg.Svc/Get ip_list=127.0.0.1:1 weight_list=50;50",
+		"/pkg.Svc/Get ip_list=127.0.0.1:1 weight_list=a",
+	} {
+		if _, err := ParseGrpcMethodRules(rules); err == nil {
+			t.Errorf("ParseGrpcMethodRules(%q): want error", rules)
+		}
+	}
+}
+
+func TestGrpcMethodRuleListMatchSpecificity(t *testing.T) {
+	//配置顺序与优先级无关，更具体的规则覆盖已设置的选项
+	rules, err := ParseGrpcMethodRules("/pkg.Svc/Get qps=10 timeout=1s,/pkg.Svc/* qps=50 timeout=3s max_timeout=5s ip_list=127.0.0.1:1,* qps=100 deny")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method   string
+		access   string
+		qps      int
+		limit    string
+		timeout  time.Duration
+		upstream string
+	}{
+		{"/pkg.Svc/Get", GrpcMethodDeny, 10, "/pkg.Svc/Get", time.Second, "/pkg.Svc/*"},
+		{"/pkg.Svc/List", GrpcMethodDeny, 50, "/pkg.Svc/*", 3 * time.Second, "/pkg.Svc/*"},
+		{"/pkg.Other/Get", GrpcMethodDeny, 100, "*", 0, ""},
+		//服务通配按前缀匹配，不能匹配名称相同前缀的其他服务
+		{"/pkg.SvcV2/Get", GrpcMethodDeny, 100, "*", 0, ""},
+	}
+	for _, test := range tests {
+		r := rules.Match(test.method)
+		if r.Access != test.access || r.QPS != test.qps || r.Limit != test.limit || r.Timeout != test.timeout ||
+			r.Upstream != test.upstream {
+			t.Errorf("Match(%v) = %+v, want access=%v qps=%v limit=%v timeout=%v upstream=%v", test.method, r,
+				test.access, test.qps, test.limit, test.timeout, test.upstream)
+		}
+		if test.upstream != "" && r.MaxTimeout != 5*time.Second {
+			t.Errorf("Match(%v).MaxTimeout = %v, want 5s", test.method, r.MaxTimeout)
+		}
+	}
+}
+
+func TestGrpcMethodRuleListMatchAllowMode(t *testing.T) {
+	rules, err := ParseGrpcMethodRules("/pkg.Svc/* allow,/pkg.Svc/Delete deny,/pkg.Admin/Get allow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		"/pkg.Svc/Get":      true,
+		"/pkg.Svc/Delete":   false, //更具体的 deny 覆盖服务通配的 allow
+		"/pkg.Admin/Get":    true,
+		"/pkg.Admin/Delete": false, //白名单模式下未匹配 allow 的方法拒绝
+		"/pkg.Other/Get":    false,
+	}
+	for method, want := range tests {
+		if got := rules.Match(method).Allow(); got != want {
+			t.Errorf("Match(%v).Allow() = %v, want %v", method, got, want)
+		}
+	}
+
+	//没有 allow 规则时只拒绝 deny 的方法
+	rules, _ = ParseGrpcMethodRules("/pkg.Svc/Delete deny")
+	if !rules.Match("/pkg.Svc/Get").Allow() || rules.Match("/pkg.Svc/Delete").Allow() {
+		t.Errorf("deny only rules: Get allowed = %v, Delete allowed = %v",
+			rules.Match("/pkg.Svc/Get").Allow(), rules.Match("/pkg.Svc/Delete").Allow())
+	}
+	if !GrpcMethodRuleList(nil).Match("/pkg.Svc/Get").Allow() {
+		t.Error("empty rules should allow all methods")
+	}
+}
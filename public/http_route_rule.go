@@ -0,0 +1,299 @@
+package public
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	HTTPRoutePathPrefix = 0
+	HTTPRoutePathExact  = 1
+	HTTPRoutePathRegexp = 2
+)
+
+var httpRouteMethodPattern = regexp.MustCompile(`^[A-Z]+$`)
+
+// HTTPRouteCondition 请求头、查询参数或 cookie 条件，Value 为空时只要求存在
+type HTTPRouteCondition struct {
+	Name  string
+	Value string
+}
+
+// HTTPRouteRule http 服务的路由规则，服务自身的接入规则(域名或前缀)也转换为一条路由
+type HTTPRouteRule struct {
+	Name     string //路由标识，由路由配置生成，作为下游节点及限流缓存 key 的后缀
+	Host     string //域名，*.example.com 匹配任意子域名，为空时不限制
+	Path     string
+	PathType int
+	Methods  []string
+	Headers  []HTTPRouteCondition
+	Queries  []HTTPRouteCondition
+	Cookies  []HTTPRouteCondition
+
+	IpList     []string //单独的下游节点，为空时使用服务的下游节点
+	WeightList []string
+	StripUri   int      //-1 为沿用服务配置
+	OpenAuth   int      //-1 为沿用服务配置
+	WhiteList  []string //为 nil 时沿用服务配置
+	BlackList  []string //为 nil 时沿用服务配置
+	QPS        int      //路由单独的限流，0 为不限制
+
+	pathRegexp *regexp.Regexp
+}
+
+// SplitHTTPDomainRule 域名接入规则可带路径前缀，如 api.example.com/v2
+func SplitHTTPDomainRule(rule string) (string, string) {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+	if pos := strings.Index(rule, "/"); pos >= 0 {
+		return rule[:pos], rule[pos:]
+	}
+	return rule, ""
+}
+
+// NewHTTPServiceRoute 服务自身的接入规则对应的路由：前缀接入不限域名，域名接入可带路径前缀
+func NewHTTPServiceRoute(ruleType int, rule string) *HTTPRouteRule {
+	route := newHTTPRouteRule("")
+	if ruleType == HTTPRuleTypeDomain {
+		route.Host, route.Path = SplitHTTPDomainRule(rule)
+		return route
+	}
+	route.Path = rule
+	return route
+}
+
+func newHTTPRouteRule(name string) *HTTPRouteRule {
+	return &HTTPRouteRule{Name: name, StripUri: -1, OpenAuth: -1}
+}
+
+// ParseHTTPRouteRules 每行一条路由，格式：路径 选项...，路径 /api 为前缀匹配、=/api 为精确匹配、~^/api/v\d+ 为正则匹配；
+// 选项空格间隔：host=*.example.com、method=GET;POST、header=X-Env:canary、query=version:2、cookie=beta、
+// ip_list=127.0.0.1:8001;127.0.0.1:8002、weight_list=50;50、strip_uri=1、open_auth=0、white_list=a;b、black_list=a;b、qps=100，
+// header/query/cookie 可重复，值为空时只要求存在；路由在服务的接入规则匹配后生效，路径为完整的请求路径；
+// 路由标识取自路由配置的摘要，调整路由顺序或增删其他路由不影响已有路由的下游节点及限流
+func ParseHTTPRouteRules(rules string) ([]*HTTPRouteRule, error) {
+	routeList := []*HTTPRouteRule{}
+	nameMap := map[string]bool{}
+	for _, line := range strings.Split(rules, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		name := "/route_" + MD5(strings.Join(fields, " "))[:16]
+		if nameMap[name] {
+			return nil, fmt.Errorf("%v: duplicate route", fields[0])
+		}
+		nameMap[name] = true
+		route := newHTTPRouteRule(name)
+		if err := route.setPath(fields[0]); err != nil {
+			return nil, err
+		}
+		for _, option := range fields[1:] {
+			if err := route.setOption(option); err != nil {
+				return nil, fmt.Errorf("%v: %v", fields[0], err)
+			}
+		}
+		if len(route.WeightList) == 0 {
+			for range route.IpList {
+				route.WeightList = append(route.WeightList, "50")
+			}
+		}
+		if len(route.WeightList) != len(route.IpList) {
+			return nil, fmt.Errorf("%v: ip_list and weight_list length mismatch", fields[0])
+		}
+		routeList = append(routeList, route)
+	}
+	return routeList, nil
+}
+
+func (r *HTTPRouteRule) setPath(path string) error {
+	switch {
+	case strings.HasPrefix(path, "~"):
+		pathRegexp, err := regexp.Compile(path[1:])
+		if err != nil {
+			return fmt.Errorf("invalid path regexp %v", path)
+		}
+		r.PathType, r.Path, r.pathRegexp = HTTPRoutePathRegexp, path[1:], pathRegexp
+		return nil
+	case strings.HasPrefix(path, "="):
+		r.PathType, r.Path = HTTPRoutePathExact, path[1:]
+	default:
+		r.PathType, r.Path = HTTPRoutePathPrefix, path
+	}
+	if !strings.HasPrefix(r.Path, "/") {
+		return fmt.Errorf("invalid path %v", path)
+	}
+	return nil
+}
+
+func (r *HTTPRouteRule) setOption(option string) error {
+	items := strings.SplitN(option, "=", 2)
+	if len(items) != 2 || items[1] == "" {
+		return fmt.Errorf("invalid option %v", option)
+	}
+	var err error
+	switch items[0] {
+	case "host":
+		r.Host = strings.ToLower(items[1])
+		if strings.Contains(strings.TrimPrefix(r.Host, "*."), "*") {
+			return fmt.Errorf("invalid host %v", items[1])
+		}
+	case "method":
+		for _, method := range strings.Split(strings.ToUpper(items[1]), ";") {
+			if !httpRouteMethodPattern.MatchString(method) {
+				return fmt.Errorf("invalid method %v", items[1])
+			}
+			r.Methods = append(r.Methods, method)
+		}
+	case "header", "query", "cookie":
+		pair := strings.SplitN(items[1], ":", 2)
+		condition := HTTPRouteCondition{Name: pair[0]}
+		if len(pair) == 2 {
+			condition.Value = pair[1]
+		}
+		if condition.Name == "" {
+			return fmt.Errorf("invalid option %v", option)
+		}
+		switch items[0] {
+		case "header":
+			r.Headers = append(r.Headers, condition)
+		case "query":
+			r.Queries = append(r.Queries, condition)
+		default:
+			r.Cookies = append(r.Cookies, condition)
+		}
+	case "ip_list":
+		r.IpList = strings.Split(items[1], ";")
+		for _, ip := range r.IpList {
+			if matched, _ := regexp.MatchString(`^\S+:\d+$`, ip); !matched {
+				return fmt.Errorf("invalid ip_list %v", items[1])
+			}
+		}
+	case "weight_list":
+		r.WeightList = strings.Split(items[1], ";")
+		for _, weight := range r.WeightList {
+			if _, err := strconv.Atoi(weight); err != nil {
+				return fmt.Errorf("invalid weight_list %v", items[1])
+			}
+		}
+	case "strip_uri":
+		r.StripUri, err = parseHTTPRouteSwitch(items[1])
+	case "open_auth":
+		r.OpenAuth, err = parseHTTPRouteSwitch(items[1])
+	case "white_list":
+		r.WhiteList = strings.Split(items[1], ";")
+	case "black_list":
+		r.BlackList = strings.Split(items[1], ";")
+	case "qps":
+		if r.QPS, err = strconv.Atoi(items[1]); err == nil && r.QPS < 0 {
+			err = fmt.Errorf("invalid qps %v", items[1])
+		}
+	default:
+		return fmt.Errorf("unknown option %v", option)
+	}
+	return err
+}
+
+func parseHTTPRouteSwitch(value string) (int, error) {
+	if value != "0" && value != "1" {
+		return 0, fmt.Errorf("invalid switch %v", value)
+	}
+	return strconv.Atoi(value)
+}
+
+// HostRank 域名匹配的优先级：精确域名 > 通配域名(后缀越长越优先) > 不限域名
+func (r *HTTPRouteRule) HostRank() int {
+	switch {
+	case r.Host == "":
+		return 0
+	case strings.HasPrefix(r.Host, "*."):
+		return len(r.Host)
+	}
+	return 1 << 16
+}
+
+// PathRank 路径匹配的优先级：精确 > 前缀(越长越优先) > 正则
+func (r *HTTPRouteRule) PathRank() int {
+	switch r.PathType {
+	case HTTPRoutePathExact:
+		return 1 << 16
+	case HTTPRoutePathPrefix:
+		return 1 + len(r.Path)
+	}
+	return 0
+}
+
+// ConditionCount 请求方法、请求头、查询参数及 cookie 条件数，路径相同时条件多的优先
+func (r *HTTPRouteRule) ConditionCount() int {
+	count := len(r.Headers) + len(r.Queries) + len(r.Cookies)
+	if len(r.Methods) > 0 {
+		count++
+	}
+	return count
+}
+
+// MatchHost host 为小写且不带端口
+func (r *HTTPRouteRule) MatchHost(host string) bool {
+	if r.Host == "" || r.Host == host {
+		return true
+	}
+	return strings.HasPrefix(r.Host, "*.") && strings.HasSuffix(host, r.Host[1:])
+}
+
+// MatchPath 匹配请求路径
+func (r *HTTPRouteRule) MatchPath(path string) bool {
+	switch r.PathType {
+	case HTTPRoutePathExact:
+		return path == r.Path
+	case HTTPRoutePathRegexp:
+		return r.pathRegexp.MatchString(path)
+	}
+	return strings.HasPrefix(path, r.Path)
+}
+
+// MatchConditions 匹配请求方法、请求头、查询参数及 cookie 条件
+func (r *HTTPRouteRule) MatchConditions(req *http.Request) bool {
+	if len(r.Methods) > 0 && !InStringSlice(r.Methods, req.Method) {
+		return false
+	}
+	for _, condition := range r.Headers {
+		if !condition.match(req.Header.Values(condition.Name)) {
+			return false
+		}
+	}
+	if len(r.Queries) > 0 {
+		query := req.URL.Query()
+		for _, condition := range r.Queries {
+			if !condition.match(query[condition.Name]) {
+				return false
+			}
+		}
+	}
+	for _, condition := range r.Cookies {
+		values := []string{}
+		if cookie, err := req.Cookie(condition.Name); err == nil {
+			values = append(values, cookie.Value)
+		}
+		if !condition.match(values) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c HTTPRouteCondition) match(values []string) bool {
+	if c.Value == "" {
+		return len(values) > 0
+	}
+	return InStringSlice(values, c.Value)
+}
+
+// StripPrefix 开启 strip_uri 时需要剥离的路径前缀，只有前缀匹配的路由剥离
+func (r *HTTPRouteRule) StripPrefix() string {
+	if r.PathType != HTTPRoutePathPrefix || r.Path == "/" {
+		return ""
+	}
+	return r.Path
+}
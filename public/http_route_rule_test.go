@@ -0,0 +1,221 @@
+package public
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseHTTPRouteRules(t *testing.T) {
+	routes, err := ParseHTTPRouteRules(`
+/api/v2 host=*.Example.com method=get;post header=X-Env:canary header=X-Beta query=version:2 cookie=beta
+=/api/health strip_uri=0 open_auth=1 white_list=a;b black_list=c qps=100
+
+~^/api/v\d+/users ip_list=127.0.0.1:8001;127.0.0.1:8002 weight_list=30;70
+/static ip_list=127.0.0.1:8003`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 4 {
+		t.Fatalf("len(routes) = %d, want 4", len(routes))
+	}
+	r := routes[0]
+	if r.PathType != HTTPRoutePathPrefix || r.Path != "/api/v2" || r.Host != "*.example.com" {
+		t.Errorf("routes[0] path = %v %v, host = %v", r.PathType, r.Path, r.Host)
+	}
+	if len(r.Methods) != 2 || r.Methods[0] != "GET" || r.Methods[1] != "POST" {
+		t.Errorf("routes[0].Methods = %v", r.Methods)
+	}
+	if len(r.Headers) != 2 || r.Headers[0] != (HTTPRouteCondition{"X-Env", "canary"}) || r.Headers[1] != (HTTPRouteCondition{"X-Beta", ""}) {
+		t.Errorf("routes[0].Headers = %v", r.Headers)
+	}
+	if len(r.Queries) != 1 || len(r.Cookies) != 1 || r.ConditionCount() != 5 {
+		t.Errorf("routes[0] queries = %v, cookies = %v, conditions = %d", r.Queries, r.Cookies, r.ConditionCount())
+	}
+	//未设置的选项沿用服务配置
+	if r.StripUri != -1 || r.OpenAuth != -1 || r.WhiteList != nil || r.BlackList != nil || r.QPS != 0 {
+		t.Errorf("routes[0] overrides = %+v", r)
+	}
+
+	r = routes[1]
+	if r.PathType != HTTPRoutePathExact || r.Path != "/api/health" || r.StripUri != 0 || r.OpenAuth != 1 || r.QPS != 100 {
+		t.Errorf("routes[1] = %+v", r)
+	}
+	if len(r.WhiteList) != 2 || len(r.BlackList) != 1 {
+		t.Errorf("routes[1] white_list = %v, black_list = %v", r.WhiteList, r.BlackList)
+	}
+
+	r = routes[2]
+	if r.PathType != HTTPRoutePathRegexp || !r.MatchPath("/api/v3/users/1") || r.MatchPath("/api/vx/users") {
+		t.Errorf("routes[2] regexp = %v", r.Path)
+	}
+	if len(r.WeightList) != 2 || r.WeightList[1] != "70" {
+		t.Errorf("routes[2].WeightList = %v", r.WeightList)
+	}
+	//未设置权重时平分
+	if r = routes[3]; len(r.WeightList) != 1 || r.WeightList[0] != "50" {
+		t.Errorf("routes[3].WeightList = %v", r.WeightList)
+	}
+}
+
+func TestParseHTTPRouteRulesName(t *testing.T) {
+	routes, err := ParseHTTPRouteRules("/a qps=10\n/b qps=20")
+	if err != nil {
+		t.Fatal(err)
+	}
+	//路由标识与顺序及其他路由无关，只取决于路由本身的配置
+	reordered, err := ParseHTTPRouteRules("/c\n/b   qps=20\n/a qps=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if routes[0].Name != reordered[2].Name || routes[1].Name != reordered[1].Name {
+		t.Errorf("names %v %v, reordered %v %v", routes[0].Name, routes[1].Name, reordered[2].Name, reordered[1].Name)
+	}
+	if routes[0].Name == routes[1].Name || routes[0].Name[0] != '/' {
+		t.Errorf("names %v %v should differ and start with /", routes[0].Name, routes[1].Name)
+	}
+	changed, _ := ParseHTTPRouteRules("/a qps=11")
+	if changed[0].Name == routes[0].Name {
+		t.Errorf("changed route keeps name %v", changed[0].Name)
+	}
+	if _, err := ParseHTTPRouteRules("/a qps=10\n/a  qps=10"); err == nil {
+		t.Error("duplicate route: want error")
+	}
+}
+
+func TestParseHTTPRouteRulesInvalid(t *testing.T) {
+	for _, rules := range []string{
+		"api",
+		"=api",
+		"~^/api(",
+		"/api host=a.*.com",
+		"/api method=GE-T",
+		"/api header=:v",
+		"/api ip_list=127.0.0.1",
+		"/api weight_list=x",
+		"/api ip_list=127.0.0.1:1 weight_list=50;50",
+		"/api strip_uri=2",
+		"/api open_auth=yes",
+		"/api qps=-1",
+		"/api qps=",
+		"/api unknown=1",
+	} {
+		if _, err := ParseHTTPRouteRules(rules); err == nil {
+			t.Errorf("ParseHTTPRouteRules(%q): want error", rules)
+		}
+	}
+}
+
+func TestHTTPRouteRuleRank(t *testing.T) {
+	parse := func(rule string) *HTTPRouteRule {
+		routes, err := ParseHTTPRouteRules(rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return routes[0]
+	}
+	exactHost := parse("/ host=api.example.com")
+	longWildcard := parse("/ host=*.api.example.com")
+	wildcard := parse("/ host=*.example.com")
+	anyHost := parse("/")
+	if !(exactHost.HostRank() > longWildcard.HostRank() && longWildcard.HostRank() > wildcard.HostRank() &&
+		wildcard.HostRank() > anyHost.HostRank()) {
+		t.Errorf("HostRank exact=%d long=%d wildcard=%d any=%d", exactHost.HostRank(), longWildcard.HostRank(),
+			wildcard.HostRank(), anyHost.HostRank())
+	}
+
+	exact := parse("=/api/users/list")
+	longPrefix := parse("/api/users")
+	prefix := parse("/api")
+	re := parse(`~^/api/users/\d+/profile/detail`)
+	if !(exact.PathRank() > longPrefix.PathRank() && longPrefix.PathRank() > prefix.PathRank() &&
+		prefix.PathRank() > re.PathRank()) {
+		t.Errorf("PathRank exact=%d long=%d prefix=%d regexp=%d", exact.PathRank(), longPrefix.PathRank(),
+			prefix.PathRank(), re.PathRank())
+	}
+	//前缀 / 仍优先于正则
+	if parse("/ qps=1").PathRank() <= re.PathRank() {
+		t.Error("prefix / should rank above regexp")
+	}
+}
+
+func TestHTTPRouteRuleMatchHost(t *testing.T) {
+	wildcard := &HTTPRouteRule{Host: "*.example.com"}
+	tests := map[string]bool{
+		"a.example.com":   true,
+		"a.b.example.com": true,
+		"example.com":     false,
+		"aexample.com":    false,
+	}
+	for host, want := range tests {
+		if got := wildcard.MatchHost(host); got != want {
+			t.Errorf("MatchHost(%v) = %v, want %v", host, got, want)
+		}
+	}
+	if !(&HTTPRouteRule{}).MatchHost("any.com") {
+		t.Error("empty host should match any host")
+	}
+}
+
+func TestHTTPRouteRuleMatchConditions(t *testing.T) {
+	routes, err := ParseHTTPRouteRules("/api method=GET;POST header=X-Env:canary header=X-Beta query=version:2 cookie=uid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	route := routes[0]
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/users?version=1&version=2", nil)
+		req.Header.Add("X-Env", "stable")
+		req.Header.Add("X-Env", "canary")
+		req.Header.Set("X-Beta", "")
+		req.AddCookie(&http.Cookie{Name: "uid", Value: "7"})
+		return req
+	}
+	if !route.MatchConditions(newRequest()) {
+		t.Error("all conditions met: want match")
+	}
+	tests := map[string]func(req *http.Request){
+		"method":       func(req *http.Request) { req.Method = http.MethodDelete },
+		"header value": func(req *http.Request) { req.Header.Set("X-Env", "stable") },
+		"header exist": func(req *http.Request) { req.Header.Del("X-Beta") },
+		"query":        func(req *http.Request) { req.URL.RawQuery = "version=1" },
+		"cookie":       func(req *http.Request) { req.Header.Del("Cookie") },
+	}
+	for name, modify := range tests {
+		req := newRequest()
+		modify(req)
+		if route.MatchConditions(req) {
+			t.Errorf("%v not met: want no match", name)
+		}
+	}
+}
+
+func TestHTTPRouteRuleStripPrefix(t *testing.T) {
+	tests := map[string]string{
+		"/api/v2":        "/api/v2",
+		"/":              "",
+		"=/api/health":   "",
+		`~^/api/v\d+`:    "",
+		"/api/v2/ qps=1": "/api/v2/",
+	}
+	for rule, want := range tests {
+		routes, err := ParseHTTPRouteRules(rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := routes[0].StripPrefix(); got != want {
+			t.Errorf("%v StripPrefix() = %q, want %q", rule, got, want)
+		}
+	}
+}
+
+func TestNewHTTPServiceRoute(t *testing.T) {
+	route := NewHTTPServiceRoute(HTTPRuleTypeDomain, " API.example.com/v2 ")
+	if route.Host != "api.example.com" || route.Path != "/v2" || route.PathType != HTTPRoutePathPrefix {
+		t.Errorf("domain route = %+v", route)
+	}
+	route = NewHTTPServiceRoute(HTTPRuleTypePrefixURL, "/svc")
+	if route.Host != "" || route.Path != "/svc" || route.StripUri != -1 || route.OpenAuth != -1 {
+		t.Errorf("prefix route = %+v", route)
+	}
+}
@@ -0,0 +1,37 @@
+package public
+
+import (
+	"go-gateway/common/lib"
+	"go-gateway/tcp_server"
+	"log"
+	"strings"
+	"time"
+)
+
+const defaultProxyProtoHeaderTimeout = 5
+
+// GetProxyProtoConfig 读取 proxy.proxy_protocol 配置，listener 为 http、grpc、tcp，未开启或未配置可信来源时返回 nil
+func GetProxyProtoConfig(listener string) *tcp_server.ProxyProtoConfig {
+	if !lib.GetBoolConf("proxy.proxy_protocol." + listener + "_open") {
+		return nil
+	}
+	trustedList := []string{}
+	for _, item := range strings.Split(lib.GetStringConf("proxy.proxy_protocol.trusted_list"), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			trustedList = append(trustedList, item)
+		}
+	}
+	//任意来源都能伪造客户端地址，绕过黑白名单及限流，必须指定可信来源
+	if len(trustedList) == 0 {
+		log.Printf(" [ERROR] proxy_protocol %v_open requires trusted_list, proxy protocol disabled\n", listener)
+		return nil
+	}
+	timeout := lib.GetIntConf("proxy.proxy_protocol.header_timeout")
+	if timeout <= 0 {
+		timeout = defaultProxyProtoHeaderTimeout
+	}
+	return &tcp_server.ProxyProtoConfig{
+		TrustedList:   trustedList,
+		HeaderTimeout: time.Duration(timeout) * time.Second,
+	}
+}
@@ -26,3 +26,9 @@ func RedisConfDo(commandName string, args ...interface{}) (interface{}, error) {
 	defer c.Close()
 	return c.Do(commandName, args...)
 }
+
+// PublishConfChange 通知代理服务器配置已变更，代理侧收到后立即热加载
+func PublishConfChange(confType string) error {
+	_, err := RedisConfDo("PUBLISH", RedisConfChangeChannel, confType)
+	return err
+}
@@ -75,6 +75,12 @@ func (o *RedisFlowCountService) GetDayKey(t time.Time) string {
 	return fmt.Sprintf("%s_%s_%s", RedisFlowDayKey, dayStr, o.AppID)
 }
 
+// UntilNextDay 距离日统计切换到下一天的时长，日请求量超限时作为重试等待时间
+func UntilNextDay(t time.Time) time.Duration {
+	t = t.In(lib.TimeLocation)
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location()).Sub(t)
+}
+
 func (o *RedisFlowCountService) GetHourKey(t time.Time) string {
 	hourStr := t.In(lib.TimeLocation).Format("2006010215")
 	return fmt.Sprintf("%s_%s_%s", RedisFlowHourKey, hourStr, o.AppID)
@@ -0,0 +1,125 @@
+package public
+
+import (
+	"sync"
+	"time"
+)
+
+// retryBudgetBuckets 重试预算按秒分桶，统计最近 retryBudgetBuckets 秒
+const retryBudgetBuckets = 10
+
+var RetryBudgetHandler *RetryBudgeter
+
+type RetryBudgeter struct {
+	RetryBudgetMap   map[string]*RetryBudget
+	RetryBudgetSlice []*RetryBudget
+	Locker           sync.RWMutex
+}
+
+func NewRetryBudgeter() *RetryBudgeter {
+	return &RetryBudgeter{
+		RetryBudgetMap:   map[string]*RetryBudget{},
+		RetryBudgetSlice: []*RetryBudget{},
+		Locker:           sync.RWMutex{},
+	}
+}
+
+func init() {
+	RetryBudgetHandler = NewRetryBudgeter()
+}
+
+// GetBudget 获取服务的重试预算，percent 为重试数占请求数的最大百分比，minPerSecond 为每秒保底重试数
+func (b *RetryBudgeter) GetBudget(serviceName string, percent, minPerSecond int) *RetryBudget {
+	b.Locker.RLock()
+	budget, ok := b.RetryBudgetMap[serviceName]
+	b.Locker.RUnlock()
+	if ok {
+		return budget
+	}
+
+	b.Locker.Lock()
+	defer b.Locker.Unlock()
+	if budget, ok := b.RetryBudgetMap[serviceName]; ok {
+		return budget
+	}
+	budget = NewRetryBudget(serviceName, percent, minPerSecond)
+	b.RetryBudgetSlice = append(b.RetryBudgetSlice, budget)
+	b.RetryBudgetMap[serviceName] = budget
+	return budget
+}
+
+func (b *RetryBudgeter) RemoveBudget(serviceName string) {
+	b.Locker.Lock()
+	defer b.Locker.Unlock()
+	budgetSlice := []*RetryBudget{}
+	for _, item := range b.RetryBudgetSlice {
+		if item.ServiceName == serviceName {
+			delete(b.RetryBudgetMap, item.ServiceName)
+			continue
+		}
+		budgetSlice = append(budgetSlice, item)
+	}
+	b.RetryBudgetSlice = budgetSlice
+}
+
+type retryBudgetBucket struct {
+	unix     int64
+	requests int64
+	retries  int64
+}
+
+// RetryBudget 限制统计窗口内的重试数不超过请求数的一定比例，防止下游故障时重试放大流量
+type RetryBudget struct {
+	ServiceName  string
+	Percent      int
+	MinPerSecond int
+
+	mux     sync.Mutex
+	buckets [retryBudgetBuckets]retryBudgetBucket
+}
+
+func NewRetryBudget(serviceName string, percent, minPerSecond int) *RetryBudget {
+	return &RetryBudget{
+		ServiceName:  serviceName,
+		Percent:      percent,
+		MinPerSecond: minPerSecond,
+	}
+}
+
+func (b *RetryBudget) bucketLocked(now int64) *retryBudgetBucket {
+	bucket := &b.buckets[now%retryBudgetBuckets]
+	if bucket.unix != now {
+		*bucket = retryBudgetBucket{unix: now}
+	}
+	return bucket
+}
+
+// Request 记录一次原始请求
+func (b *RetryBudget) Request() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.bucketLocked(time.Now().Unix()).requests++
+}
+
+// TryRetry 预算充足时记录一次重试并返回 true
+func (b *RetryBudget) TryRetry() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	now := time.Now().Unix()
+	var requests, retries int64
+	for _, bucket := range b.buckets {
+		if now-bucket.unix < retryBudgetBuckets {
+			requests += bucket.requests
+			retries += bucket.retries
+		}
+	}
+	allowed := requests * int64(b.Percent) / 100
+	if minRetries := int64(b.MinPerSecond) * retryBudgetBuckets; allowed < minRetries {
+		allowed = minRetries
+	}
+	if retries >= allowed {
+		return false
+	}
+	b.bucketLocked(now).retries++
+	return true
+}
@@ -0,0 +1,36 @@
+package public
+
+import "testing"
+
+func TestRetryBudgetPercent(t *testing.T) {
+	budget := NewRetryBudget("test", 20, 0)
+	for i := 0; i < 10; i++ {
+		budget.Request()
+	}
+	for i := 0; i < 2; i++ {
+		if !budget.TryRetry() {
+			t.Fatalf("retry %d denied, want 2 retries for 10 requests at 20%%", i+1)
+		}
+	}
+	if budget.TryRetry() {
+		t.Error("third retry allowed, want budget exhausted")
+	}
+	for i := 0; i < 5; i++ {
+		budget.Request()
+	}
+	if !budget.TryRetry() {
+		t.Error("retry denied after more requests, want allowed")
+	}
+}
+
+func TestRetryBudgetMinPerSecond(t *testing.T) {
+	budget := NewRetryBudget("test", 0, 1)
+	for i := 0; i < retryBudgetBuckets; i++ {
+		if !budget.TryRetry() {
+			t.Fatalf("retry %d denied, want %d retries from min_per_second", i+1, retryBudgetBuckets)
+		}
+	}
+	if budget.TryRetry() {
+		t.Error("retry allowed beyond min_per_second budget")
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"strings"
+	"time"
 )
 
 func GenSaltPassword(salt, password string) string {
@@ -36,3 +39,23 @@ func InStringSlice(slice []string, str string) bool {
 	}
 	return false
 }
+
+// IsGrpcRequest content-type 为 application/grpc 或 application/grpc+proto 等的请求
+func IsGrpcRequest(req *http.Request) bool {
+	contentType := req.Header.Get("Content-Type")
+	return contentType == "application/grpc" ||
+		strings.HasPrefix(contentType, "application/grpc+") ||
+		strings.HasPrefix(contentType, "application/grpc;")
+}
+
+// RetryDelay 连续失败 failures 次后到下次重试的等待时间，从 base 开始每次翻倍，不超过 max
+func RetryDelay(failures int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
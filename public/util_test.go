@@ -0,0 +1,27 @@
+package public
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	base, max := time.Hour, 24*time.Hour
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, time.Hour},
+		{1, time.Hour},
+		{2, 2 * time.Hour},
+		{3, 4 * time.Hour},
+		{5, 16 * time.Hour},
+		{6, 24 * time.Hour},
+		{100, 24 * time.Hour},
+	}
+	for _, test := range tests {
+		if got := RetryDelay(test.failures, base, max); got != test.want {
+			t.Errorf("RetryDelay(%d) = %v, want %v", test.failures, got, test.want)
+		}
+	}
+}
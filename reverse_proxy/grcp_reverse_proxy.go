@@ -2,26 +2,66 @@ package reverse_proxy
 
 import (
 	"context"
-	"github.com/e421083458/grpc-proxy/proxy"
 	"go-gateway/reverse_proxy/load_balance"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
-	"log"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"time"
 )
 
-func NewGrpcLoadBalanceHandler(lb load_balance.LoadBalance) grpc.StreamHandler {
-	return func() grpc.StreamHandler {
-		nextAddr, err := lb.Get("")
-		if err != nil {
-			log.Fatal("get next addr fail")
-		}
+// NewGrpcLoadBalanceHandler 每个 stream 单独通过负载均衡选择下游，连接从 pool 中复用
+func NewGrpcLoadBalanceHandler(lb load_balance.LoadBalance, pool *GrpcConnPool) grpc.StreamHandler {
+	return func(srv interface{}, serverStream grpc.ServerStream) error {
+		//本次 stream 选中的节点，结束时回调负载均衡
+		var nextAddr string
+		var startTime time.Time
 		director := func(ctx context.Context, fullMethodName string) (context.Context, *grpc.ClientConn, error) {
-			c, err := grpc.DialContext(ctx, nextAddr, grpc.WithCodec(proxy.Codec()), grpc.WithInsecure())
+			addr, err := lb.Get(grpcClientIP(ctx))
+			if err != nil || addr == "" {
+				return nil, nil, status.Errorf(codes.Unavailable, "get next addr fail")
+			}
+			nextAddr, startTime = addr, time.Now()
+			lb.OnRequestStart(nextAddr)
+			c, err := pool.Get(nextAddr)
+			if err != nil {
+				return nil, nil, status.Errorf(codes.Unavailable, "dial %v fail: %v", nextAddr, err)
+			}
 			md, _ := metadata.FromIncomingContext(ctx)
-			outCtx, _ := context.WithCancel(ctx)
-			outCtx = metadata.NewOutgoingContext(outCtx, md.Copy())
-			return outCtx, c, err
+			outMD := md.Copy()
+			//租户信息只在网关中间件间传递，不转发给下游
+			outMD.Delete("app")
+			outCtx := metadata.NewOutgoingContext(ctx, outMD)
+			return outCtx, c, nil
+		}
+		err := GrpcTransparentHandler(director)(srv, serverStream)
+		if nextAddr != "" {
+			lb.OnRequestFinish(nextAddr, time.Since(startTime), grpcBackendErr(err))
 		}
-		return proxy.TransparentHandler(director)
-	}()
+		return err
+	}
+}
+
+// grpcBackendErr 只有下游不可用、超时等错误计入节点异常，业务错误码视为正常响应
+func grpcBackendErr(err error) error {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown:
+		return err
+	}
+	return nil
+}
+
+// grpcClientIP 客户端 ip，作为 ip_hash 负载的 key
+func grpcClientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
 }
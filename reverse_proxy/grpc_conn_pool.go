@@ -0,0 +1,87 @@
+package reverse_proxy
+
+import (
+	"crypto/tls"
+	"errors"
+	"go-gateway/reverse_proxy/load_balance"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"log"
+	"strings"
+	"sync"
+)
+
+// GrpcConnPool 按下游地址复用 grpc.ClientConn；
+// 作为观察者挂载到负载均衡配置上，节点被探活摘除时关闭对应连接
+type GrpcConnPool struct {
+	conf    load_balance.LoadBalanceConf
+	tlsConf *tls.Config //为空时以明文 h2c 连接下游
+	conns   map[string]*grpc.ClientConn
+	locker  sync.Mutex
+	closed  bool
+}
+
+func NewGrpcConnPool(conf load_balance.LoadBalanceConf, tlsConf *tls.Config) *GrpcConnPool {
+	pool := &GrpcConnPool{
+		conf:    conf,
+		tlsConf: tlsConf,
+		conns:   map[string]*grpc.ClientConn{},
+	}
+	if conf != nil {
+		conf.Attach(pool)
+	}
+	return pool
+}
+
+// Get 获取下游连接，不存在时新建；grpc.ClientConn 自带断线重连，可长期复用
+func (p *GrpcConnPool) Get(addr string) (*grpc.ClientConn, error) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if p.closed {
+		return nil, errors.New("grpc conn pool closed")
+	}
+	if c, ok := p.conns[addr]; ok {
+		return c, nil
+	}
+	creds := grpc.WithInsecure()
+	if p.tlsConf != nil {
+		creds = grpc.WithTransportCredentials(credentials.NewTLS(p.tlsConf))
+	}
+	c, err := grpc.Dial(addr,
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(GrpcCodec())),
+		creds)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[addr] = c
+	return c, nil
+}
+
+// Update 可用节点变化时关闭已被摘除节点的连接
+func (p *GrpcConnPool) Update() {
+	activeAddr := map[string]bool{}
+	for _, item := range p.conf.GetConf() {
+		activeAddr[strings.Split(item, ",")[0]] = true
+	}
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	for addr, c := range p.conns {
+		if activeAddr[addr] {
+			continue
+		}
+		c.Close()
+		delete(p.conns, addr)
+		log.Printf(" [INFO] grpc_conn_pool close %v\n", addr)
+	}
+}
+
+// Close 关闭全部连接，服务下线时调用
+func (p *GrpcConnPool) Close() {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	p.closed = true
+	for addr, c := range p.conns {
+		c.Close()
+		delete(p.conns, addr)
+	}
+}
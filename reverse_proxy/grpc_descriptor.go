@@ -0,0 +1,112 @@
+package reverse_proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-gateway/public"
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"strings"
+)
+
+// FetchGrpcDescriptorByReflection 通过下游的 server reflection(grpc.reflection.v1) 获取全部服务的描述，
+// 下游未返回的依赖文件(如 google/protobuf 下的公共类型)从本地注册的描述中补齐
+func FetchGrpcDescriptorByReflection(ctx context.Context, conn *grpc.ClientConn) (*protoregistry.Files, error) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.CloseSend()
+	call := func(req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if errResp := resp.GetErrorResponse(); errResp != nil {
+			return nil, fmt.Errorf("reflection error %d: %s", errResp.GetErrorCode(), errResp.GetErrorMessage())
+		}
+		return resp, nil
+	}
+
+	resp, err := call(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_ListServices{},
+	})
+	if err != nil {
+		return nil, err
+	}
+	fileMap := map[string]*descriptorpb.FileDescriptorProto{}
+	addFiles := func(resp *rpb.ServerReflectionResponse) error {
+		for _, data := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(data, fd); err != nil {
+				return err
+			}
+			fileMap[fd.GetName()] = fd
+		}
+		return nil
+	}
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		if strings.HasPrefix(service.GetName(), "grpc.reflection.") {
+			continue
+		}
+		resp, err := call(&rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service.GetName()},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := addFiles(resp); err != nil {
+			return nil, err
+		}
+	}
+	if len(fileMap) == 0 {
+		return nil, errors.New("no service found by reflection")
+	}
+
+	//下游可能只返回服务所在的文件，逐个补齐缺失的依赖
+	for pending := missingDependencies(fileMap); len(pending) > 0; pending = missingDependencies(fileMap) {
+		for _, name := range pending {
+			if fd, err := protoregistry.GlobalFiles.FindFileByPath(name); err == nil {
+				fileMap[name] = protodesc.ToFileDescriptorProto(fd)
+				continue
+			}
+			resp, err := call(&rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: name},
+			})
+			if err != nil {
+				return nil, err
+			}
+			if err := addFiles(resp); err != nil {
+				return nil, err
+			}
+			if _, ok := fileMap[name]; !ok {
+				return nil, fmt.Errorf("dependency %v not found by reflection", name)
+			}
+		}
+	}
+	fdSet := &descriptorpb.FileDescriptorSet{}
+	for _, fd := range fileMap {
+		fdSet.File = append(fdSet.File, fd)
+	}
+	return protodesc.NewFiles(fdSet)
+}
+
+func missingDependencies(fileMap map[string]*descriptorpb.FileDescriptorProto) []string {
+	missing := []string{}
+	for _, fd := range fileMap {
+		for _, dep := range fd.GetDependency() {
+			if _, ok := fileMap[dep]; !ok && !public.InStringSlice(missing, dep) {
+				missing = append(missing, dep)
+			}
+		}
+	}
+	return missing
+}
@@ -0,0 +1,662 @@
+package reverse_proxy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-gateway/reverse_proxy/load_balance"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// google.api.http 方法扩展的字段号，及 google.api.HttpRule 中的字段号；
+// 网关不依赖 googleapis 的生成代码，直接从方法选项的原始字节中解析
+const (
+	googleApiHttpField = 72295728
+
+	httpRuleGet                = 2
+	httpRulePut                = 3
+	httpRulePost               = 4
+	httpRuleDelete             = 5
+	httpRulePatch              = 6
+	httpRuleBody               = 7
+	httpRuleCustom             = 8
+	httpRuleAdditionalBindings = 11
+	httpRuleResponseBody       = 12
+
+	customPatternKind = 1
+	customPatternPath = 2
+)
+
+// DefaultGrpcTranscodeMaxBody 请求体大小上限，与 grpc 默认的最大接收消息一致
+const DefaultGrpcTranscodeMaxBody = 4 << 20
+
+// grpcMetadataHeaderPrefix 该前缀的请求头去掉前缀后作为 grpc metadata 转发，响应 metadata 以同样前缀返回
+const (
+	grpcMetadataHeaderPrefix = "Grpc-Metadata-"
+	grpcTrailerHeaderPrefix  = "Grpc-Trailer-"
+)
+
+// grpcHttpBinding google.api.http 中的一条 http 映射
+type grpcHttpBinding struct {
+	httpMethod   string //custom 映射的 kind 为 * 时匹配全部方法
+	pattern      string
+	body         string
+	responseBody string
+}
+
+// grpcTranscodeRoute 编译后的路径模板，捕获组按顺序对应 fieldPaths
+type grpcTranscodeRoute struct {
+	grpcHttpBinding
+	regexp     *regexp.Regexp
+	fieldPaths []string
+	literalLen int
+	fullMethod string
+	method     protoreflect.MethodDescriptor
+}
+
+// GrpcTranscoder 将 json http 请求按 google.api.http 映射转换为 grpc 一元调用，
+// 未声明映射的方法可通过 POST /包名.服务名/方法名 调用，请求体为完整的请求消息
+type GrpcTranscoder struct {
+	routes  []*grpcTranscodeRoute
+	lb      load_balance.LoadBalance
+	pool    *GrpcConnPool
+	Timeout time.Duration //单次调用超时，0 为不限制
+	MaxBody int64         //请求体大小上限，超出时返回 413
+}
+
+func NewGrpcTranscoder(files *protoregistry.Files, lb load_balance.LoadBalance, pool *GrpcConnPool) (*GrpcTranscoder, error) {
+	t := &GrpcTranscoder{lb: lb, pool: pool, MaxBody: DefaultGrpcTranscodeMaxBody}
+	var err error
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		services := fd.Services()
+		for i := 0; i < services.Len(); i++ {
+			methods := services.Get(i).Methods()
+			for j := 0; j < methods.Len(); j++ {
+				if err = t.addMethod(methods.Get(j)); err != nil {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(t.routes) == 0 {
+		return nil, errors.New("no unary grpc method in descriptor")
+	}
+	//字面量越长的模板越具体，优先匹配
+	sort.SliceStable(t.routes, func(i, j int) bool {
+		if t.routes[i].literalLen != t.routes[j].literalLen {
+			return t.routes[i].literalLen > t.routes[j].literalLen
+		}
+		return len(t.routes[i].fieldPaths) < len(t.routes[j].fieldPaths)
+	})
+	return t, nil
+}
+
+// addMethod 只转换一元方法，流式方法需使用 grpc 或 grpc-web 客户端
+func (t *GrpcTranscoder) addMethod(method protoreflect.MethodDescriptor) error {
+	if method.IsStreamingClient() || method.IsStreamingServer() {
+		return nil
+	}
+	fullMethod := fmt.Sprintf("/%s/%s", method.Parent().FullName(), method.Name())
+	bindings := append(grpcHttpBindings(method), grpcHttpBinding{
+		httpMethod: http.MethodPost,
+		pattern:    fullMethod,
+		body:       "*",
+	})
+	for _, binding := range bindings {
+		route, err := compileGrpcTranscodeRoute(binding)
+		if err != nil {
+			return fmt.Errorf("%v http pattern %v: %v", fullMethod, binding.pattern, err)
+		}
+		route.fullMethod = fullMethod
+		route.method = method
+		t.routes = append(t.routes, route)
+	}
+	return nil
+}
+
+// grpcHttpBindings 解析方法选项中的 google.api.http，包含 additional_bindings
+func grpcHttpBindings(method protoreflect.MethodDescriptor) []grpcHttpBinding {
+	opts, ok := method.Options().(proto.Message)
+	if !ok || opts == nil {
+		return nil
+	}
+	data, err := proto.Marshal(opts)
+	if err != nil {
+		return nil
+	}
+	bindings := []grpcHttpBinding{}
+	rangeProtoBytesField(data, func(num protowire.Number, value []byte) {
+		if num == googleApiHttpField {
+			bindings = append(bindings, parseGrpcHttpRule(value)...)
+		}
+	})
+	return bindings
+}
+
+func parseGrpcHttpRule(data []byte) []grpcHttpBinding {
+	binding := grpcHttpBinding{}
+	additional := []grpcHttpBinding{}
+	rangeProtoBytesField(data, func(num protowire.Number, value []byte) {
+		switch num {
+		case httpRuleGet:
+			binding.httpMethod, binding.pattern = http.MethodGet, string(value)
+		case httpRulePut:
+			binding.httpMethod, binding.pattern = http.MethodPut, string(value)
+		case httpRulePost:
+			binding.httpMethod, binding.pattern = http.MethodPost, string(value)
+		case httpRuleDelete:
+			binding.httpMethod, binding.pattern = http.MethodDelete, string(value)
+		case httpRulePatch:
+			binding.httpMethod, binding.pattern = http.MethodPatch, string(value)
+		case httpRuleCustom:
+			rangeProtoBytesField(value, func(num protowire.Number, value []byte) {
+				switch num {
+				case customPatternKind:
+					binding.httpMethod = strings.ToUpper(string(value))
+				case customPatternPath:
+					binding.pattern = string(value)
+				}
+			})
+		case httpRuleBody:
+			binding.body = string(value)
+		case httpRuleResponseBody:
+			binding.responseBody = string(value)
+		case httpRuleAdditionalBindings:
+			additional = append(additional, parseGrpcHttpRule(value)...)
+		}
+	})
+	if binding.pattern == "" {
+		return additional
+	}
+	return append([]grpcHttpBinding{binding}, additional...)
+}
+
+// rangeProtoBytesField 遍历消息中 length-delimited 类型的字段，其余字段跳过
+func rangeProtoBytesField(data []byte, f func(num protowire.Number, value []byte)) {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return
+		}
+		data = data[n:]
+		if typ == protowire.BytesType {
+			value, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return
+			}
+			f(num, value)
+			data = data[n:]
+			continue
+		}
+		if n = protowire.ConsumeFieldValue(num, typ, data); n < 0 {
+			return
+		}
+		data = data[n:]
+	}
+}
+
+// compileGrpcTranscodeRoute 路径模板转为正则：* 匹配单段，** 匹配多段，{field=子模板} 为捕获组，:verb 为字面量后缀
+func compileGrpcTranscodeRoute(binding grpcHttpBinding) (*grpcTranscodeRoute, error) {
+	pattern := binding.pattern
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, errors.New("pattern must start with /")
+	}
+	//大括号外最后一个 / 之后的 : 为 verb
+	verb := ""
+	depth, verbIndex := 0, -1
+	for i, ch := range pattern {
+		switch {
+		case ch == '{':
+			depth++
+		case ch == '}':
+			if depth--; depth < 0 {
+				return nil, errors.New("unbalanced braces")
+			}
+		case ch == '/' && depth == 0:
+			verbIndex = -1
+		case ch == ':' && depth == 0 && verbIndex < 0:
+			verbIndex = i
+		}
+	}
+	if depth != 0 {
+		return nil, errors.New("unbalanced braces")
+	}
+	if verbIndex > 0 {
+		verb, pattern = pattern[verbIndex:], pattern[:verbIndex]
+	}
+
+	route := &grpcTranscodeRoute{grpcHttpBinding: binding, literalLen: len(verb)}
+	expr := &strings.Builder{}
+	expr.WriteString("^")
+	for _, segment := range splitGrpcTemplate(pattern[1:]) {
+		expr.WriteString("/")
+		if !strings.HasPrefix(segment, "{") {
+			sub, literalLen := compileGrpcSegment(segment)
+			expr.WriteString(sub)
+			route.literalLen += literalLen
+			continue
+		}
+		if !strings.HasSuffix(segment, "}") {
+			return nil, fmt.Errorf("invalid segment %v", segment)
+		}
+		fieldPath, subPattern := segment[1:len(segment)-1], "*"
+		if index := strings.Index(fieldPath, "="); index >= 0 {
+			fieldPath, subPattern = fieldPath[:index], fieldPath[index+1:]
+		}
+		if fieldPath == "" || subPattern == "" {
+			return nil, fmt.Errorf("invalid variable %v", segment)
+		}
+		subList := []string{}
+		for _, sub := range strings.Split(subPattern, "/") {
+			subExpr, literalLen := compileGrpcSegment(sub)
+			subList = append(subList, subExpr)
+			route.literalLen += literalLen
+		}
+		expr.WriteString("(" + strings.Join(subList, "/") + ")")
+		route.fieldPaths = append(route.fieldPaths, fieldPath)
+	}
+	expr.WriteString(regexp.QuoteMeta(verb) + "$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+	route.regexp = re
+	return route, nil
+}
+
+// splitGrpcTemplate 按大括号外的 / 切分路径段
+func splitGrpcTemplate(pattern string) []string {
+	segments := []string{}
+	depth, start := 0, 0
+	for i, ch := range pattern {
+		switch {
+		case ch == '{':
+			depth++
+		case ch == '}':
+			depth--
+		case ch == '/' && depth == 0:
+			segments = append(segments, pattern[start:i])
+			start = i + 1
+		}
+	}
+	return append(segments, pattern[start:])
+}
+
+func compileGrpcSegment(segment string) (string, int) {
+	switch segment {
+	case "*":
+		return "[^/]+", 0
+	case "**":
+		return ".*", 0
+	}
+	return regexp.QuoteMeta(segment), len(segment)
+}
+
+func (t *GrpcTranscoder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	route, params, err := t.match(req)
+	if err != nil {
+		writeGrpcTranscodeError(w, err)
+		return
+	}
+	if t.MaxBody > 0 {
+		req.Body = http.MaxBytesReader(w, req.Body, t.MaxBody)
+	}
+	reqMsg, err := newGrpcTranscodeRequest(route, params, req)
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		writeGrpcTranscodeError(w, errGrpcTranscodeBodyTooLarge)
+		return
+	}
+	if err != nil {
+		writeGrpcTranscodeError(w, status.Error(codes.InvalidArgument, err.Error()))
+		return
+	}
+	respMsg := dynamicpb.NewMessage(route.method.Output())
+	var header, trailer metadata.MD
+	if err := t.invoke(req, route.fullMethod, reqMsg, respMsg, grpc.Header(&header), grpc.Trailer(&trailer)); err != nil {
+		writeGrpcMetadataHeader(w, grpcMetadataHeaderPrefix, header)
+		writeGrpcMetadataHeader(w, grpcTrailerHeaderPrefix, trailer)
+		writeGrpcTranscodeError(w, err)
+		return
+	}
+	body, err := marshalGrpcTranscodeResponse(route, respMsg)
+	if err != nil {
+		writeGrpcTranscodeError(w, status.Error(codes.Internal, err.Error()))
+		return
+	}
+	writeGrpcMetadataHeader(w, grpcMetadataHeaderPrefix, header)
+	writeGrpcMetadataHeader(w, grpcTrailerHeaderPrefix, trailer)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// match 路径匹配但 http 方法不匹配时返回 405
+func (t *GrpcTranscoder) match(req *http.Request) (*grpcTranscodeRoute, map[string]string, error) {
+	path := req.URL.EscapedPath()
+	pathMatched := false
+	for _, route := range t.routes {
+		matches := route.regexp.FindStringSubmatch(path)
+		if matches == nil {
+			continue
+		}
+		if route.httpMethod != "*" && route.httpMethod != req.Method {
+			pathMatched = true
+			continue
+		}
+		params := map[string]string{}
+		for i, fieldPath := range route.fieldPaths {
+			value, err := url.PathUnescape(matches[i+1])
+			if err != nil {
+				return nil, nil, status.Errorf(codes.InvalidArgument, "invalid path parameter %v", fieldPath)
+			}
+			params[fieldPath] = value
+		}
+		return route, params, nil
+	}
+	if pathMatched {
+		return nil, nil, errGrpcTranscodeMethodNotAllowed
+	}
+	return nil, nil, status.Errorf(codes.NotFound, "no grpc method for %v %v", req.Method, path)
+}
+
+var (
+	errGrpcTranscodeMethodNotAllowed = status.Error(codes.Unimplemented, "method not allowed")
+	errGrpcTranscodeBodyTooLarge     = status.Error(codes.InvalidArgument, "request body too large")
+)
+
+// newGrpcTranscodeRequest 依次填充请求体、查询参数、路径参数，后者覆盖前者；
+// 请求体映射为 * 时不解析查询参数，未知字段忽略
+func newGrpcTranscodeRequest(route *grpcTranscodeRoute, params map[string]string, req *http.Request) (*dynamicpb.Message, error) {
+	msg := dynamicpb.NewMessage(route.method.Input())
+	if route.body != "" {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(strings.TrimSpace(string(body))) > 0 {
+			if route.body != "*" {
+				fd := findGrpcField(msg.Descriptor(), route.body)
+				if fd == nil {
+					return nil, fmt.Errorf("body field %v not found", route.body)
+				}
+				body = append([]byte(`{"`+fd.JSONName()+`":`), append(body, '}')...)
+			}
+			if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, msg); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if route.body != "*" {
+		for key, values := range req.URL.Query() {
+			if _, ok := params[key]; ok || len(values) == 0 {
+				continue
+			}
+			if err := setGrpcFieldValue(msg, key, values); err != nil && err != errGrpcFieldNotFound {
+				return nil, fmt.Errorf("query parameter %v: %v", key, err)
+			}
+		}
+	}
+	for fieldPath, value := range params {
+		if err := setGrpcFieldValue(msg, fieldPath, []string{value}); err != nil {
+			return nil, fmt.Errorf("path parameter %v: %v", fieldPath, err)
+		}
+	}
+	return msg, nil
+}
+
+var errGrpcFieldNotFound = errors.New("field not found")
+
+func findGrpcField(desc protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if fd := desc.Fields().ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return desc.Fields().ByJSONName(name)
+}
+
+// setGrpcFieldValue 按 a.b.c 形式的字段路径设置值，重复字段追加全部取值
+func setGrpcFieldValue(msg protoreflect.Message, fieldPath string, values []string) error {
+	names := strings.Split(fieldPath, ".")
+	for i, name := range names {
+		fd := findGrpcField(msg.Descriptor(), name)
+		if fd == nil {
+			return errGrpcFieldNotFound
+		}
+		if i < len(names)-1 {
+			if fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+				return fmt.Errorf("%v is not a message field", name)
+			}
+			msg = msg.Mutable(fd).Message()
+			continue
+		}
+		if fd.IsMap() {
+			return fmt.Errorf("map field %v not supported", name)
+		}
+		if fd.IsList() {
+			list := msg.Mutable(fd).List()
+			for _, value := range values {
+				v, err := parseGrpcFieldValue(fd, list.NewElement, value)
+				if err != nil {
+					return err
+				}
+				list.Append(v)
+			}
+			return nil
+		}
+		newField := func() protoreflect.Value { return msg.NewField(fd) }
+		v, err := parseGrpcFieldValue(fd, newField, values[len(values)-1])
+		if err != nil {
+			return err
+		}
+		msg.Set(fd, v)
+	}
+	return nil
+}
+
+// parseGrpcFieldValue 字符串转为字段值，消息类型(如 Timestamp、包装类型)按 json 解析
+func parseGrpcFieldValue(fd protoreflect.FieldDescriptor, newValue func() protoreflect.Value,
+	value string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(value)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(value, 10, 64)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(value, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(value, 10, 64)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(value, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(value, 64)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.BytesKind:
+		v, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			v, err = base64.URLEncoding.DecodeString(value)
+		}
+		return protoreflect.ValueOfBytes(v), err
+	case protoreflect.EnumKind:
+		if enumValue := fd.Enum().Values().ByName(protoreflect.Name(value)); enumValue != nil {
+			return protoreflect.ValueOfEnum(enumValue.Number()), nil
+		}
+		v, err := strconv.ParseInt(value, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), err
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		v := newValue()
+		if err := protojson.Unmarshal([]byte(value), v.Message().Interface()); err == nil {
+			return v, nil
+		}
+		quoted, _ := json.Marshal(value)
+		err := protojson.Unmarshal(quoted, v.Message().Interface())
+		return v, err
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field kind %v", fd.Kind())
+}
+
+// invoke 通过负载均衡选择下游节点发起调用，下游不可用、超时等错误计入节点异常
+func (t *GrpcTranscoder) invoke(req *http.Request, fullMethod string, reqMsg, respMsg proto.Message, opts ...grpc.CallOption) error {
+	addr, err := t.lb.Get(clientIP(req))
+	if err != nil || addr == "" {
+		return status.Errorf(codes.Unavailable, "get next addr fail")
+	}
+	startTime := time.Now()
+	t.lb.OnRequestStart(addr)
+	conn, err := t.pool.Get(addr)
+	if err != nil {
+		err = status.Errorf(codes.Unavailable, "dial %v fail: %v", addr, err)
+		t.lb.OnRequestFinish(addr, time.Since(startTime), err)
+		return err
+	}
+	ctx := req.Context()
+	if t.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.Timeout)
+		defer cancel()
+	}
+	ctx = metadata.NewOutgoingContext(ctx, grpcTranscodeMetadata(req))
+	err = conn.Invoke(ctx, fullMethod, reqMsg, respMsg, opts...)
+	t.lb.OnRequestFinish(addr, time.Since(startTime), grpcBackendErr(err))
+	return err
+}
+
+// grpcTranscodeMetadata 转发 Authorization 及 Grpc-Metadata- 前缀的请求头，并追加 x-forwarded-for
+func grpcTranscodeMetadata(req *http.Request) metadata.MD {
+	md := metadata.MD{}
+	for key, values := range req.Header {
+		switch {
+		case key == "Authorization":
+			md.Append("authorization", values...)
+		case strings.HasPrefix(key, grpcMetadataHeaderPrefix) && len(key) > len(grpcMetadataHeaderPrefix):
+			md.Append(strings.ToLower(key[len(grpcMetadataHeaderPrefix):]), values...)
+		}
+	}
+	forwardedFor := clientIP(req)
+	if prior := req.Header.Get("X-Forwarded-For"); prior != "" {
+		forwardedFor = prior + ", " + forwardedFor
+	}
+	md.Set("x-forwarded-for", forwardedFor)
+	if req.Host != "" {
+		md.Set("x-forwarded-host", req.Host)
+	}
+	return md
+}
+
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// writeGrpcMetadataHeader 二进制 metadata(-bin 后缀)按 base64 编码返回
+func writeGrpcMetadataHeader(w http.ResponseWriter, prefix string, md metadata.MD) {
+	for key, values := range md {
+		for _, value := range values {
+			if strings.HasSuffix(key, "-bin") {
+				value = base64.StdEncoding.EncodeToString([]byte(value))
+			}
+			w.Header().Add(prefix+key, value)
+		}
+	}
+}
+
+// marshalGrpcTranscodeResponse 零值字段同样返回；设置了 response_body 时只返回该字段
+func marshalGrpcTranscodeResponse(route *grpcTranscodeRoute, respMsg *dynamicpb.Message) ([]byte, error) {
+	body, err := (protojson.MarshalOptions{EmitUnpopulated: true}).Marshal(respMsg)
+	if err != nil || route.responseBody == "" {
+		return body, err
+	}
+	fd := findGrpcField(respMsg.Descriptor(), route.responseBody)
+	if fd == nil {
+		return nil, fmt.Errorf("response body field %v not found", route.responseBody)
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+	return fields[fd.JSONName()], nil
+}
+
+// writeGrpcTranscodeError grpc 状态码转为 http 状态码，响应体为 {"code":grpc状态码,"message":错误信息}
+func writeGrpcTranscodeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	httpStatus := HTTPStatusFromGrpcCode(st.Code())
+	switch err {
+	case errGrpcTranscodeMethodNotAllowed:
+		httpStatus = http.StatusMethodNotAllowed
+	case errGrpcTranscodeBodyTooLarge:
+		httpStatus = http.StatusRequestEntityTooLarge
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"code":    st.Code(),
+		"message": st.Message(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus)
+	w.Write(body)
+}
+
+// HTTPStatusFromGrpcCode grpc 状态码与 http 状态码的对应关系，与 google.rpc.Code 的说明一致
+func HTTPStatusFromGrpcCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,247 @@
+package reverse_proxy
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompileGrpcTranscodeRoute(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		params  map[string]string //nil 为不匹配
+	}{
+		{"/v1/users/{id}", "/v1/users/42", map[string]string{"id": "42"}},
+		{"/v1/users/{id}", "/v1/users/42/books", nil},
+		{"/v1/users/{id}", "/v1/users/", nil},
+		{"/v1/{name=projects/*/books/*}", "/v1/projects/p1/books/b2", map[string]string{"name": "projects/p1/books/b2"}},
+		{"/v1/{name=projects/*/books/*}", "/v1/projects/p1/shelves/b2", nil},
+		{"/v1/files/{path=**}", "/v1/files/a/b/c.txt", map[string]string{"path": "a/b/c.txt"}},
+		{"/v1/*/items/{item.id}", "/v1/shop/items/7", map[string]string{"item.id": "7"}},
+		{"/v1/users/{id}:cancel", "/v1/users/1:cancel", map[string]string{"id": "1"}},
+		{"/v1/users/{id}:cancel", "/v1/users/1", nil},
+		{"/v1/users/{id}:cancel", "/v1/users/1:undo", nil},
+		{"/v1/a.b+c", "/v1/a.b+c", map[string]string{}},
+		{"/v1/a.b+c", "/v1/aXb+c", nil},
+	}
+	for _, test := range tests {
+		route, err := compileGrpcTranscodeRoute(grpcHttpBinding{httpMethod: http.MethodGet, pattern: test.pattern})
+		if err != nil {
+			t.Fatalf("compile %v: %v", test.pattern, err)
+		}
+		matches := route.regexp.FindStringSubmatch(test.path)
+		if (matches != nil) != (test.params != nil) {
+			t.Errorf("%v match %v = %v, want %v", test.pattern, test.path, matches != nil, test.params != nil)
+			continue
+		}
+		if matches == nil {
+			continue
+		}
+		for i, fieldPath := range route.fieldPaths {
+			if got := matches[i+1]; got != test.params[fieldPath] {
+				t.Errorf("%v match %v: %v = %q, want %q", test.pattern, test.path, fieldPath, got, test.params[fieldPath])
+			}
+		}
+	}
+}
+
+func TestCompileGrpcTranscodeRouteInvalid(t *testing.T) {
+	for _, pattern := range []string{"v1/users", "/v1/{id", "/v1/id}/{", "/v1/{=*}", "/v1/{id=}", "/v1/{id}x"} {
+		if _, err := compileGrpcTranscodeRoute(grpcHttpBinding{pattern: pattern}); err == nil {
+			t.Errorf("compile %v: want error", pattern)
+		}
+	}
+}
+
+func TestGrpcTranscodeRouteLiteralLen(t *testing.T) {
+	//字面量越长越具体：/v1/users/me 优先于 /v1/users/{id}
+	specific, _ := compileGrpcTranscodeRoute(grpcHttpBinding{pattern: "/v1/users/me"})
+	general, _ := compileGrpcTranscodeRoute(grpcHttpBinding{pattern: "/v1/users/{id}"})
+	verb, _ := compileGrpcTranscodeRoute(grpcHttpBinding{pattern: "/v1/users/{id}:cancel"})
+	if specific.literalLen <= general.literalLen || verb.literalLen <= general.literalLen {
+		t.Errorf("literalLen specific=%d verb=%d general=%d", specific.literalLen, verb.literalLen, general.literalLen)
+	}
+}
+
+// newTestGrpcTranscodeFiles 测试用的描述：test.v1.User 及一元方法 test.v1.UserService/Create
+func newTestGrpcTranscodeFiles(t *testing.T) *protoregistry.Files {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string,
+		repeated bool) *descriptorpb.FieldDescriptorProto {
+		label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		if repeated {
+			label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		}
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(strings.ReplaceAll(name, "_id", "Id")),
+			Number:   proto.Int32(number),
+			Type:     typ.Enum(),
+			Label:    label.Enum(),
+		}
+		if typeName != "" {
+			fd.TypeName = proto.String(typeName)
+		}
+		return fd
+	}
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/v1/user.proto"),
+		Package:    proto.String("test.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Kind"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("KIND_UNKNOWN"), Number: proto.Int32(0)},
+				{Name: proto.String("KIND_ADMIN"), Number: proto.Int32(2)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name:  proto.String("Profile"),
+				Field: []*descriptorpb.FieldDescriptorProto{field("level", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, "", false)},
+			},
+			{
+				Name: proto.String("User"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("user_id", 1, descriptorpb.FieldDescriptorProto_TYPE_UINT32, "", false),
+					field("name", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+					field("active", 3, descriptorpb.FieldDescriptorProto_TYPE_BOOL, "", false),
+					field("score", 4, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, "", false),
+					field("tags", 5, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", true),
+					field("kind", 6, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".test.v1.Kind", false),
+					field("profile", 7, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.v1.Profile", false),
+					field("avatar", 8, descriptorpb.FieldDescriptorProto_TYPE_BYTES, "", false),
+					field("created", 9, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp", false),
+					field("profiles", 10, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.v1.Profile", true),
+				},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("UserService"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Create"),
+				InputType:  proto.String(".test.v1.User"),
+				OutputType: proto.String(".test.v1.User"),
+			}},
+		}},
+	}
+	files, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(timestamppb.File_google_protobuf_timestamp_proto), file},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func newTestGrpcUser(t *testing.T) *dynamicpb.Message {
+	desc, err := newTestGrpcTranscodeFiles(t).FindDescriptorByName("test.v1.User")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dynamicpb.NewMessage(desc.(protoreflect.MessageDescriptor))
+}
+
+func TestSetGrpcFieldValue(t *testing.T) {
+	msg := newTestGrpcUser(t)
+	sets := []struct {
+		fieldPath string
+		values    []string
+	}{
+		{"user_id", []string{"7"}},
+		{"name", []string{"first", "last"}}, //非重复字段取最后一个值
+		{"active", []string{"true"}},
+		{"score", []string{"1.5"}},
+		{"tags", []string{"a", "b"}},
+		{"kind", []string{"KIND_ADMIN"}},
+		{"profile.level", []string{"3"}},
+		{"avatar", []string{"aGk="}},
+		{"created", []string{"2024-01-02T03:04:05Z"}},
+	}
+	for _, set := range sets {
+		if err := setGrpcFieldValue(msg, set.fieldPath, set.values); err != nil {
+			t.Fatalf("set %v: %v", set.fieldPath, err)
+		}
+	}
+	//按 json 名称同样可以设置
+	if err := setGrpcFieldValue(msg, "userId", []string{"8"}); err != nil {
+		t.Fatalf("set userId: %v", err)
+	}
+
+	fields := msg.Descriptor().Fields()
+	get := func(name string) protoreflect.Value { return msg.Get(fields.ByName(protoreflect.Name(name))) }
+	if got := get("user_id").Uint(); got != 8 {
+		t.Errorf("user_id = %v, want 8", got)
+	}
+	if got := get("name").String(); got != "last" {
+		t.Errorf("name = %v, want last", got)
+	}
+	if !get("active").Bool() || get("score").Float() != 1.5 {
+		t.Errorf("active = %v, score = %v", get("active"), get("score"))
+	}
+	if tags := get("tags").List(); tags.Len() != 2 || tags.Get(0).String() != "a" || tags.Get(1).String() != "b" {
+		t.Errorf("tags = %v, want [a b]", tags)
+	}
+	if got := get("kind").Enum(); got != 2 {
+		t.Errorf("kind = %v, want 2", got)
+	}
+	profile := get("profile").Message()
+	if got := profile.Get(profile.Descriptor().Fields().ByName("level")).Int(); got != 3 {
+		t.Errorf("profile.level = %v, want 3", got)
+	}
+	if got := string(get("avatar").Bytes()); got != "hi" {
+		t.Errorf("avatar = %q, want hi", got)
+	}
+	created := get("created").Message()
+	if got := created.Get(created.Descriptor().Fields().ByName("seconds")).Int(); got != 1704164645 {
+		t.Errorf("created.seconds = %v, want 1704164645", got)
+	}
+}
+
+func TestSetGrpcFieldValueInvalid(t *testing.T) {
+	tests := []struct {
+		fieldPath string
+		value     string
+	}{
+		{"user_id", "-1"},
+		{"user_id", "abc"},
+		{"active", "yes!"},
+		{"kind", "KIND_OTHER"},
+		{"avatar", "%%%"},
+		{"name.first", "x"},     //非消息字段
+		{"profiles.level", "1"}, //重复消息字段不能作为中间路径
+	}
+	for _, test := range tests {
+		if err := setGrpcFieldValue(newTestGrpcUser(t), test.fieldPath, []string{test.value}); err == nil {
+			t.Errorf("set %v=%v: want error", test.fieldPath, test.value)
+		}
+	}
+	if err := setGrpcFieldValue(newTestGrpcUser(t), "profile.missing", []string{"1"}); err != errGrpcFieldNotFound {
+		t.Errorf("set profile.missing err = %v, want %v", err, errGrpcFieldNotFound)
+	}
+}
+
+func TestGrpcTranscodeBodyTooLarge(t *testing.T) {
+	transcoder, err := NewGrpcTranscoder(newTestGrpcTranscodeFiles(t), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transcoder.MaxBody = 16
+	req := httptest.NewRequest(http.MethodPost, "/test.v1.UserService/Create",
+		strings.NewReader(`{"name":"`+strings.Repeat("x", 32)+`"}`))
+	rec := httptest.NewRecorder()
+	//超出上限时在选择下游节点之前返回
+	transcoder.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("code = %v, want %v, body %v", rec.Code, http.StatusRequestEntityTooLarge, rec.Body.String())
+	}
+}
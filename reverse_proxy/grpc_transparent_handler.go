@@ -0,0 +1,144 @@
+package reverse_proxy
+
+import (
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"io"
+)
+
+// GrpcStreamDirector 为每个 stream 选择下游连接，返回的 ctx 必须继承自 serverStream.Context()
+type GrpcStreamDirector func(ctx context.Context, fullMethodName string) (context.Context, *grpc.ClientConn, error)
+
+var clientStreamDescForProxying = &grpc.StreamDesc{
+	ServerStreams: true,
+	ClientStreams: true,
+}
+
+// grpcFrame 透传的原始消息体，代理不解析业务 proto
+type grpcFrame struct {
+	payload []byte
+}
+
+// GrpcRawCodec 对 grpcFrame 直接透传字节，其余消息按 proto 编解码
+type GrpcRawCodec struct{}
+
+// GrpcCodec 代理服务端与下游连接都需使用该编解码器
+func GrpcCodec() *GrpcRawCodec {
+	return &GrpcRawCodec{}
+}
+
+func (c *GrpcRawCodec) Marshal(v interface{}) ([]byte, error) {
+	if out, ok := v.(*grpcFrame); ok {
+		return out.payload, nil
+	}
+	return proto.Marshal(v.(proto.Message))
+}
+
+func (c *GrpcRawCodec) Unmarshal(data []byte, v interface{}) error {
+	if dst, ok := v.(*grpcFrame); ok {
+		dst.payload = data
+		return nil
+	}
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+func (c *GrpcRawCodec) Name() string {
+	return "proto"
+}
+
+func (c *GrpcRawCodec) String() string {
+	return "proxy>proto"
+}
+
+// GrpcTransparentHandler 透明代理所有未注册的方法，用于 grpc.UnknownServiceHandler。
+// 与 grpc-proxy 的实现不同，stream 结束后不关闭下游连接，连接由 director 复用管理
+func GrpcTransparentHandler(director GrpcStreamDirector) grpc.StreamHandler {
+	return func(srv interface{}, serverStream grpc.ServerStream) error {
+		fullMethodName, ok := grpc.MethodFromServerStream(serverStream)
+		if !ok {
+			return status.Errorf(codes.Internal, "lowLevelServerStream not exists in context")
+		}
+		outgoingCtx, backendConn, err := director(serverStream.Context(), fullMethodName)
+		if err != nil {
+			return err
+		}
+
+		clientCtx, clientCancel := context.WithCancel(outgoingCtx)
+		defer clientCancel()
+		clientStream, err := grpc.NewClientStream(clientCtx, clientStreamDescForProxying, backendConn, fullMethodName)
+		if err != nil {
+			return err
+		}
+		s2cErrChan := forwardServerToClient(serverStream, clientStream)
+		c2sErrChan := forwardClientToServer(clientStream, serverStream)
+		for i := 0; i < 2; i++ {
+			select {
+			case s2cErr := <-s2cErrChan:
+				if s2cErr == io.EOF {
+					//客户端发送完毕，下游仍可能继续返回数据
+					clientStream.CloseSend()
+					break
+				}
+				return status.Errorf(codes.Internal, "failed proxying s2c: %v", s2cErr)
+			case c2sErr := <-c2sErrChan:
+				serverStream.SetTrailer(clientStream.Trailer())
+				if c2sErr != io.EOF {
+					return c2sErr
+				}
+				return nil
+			}
+		}
+		return status.Errorf(codes.Internal, "gRPC proxying should never reach this stage.")
+	}
+}
+
+func forwardClientToServer(src grpc.ClientStream, dst grpc.ServerStream) chan error {
+	ret := make(chan error, 1)
+	go func() {
+		f := &grpcFrame{}
+		for i := 0; ; i++ {
+			if i == 0 {
+				//下游 header 需在首个消息写回前发送
+				md, err := src.Header()
+				if err != nil {
+					ret <- err
+					break
+				}
+				if err := dst.SendHeader(md); err != nil {
+					ret <- err
+					break
+				}
+			}
+			if err := src.RecvMsg(f); err != nil {
+				ret <- err
+				break
+			}
+			if err := dst.SendMsg(f); err != nil {
+				ret <- err
+				break
+			}
+		}
+	}()
+	return ret
+}
+
+func forwardServerToClient(src grpc.ServerStream, dst grpc.ClientStream) chan error {
+	ret := make(chan error, 1)
+	go func() {
+		f := &grpcFrame{}
+		for {
+			if err := src.RecvMsg(f); err != nil {
+				ret <- err
+				break
+			}
+			if err := dst.SendMsg(f); err != nil {
+				ret <- err
+				break
+			}
+		}
+	}()
+	return ret
+}
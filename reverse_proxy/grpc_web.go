@@ -0,0 +1,261 @@
+package reverse_proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"go-gateway/public"
+	"google.golang.org/grpc"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	grpcWebContentType     = "application/grpc-web"
+	grpcWebTextContentType = "application/grpc-web-text"
+
+	// grpcWebTrailerFlag grpc-web 以该标志位的消息帧在响应体末尾返回 trailer
+	grpcWebTrailerFlag = 0x80
+)
+
+// IsGrpcWebRequest content-type 为 application/grpc-web 或 application/grpc-web-text 的请求
+func IsGrpcWebRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasPrefix(req.Header.Get("Content-Type"), grpcWebContentType)
+}
+
+// IsGrpcWebPreflight 浏览器发起 grpc-web 调用前的跨域预检请求
+func IsGrpcWebPreflight(req *http.Request) bool {
+	return req.Method == http.MethodOptions && req.Header.Get("Origin") != "" &&
+		req.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// GrpcWebHandler 将 grpc-web 请求(binary 及 text/base64)转换为原生 grpc 请求交给 grpc.Server 处理，
+// 原生 http2 grpc 请求直接交给 grpc.Server，服务端的拦截器对两种请求均生效
+type GrpcWebHandler struct {
+	server  *grpc.Server
+	origins []string //允许跨域的来源，* 为允许全部来源，为空时只允许同源请求
+	wg      sync.WaitGroup
+}
+
+func NewGrpcWebHandler(server *grpc.Server, origins []string) *GrpcWebHandler {
+	return &GrpcWebHandler{
+		server:  server,
+		origins: origins,
+	}
+}
+
+func (h *GrpcWebHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.wg.Add(1)
+	defer h.wg.Done()
+	switch {
+	case IsGrpcWebPreflight(r):
+		h.servePreflight(w, r)
+	case IsGrpcWebRequest(r):
+		h.serveGrpcWeb(w, r)
+	case r.ProtoMajor == 2 && public.IsGrpcRequest(r):
+		h.server.ServeHTTP(w, r)
+	default:
+		http.Error(w, "grpc or grpc-web request required", http.StatusUnsupportedMediaType)
+	}
+}
+
+// Wait 等待处理中的请求结束，grpc.Server 以 ServeHTTP 方式处理的请求不能通过 GracefulStop 排空
+func (h *GrpcWebHandler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// allowOrigin 来源与请求的域名及端口相同时视为同源，不需要配置
+func (h *GrpcWebHandler) allowOrigin(r *http.Request, origin string) bool {
+	if public.InStringSlice(h.origins, "*") || public.InStringSlice(h.origins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host != "" && strings.EqualFold(u.Host, r.Host)
+}
+
+func (h *GrpcWebHandler) servePreflight(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if !h.allowOrigin(r, origin) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	w.Header().Add("Vary", "Origin")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+		w.Header().Set("Access-Control-Allow-Headers", headers)
+	}
+	w.Header().Set("Access-Control-Max-Age", "86400")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *GrpcWebHandler) serveGrpcWeb(w http.ResponseWriter, r *http.Request) {
+	//不允许的来源直接拒绝，不能只依赖浏览器拦截响应，请求本身已被执行
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if !h.allowOrigin(r, origin) {
+			http.Error(w, "origin not allowed", http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Add("Vary", "Origin")
+	}
+	contentType := r.Header.Get("Content-Type")
+	text := strings.HasPrefix(contentType, grpcWebTextContentType)
+
+	//伪装为 http2 的原生 grpc 请求，grpc.Server 只接受 http2
+	req := r.Clone(r.Context())
+	req.ProtoMajor, req.ProtoMinor, req.Proto = 2, 0, "HTTP/2.0"
+	req.Header.Del("Content-Length")
+	req.ContentLength = -1
+	if text {
+		req.Header.Set("Content-Type", "application/grpc"+strings.TrimPrefix(contentType, grpcWebTextContentType))
+		req.Body = io.NopCloser(&grpcWebTextReader{src: bufio.NewReader(r.Body)})
+	} else {
+		req.Header.Set("Content-Type", "application/grpc"+strings.TrimPrefix(contentType, grpcWebContentType))
+	}
+
+	rw := &grpcWebResponseWriter{
+		w:           w,
+		header:      http.Header{},
+		text:        text,
+		contentType: contentType,
+	}
+	h.server.ServeHTTP(rw, req)
+	rw.finish()
+}
+
+// grpcWebTextReader 按 4 字节一组解码 base64 请求体，兼容客户端分段编码时中间出现的填充
+type grpcWebTextReader struct {
+	src     *bufio.Reader
+	pending []byte
+}
+
+func (r *grpcWebTextReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		quantum := make([]byte, 0, 4)
+		for len(quantum) < 4 {
+			b, err := r.src.ReadByte()
+			if err != nil {
+				if err == io.EOF && len(quantum) > 0 {
+					err = io.ErrUnexpectedEOF
+				}
+				return 0, err
+			}
+			if b == '\r' || b == '\n' {
+				continue
+			}
+			quantum = append(quantum, b)
+		}
+		decoded := make([]byte, 3)
+		n, err := base64.StdEncoding.Decode(decoded, quantum)
+		if err != nil {
+			return 0, err
+		}
+		r.pending = decoded[:n]
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// grpcWebResponseWriter 转换 grpc.Server 的响应：header 原样返回，trailer 编码为响应体末尾的消息帧，
+// text 模式下响应体按 base64 编码，每次 Flush 结束一段编码
+type grpcWebResponseWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	text        bool
+	contentType string
+	wroteHeader bool
+	encoder     io.WriteCloser
+}
+
+func (rw *grpcWebResponseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *grpcWebResponseWriter) WriteHeader(code int) {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+	exposeHeaders := []string{"grpc-status", "grpc-message", "grpc-status-details-bin"}
+	for k, vv := range rw.header {
+		if len(vv) == 0 || k == "Trailer" || strings.HasPrefix(k, http.TrailerPrefix) || k == "Content-Type" {
+			continue
+		}
+		rw.w.Header()[k] = vv
+		exposeHeaders = append(exposeHeaders, strings.ToLower(k))
+	}
+	rw.w.Header().Set("Content-Type", rw.contentType)
+	if rw.w.Header().Get("Access-Control-Allow-Origin") != "" {
+		rw.w.Header().Set("Access-Control-Expose-Headers", strings.Join(exposeHeaders, ","))
+	}
+	rw.w.WriteHeader(code)
+}
+
+func (rw *grpcWebResponseWriter) Write(b []byte) (int, error) {
+	rw.WriteHeader(http.StatusOK)
+	if !rw.text {
+		return rw.w.Write(b)
+	}
+	if rw.encoder == nil {
+		rw.encoder = base64.NewEncoder(base64.StdEncoding, rw.w)
+	}
+	return rw.encoder.Write(b)
+}
+
+func (rw *grpcWebResponseWriter) Flush() {
+	rw.WriteHeader(http.StatusOK)
+	if rw.encoder != nil {
+		rw.encoder.Close()
+		rw.encoder = nil
+	}
+	if flusher, ok := rw.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish 写入 trailer 帧：预先声明的 trailer 及 http.TrailerPrefix 前缀的 trailer，key 统一小写
+func (rw *grpcWebResponseWriter) finish() {
+	rw.WriteHeader(http.StatusOK)
+	trailer := http.Header{}
+	for _, declared := range rw.header["Trailer"] {
+		for _, k := range strings.Split(declared, ",") {
+			k = http.CanonicalHeaderKey(strings.TrimSpace(k))
+			if vv, ok := rw.header[k]; ok {
+				trailer[k] = vv
+			}
+		}
+	}
+	for k, vv := range rw.header {
+		if strings.HasPrefix(k, http.TrailerPrefix) {
+			trailer[strings.TrimPrefix(k, http.TrailerPrefix)] = vv
+		}
+	}
+	payload := &bytes.Buffer{}
+	for k, vv := range trailer {
+		for _, v := range vv {
+			payload.WriteString(strings.ToLower(k) + ": " + v + "\r\n")
+		}
+	}
+	frame := make([]byte, 5, 5+payload.Len())
+	frame[0] = grpcWebTrailerFlag
+	binary.BigEndian.PutUint32(frame[1:5], uint32(payload.Len()))
+	rw.Write(append(frame, payload.Bytes()...))
+	rw.Flush()
+}
@@ -0,0 +1,189 @@
+package reverse_proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/protobuf/proto"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestGrpcWebHandler(origins []string) *GrpcWebHandler {
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	return NewGrpcWebHandler(server, origins)
+}
+
+// grpcWebFrame 按 grpc 消息帧格式编码：1 字节标志位 + 4 字节长度 + 消息体
+func grpcWebFrame(flag byte, payload []byte) []byte {
+	frame := make([]byte, 5, 5+len(payload))
+	frame[0] = flag
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+func readGrpcWebFrames(t *testing.T, body []byte) map[byte][]byte {
+	frames := map[byte][]byte{}
+	for len(body) > 0 {
+		if len(body) < 5 {
+			t.Fatalf("truncated frame header %x", body)
+		}
+		size := binary.BigEndian.Uint32(body[1:5])
+		if uint32(len(body)-5) < size {
+			t.Fatalf("truncated frame body %x", body)
+		}
+		frames[body[0]] = body[5 : 5+size]
+		body = body[5+size:]
+	}
+	return frames
+}
+
+func newHealthCheckRequest(t *testing.T, contentType string, text bool) *http.Request {
+	payload, err := proto.Marshal(&healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := grpcWebFrame(0, payload)
+	if text {
+		body = []byte(base64.StdEncoding.EncodeToString(body))
+	}
+	req := httptest.NewRequest(http.MethodPost, "http://gateway.example.com/grpc.health.v1.Health/Check", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	return req
+}
+
+func checkHealthResponse(t *testing.T, name string, body []byte) {
+	frames := readGrpcWebFrames(t, body)
+	resp := &healthpb.HealthCheckResponse{}
+	if err := proto.Unmarshal(frames[0], resp); err != nil {
+		t.Fatalf("%v: unmarshal response: %v", name, err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("%v: status = %v, want SERVING", name, resp.Status)
+	}
+	trailer, ok := frames[grpcWebTrailerFlag]
+	if !ok || !strings.Contains(string(trailer), "grpc-status: 0\r\n") {
+		t.Errorf("%v: trailer frame = %q, want grpc-status: 0", name, trailer)
+	}
+}
+
+func TestGrpcWebBinaryFraming(t *testing.T) {
+	handler := newTestGrpcWebHandler(nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newHealthCheckRequest(t, "application/grpc-web+proto", false))
+	if got := rec.Header().Get("Content-Type"); got != "application/grpc-web+proto" {
+		t.Errorf("Content-Type = %v, want application/grpc-web+proto", got)
+	}
+	checkHealthResponse(t, "binary", rec.Body.Bytes())
+}
+
+func TestGrpcWebTextFraming(t *testing.T) {
+	handler := newTestGrpcWebHandler(nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, newHealthCheckRequest(t, "application/grpc-web-text", true))
+	if got := rec.Header().Get("Content-Type"); got != "application/grpc-web-text" {
+		t.Errorf("Content-Type = %v, want application/grpc-web-text", got)
+	}
+	//每次 Flush 结束一段编码，按 4 字节一组解码可跨越中间的填充
+	body, err := io.ReadAll(&grpcWebTextReader{src: bufio.NewReader(rec.Body)})
+	if err != nil {
+		t.Fatalf("decode %q: %v", rec.Body.String(), err)
+	}
+	checkHealthResponse(t, "text", body)
+}
+
+func TestGrpcWebTextReaderPadding(t *testing.T) {
+	//客户端分段编码时中间出现填充
+	src := base64.StdEncoding.EncodeToString([]byte("ab")) + "\r\n" + base64.StdEncoding.EncodeToString([]byte("cde"))
+	got, err := io.ReadAll(&grpcWebTextReader{src: bufio.NewReader(strings.NewReader(src))})
+	if err != nil || string(got) != "abcde" {
+		t.Errorf("decoded = %q, %v, want abcde", got, err)
+	}
+	_, err = io.ReadAll(&grpcWebTextReader{src: bufio.NewReader(strings.NewReader("YWJ"))})
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated err = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestGrpcWebTrailerEncoding(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rw := &grpcWebResponseWriter{w: rec, header: http.Header{}, contentType: "application/grpc-web"}
+	rw.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	rw.Header().Set("X-Request-Id", "1")
+	rw.WriteHeader(http.StatusOK)
+	rw.Header().Set("Grpc-Status", "5")
+	rw.Header().Set("Grpc-Message", "not found")
+	rw.Header().Set(http.TrailerPrefix+"X-Cost", "3ms")
+	rw.finish()
+
+	if rec.Header().Get("X-Request-Id") != "1" || rec.Header().Get("Trailer") != "" {
+		t.Errorf("header = %v, want X-Request-Id without Trailer", rec.Header())
+	}
+	trailer := string(readGrpcWebFrames(t, rec.Body.Bytes())[grpcWebTrailerFlag])
+	for _, want := range []string{"grpc-status: 5\r\n", "grpc-message: not found\r\n", "x-cost: 3ms\r\n"} {
+		if !strings.Contains(trailer, want) {
+			t.Errorf("trailer = %q, want %q", trailer, want)
+		}
+	}
+}
+
+func TestGrpcWebAllowOrigin(t *testing.T) {
+	tests := []struct {
+		origins []string
+		origin  string
+		want    bool
+	}{
+		{nil, "http://gateway.example.com", true},
+		{nil, "https://GATEWAY.example.com", true},
+		{nil, "http://evil.example.com", false},
+		{nil, "null", false},
+		{[]string{"http://app.example.com"}, "http://app.example.com", true},
+		{[]string{"http://app.example.com"}, "http://evil.example.com", false},
+		{[]string{"*"}, "http://evil.example.com", true},
+	}
+	for _, test := range tests {
+		handler := &GrpcWebHandler{origins: test.origins}
+		req := httptest.NewRequest(http.MethodPost, "http://gateway.example.com/svc/Method", nil)
+		if got := handler.allowOrigin(req, test.origin); got != test.want {
+			t.Errorf("origins %v allowOrigin(%v) = %v, want %v", test.origins, test.origin, got, test.want)
+		}
+	}
+}
+
+func TestGrpcWebRejectsCrossOrigin(t *testing.T) {
+	handler := newTestGrpcWebHandler(nil)
+
+	preflight := httptest.NewRequest(http.MethodOptions, "http://gateway.example.com/grpc.health.v1.Health/Check", nil)
+	preflight.Header.Set("Origin", "http://evil.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, preflight)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("preflight code = %v, want %v", rec.Code, http.StatusForbidden)
+	}
+
+	req := newHealthCheckRequest(t, "application/grpc-web+proto", false)
+	req.Header.Set("Origin", "http://evil.example.com")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("cross origin code = %v, header = %v, want %v", rec.Code, rec.Header(), http.StatusForbidden)
+	}
+
+	req = newHealthCheckRequest(t, "application/grpc-web+proto", false)
+	req.Header.Set("Origin", "http://gateway.example.com")
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "http://gateway.example.com" {
+		t.Errorf("same origin Access-Control-Allow-Origin = %v", got)
+	}
+	checkHealthResponse(t, "same origin", rec.Body.Bytes())
+}
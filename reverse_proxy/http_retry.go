@@ -0,0 +1,247 @@
+package reverse_proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go-gateway/public"
+	"go-gateway/reverse_proxy/load_balance"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	RetryOnConnectFailure = "connect-failure" //建立连接失败
+	RetryOnReset          = "reset"           //连接被重置、下游提前断开等
+	RetryOnTimeout        = "timeout"         //单次尝试超时
+	RetryOn5xx            = "5xx"             //下游返回任意 5xx
+
+	DefaultRetryOn = "connect-failure,reset,502,503,504"
+
+	//需要重放的请求体超过该大小时不重试
+	retryBodyMaxBytes = 1 << 20
+)
+
+// RetryPolicy http 服务失败后换节点重试的策略
+type RetryPolicy struct {
+	MaxRetry      int                 //最大重试次数，不含首次请求
+	NonIdempotent bool                //非幂等请求(POST/PATCH 等)是否重试
+	PerTryTimeout time.Duration       //单次尝试超时，0为不限制
+	Budget        *public.RetryBudget //重试预算，为空不限制
+	OnRetry       func()              //每次重试回调，用于统计
+
+	retryOn map[string]bool
+}
+
+// NewRetryPolicy retryOn 为逗号分隔的重试条件，如 connect-failure,timeout,5xx,429；为空使用 DefaultRetryOn
+func NewRetryPolicy(maxRetry int, retryOn string, nonIdempotent bool, perTryTimeout time.Duration) (*RetryPolicy, error) {
+	if retryOn == "" {
+		retryOn = DefaultRetryOn
+	}
+	conds, err := ParseRetryOn(retryOn)
+	if err != nil {
+		return nil, err
+	}
+	return &RetryPolicy{
+		MaxRetry:      maxRetry,
+		NonIdempotent: nonIdempotent,
+		PerTryTimeout: perTryTimeout,
+		retryOn:       conds,
+	}, nil
+}
+
+// ParseRetryOn 解析重试条件
+func ParseRetryOn(retryOn string) (map[string]bool, error) {
+	conds := map[string]bool{}
+	for _, item := range strings.Split(retryOn, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		switch item {
+		case "":
+			continue
+		case RetryOnConnectFailure, RetryOnReset, RetryOnTimeout, RetryOn5xx:
+		default:
+			code, err := strconv.Atoi(item)
+			if err != nil || code < 100 || code > 599 {
+				return nil, fmt.Errorf("invalid retry condition %q", item)
+			}
+		}
+		conds[item] = true
+	}
+	return conds, nil
+}
+
+// methodRetryable 默认只重试幂等请求，带 Idempotency-Key 的请求也视为幂等；
+// grpc 请求体为流式数据，不缓存也不重试
+func (p *RetryPolicy) methodRetryable(req *http.Request) bool {
+	if public.IsGrpcRequest(req) {
+		return false
+	}
+	if p.NonIdempotent || req.Header.Get("Idempotency-Key") != "" {
+		return true
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func (p *RetryPolicy) shouldRetry(resp *http.Response, err error) bool {
+	if err == nil {
+		return (p.retryOn[RetryOn5xx] && resp.StatusCode >= http.StatusInternalServerError) ||
+			p.retryOn[strconv.Itoa(resp.StatusCode)]
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return p.retryOn[RetryOnConnectFailure]
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return p.retryOn[RetryOnTimeout]
+	}
+	return p.retryOn[RetryOnReset]
+}
+
+// retryTransport 单次请求的 transport，失败时按策略换一个未尝试过的节点重试
+type retryTransport struct {
+	transport http.RoundTripper
+	lb        load_balance.LoadBalance
+	tracker   *lbRequestTracker
+	policy    *RetryPolicy
+
+	originURL  url.URL //director 改写前的请求地址
+	originHost string
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.tracker.pickErr != nil {
+		return nil, t.tracker.pickErr
+	}
+	if t.policy == nil {
+		return t.transport.RoundTrip(req)
+	}
+	if t.policy.Budget != nil {
+		t.policy.Budget.Request()
+	}
+	maxRetry := 0
+	if t.policy.methodRetryable(req) {
+		maxRetry = t.policy.MaxRetry
+	}
+	var body []byte
+	if maxRetry > 0 && req.Body != nil && req.Body != http.NoBody {
+		buffered, ok, err := bufferRetryBody(req)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			maxRetry = 0
+		}
+		body = buffered
+	}
+
+	key := req.URL.String()
+	tried := map[string]bool{}
+	for retry := 0; ; retry++ {
+		resp, err := t.roundTripOnce(req)
+		//客户端已断开时不再重试
+		if retry >= maxRetry || req.Context().Err() != nil || !t.policy.shouldRetry(resp, err) {
+			return resp, err
+		}
+		tried[t.tracker.addr] = true
+		nextAddr := t.nextAddr(key, retry, tried)
+		if nextAddr == "" || (t.policy.Budget != nil && !t.policy.Budget.TryRetry()) {
+			return resp, err
+		}
+
+		//本次尝试失败，结束回调后换节点
+		attemptErr := err
+		if resp != nil {
+			attemptErr = fmt.Errorf("upstream status %d", resp.StatusCode)
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, retryBodyMaxBytes))
+			resp.Body.Close()
+		}
+		t.tracker.finish(attemptErr)
+		t.tracker.start(nextAddr)
+		if t.policy.OnRetry != nil {
+			t.policy.OnRetry()
+		}
+
+		target, err := url.Parse(nextAddr)
+		if err != nil {
+			return nil, err
+		}
+		//在改写前的地址上按新节点重新改写，节点地址带的路径前缀等与首次请求一致
+		req = req.Clone(req.Context())
+		originURL := t.originURL
+		req.URL, req.Host = &originURL, t.originHost
+		rewriteRequestURL(req, target)
+		if body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+	}
+}
+
+// roundTripOnce 单次尝试，设置了 PerTryTimeout 时超时取消，响应体关闭后释放
+func (t *retryTransport) roundTripOnce(req *http.Request) (*http.Response, error) {
+	if t.policy.PerTryTimeout <= 0 {
+		return t.transport.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.policy.PerTryTimeout)
+	resp, err := t.transport.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// nextAddr 选择本次请求未尝试过的节点，没有可用节点时返回空
+func (t *retryTransport) nextAddr(key string, retry int, tried map[string]bool) string {
+	for i := 0; i < nextAddrMaxPick; i++ {
+		//一致性hash对同一key总返回同一节点，重试时变换key
+		addr, err := t.lb.Get(fmt.Sprintf("%s#%d#%d", key, retry, i))
+		if err != nil {
+			return ""
+		}
+		if addr != "" && !tried[addr] {
+			return addr
+		}
+	}
+	return ""
+}
+
+// bufferRetryBody 缓存请求体用于重放，超过 retryBodyMaxBytes 时返回 false 并保持请求体可完整读取
+func bufferRetryBody(req *http.Request) ([]byte, bool, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, retryBodyMaxBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(body) > retryBodyMaxBytes {
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		return nil, false, nil
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, true, nil
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
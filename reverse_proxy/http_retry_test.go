@@ -0,0 +1,142 @@
+package reverse_proxy
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go-gateway/public"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testLoadBalance 按顺序轮流返回节点
+type testLoadBalance struct {
+	addrs []string
+	next  int
+}
+
+func (lb *testLoadBalance) Add(...string) error { return nil }
+func (lb *testLoadBalance) Update()             {}
+func (lb *testLoadBalance) Get(string) (string, error) {
+	addr := lb.addrs[lb.next%len(lb.addrs)]
+	lb.next++
+	return addr, nil
+}
+func (lb *testLoadBalance) OnRequestStart(string)                        {}
+func (lb *testLoadBalance) OnRequestFinish(string, time.Duration, error) {}
+
+func TestParseRetryOn(t *testing.T) {
+	conds, err := ParseRetryOn(" connect-failure, 5XX ,429,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cond := range []string{RetryOnConnectFailure, RetryOn5xx, "429"} {
+		if !conds[cond] {
+			t.Errorf("condition %v not parsed", cond)
+		}
+	}
+	if len(conds) != 3 {
+		t.Errorf("conds = %v", conds)
+	}
+	for _, retryOn := range []string{"gateway-error", "99", "600"} {
+		if _, err := ParseRetryOn(retryOn); err == nil {
+			t.Errorf("ParseRetryOn(%q) should fail", retryOn)
+		}
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	resetErr := &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}
+	tests := []struct {
+		retryOn string
+		status  int
+		err     error
+		want    bool
+	}{
+		{DefaultRetryOn, 0, dialErr, true},
+		{DefaultRetryOn, 0, resetErr, true},
+		{DefaultRetryOn, 0, context.DeadlineExceeded, false},
+		{DefaultRetryOn, 502, nil, true},
+		{DefaultRetryOn, 500, nil, false},
+		{DefaultRetryOn, 200, nil, false},
+		{"5xx", 500, nil, true},
+		{"5xx", 429, nil, false},
+		{"429", 429, nil, true},
+		{"timeout", 0, context.DeadlineExceeded, true},
+		{"timeout", 0, dialErr, false},
+		{"connect-failure", 0, errors.New("unexpected EOF"), false},
+	}
+	for _, test := range tests {
+		policy, err := NewRetryPolicy(1, test.retryOn, false, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var resp *http.Response
+		if test.err == nil {
+			resp = &http.Response{StatusCode: test.status}
+		}
+		if got := policy.shouldRetry(resp, test.err); got != test.want {
+			t.Errorf("retry_on=%v status=%v err=%v: shouldRetry = %v, want %v", test.retryOn, test.status, test.err, got, test.want)
+		}
+	}
+}
+
+func TestRetryRewritesRequestForEachTarget(t *testing.T) {
+	failed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failed.Close()
+	var gotPath, gotHost string
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotHost = r.URL.Path, r.Host
+	}))
+	defer ok.Close()
+
+	policy, err := NewRetryPolicy(1, "", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lb := &testLoadBalance{addrs: []string{failed.URL + "/first", ok.URL + "/second"}}
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	proxy := NewLoadBalanceReverseProxy(c, lb, nil, policy)
+	proxy.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://gateway/api/users", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %v, want 200", rec.Code)
+	}
+	if gotPath != "/second/api/users" {
+		t.Errorf("retried path = %v, want /second/api/users", gotPath)
+	}
+	if gotHost != ok.Listener.Addr().String() {
+		t.Errorf("retried host = %v, want %v", gotHost, ok.Listener.Addr().String())
+	}
+}
+
+func TestRetryStopsWhenBudgetExhausted(t *testing.T) {
+	attempts := 0
+	failed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failed.Close()
+
+	policy, err := NewRetryPolicy(3, "", false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.Budget = public.NewRetryBudget("test", 0, 0)
+	lb := &testLoadBalance{addrs: []string{failed.URL + "/a", failed.URL + "/b"}}
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	NewLoadBalanceReverseProxy(c, lb, nil, policy).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://gateway/", nil))
+
+	if rec.Code != http.StatusServiceUnavailable || attempts != 1 {
+		t.Errorf("status = %v attempts = %v, want 503 after 1 attempt", rec.Code, attempts)
+	}
+}
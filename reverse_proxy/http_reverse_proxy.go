@@ -1,47 +1,66 @@
 package reverse_proxy
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"go-gateway/middleware"
+	"go-gateway/public"
 	"go-gateway/reverse_proxy/load_balance"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 )
 
-func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, trans *http.Transport) *httputil.ReverseProxy {
+// NewLoadBalanceReverseProxy retry 不为空时失败请求按策略换节点重试
+func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, trans *http.Transport, retry *RetryPolicy) *httputil.ReverseProxy {
+	//本次请求选中的节点，请求结束时回调负载均衡
+	tracker := &lbRequestTracker{lb: lb}
+	var transport http.RoundTripper = http.DefaultTransport
+	if trans != nil {
+		transport = trans
+	}
+	retryTrans := &retryTransport{transport: transport, lb: lb, tracker: tracker, policy: retry}
+
 	//请求协调者
 	director := func(req *http.Request) {
+		//保存改写前的请求地址，重试换节点时在请求副本上重新改写
+		retryTrans.originURL, retryTrans.originHost = *req.URL, req.Host
 		nextAddr, err := lb.Get(req.URL.String())
-		//todo 优化点3
+		//没有可用节点时不再 panic，由 transport 返回错误交给 errFunc 处理
 		if err != nil || nextAddr == "" {
-			panic("get next addr fail")
+			if err == nil {
+				err = errors.New("get next addr fail")
+			}
+			tracker.pickErr = err
+			return
 		}
+		tracker.start(nextAddr)
 		target, err := url.Parse(nextAddr)
 		if err != nil {
 			panic(err)
 		}
-		targetQuery := target.RawQuery
-		req.URL.Scheme = target.Scheme
-		req.URL.Host = target.Host
-		req.URL.Path = singleJoiningSlash(target.Path, req.URL.Path)
-		req.Host = target.Host
-		if targetQuery == "" || req.URL.RawQuery == "" {
-			req.URL.RawQuery = targetQuery + req.URL.RawQuery
-		} else {
-			req.URL.RawQuery = targetQuery + "&" + req.URL.RawQuery
-		}
-		if _, ok := req.Header["User-Agent"]; !ok {
-			req.Header.Set("User-Agent", "user-agent")
-		}
+		rewriteRequestURL(req, target)
 	}
 
 	//更改内容
 	modifyFunc := func(resp *http.Response) error {
 		if strings.Contains(resp.Header.Get("Connection"), "Upgrade") {
+			tracker.finish(nil)
 			return nil
 		}
+		//下游 5xx 计入节点异常，用于被动健康检查
+		if resp.StatusCode >= http.StatusInternalServerError {
+			tracker.respErr = fmt.Errorf("upstream status %d", resp.StatusCode)
+		}
+		//响应体读取完毕后才算请求结束
+		resp.Body = &lbTrackBody{ReadCloser: resp.Body, tracker: tracker}
 
 		//todo 优化点2
 		//var payload []byte
@@ -72,9 +91,82 @@ func NewLoadBalanceReverseProxy(c *gin.Context, lb load_balance.LoadBalance, tra
 	//错误回调 ：关闭real_server时测试，错误回调
 	//范围：transport.RoundTrip发生的错误、以及ModifyResponse发生的错误
 	errFunc := func(w http.ResponseWriter, r *http.Request, err error) {
-		middleware.ResponseError(c, 999, err)
+		tracker.finish(err)
+		//grpc 客户端只识别 grpc-status，以 trailers-only 响应返回 UNAVAILABLE，超时返回 DEADLINE_EXCEEDED
+		if public.IsGrpcRequest(r) {
+			grpcStatus := "14"
+			if UpstreamErrorStatus(err) == http.StatusGatewayTimeout {
+				grpcStatus = "4"
+			}
+			w.Header().Set("Content-Type", "application/grpc")
+			w.Header().Set("Grpc-Status", grpcStatus)
+			w.Header().Set("Grpc-Message", url.PathEscape(err.Error()))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		middleware.ResponseProxyError(c, 999, UpstreamErrorStatus(err), err)
+	}
+	return &httputil.ReverseProxy{
+		Director:       director,
+		Transport:      retryTrans,
+		ModifyResponse: modifyFunc,
+		ErrorHandler:   errFunc,
+	}
+}
+
+// lbRequestTracker 记录单次请求当前尝试的节点及耗时，保证每次尝试的结束回调只执行一次
+type lbRequestTracker struct {
+	lb        load_balance.LoadBalance
+	addr      string
+	startTime time.Time
+	respErr   error
+	pickErr   error //选择节点失败的原因
+	once      sync.Once
+}
+
+func (t *lbRequestTracker) start(addr string) {
+	t.addr = addr
+	t.startTime = time.Now()
+	t.respErr = nil
+	t.once = sync.Once{}
+	t.lb.OnRequestStart(addr)
+}
+
+func (t *lbRequestTracker) finish(err error) {
+	if t.addr == "" {
+		return
+	}
+	t.once.Do(func() {
+		t.lb.OnRequestFinish(t.addr, time.Since(t.startTime), err)
+	})
+}
+
+type lbTrackBody struct {
+	io.ReadCloser
+	tracker *lbRequestTracker
+}
+
+func (b *lbTrackBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.tracker.finish(b.tracker.respErr)
+	return err
+}
+
+// rewriteRequestURL 将请求地址改写为下游节点地址
+func rewriteRequestURL(req *http.Request, target *url.URL) {
+	targetQuery := target.RawQuery
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.URL.Path = singleJoiningSlash(target.Path, req.URL.Path)
+	req.Host = target.Host
+	if targetQuery == "" || req.URL.RawQuery == "" {
+		req.URL.RawQuery = targetQuery + req.URL.RawQuery
+	} else {
+		req.URL.RawQuery = targetQuery + "&" + req.URL.RawQuery
+	}
+	if _, ok := req.Header["User-Agent"]; !ok {
+		req.Header.Set("User-Agent", "user-agent")
 	}
-	return &httputil.ReverseProxy{Director: director, ModifyResponse: modifyFunc, ErrorHandler: errFunc}
 }
 
 func singleJoiningSlash(a, b string) string {
@@ -88,3 +180,15 @@ func singleJoiningSlash(a, b string) string {
 	}
 	return a + b
 }
+
+// UpstreamErrorStatus 请求下游失败时返回的状态码，熔断为 503，超时为 504，其余为 502
+func UpstreamErrorStatus(err error) int {
+	if errors.Is(err, public.ErrCircuitBreakerOpen) {
+		return http.StatusServiceUnavailable
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusBadGateway
+}
@@ -2,9 +2,10 @@ package load_balance
 
 import (
 	"fmt"
-	"net"
+	"log"
 	"reflect"
 	"sort"
+	"sync"
 	"time"
 )
 
@@ -19,21 +20,38 @@ const (
 type LoadBalanceCheckConf struct {
 	observers    []Observer
 	confIpWeight map[string]string
-	activeList   []string
+	healthyList  []string //主动探活通过的节点
+	activeList   []string //探活通过且未被异常摘除的节点，即对外可用节点
 	format       string
+	addrMap      map[string]string //格式化后的地址与节点的对应，用于请求结果上报
+	check        *HealthCheckConf
+	outlier      *OutlierDetector
+	closeChan    chan struct{}
+	closeOnce    sync.Once
+	locker       sync.RWMutex //保护 observers、healthyList、activeList，探活协程与请求协程并发访问
 }
 
 func (s *LoadBalanceCheckConf) Attach(o Observer) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
 	s.observers = append(s.observers, o)
 }
 
 func (s *LoadBalanceCheckConf) NotifyAllObservers() {
-	for _, obs := range s.observers {
+	for _, obs := range s.getObservers() {
 		obs.Update()
 	}
 }
 
+func (s *LoadBalanceCheckConf) getObservers() []Observer {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	return s.observers
+}
+
 func (s *LoadBalanceCheckConf) GetConf() []string {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
 	confList := []string{}
 	for _, ip := range s.activeList {
 		weight, ok := s.confIpWeight[ip]
@@ -45,59 +63,143 @@ func (s *LoadBalanceCheckConf) GetConf() []string {
 	return confList
 }
 
-// WatchConf 更新配置时，通知监听者也更新
+// WatchConf 按探活配置周期检查节点，可用节点变化时通知监听者更新
 func (s *LoadBalanceCheckConf) WatchConf() {
 	//fmt.Println("watchConf")
 	go func() {
+		//节点连续成功、失败次数，达到 rise/fall 阈值后才切换状态，避免抖动
+		confIpOkNum := map[string]int{}
 		confIpErrNum := map[string]int{}
+		confIpDown := map[string]bool{}
 		for {
+			checkErr := s.checkAll()
 			changedList := []string{}
 			for item, _ := range s.confIpWeight {
-				conn, err := net.DialTimeout("tcp", item, time.Duration(DefaultCheckTimeout)*time.Second)
-				//todo http statuscode
-				if err == nil {
-					conn.Close()
-					if _, ok := confIpErrNum[item]; ok {
-						confIpErrNum[item] = 0
+				if checkErr[item] == nil {
+					confIpOkNum[item]++
+					confIpErrNum[item] = 0
+					if confIpDown[item] && confIpOkNum[item] >= s.check.Rise {
+						confIpDown[item] = false
 					}
-				}
-				if err != nil {
-					if _, ok := confIpErrNum[item]; ok {
-						confIpErrNum[item] += 1
-					} else {
-						confIpErrNum[item] = 1
+				} else {
+					confIpErrNum[item]++
+					confIpOkNum[item] = 0
+					if !confIpDown[item] && confIpErrNum[item] >= s.check.Fall {
+						confIpDown[item] = true
+						log.Printf(" [INFO] health check %v down: %v\n", item, checkErr[item])
 					}
 				}
-				if confIpErrNum[item] < DefaultCheckMaxErrNum {
+				if !confIpDown[item] {
 					changedList = append(changedList, item)
 				}
 			}
 			sort.Strings(changedList)
-			sort.Strings(s.activeList)
-			if !reflect.DeepEqual(changedList, s.activeList) {
+			if !reflect.DeepEqual(changedList, s.getHealthyList()) {
 				s.UpdateConf(changedList)
 			}
-			time.Sleep(time.Duration(DefaultCheckInterval) * time.Second)
+			select {
+			case <-s.closeChan:
+				return
+			case <-time.After(s.check.Interval):
+			}
 		}
 	}()
 }
 
-// UpdateConf 更新配置时，通知监听者也更新
+// checkAll 并发探测全部节点，返回各节点的探测结果
+func (s *LoadBalanceCheckConf) checkAll() map[string]error {
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+	checkErr := map[string]error{}
+	for item, _ := range s.confIpWeight {
+		wg.Add(1)
+		go func(item string) {
+			defer wg.Done()
+			err := s.check.Check(item)
+			mux.Lock()
+			checkErr[item] = err
+			mux.Unlock()
+		}(item)
+	}
+	wg.Wait()
+	return checkErr
+}
+
+// CloseWatch 停止探活协程，服务配置变更或删除时调用
+func (s *LoadBalanceCheckConf) CloseWatch() {
+	s.closeOnce.Do(func() {
+		close(s.closeChan)
+	})
+}
+
+// UpdateConf 更新探活通过的节点，可用节点变化时通知监听者也更新
 func (s *LoadBalanceCheckConf) UpdateConf(conf []string) {
 	//fmt.Println("UpdateConf", conf)
-	s.activeList = conf
-	for _, obs := range s.observers {
-		obs.Update()
+	s.locker.Lock()
+	s.healthyList = conf
+	s.locker.Unlock()
+	s.refreshActiveList()
+}
+
+// refreshActiveList 探活通过的节点中去掉被异常摘除的节点，结果变化时通知监听者
+func (s *LoadBalanceCheckConf) refreshActiveList() {
+	ejected := s.outlier.Ejected()
+	s.locker.Lock()
+	activeList := []string{}
+	for _, item := range s.healthyList {
+		if !ejected[item] {
+			activeList = append(activeList, item)
+		}
+	}
+	sort.Strings(activeList)
+	oldList := append([]string{}, s.activeList...)
+	sort.Strings(oldList)
+	if reflect.DeepEqual(activeList, oldList) {
+		s.locker.Unlock()
+		return
+	}
+	s.activeList = activeList
+	s.locker.Unlock()
+	log.Printf(" [INFO] active list changed: %v ejected: %v\n", activeList, ejected)
+	s.NotifyAllObservers()
+}
+
+// ReportResult 代理上报请求结果，addr 为负载均衡返回的地址
+func (s *LoadBalanceCheckConf) ReportResult(addr string, err error) {
+	item, ok := s.addrMap[addr]
+	if !ok {
+		return
 	}
+	s.outlier.Report(item, err)
+}
+
+// getHealthyList 返回排序后的探活通过节点副本
+func (s *LoadBalanceCheckConf) getHealthyList() []string {
+	s.locker.RLock()
+	defer s.locker.RUnlock()
+	healthyList := append([]string{}, s.healthyList...)
+	sort.Strings(healthyList)
+	return healthyList
 }
 
-func NewLoadBalanceCheckConf(format string, conf map[string]string) (*LoadBalanceCheckConf, error) {
+// NewLoadBalanceCheckConf check 为空时使用默认的 tcp 探活
+func NewLoadBalanceCheckConf(format string, conf map[string]string, check *HealthCheckConf) (*LoadBalanceCheckConf, error) {
+	if check == nil {
+		defaultCheck, err := NewHealthCheckConf(HealthCheckConf{Method: DefaultCheckMethod}, "")
+		if err != nil {
+			return nil, err
+		}
+		check = defaultCheck
+	}
 	aList := []string{}
+	addrMap := map[string]string{}
 	//默认初始化
 	for item, _ := range conf {
 		aList = append(aList, item)
+		addrMap[fmt.Sprintf(format, item)] = item
 	}
-	mConf := &LoadBalanceCheckConf{format: format, activeList: aList, confIpWeight: conf}
+	mConf := &LoadBalanceCheckConf{format: format, healthyList: aList, activeList: aList, addrMap: addrMap, confIpWeight: conf, check: check, closeChan: make(chan struct{})}
+	mConf.outlier = NewOutlierDetector(check.Outlier, len(conf), mConf.refreshActiveList)
 	mConf.WatchConf()
 	return mConf, nil
 }
@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type Hash func(data []byte) uint32
@@ -93,6 +94,10 @@ func (c *ConsistentHashBanlance) Get(key string) (string, error) {
 	return c.hashMap[c.keys[idx]], nil
 }
 
+func (c *ConsistentHashBanlance) OnRequestStart(addr string) {}
+
+func (c *ConsistentHashBanlance) OnRequestFinish(addr string, cost time.Duration, err error) {}
+
 func (c *ConsistentHashBanlance) SetConf(conf LoadBalanceConf) {
 	c.conf = conf
 }
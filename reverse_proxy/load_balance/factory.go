@@ -1,5 +1,7 @@
 package load_balance
 
+import "time"
+
 type LbType int
 
 const (
@@ -7,6 +9,8 @@ const (
 	LbRoundRobin
 	LbWeightRoundRobin
 	LbConsistentHash
+	LbLeastConn
+	LbPeakEwma
 )
 
 func LoadBanlanceFactory(lbType LbType) LoadBalance {
@@ -19,12 +23,40 @@ func LoadBanlanceFactory(lbType LbType) LoadBalance {
 		return &RoundRobinBalance{}
 	case LbWeightRoundRobin:
 		return &WeightRoundRobinBalance{}
+	case LbLeastConn:
+		return NewLeastConnBalance()
+	case LbPeakEwma:
+		return NewPeakEwmaBalance()
 	default:
 		return &RandomBalance{}
 	}
 }
 
+// ResultReporter 接收请求结果的配置主题，用于被动健康检查
+type ResultReporter interface {
+	ReportResult(addr string, err error)
+}
+
+// reportBalance 请求结束时将结果同时上报给配置主题
+type reportBalance struct {
+	LoadBalance
+	reporter ResultReporter
+}
+
+func (r *reportBalance) OnRequestFinish(addr string, cost time.Duration, err error) {
+	r.LoadBalance.OnRequestFinish(addr, cost, err)
+	r.reporter.ReportResult(addr, err)
+}
+
 func LoadBanlanceFactorWithConf(lbType LbType, mConf LoadBalanceConf) LoadBalance {
+	lb := loadBanlanceWithConf(lbType, mConf)
+	if reporter, ok := mConf.(ResultReporter); ok {
+		return &reportBalance{LoadBalance: lb, reporter: reporter}
+	}
+	return lb
+}
+
+func loadBanlanceWithConf(lbType LbType, mConf LoadBalanceConf) LoadBalance {
 	//观察者模式
 	switch lbType {
 	case LbRandom:
@@ -51,6 +83,18 @@ func LoadBanlanceFactorWithConf(lbType LbType, mConf LoadBalanceConf) LoadBalanc
 		mConf.Attach(lb)
 		lb.Update()
 		return lb
+	case LbLeastConn:
+		lb := NewLeastConnBalance()
+		lb.SetConf(mConf)
+		mConf.Attach(lb)
+		lb.Update()
+		return lb
+	case LbPeakEwma:
+		lb := NewPeakEwmaBalance()
+		lb.SetConf(mConf)
+		mConf.Attach(lb)
+		lb.Update()
+		return lb
 	default:
 		lb := &RandomBalance{}
 		lb.SetConf(mConf)
@@ -0,0 +1,233 @@
+package load_balance
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CheckMethodTcp  = 0 //tcpchk 检测端口是否握手成功
+	CheckMethodHttp = 1 //httpchk 检测 http(s) 状态码及响应体
+	CheckMethodGrpc = 2 //grpc.health.v1 检测服务状态为 SERVING
+	CheckMethodUdp  = 3 //udpchk 发送空数据报，收到端口不可达时判定失败
+
+	DefaultCheckRise         = 2
+	DefaultCheckHttpMethod   = "GET"
+	DefaultCheckPath         = "/"
+	DefaultCheckExpectStatus = "200-399"
+
+	//响应体匹配时最多读取的字节数
+	checkBodyMaxBytes = 64 * 1024
+)
+
+// HealthCheckConf 单个服务的主动健康检查配置
+type HealthCheckConf struct {
+	Method       int
+	Timeout      time.Duration
+	Interval     time.Duration
+	Path         string //http 检查路径，grpc 检查时为服务名
+	HttpMethod   string
+	ExpectStatus string      //期望状态码，如 200-399,404
+	ExpectBody   string      //响应体需包含的内容，为空不检查
+	Rise         int         //连续成功次数，达到后节点恢复
+	Fall         int         //连续失败次数，达到后节点摘除
+	Outlier      OutlierConf //被动健康检查
+
+	scheme      string
+	statusRange [][2]int
+	httpClient  *http.Client
+}
+
+// NewHealthCheckConf 未设置的项使用默认值；scheme 为 http 或 https，仅 http 检查使用
+func NewHealthCheckConf(conf HealthCheckConf, scheme string) (*HealthCheckConf, error) {
+	c := conf
+	if c.Timeout <= 0 {
+		c.Timeout = time.Duration(DefaultCheckTimeout) * time.Second
+	}
+	if c.Interval <= 0 {
+		c.Interval = time.Duration(DefaultCheckInterval) * time.Second
+	}
+	if c.Rise <= 0 {
+		c.Rise = DefaultCheckRise
+	}
+	if c.Fall <= 0 {
+		c.Fall = DefaultCheckMaxErrNum
+	}
+	if c.Method != CheckMethodHttp {
+		return &c, nil
+	}
+	if c.Path == "" {
+		c.Path = DefaultCheckPath
+	}
+	if !strings.HasPrefix(c.Path, "/") {
+		c.Path = "/" + c.Path
+	}
+	if c.HttpMethod == "" {
+		c.HttpMethod = DefaultCheckHttpMethod
+	}
+	c.HttpMethod = strings.ToUpper(c.HttpMethod)
+	if c.ExpectStatus == "" {
+		c.ExpectStatus = DefaultCheckExpectStatus
+	}
+	statusRange, err := ParseStatusRange(c.ExpectStatus)
+	if err != nil {
+		return nil, err
+	}
+	c.statusRange = statusRange
+	c.scheme = scheme
+	if c.scheme == "" {
+		c.scheme = "http"
+	}
+	c.httpClient = &http.Client{
+		Timeout: c.Timeout,
+		Transport: &http.Transport{
+			DisableKeepAlives: true,
+			//探活只关心节点存活，不校验下游证书
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+		//不跟随跳转，以首个响应状态码为准
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &c, nil
+}
+
+// ParseStatusRange 解析状态码范围，格式如 200-399,404
+func ParseStatusRange(expect string) ([][2]int, error) {
+	statusRange := [][2]int{}
+	for _, item := range strings.Split(expect, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		bounds := strings.SplitN(item, "-", 2)
+		low, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid status range %q", item)
+		}
+		high := low
+		if len(bounds) == 2 {
+			if high, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("invalid status range %q", item)
+			}
+		}
+		if low < 100 || high > 599 || low > high {
+			return nil, fmt.Errorf("invalid status range %q", item)
+		}
+		statusRange = append(statusRange, [2]int{low, high})
+	}
+	if len(statusRange) == 0 {
+		return nil, errors.New("empty status range")
+	}
+	return statusRange, nil
+}
+
+// Check 对单个节点执行一次探测，addr 为 ip:port
+func (c *HealthCheckConf) Check(addr string) error {
+	switch c.Method {
+	case CheckMethodHttp:
+		return c.checkHttp(addr)
+	case CheckMethodGrpc:
+		return c.checkGrpc(addr)
+	case CheckMethodUdp:
+		return c.checkUdp(addr)
+	default:
+		return c.checkTcp(addr)
+	}
+}
+
+func (c *HealthCheckConf) checkTcp(addr string) error {
+	conn, err := net.DialTimeout("tcp", addr, c.Timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// checkUdp udp 无握手，发送空数据报后在超时时间内等待回包：
+// 收到 icmp 端口不可达(读取返回 connection refused)时失败，超时未收到回包视为存活
+func (c *HealthCheckConf) checkUdp(addr string) error {
+	conn, err := net.DialTimeout("udp", addr, c.Timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte{}); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(c.Timeout))
+	if _, err := conn.Read(make([]byte, 1)); err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (c *HealthCheckConf) checkHttp(addr string) error {
+	req, err := http.NewRequest(c.HttpMethod, c.scheme+"://"+addr+c.Path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "go-gateway-health-check")
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if !c.statusExpected(resp.StatusCode) {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, checkBodyMaxBytes))
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if c.ExpectBody == "" {
+		return nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, checkBodyMaxBytes))
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(string(body), c.ExpectBody) {
+		return errors.New("body not match")
+	}
+	return nil
+}
+
+func (c *HealthCheckConf) statusExpected(code int) bool {
+	for _, r := range c.statusRange {
+		if code >= r[0] && code <= r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *HealthCheckConf) checkGrpc(addr string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: c.Path})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("grpc health status %v", resp.Status)
+	}
+	return nil
+}
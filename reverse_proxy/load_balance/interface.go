@@ -1,9 +1,15 @@
 package load_balance
 
+import "time"
+
 type LoadBalance interface {
 	Add(...string) error
 	Get(string) (string, error)
 
 	//后期服务发现补充
 	Update()
+
+	//请求开始、结束回调，按节点负载选择的策略据此统计并发数及延迟，其余策略为空实现
+	OnRequestStart(addr string)
+	OnRequestFinish(addr string, cost time.Duration, err error)
 }
@@ -0,0 +1,120 @@
+package load_balance
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LeastConnBalance 最少未完成请求：按 p2c 选取 (并发数+1)/权重 较小的节点
+type LeastConnBalance struct {
+	mux      sync.RWMutex
+	rss      []*LeastConnNode
+	inflight map[string]*int64 //节点并发数，节点列表更新后保留
+	//观察主体
+	conf LoadBalanceConf
+}
+
+type LeastConnNode struct {
+	addr     string
+	weight   int
+	inflight *int64
+}
+
+func NewLeastConnBalance() *LeastConnBalance {
+	return &LeastConnBalance{
+		inflight: map[string]*int64{},
+	}
+}
+
+func (r *LeastConnBalance) Add(params ...string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.addLocked(params...)
+}
+
+// addLocked 调用方需持有写锁
+func (r *LeastConnBalance) addLocked(params ...string) error {
+	if len(params) == 0 {
+		return errors.New("param len 1 at least")
+	}
+	weight := 1
+	if len(params) > 1 {
+		parInt, err := strconv.ParseInt(params[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		if parInt > 0 {
+			weight = int(parInt)
+		}
+	}
+	r.rss = append(r.rss, &LeastConnNode{addr: params[0], weight: weight, inflight: r.getInflight(params[0])})
+	return nil
+}
+
+// getInflight 调用方需持有写锁
+func (r *LeastConnBalance) getInflight(addr string) *int64 {
+	if r.inflight == nil {
+		r.inflight = map[string]*int64{}
+	}
+	counter, ok := r.inflight[addr]
+	if !ok {
+		counter = new(int64)
+		r.inflight[addr] = counter
+	}
+	return counter
+}
+
+func (r *LeastConnBalance) Next() string {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	if len(r.rss) == 0 {
+		return ""
+	}
+	index := p2cPick(len(r.rss), func(i int) float64 {
+		node := r.rss[i]
+		return float64(atomic.LoadInt64(node.inflight)+1) / float64(node.weight)
+	})
+	return r.rss[index].addr
+}
+
+func (r *LeastConnBalance) Get(key string) (string, error) {
+	return r.Next(), nil
+}
+
+func (r *LeastConnBalance) OnRequestStart(addr string) {
+	r.mux.RLock()
+	counter, ok := r.inflight[addr]
+	r.mux.RUnlock()
+	if ok {
+		atomic.AddInt64(counter, 1)
+	}
+}
+
+func (r *LeastConnBalance) OnRequestFinish(addr string, cost time.Duration, err error) {
+	r.mux.RLock()
+	counter, ok := r.inflight[addr]
+	r.mux.RUnlock()
+	if ok && atomic.AddInt64(counter, -1) < 0 {
+		atomic.StoreInt64(counter, 0)
+	}
+}
+
+func (r *LeastConnBalance) SetConf(conf LoadBalanceConf) {
+	r.conf = conf
+}
+
+func (r *LeastConnBalance) Update() {
+	if conf, ok := r.conf.(*LoadBalanceCheckConf); ok {
+		confList := conf.GetConf()
+		r.mux.Lock()
+		defer r.mux.Unlock()
+		r.rss = nil
+		for _, ip := range confList {
+			r.addLocked(strings.Split(ip, ",")...)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package load_balance
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLeastConnBalancePicksFewerInflight(t *testing.T) {
+	rb := NewLeastConnBalance()
+	rb.Add("127.0.0.1:2001", "1")
+	rb.Add("127.0.0.1:2002", "1")
+
+	for i := 0; i < 3; i++ {
+		rb.OnRequestStart("127.0.0.1:2001")
+	}
+	for i := 0; i < 10; i++ {
+		if addr := rb.Next(); addr != "127.0.0.1:2002" {
+			t.Fatalf("Next() = %v, want 127.0.0.1:2002", addr)
+		}
+	}
+	//请求结束后并发数回落，失败同样计入结束
+	rb.OnRequestFinish("127.0.0.1:2001", 0, nil)
+	rb.OnRequestFinish("127.0.0.1:2001", 0, errors.New("reset"))
+	rb.OnRequestFinish("127.0.0.1:2001", 0, nil)
+	for i := 0; i < 3; i++ {
+		rb.OnRequestStart("127.0.0.1:2002")
+	}
+	if addr := rb.Next(); addr != "127.0.0.1:2001" {
+		t.Errorf("Next() = %v, want 127.0.0.1:2001", addr)
+	}
+	//多余的结束回调不会使并发数小于 0
+	rb.OnRequestFinish("127.0.0.1:2001", 0, nil)
+	if n := *rb.inflight["127.0.0.1:2001"]; n != 0 {
+		t.Errorf("inflight = %d, want 0", n)
+	}
+	//未知节点的回调忽略
+	rb.OnRequestStart("127.0.0.1:9999")
+	rb.OnRequestFinish("127.0.0.1:9999", 0, nil)
+}
+
+func TestLeastConnBalanceWeight(t *testing.T) {
+	rb := NewLeastConnBalance()
+	rb.Add("127.0.0.1:2001", "10")
+	rb.Add("127.0.0.1:2002", "1")
+	//(5+1)/10 < (0+1)/1，权重高的节点承担更多并发
+	for i := 0; i < 5; i++ {
+		rb.OnRequestStart("127.0.0.1:2001")
+	}
+	if addr := rb.Next(); addr != "127.0.0.1:2001" {
+		t.Errorf("Next() = %v, want 127.0.0.1:2001", addr)
+	}
+	for i := 0; i < 5; i++ {
+		rb.OnRequestStart("127.0.0.1:2001")
+	}
+	if addr := rb.Next(); addr != "127.0.0.1:2002" {
+		t.Errorf("Next() = %v, want 127.0.0.1:2002", addr)
+	}
+}
+
+func TestLeastConnBalanceKeepsInflightOnUpdate(t *testing.T) {
+	rb := NewLeastConnBalance()
+	rb.Add("127.0.0.1:2001", "1")
+	rb.Add("127.0.0.1:2002", "1")
+	rb.OnRequestStart("127.0.0.1:2001")
+	rb.OnRequestStart("127.0.0.1:2001")
+
+	//节点列表更新时重建节点，处理中的请求数保留
+	rb.mux.Lock()
+	rb.rss = nil
+	rb.addLocked("127.0.0.1:2002", "1")
+	rb.addLocked("127.0.0.1:2001", "1")
+	rb.mux.Unlock()
+	if addr := rb.Next(); addr != "127.0.0.1:2002" {
+		t.Errorf("Next() = %v, want 127.0.0.1:2002", addr)
+	}
+	rb.OnRequestFinish("127.0.0.1:2001", 0, nil)
+	if n := *rb.inflight["127.0.0.1:2001"]; n != 1 {
+		t.Errorf("inflight = %d, want 1", n)
+	}
+	if addr := NewLeastConnBalance().Next(); addr != "" {
+		t.Errorf("empty balance Next() = %v", addr)
+	}
+}
@@ -0,0 +1,174 @@
+package load_balance
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	//default outlier setting
+	DefaultOutlierConsecutiveErrors  = 5
+	DefaultOutlierErrorPercent       = 50
+	DefaultOutlierMinRequest         = 20
+	DefaultOutlierInterval           = 10 * time.Second
+	DefaultOutlierBaseEjectionTime   = 30 * time.Second
+	DefaultOutlierMaxEjectionTime    = 300 * time.Second
+	DefaultOutlierMaxEjectionPercent = 50
+)
+
+// OutlierConf 被动健康检查配置，根据真实请求结果临时摘除异常节点
+type OutlierConf struct {
+	Open               bool
+	ConsecutiveErrors  int           //连续失败次数达到后摘除
+	ErrorPercent       int           //统计窗口内失败率达到后摘除, 百分比
+	MinRequest         int           //统计窗口内请求数不足时不按失败率摘除
+	Interval           time.Duration //失败率统计窗口
+	BaseEjectionTime   time.Duration //首次摘除时长，之后每次摘除翻倍
+	MaxEjectionTime    time.Duration //摘除时长上限
+	MaxEjectionPercent int           //同时被摘除节点的最大占比, 百分比
+}
+
+func (c *OutlierConf) setDefault() {
+	if c.ConsecutiveErrors <= 0 {
+		c.ConsecutiveErrors = DefaultOutlierConsecutiveErrors
+	}
+	if c.ErrorPercent <= 0 {
+		c.ErrorPercent = DefaultOutlierErrorPercent
+	}
+	if c.MinRequest <= 0 {
+		c.MinRequest = DefaultOutlierMinRequest
+	}
+	if c.Interval <= 0 {
+		c.Interval = DefaultOutlierInterval
+	}
+	if c.BaseEjectionTime <= 0 {
+		c.BaseEjectionTime = DefaultOutlierBaseEjectionTime
+	}
+	if c.MaxEjectionTime <= 0 {
+		c.MaxEjectionTime = DefaultOutlierMaxEjectionTime
+	}
+	if c.MaxEjectionPercent <= 0 {
+		c.MaxEjectionPercent = DefaultOutlierMaxEjectionPercent
+	}
+}
+
+type outlierNode struct {
+	consecutive  int
+	total        int
+	failed       int
+	ejectCount   int       //累计摘除次数，决定下次摘除时长
+	ejectedUntil time.Time //摘除截止时间
+}
+
+// OutlierDetector 统计各节点请求结果，节点被摘除或恢复时回调 onChange
+type OutlierDetector struct {
+	mux         sync.Mutex
+	conf        OutlierConf
+	nodes       map[string]*outlierNode
+	nodeNum     int
+	windowStart time.Time
+	onChange    func()
+}
+
+func NewOutlierDetector(conf OutlierConf, nodeNum int, onChange func()) *OutlierDetector {
+	conf.setDefault()
+	return &OutlierDetector{
+		conf:        conf,
+		nodes:       map[string]*outlierNode{},
+		nodeNum:     nodeNum,
+		windowStart: time.Now(),
+		onChange:    onChange,
+	}
+}
+
+// Report 上报一次请求结果，err 不为空视为失败
+func (d *OutlierDetector) Report(addr string, err error) {
+	if !d.conf.Open {
+		return
+	}
+	d.mux.Lock()
+	now := time.Now()
+	d.rollWindowLocked(now)
+	node, ok := d.nodes[addr]
+	if !ok {
+		node = &outlierNode{}
+		d.nodes[addr] = node
+	}
+	if now.Before(node.ejectedUntil) {
+		d.mux.Unlock()
+		return
+	}
+	node.total++
+	if err == nil {
+		node.consecutive = 0
+		d.mux.Unlock()
+		return
+	}
+	node.failed++
+	node.consecutive++
+	eject := node.consecutive >= d.conf.ConsecutiveErrors ||
+		(node.total >= d.conf.MinRequest && node.failed*100 >= node.total*d.conf.ErrorPercent)
+	if !eject || !d.canEjectLocked(now) {
+		d.mux.Unlock()
+		return
+	}
+	//摘除时长按摘除次数指数退避
+	ejectTime := d.conf.BaseEjectionTime << uint(node.ejectCount)
+	if ejectTime <= 0 || ejectTime > d.conf.MaxEjectionTime {
+		ejectTime = d.conf.MaxEjectionTime
+	}
+	node.ejectCount++
+	node.ejectedUntil = now.Add(ejectTime)
+	node.consecutive, node.total, node.failed = 0, 0, 0
+	d.mux.Unlock()
+
+	time.AfterFunc(ejectTime, d.onChange)
+	d.onChange()
+}
+
+// rollWindowLocked 统计窗口到期后清零计数；节点恢复后一个最大摘除时长内未再被摘除，则重置退避
+func (d *OutlierDetector) rollWindowLocked(now time.Time) {
+	if now.Sub(d.windowStart) < d.conf.Interval {
+		return
+	}
+	d.windowStart = now
+	for _, node := range d.nodes {
+		node.total, node.failed = 0, 0
+		if node.ejectCount > 0 && now.Sub(node.ejectedUntil) > d.conf.MaxEjectionTime {
+			node.ejectCount = 0
+		}
+	}
+}
+
+// canEjectLocked 被摘除节点占比不超过 MaxEjectionPercent，避免全部节点被摘除；
+// 多个节点时占比向下取整不足一个也允许摘除一个，只有一个节点时不摘除，摘除后没有可用节点，只能让请求直接失败
+func (d *OutlierDetector) canEjectLocked(now time.Time) bool {
+	if d.nodeNum <= 1 {
+		return false
+	}
+	ejectedNum := 0
+	for _, node := range d.nodes {
+		if now.Before(node.ejectedUntil) {
+			ejectedNum++
+		}
+	}
+	maxEjected := d.nodeNum * d.conf.MaxEjectionPercent / 100
+	if maxEjected < 1 {
+		maxEjected = 1
+	}
+	return ejectedNum < maxEjected
+}
+
+// Ejected 当前处于摘除状态的节点
+func (d *OutlierDetector) Ejected() map[string]bool {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	now := time.Now()
+	ejected := map[string]bool{}
+	for addr, node := range d.nodes {
+		if now.Before(node.ejectedUntil) {
+			ejected[addr] = true
+		}
+	}
+	return ejected
+}
@@ -0,0 +1,110 @@
+package load_balance
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errOutlierTest = errors.New("connection refused")
+
+func TestOutlierDetectorConsecutiveErrors(t *testing.T) {
+	var changed int32
+	d := NewOutlierDetector(OutlierConf{Open: true, ConsecutiveErrors: 3}, 3, func() { atomic.AddInt32(&changed, 1) })
+	addr := "127.0.0.1:2001"
+
+	d.Report(addr, errOutlierTest)
+	d.Report(addr, errOutlierTest)
+	//成功请求清零连续失败次数
+	d.Report(addr, nil)
+	d.Report(addr, errOutlierTest)
+	d.Report(addr, errOutlierTest)
+	if d.Ejected()[addr] {
+		t.Fatal("ejected before consecutive errors reached")
+	}
+	d.Report(addr, errOutlierTest)
+	if !d.Ejected()[addr] {
+		t.Fatal("not ejected after consecutive errors reached")
+	}
+	if atomic.LoadInt32(&changed) != 1 {
+		t.Errorf("onChange called %d times, want 1", changed)
+	}
+
+	//未开启时不统计
+	d = NewOutlierDetector(OutlierConf{ConsecutiveErrors: 1}, 3, func() {})
+	d.Report(addr, errOutlierTest)
+	if len(d.Ejected()) != 0 {
+		t.Error("ejected when outlier detection is off")
+	}
+}
+
+func TestOutlierDetectorMaxEjectionPercent(t *testing.T) {
+	tests := []struct {
+		nodeNum int
+		percent int
+		want    int
+	}{
+		{nodeNum: 1, percent: 50, want: 0},
+		{nodeNum: 2, percent: 50, want: 1},
+		{nodeNum: 2, percent: 10, want: 1},
+		{nodeNum: 4, percent: 50, want: 2},
+		{nodeNum: 4, percent: 100, want: 4},
+		{nodeNum: 5, percent: 50, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d_nodes_%d_percent", tt.nodeNum, tt.percent), func(t *testing.T) {
+			d := NewOutlierDetector(OutlierConf{Open: true, ConsecutiveErrors: 1, MaxEjectionPercent: tt.percent}, tt.nodeNum, func() {})
+			for i := 0; i < tt.nodeNum; i++ {
+				d.Report(fmt.Sprintf("127.0.0.1:%d", 2001+i), errOutlierTest)
+			}
+			if got := len(d.Ejected()); got != tt.want {
+				t.Errorf("ejected %d nodes, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutlierDetectorEjectionTime(t *testing.T) {
+	changed := make(chan struct{}, 4)
+	d := NewOutlierDetector(OutlierConf{
+		Open:              true,
+		ConsecutiveErrors: 1,
+		BaseEjectionTime:  50 * time.Millisecond,
+		MaxEjectionTime:   80 * time.Millisecond,
+	}, 2, func() { changed <- struct{}{} })
+	addr := "127.0.0.1:2001"
+
+	d.Report(addr, errOutlierTest)
+	if !d.Ejected()[addr] {
+		t.Fatal("not ejected")
+	}
+	<-changed
+	//摘除期间的请求结果不统计
+	d.Report(addr, nil)
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("onChange not called after ejection time")
+	}
+	if d.Ejected()[addr] {
+		t.Fatal("still ejected after ejection time")
+	}
+
+	//再次摘除时长翻倍，不超过上限
+	start := time.Now()
+	d.Report(addr, errOutlierTest)
+	<-changed
+	select {
+	case <-changed:
+	case <-time.After(time.Second):
+		t.Fatal("onChange not called after second ejection time")
+	}
+	if cost := time.Since(start); cost < 80*time.Millisecond {
+		t.Errorf("second ejection lasted %v, want at least 80ms", cost)
+	}
+	if d.Ejected()[addr] {
+		t.Error("still ejected after second ejection time")
+	}
+}
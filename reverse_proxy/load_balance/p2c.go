@@ -0,0 +1,20 @@
+package load_balance
+
+import "math/rand"
+
+// p2cPick power of two choices：随机选取两个不同节点，返回负载较低者的下标；
+// 相比全量比较，避免所有请求同时涌向同一个"最空闲"节点
+func p2cPick(n int, load func(i int) float64) int {
+	if n <= 1 {
+		return 0
+	}
+	a := rand.Intn(n)
+	b := rand.Intn(n - 1)
+	if b >= a {
+		b++
+	}
+	if load(b) < load(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,27 @@
+package load_balance
+
+import "testing"
+
+func TestP2cPick(t *testing.T) {
+	if got := p2cPick(1, func(i int) float64 { return 0 }); got != 0 {
+		t.Errorf("single node pick = %d, want 0", got)
+	}
+	//两个节点时总是比较这两个，必然选负载低的
+	for i := 0; i < 100; i++ {
+		if got := p2cPick(2, func(i int) float64 { return float64(1 - i) }); got != 1 {
+			t.Fatalf("two node pick = %d, want 1", got)
+		}
+	}
+	//负载最高的节点永远不会被选中，负载最低的节点只要被抽中就会被选中
+	loads := []float64{1, 2, 3}
+	count := make([]int, len(loads))
+	for i := 0; i < 3000; i++ {
+		count[p2cPick(len(loads), func(i int) float64 { return loads[i] })]++
+	}
+	if count[2] != 0 {
+		t.Errorf("highest load node picked %d times", count[2])
+	}
+	if count[0] < 1800 || count[0] > 2200 {
+		t.Errorf("lowest load node picked %d times, want about 2000", count[0])
+	}
+}
@@ -0,0 +1,166 @@
+package load_balance
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	//延迟衰减时间常数，越大历史延迟影响越久
+	DefaultEwmaDecay = 10 * time.Second
+	//请求失败时按该延迟计入，使异常节点分数升高
+	DefaultEwmaErrorPenalty = time.Second
+)
+
+// PeakEwmaBalance 峰值 EWMA 延迟：延迟升高时立即取峰值，降低时按时间指数衰减；
+// 按 p2c 选取 延迟*(并发数+1)/权重 较小的节点
+type PeakEwmaBalance struct {
+	mux   sync.RWMutex
+	rss   []*PeakEwmaNode
+	stats map[string]*peakEwmaStat //节点统计，节点列表更新后保留
+	//观察主体
+	conf LoadBalanceConf
+}
+
+type PeakEwmaNode struct {
+	addr   string
+	weight int
+	stat   *peakEwmaStat
+}
+
+type peakEwmaStat struct {
+	mux        sync.Mutex
+	ewma       float64 //纳秒
+	lastUpdate time.Time
+	inflight   int64
+}
+
+func NewPeakEwmaBalance() *PeakEwmaBalance {
+	return &PeakEwmaBalance{
+		stats: map[string]*peakEwmaStat{},
+	}
+}
+
+func (r *PeakEwmaBalance) Add(params ...string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.addLocked(params...)
+}
+
+// addLocked 调用方需持有写锁
+func (r *PeakEwmaBalance) addLocked(params ...string) error {
+	if len(params) == 0 {
+		return errors.New("param len 1 at least")
+	}
+	weight := 1
+	if len(params) > 1 {
+		parInt, err := strconv.ParseInt(params[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		if parInt > 0 {
+			weight = int(parInt)
+		}
+	}
+	if r.stats == nil {
+		r.stats = map[string]*peakEwmaStat{}
+	}
+	stat, ok := r.stats[params[0]]
+	if !ok {
+		stat = &peakEwmaStat{}
+		r.stats[params[0]] = stat
+	}
+	r.rss = append(r.rss, &PeakEwmaNode{addr: params[0], weight: weight, stat: stat})
+	return nil
+}
+
+func (r *PeakEwmaBalance) Next() string {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	if len(r.rss) == 0 {
+		return ""
+	}
+	now := time.Now()
+	index := p2cPick(len(r.rss), func(i int) float64 {
+		node := r.rss[i]
+		return node.stat.score(now) / float64(node.weight)
+	})
+	return r.rss[index].addr
+}
+
+func (r *PeakEwmaBalance) Get(key string) (string, error) {
+	return r.Next(), nil
+}
+
+func (r *PeakEwmaBalance) getStat(addr string) *peakEwmaStat {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.stats[addr]
+}
+
+func (r *PeakEwmaBalance) OnRequestStart(addr string) {
+	if stat := r.getStat(addr); stat != nil {
+		stat.mux.Lock()
+		stat.inflight++
+		stat.mux.Unlock()
+	}
+}
+
+func (r *PeakEwmaBalance) OnRequestFinish(addr string, cost time.Duration, err error) {
+	stat := r.getStat(addr)
+	if stat == nil {
+		return
+	}
+	if err != nil && cost < DefaultEwmaErrorPenalty {
+		cost = DefaultEwmaErrorPenalty
+	}
+	stat.mux.Lock()
+	defer stat.mux.Unlock()
+	if stat.inflight > 0 {
+		stat.inflight--
+	}
+	stat.observe(float64(cost), time.Now())
+}
+
+// observe 调用方需持有 stat.mux
+func (s *peakEwmaStat) observe(rtt float64, now time.Time) {
+	if rtt > s.ewma {
+		s.ewma = rtt
+	} else {
+		w := math.Exp(-float64(now.Sub(s.lastUpdate)) / float64(DefaultEwmaDecay))
+		s.ewma = s.ewma*w + rtt*(1-w)
+	}
+	s.lastUpdate = now
+}
+
+// score 延迟*(并发数+1)，未有统计的节点仅按并发数比较
+func (s *peakEwmaStat) score(now time.Time) float64 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	ewma := s.ewma
+	if ewma > 0 {
+		//无新样本时向 0 衰减，避免节点因一次慢请求长期不被选中
+		ewma *= math.Exp(-float64(now.Sub(s.lastUpdate)) / float64(DefaultEwmaDecay))
+	}
+	return (ewma + 1) * float64(s.inflight+1)
+}
+
+func (r *PeakEwmaBalance) SetConf(conf LoadBalanceConf) {
+	r.conf = conf
+}
+
+func (r *PeakEwmaBalance) Update() {
+	if conf, ok := r.conf.(*LoadBalanceCheckConf); ok {
+		confList := conf.GetConf()
+		r.mux.Lock()
+		defer r.mux.Unlock()
+		r.rss = nil
+		for _, ip := range confList {
+			r.addLocked(strings.Split(ip, ",")...)
+		}
+	}
+}
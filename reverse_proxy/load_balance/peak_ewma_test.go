@@ -0,0 +1,96 @@
+package load_balance
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPeakEwmaStatObserve(t *testing.T) {
+	now := time.Now()
+	stat := &peakEwmaStat{}
+	stat.observe(float64(100*time.Millisecond), now)
+	if stat.ewma != float64(100*time.Millisecond) {
+		t.Fatalf("ewma = %v, want 100ms", time.Duration(stat.ewma))
+	}
+	//延迟升高时立即取峰值
+	stat.observe(float64(200*time.Millisecond), now)
+	if stat.ewma != float64(200*time.Millisecond) {
+		t.Fatalf("ewma = %v, want 200ms", time.Duration(stat.ewma))
+	}
+	//延迟降低时按间隔指数衰减，间隔一个时间常数时旧值权重为 1/e
+	stat.observe(float64(10*time.Millisecond), now.Add(DefaultEwmaDecay))
+	w := math.Exp(-1)
+	want := float64(200*time.Millisecond)*w + float64(10*time.Millisecond)*(1-w)
+	if math.Abs(stat.ewma-want) > 1 {
+		t.Errorf("ewma = %v, want %v", time.Duration(stat.ewma), time.Duration(want))
+	}
+}
+
+func TestPeakEwmaStatScoreDecay(t *testing.T) {
+	now := time.Now()
+	stat := &peakEwmaStat{}
+	stat.observe(float64(time.Second), now)
+	stat.inflight = 1
+	if got, want := stat.score(now), (float64(time.Second)+1)*2; got != want {
+		t.Errorf("score = %v, want %v", got, want)
+	}
+	//没有新样本时分数向 0 衰减
+	later := stat.score(now.Add(3 * DefaultEwmaDecay))
+	want := (float64(time.Second)*math.Exp(-3) + 1) * 2
+	if math.Abs(later-want) > 1 {
+		t.Errorf("decayed score = %v, want %v", later, want)
+	}
+	if stat.ewma != float64(time.Second) {
+		t.Errorf("score should not modify ewma, got %v", time.Duration(stat.ewma))
+	}
+}
+
+func TestPeakEwmaBalancePicksFasterNode(t *testing.T) {
+	rb := NewPeakEwmaBalance()
+	rb.Add("127.0.0.1:2001", "1")
+	rb.Add("127.0.0.1:2002", "1")
+	rb.OnRequestStart("127.0.0.1:2001")
+	rb.OnRequestFinish("127.0.0.1:2001", 500*time.Millisecond, nil)
+	rb.OnRequestStart("127.0.0.1:2002")
+	rb.OnRequestFinish("127.0.0.1:2002", time.Millisecond, nil)
+	for i := 0; i < 10; i++ {
+		if addr := rb.Next(); addr != "127.0.0.1:2002" {
+			t.Fatalf("Next() = %v, want 127.0.0.1:2002", addr)
+		}
+	}
+
+	//失败按惩罚延迟计入
+	rb.OnRequestStart("127.0.0.1:2002")
+	rb.OnRequestFinish("127.0.0.1:2002", time.Millisecond, errors.New("reset"))
+	if stat := rb.getStat("127.0.0.1:2002"); stat.ewma < float64(DefaultEwmaErrorPenalty) || stat.inflight != 0 {
+		t.Errorf("after error ewma = %v, inflight = %d", time.Duration(stat.ewma), stat.inflight)
+	}
+	if addr := rb.Next(); addr != "127.0.0.1:2001" {
+		t.Errorf("Next() = %v, want 127.0.0.1:2001", addr)
+	}
+}
+
+func TestPeakEwmaBalanceInflight(t *testing.T) {
+	rb := NewPeakEwmaBalance()
+	rb.Add("127.0.0.1:2001", "1")
+	rb.Add("127.0.0.1:2002", "1")
+	//延迟相同时并发数少的优先
+	for i := 0; i < 3; i++ {
+		rb.OnRequestStart("127.0.0.1:2001")
+	}
+	if addr := rb.Next(); addr != "127.0.0.1:2002" {
+		t.Errorf("Next() = %v, want 127.0.0.1:2002", addr)
+	}
+	for i := 0; i < 3; i++ {
+		rb.OnRequestFinish("127.0.0.1:2001", 0, nil)
+	}
+	//多余的结束回调不会使并发数小于 0
+	rb.OnRequestFinish("127.0.0.1:2001", 0, nil)
+	if stat := rb.getStat("127.0.0.1:2001"); stat.inflight != 0 {
+		t.Errorf("inflight = %d, want 0", stat.inflight)
+	}
+	rb.OnRequestStart("127.0.0.1:9999")
+	rb.OnRequestFinish("127.0.0.1:9999", time.Second, nil)
+}
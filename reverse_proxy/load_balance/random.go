@@ -5,6 +5,7 @@ import (
 	"github.com/pkg/errors"
 	"math/rand"
 	"strings"
+	"time"
 )
 
 type RandomBalance struct {
@@ -35,6 +36,10 @@ func (r *RandomBalance) Get(key string) (string, error) {
 	return r.Next(), nil
 }
 
+func (r *RandomBalance) OnRequestStart(addr string) {}
+
+func (r *RandomBalance) OnRequestFinish(addr string, cost time.Duration, err error) {}
+
 func (r *RandomBalance) SetConf(conf LoadBalanceConf) {
 	r.conf = conf
 }
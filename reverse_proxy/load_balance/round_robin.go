@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"strings"
+	"time"
 )
 
 type RoundRobinBalance struct {
@@ -39,6 +40,10 @@ func (r *RoundRobinBalance) Get(key string) (string, error) {
 	return r.Next(), nil
 }
 
+func (r *RoundRobinBalance) OnRequestStart(addr string) {}
+
+func (r *RoundRobinBalance) OnRequestFinish(addr string, cost time.Duration, err error) {}
+
 func (r *RoundRobinBalance) SetConf(conf LoadBalanceConf) {
 	r.conf = conf
 }
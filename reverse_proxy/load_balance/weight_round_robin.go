@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type WeightRoundRobinBalance struct {
+	mux      sync.Mutex
 	curIndex int
 	rss      []*WeightNode
 	rsw      []int
@@ -23,6 +26,13 @@ type WeightNode struct {
 }
 
 func (r *WeightRoundRobinBalance) Add(params ...string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.addLocked(params...)
+}
+
+// addLocked 调用方需持有锁
+func (r *WeightRoundRobinBalance) addLocked(params ...string) error {
 	if len(params) != 2 {
 		return errors.New("param len need 2")
 	}
@@ -37,6 +47,8 @@ func (r *WeightRoundRobinBalance) Add(params ...string) error {
 }
 
 func (r *WeightRoundRobinBalance) Next() string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
 	total := 0
 	var best *WeightNode
 	for i := 0; i < len(r.rss); i++ {
@@ -68,6 +80,30 @@ func (r *WeightRoundRobinBalance) Get(key string) (string, error) {
 	return r.Next(), nil
 }
 
+func (r *WeightRoundRobinBalance) OnRequestStart(addr string) {}
+
+// OnRequestFinish 通讯异常时降低节点有效权重，按连续失败阈值分摊，之后每轮选择+1逐步恢复
+func (r *WeightRoundRobinBalance) OnRequestFinish(addr string, cost time.Duration, err error) {
+	if err == nil {
+		return
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	for _, w := range r.rss {
+		if w.addr != addr {
+			continue
+		}
+		step := w.weight / DefaultOutlierConsecutiveErrors
+		if step < 1 {
+			step = 1
+		}
+		w.effectiveWeight -= step
+		if w.effectiveWeight < 0 {
+			w.effectiveWeight = 0
+		}
+	}
+}
+
 func (r *WeightRoundRobinBalance) SetConf(conf LoadBalanceConf) {
 	r.conf = conf
 }
@@ -81,10 +117,23 @@ func (r *WeightRoundRobinBalance) Update() {
 	//	}
 	//}
 	if conf, ok := r.conf.(*LoadBalanceCheckConf); ok {
-		fmt.Println("WeightRoundRobinBalance get check conf:", conf.GetConf())
+		confList := conf.GetConf()
+		fmt.Println("WeightRoundRobinBalance get check conf:", confList)
+		//保留仍在列表中节点的有效权重，避免节点变化时异常节点权重被重置
+		r.mux.Lock()
+		defer r.mux.Unlock()
+		effectiveWeight := map[string]int{}
+		for _, w := range r.rss {
+			effectiveWeight[w.addr] = w.effectiveWeight
+		}
 		r.rss = nil
-		for _, ip := range conf.GetConf() {
-			r.Add(strings.Split(ip, ",")...)
+		for _, ip := range confList {
+			r.addLocked(strings.Split(ip, ",")...)
+		}
+		for _, w := range r.rss {
+			if ew, ok := effectiveWeight[w.addr]; ok && ew < w.weight {
+				w.effectiveWeight = ew
+			}
 		}
 	}
 }
@@ -0,0 +1,65 @@
+package load_balance
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWeightRoundRobinBalance(t *testing.T) {
+	rb := &WeightRoundRobinBalance{}
+	rb.Add("127.0.0.1:2001", "4")
+	rb.Add("127.0.0.1:2002", "2")
+	rb.Add("127.0.0.1:2003", "1")
+
+	count := map[string]int{}
+	for i := 0; i < 70; i++ {
+		count[rb.Next()]++
+	}
+	if count["127.0.0.1:2001"] != 40 || count["127.0.0.1:2002"] != 20 || count["127.0.0.1:2003"] != 10 {
+		t.Errorf("unexpected distribution %v", count)
+	}
+}
+
+func TestWeightRoundRobinBalanceEffectiveWeight(t *testing.T) {
+	rb := &WeightRoundRobinBalance{}
+	rb.Add("127.0.0.1:2001", "20")
+	rb.Add("127.0.0.1:2002", "5")
+	node := rb.rss[0]
+
+	//失败时按权重分摊降低有效权重
+	rb.OnRequestFinish("127.0.0.1:2001", 0, errors.New("reset"))
+	if node.effectiveWeight != 16 {
+		t.Fatalf("effectiveWeight = %d, want 16", node.effectiveWeight)
+	}
+	//成功不影响有效权重
+	rb.OnRequestFinish("127.0.0.1:2001", 0, nil)
+	if node.effectiveWeight != 16 {
+		t.Fatalf("effectiveWeight = %d, want 16", node.effectiveWeight)
+	}
+	//有效权重不小于 0
+	for i := 0; i < 10; i++ {
+		rb.OnRequestFinish("127.0.0.1:2001", 0, errors.New("reset"))
+	}
+	if node.effectiveWeight != 0 {
+		t.Fatalf("effectiveWeight = %d, want 0", node.effectiveWeight)
+	}
+
+	//每轮选择恢复 1，直到恢复为权重
+	for i := 1; i <= 25; i++ {
+		rb.Next()
+		want := i
+		if want > 20 {
+			want = 20
+		}
+		if node.effectiveWeight != want {
+			t.Fatalf("after %d rounds effectiveWeight = %d, want %d", i, node.effectiveWeight, want)
+		}
+	}
+	count := map[string]int{}
+	for i := 0; i < 250; i++ {
+		count[rb.Next()]++
+	}
+	if count["127.0.0.1:2001"] != 200 || count["127.0.0.1:2002"] != 50 {
+		t.Errorf("unexpected distribution after recovery %v", count)
+	}
+}
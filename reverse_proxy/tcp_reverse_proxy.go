@@ -2,38 +2,46 @@ package reverse_proxy
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"go-gateway/reverse_proxy/load_balance"
 	"go-gateway/tcp_proxy_middleware"
+	"go-gateway/tcp_server"
 	"io"
 	"log"
 	"net"
 	"time"
 )
 
-func NewTcpLoadBalanceReverseProxy(c *tcp_proxy_middleware.TcpSliceRouterContext, lb load_balance.LoadBalance) *TcpReverseProxy {
-	return func() *TcpReverseProxy {
-		nextAddr, err := lb.Get("")
-		if err != nil {
-			log.Fatal("get next addr fail")
-		}
-		return &TcpReverseProxy{
-			ctx:             c.Ctx,
-			Addr:            nextAddr,
-			KeepAlivePeriod: time.Second,
-			DialTimeout:     time.Second,
-		}
-	}()
+// nextAddrMaxPick 重试时从负载均衡中挑选未尝试过节点的最大次数
+const nextAddrMaxPick = 5
+
+// NewTcpLoadBalanceReverseProxy 每个连接单独向负载均衡获取下游，拨号失败时换下一个节点，最多重试 maxDialRetry 次
+func NewTcpLoadBalanceReverseProxy(c *tcp_proxy_middleware.TcpSliceRouterContext, lb load_balance.LoadBalance, maxDialRetry int) *TcpReverseProxy {
+	return &TcpReverseProxy{
+		ctx:             c.Ctx,
+		LoadBalance:     lb,
+		MaxDialRetry:    maxDialRetry,
+		KeepAlivePeriod: time.Second,
+		DialTimeout:     time.Second,
+	}
 }
 
 // TCP反向代理
 type TcpReverseProxy struct {
 	ctx                  context.Context //单次请求单独设置
 	Addr                 string
-	KeepAlivePeriod      time.Duration //设置
-	DialTimeout          time.Duration //设置超时时间
+	LoadBalance          load_balance.LoadBalance //设置后每个连接通过负载均衡选择下游，忽略 Addr
+	MaxDialRetry         int                      //拨号失败或超时后换节点重试的次数
+	KeepAlivePeriod      time.Duration            //设置
+	DialTimeout          time.Duration            //设置超时时间
 	DialContext          func(ctx context.Context, network, address string) (net.Conn, error)
 	OnDialError          func(src net.Conn, dstDialErr error)
-	ProxyProtocolVersion int
+	ProxyProtocolVersion int         //大于0时建连后先向下游发送对应版本的 PROXY 协议头
+	TLSConfig            *tls.Config //设置后以 tls 连接下游
+
+	dialCost time.Duration //最近一次拨号耗时，连接结束时上报负载均衡
 }
 
 func (dp *TcpReverseProxy) dialTimeout() time.Duration {
@@ -64,21 +72,14 @@ func (dp *TcpReverseProxy) keepAlivePeriod() time.Duration {
 
 // 传入上游 conn，在这里完成下游连接与数据交换
 func (dp *TcpReverseProxy) ServeTCP(ctx context.Context, src net.Conn) {
-	//设置连接超时
-	var cancel context.CancelFunc
-	if dp.DialTimeout >= 0 {
-		ctx, cancel = context.WithTimeout(ctx, dp.dialTimeout())
-	}
-	dst, err := dp.dialContext()(ctx, "tcp", dp.Addr)
-	if cancel != nil {
-		cancel()
-	}
+	dst, err := dp.dialWithRetry(ctx, src)
 	if err != nil {
 		dp.onDialError()(src, err)
 		return
 	}
 
 	defer func() { go dst.Close() }() //记得退出下游连接
+	defer dp.onRequestFinish(dp.Addr, nil)
 
 	//设置dst的 keepAlive 参数,在数据请求之前
 	if ka := dp.keepAlivePeriod(); ka > 0 {
@@ -93,12 +94,124 @@ func (dp *TcpReverseProxy) ServeTCP(ctx context.Context, src net.Conn) {
 	<-errc
 }
 
+// dialWithRetry 依次拨号下游节点，失败节点本次连接内不再重复选择
+func (dp *TcpReverseProxy) dialWithRetry(ctx context.Context, src net.Conn) (net.Conn, error) {
+	var lastErr error
+	tried := map[string]bool{}
+	for retry := 0; retry <= dp.MaxDialRetry; retry++ {
+		addr, err := dp.nextAddr(src, retry, tried)
+		if err != nil {
+			if lastErr == nil {
+				lastErr = err
+			}
+			break
+		}
+		dst, err := dp.dial(ctx, src, addr)
+		if err == nil {
+			dp.Addr = addr
+			return dst, nil
+		}
+		dp.onRequestFinish(addr, err)
+		tried[addr] = true
+		lastErr = fmt.Errorf("dial %v: %v", addr, err)
+		if ctx.Err() != nil {
+			break
+		}
+		if retry < dp.MaxDialRetry {
+			log.Printf("tcpproxy: for incoming conn %v, error dialing %q: %v, try next", src.RemoteAddr().String(), addr, err)
+		}
+	}
+	return nil, lastErr
+}
+
+// nextAddr 选择下一个下游节点，优先返回本次连接未尝试过的节点
+func (dp *TcpReverseProxy) nextAddr(src net.Conn, retry int, tried map[string]bool) (string, error) {
+	if dp.LoadBalance == nil {
+		return dp.Addr, nil
+	}
+	key := src.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(key); err == nil {
+		key = host
+	}
+	addr := ""
+	for i := 0; i < nextAddrMaxPick; i++ {
+		//一致性hash对同一key总返回同一节点，重试时变换key
+		pickKey := key
+		if retry > 0 {
+			pickKey = fmt.Sprintf("%s#%d#%d", key, retry, i)
+		}
+		next, err := dp.LoadBalance.Get(pickKey)
+		if err != nil {
+			return "", err
+		}
+		addr = next
+		if !tried[addr] {
+			break
+		}
+	}
+	if addr == "" {
+		return "", errors.New("get next addr fail")
+	}
+	return addr, nil
+}
+
+func (dp *TcpReverseProxy) dial(ctx context.Context, src net.Conn, addr string) (net.Conn, error) {
+	dialStart := time.Now()
+	if dp.LoadBalance != nil {
+		dp.LoadBalance.OnRequestStart(addr)
+	}
+	//设置连接超时
+	if dp.DialTimeout >= 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, dp.dialTimeout())
+		defer cancel()
+	}
+	dst, err := dp.dialContext()(ctx, "tcp", addr)
+	//PROXY 协议头先于 tls 握手发送，下游据此获取客户端地址
+	if err == nil && dp.ProxyProtocolVersion > 0 {
+		if err = tcp_server.WriteProxyHeader(dst, dp.ProxyProtocolVersion, src.RemoteAddr(), src.LocalAddr()); err != nil {
+			dst.Close()
+			dst = nil
+		}
+	}
+	if err == nil && dp.TLSConfig != nil {
+		dst, err = dp.tlsHandshake(ctx, dst, addr)
+	}
+	dp.dialCost = time.Since(dialStart)
+	return dst, err
+}
+
+// tlsHandshake 与下游完成 tls 握手，未指定 ServerName 时取下游地址
+func (dp *TcpReverseProxy) tlsHandshake(ctx context.Context, conn net.Conn, addr string) (net.Conn, error) {
+	conf := dp.TLSConfig
+	if conf.ServerName == "" {
+		conf = conf.Clone()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			conf.ServerName = host
+		}
+	}
+	tlsConn := tls.Client(conn, conf)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// onRequestFinish 连接结束时回调负载均衡，耗时按建连时间统计
+func (dp *TcpReverseProxy) onRequestFinish(addr string, err error) {
+	if dp.LoadBalance == nil {
+		return
+	}
+	dp.LoadBalance.OnRequestFinish(addr, dp.dialCost, err)
+}
+
 func (dp *TcpReverseProxy) onDialError() func(src net.Conn, dstDialErr error) {
 	if dp.OnDialError != nil {
 		return dp.OnDialError
 	}
 	return func(src net.Conn, dstDialErr error) {
-		log.Printf("tcpproxy: for incoming conn %v, error dialing %q: %v", src.RemoteAddr().String(), dp.Addr, dstDialErr)
+		log.Printf("tcpproxy: for incoming conn %v, error dialing: %v", src.RemoteAddr().String(), dstDialErr)
 		src.Close()
 	}
 }
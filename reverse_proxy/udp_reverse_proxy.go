@@ -0,0 +1,121 @@
+package reverse_proxy
+
+import (
+	"context"
+	"errors"
+	"go-gateway/reverse_proxy/load_balance"
+	"go-gateway/udp_proxy_middleware"
+	"go-gateway/udp_server"
+	"log"
+	"net"
+	"time"
+)
+
+// NewUdpLoadBalanceReverseProxy 每个客户端会话单独向负载均衡获取下游，会话内的数据报固定转发到该下游
+func NewUdpLoadBalanceReverseProxy(c *udp_proxy_middleware.UdpSliceRouterContext, lb load_balance.LoadBalance) *UdpReverseProxy {
+	return &UdpReverseProxy{
+		ctx:         c.Ctx,
+		LoadBalance: lb,
+		DialTimeout: time.Second,
+	}
+}
+
+// UDP反向代理，单个实例对应一个客户端会话
+type UdpReverseProxy struct {
+	ctx         context.Context //单次会话单独设置
+	Addr        string
+	LoadBalance load_balance.LoadBalance //设置后通过负载均衡选择下游，忽略 Addr
+	DialTimeout time.Duration
+
+	dst      net.Conn
+	dialCost time.Duration //建连耗时，会话结束时上报负载均衡
+}
+
+// ServeUDP 会话的首个数据报时连接下游并启动回包协程，之后的数据报直接写入下游
+func (dp *UdpReverseProxy) ServeUDP(ctx context.Context, session *udp_server.Session, packet []byte) {
+	if dp.dst == nil {
+		if err := dp.dial(ctx, session); err != nil {
+			log.Printf("udpproxy: for incoming session %v, error dialing: %v", session.RemoteAddr().String(), err)
+			session.Close()
+			return
+		}
+	}
+	if _, err := dp.dst.Write(packet); err != nil {
+		log.Printf("udpproxy: for incoming session %v, error writing %v: %v", session.RemoteAddr().String(), dp.Addr, err)
+		session.Close()
+	}
+}
+
+func (dp *UdpReverseProxy) dial(ctx context.Context, session *udp_server.Session) error {
+	addr, err := dp.nextAddr(session)
+	if err != nil {
+		return err
+	}
+	dialCtx, cancel := context.WithTimeout(ctx, dp.DialTimeout)
+	defer cancel()
+	dialStart := time.Now()
+	if dp.LoadBalance != nil {
+		dp.LoadBalance.OnRequestStart(addr)
+	}
+	dst, err := (&net.Dialer{}).DialContext(dialCtx, "udp", addr)
+	dp.dialCost = time.Since(dialStart)
+	if err != nil {
+		dp.onRequestFinish(addr, err)
+		return err
+	}
+	dp.Addr = addr
+	dp.dst = dst
+	session.OnClose(func() {
+		dst.Close()
+	})
+	go dp.copyToClient(session)
+	return nil
+}
+
+// nextAddr 以客户端 ip 作为负载均衡的 key，一致性hash时同一客户端固定到同一下游
+func (dp *UdpReverseProxy) nextAddr(session *udp_server.Session) (string, error) {
+	if dp.LoadBalance == nil {
+		return dp.Addr, nil
+	}
+	key := session.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(key); err == nil {
+		key = host
+	}
+	addr, err := dp.LoadBalance.Get(key)
+	if err != nil {
+		return "", err
+	}
+	if addr == "" {
+		return "", errors.New("get next addr fail")
+	}
+	return addr, nil
+}
+
+// copyToClient 将下游回包写回客户端；下游端口不可达等错误时关闭会话并上报负载均衡
+func (dp *UdpReverseProxy) copyToClient(session *udp_server.Session) {
+	var readErr error
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := dp.dst.Read(buf)
+		if err != nil {
+			select {
+			case <-session.Done():
+			default:
+				readErr = err
+			}
+			break
+		}
+		if _, err := session.WriteToClient(buf[:n]); err != nil {
+			break
+		}
+	}
+	dp.onRequestFinish(dp.Addr, readErr)
+	session.Close()
+}
+
+func (dp *UdpReverseProxy) onRequestFinish(addr string, err error) {
+	if dp.LoadBalance == nil {
+		return
+	}
+	dp.LoadBalance.OnRequestFinish(addr, dp.dialCost, err)
+}
@@ -0,0 +1,507 @@
+package reverse_proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go-gateway/middleware"
+	"go-gateway/reverse_proxy/load_balance"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+
+	WsCloseGoingAway     = 1001 //空闲超时、超过最长存活时间、网关退出
+	WsCloseMessageTooBig = 1009 //消息超过大小限制
+
+	DefaultWsCloseGrace   = 5 * time.Second
+	DefaultWsWriteTimeout = 10 * time.Second
+	wsHandshakeTimeout    = 10 * time.Second
+
+	//控制帧负载最长 125 字节，关闭原因去掉 2 字节关闭码后最长 123 字节
+	wsMaxCloseReason = 123
+)
+
+var errWsMessageTooBig = errors.New("websocket message too big")
+
+// WebsocketConf websocket 连接配置
+type WebsocketConf struct {
+	IdleTimeout    time.Duration //双向均无数据超过该时间后关闭，0为不限制
+	MaxLifetime    time.Duration //连接最长存活时间，0为不限制
+	MaxMessageSize int64         //单条消息最大字节数，0为不限制
+	CloseGrace     time.Duration //发送关闭帧后等待对端断开的时间
+	WriteTimeout   time.Duration //单次写入超时，对端长时间不读取时断开连接
+	OnConnect      func()        //连接建立后回调，用于统计
+}
+
+// IsWebsocketRequest 是否为 websocket 升级请求
+func IsWebsocketRequest(req *http.Request) bool {
+	if !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range req.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WebsocketReverseProxy 完成握手后接管双向连接，按帧转发并执行超时及消息大小限制
+type WebsocketReverseProxy struct {
+	c     *gin.Context
+	lb    load_balance.LoadBalance
+	trans *http.Transport
+	conf  WebsocketConf
+}
+
+func NewWebsocketReverseProxy(c *gin.Context, lb load_balance.LoadBalance, trans *http.Transport, conf WebsocketConf) *WebsocketReverseProxy {
+	if conf.CloseGrace <= 0 {
+		conf.CloseGrace = DefaultWsCloseGrace
+	}
+	if conf.WriteTimeout <= 0 {
+		conf.WriteTimeout = DefaultWsWriteTimeout
+	}
+	return &WebsocketReverseProxy{c: c, lb: lb, trans: trans, conf: conf}
+}
+
+func (p *WebsocketReverseProxy) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	nextAddr, err := p.lb.Get(req.URL.String())
+	if err != nil || nextAddr == "" {
+		if err == nil {
+			err = errors.New("get next addr fail")
+		}
+		middleware.ResponseProxyError(p.c, 999, http.StatusBadGateway, err)
+		return
+	}
+	target, err := url.Parse(nextAddr)
+	if err != nil {
+		middleware.ResponseProxyError(p.c, 999, http.StatusBadGateway, err)
+		return
+	}
+
+	//握手耗时及结果回调负载均衡，连接时长不计入
+	tracker := &lbRequestTracker{lb: p.lb}
+	tracker.start(nextAddr)
+	backendConn, backendReader, resp, err := p.handshake(req, target)
+	if err != nil {
+		tracker.finish(err)
+		middleware.ResponseProxyError(p.c, 999, UpstreamErrorStatus(err), err)
+		return
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		//下游拒绝升级，原样返回响应
+		defer backendConn.Close()
+		defer resp.Body.Close()
+		var respErr error
+		if resp.StatusCode >= http.StatusInternalServerError {
+			respErr = fmt.Errorf("upstream status %d", resp.StatusCode)
+		}
+		tracker.finish(respErr)
+		for k, vv := range resp.Header {
+			for _, v := range vv {
+				rw.Header().Add(k, v)
+			}
+		}
+		rw.WriteHeader(resp.StatusCode)
+		io.Copy(rw, resp.Body)
+		return
+	}
+	tracker.finish(nil)
+
+	hijacker, ok := rw.(http.Hijacker)
+	if !ok {
+		backendConn.Close()
+		middleware.ResponseProxyError(p.c, 999, http.StatusInternalServerError, errors.New("websocket hijack not supported"))
+		return
+	}
+	clientConn, clientBuf, err := hijacker.Hijack()
+	if err != nil {
+		backendConn.Close()
+		return
+	}
+	//清除 http server 设置的读写超时，连接超时由 websocket 配置控制
+	clientConn.SetDeadline(time.Time{})
+	backendConn.SetDeadline(time.Time{})
+	if err := resp.Write(clientBuf); err != nil {
+		clientConn.Close()
+		backendConn.Close()
+		return
+	}
+	if err := clientBuf.Flush(); err != nil {
+		clientConn.Close()
+		backendConn.Close()
+		return
+	}
+	if p.conf.OnConnect != nil {
+		p.conf.OnConnect()
+	}
+	newWebsocketSession(p.conf,
+		&wsPeer{conn: clientConn, reader: clientBuf.Reader},
+		&wsPeer{conn: backendConn, reader: backendReader, masked: true},
+	).serve()
+}
+
+// handshake 连接下游并转发升级请求
+func (p *WebsocketReverseProxy) handshake(req *http.Request, target *url.URL) (net.Conn, *bufio.Reader, *http.Response, error) {
+	outreq := req.Clone(req.Context())
+	rewriteRequestURL(outreq, target)
+	if clientIP, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		if prior := outreq.Header.Get("X-Forwarded-For"); prior != "" {
+			clientIP = prior + ", " + clientIP
+		}
+		outreq.Header.Set("X-Forwarded-For", clientIP)
+	}
+
+	conn, err := p.dial(req.Context(), target)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	conn.SetDeadline(time.Now().Add(wsHandshakeTimeout))
+	if err := outreq.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, outreq)
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+	return conn, reader, resp, nil
+}
+
+func (p *WebsocketReverseProxy) dial(ctx context.Context, target *url.URL) (net.Conn, error) {
+	host := target.Host
+	if target.Port() == "" {
+		if target.Scheme == "https" || target.Scheme == "wss" {
+			host = net.JoinHostPort(target.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(target.Hostname(), "80")
+		}
+	}
+	dialer := &net.Dialer{Timeout: wsHandshakeTimeout}
+	dialContext := dialer.DialContext
+	if p.trans != nil && p.trans.DialContext != nil {
+		dialContext = p.trans.DialContext
+	}
+	conn, err := dialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	if target.Scheme != "https" && target.Scheme != "wss" {
+		return conn, nil
+	}
+	tlsConf := &tls.Config{}
+	if p.trans != nil && p.trans.TLSClientConfig != nil {
+		tlsConf = p.trans.TLSClientConfig.Clone()
+	}
+	if tlsConf.ServerName == "" {
+		tlsConf.ServerName = target.Hostname()
+	}
+	//下游握手只支持 http/1.1
+	tlsConf.NextProtos = nil
+	tlsConn := tls.Client(conn, tlsConf)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// wsPeer websocket 连接的一端，masked 表示网关向该端写帧时需要掩码(即网关作为客户端)
+type wsPeer struct {
+	conn     net.Conn
+	reader   *bufio.Reader
+	masked   bool
+	writeMux sync.Mutex
+}
+
+// writeClose 写入网关主动发起的关闭帧
+func (w *wsPeer) writeClose(code int, reason string, timeout time.Duration) error {
+	w.writeMux.Lock()
+	defer w.writeMux.Unlock()
+	w.conn.SetWriteDeadline(time.Now().Add(timeout))
+	_, err := w.conn.Write(newWsCloseFrame(code, reason, w.masked))
+	return err
+}
+
+// write 每次写入前设置写超时，对端长时间不读取时写入失败，避免转发协程一直阻塞
+func (w *wsPeer) write(b []byte, timeout time.Duration) (int, error) {
+	w.conn.SetWriteDeadline(time.Now().Add(timeout))
+	return w.conn.Write(b)
+}
+
+// wsPeerWriter 按次设置写超时的 io.Writer，用于转发帧负载
+type wsPeerWriter struct {
+	peer    *wsPeer
+	timeout time.Duration
+}
+
+func (w wsPeerWriter) Write(b []byte) (int, error) {
+	return w.peer.write(b, w.timeout)
+}
+
+// newWsCloseFrame 关闭帧，原因超过控制帧长度限制时按 utf-8 字符截断
+func newWsCloseFrame(code int, reason string, masked bool) []byte {
+	if len(reason) > wsMaxCloseReason {
+		cut := wsMaxCloseReason
+		for cut > 0 && !utf8.RuneStart(reason[cut]) {
+			cut--
+		}
+		reason = reason[:cut]
+	}
+	payload := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], reason)
+	frame := []byte{0x80 | wsOpClose, byte(len(payload))}
+	if masked {
+		//掩码值只需不可预测，关闭帧使用时间派生的掩码即可
+		mask := make([]byte, 4)
+		binary.BigEndian.PutUint32(mask, uint32(time.Now().UnixNano()))
+		frame[1] |= 0x80
+		frame = append(frame, mask...)
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return append(frame, payload...)
+}
+
+// websocketSession 已建立的 websocket 连接，帧头解析后原样转发
+type websocketSession struct {
+	conf       WebsocketConf
+	client     *wsPeer
+	backend    *wsPeer
+	lastActive int64 //最近一次收到数据的时间, unix nano
+
+	deadlineMux sync.Mutex
+	closing     bool
+	closeOnce   sync.Once
+}
+
+func newWebsocketSession(conf WebsocketConf, client, backend *wsPeer) *websocketSession {
+	return &websocketSession{
+		conf:       conf,
+		client:     client,
+		backend:    backend,
+		lastActive: time.Now().UnixNano(),
+	}
+}
+
+func (s *websocketSession) serve() {
+	websocketSessions.add(s)
+	defer websocketSessions.remove(s)
+	defer s.client.conn.Close()
+	defer s.backend.conn.Close()
+
+	if s.conf.MaxLifetime > 0 {
+		timer := time.AfterFunc(s.conf.MaxLifetime, func() {
+			s.closeWith(WsCloseGoingAway, "max lifetime exceeded")
+		})
+		defer timer.Stop()
+	}
+	errc := make(chan error, 2)
+	go func() { errc <- s.pump(s.client, s.backend) }()
+	go func() { errc <- s.pump(s.backend, s.client) }()
+	<-errc
+	//一端断开后给另一端留出转发剩余关闭帧的时间
+	s.beginClose()
+	<-errc
+}
+
+// pump 从 src 读帧写入 dst，网关已发起关闭后只读取不再转发
+func (s *websocketSession) pump(src, dst *wsPeer) error {
+	header := make([]byte, 14)
+	var msgSize int64
+	for {
+		if err := s.waitFrame(src); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(src.reader, header[:2]); err != nil {
+			return err
+		}
+		opcode := header[0] & 0x0f
+		length := int64(header[1] & 0x7f)
+		n := 2
+		switch length {
+		case 126:
+			if _, err := io.ReadFull(src.reader, header[n:n+2]); err != nil {
+				return err
+			}
+			length = int64(binary.BigEndian.Uint16(header[n:]))
+			n += 2
+		case 127:
+			if _, err := io.ReadFull(src.reader, header[n:n+8]); err != nil {
+				return err
+			}
+			length = int64(binary.BigEndian.Uint64(header[n:]))
+			n += 8
+		}
+		if header[1]&0x80 != 0 {
+			if _, err := io.ReadFull(src.reader, header[n:n+4]); err != nil {
+				return err
+			}
+			n += 4
+		}
+
+		//分片消息累计大小，控制帧不计入
+		switch opcode {
+		case wsOpText, wsOpBinary:
+			msgSize = length
+		case wsOpContinuation:
+			msgSize += length
+		}
+		if s.conf.MaxMessageSize > 0 && opcode < wsOpClose && msgSize > s.conf.MaxMessageSize {
+			s.closeWith(WsCloseMessageTooBig, "message too big")
+			return errWsMessageTooBig
+		}
+		atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+
+		if s.isClosing() {
+			if _, err := io.CopyN(io.Discard, src.reader, length); err != nil {
+				return err
+			}
+			continue
+		}
+		dst.writeMux.Lock()
+		_, err := dst.write(header[:n], s.conf.WriteTimeout)
+		if err == nil {
+			_, err = io.CopyN(wsPeerWriter{peer: dst, timeout: s.conf.WriteTimeout}, src.reader, length)
+		}
+		dst.writeMux.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// waitFrame 等待下一帧到达，双向均空闲超过 IdleTimeout 时关闭连接
+func (s *websocketSession) waitFrame(src *wsPeer) error {
+	for {
+		if s.conf.IdleTimeout > 0 {
+			lastActive := time.Unix(0, atomic.LoadInt64(&s.lastActive))
+			s.setReadDeadline(src, lastActive.Add(s.conf.IdleTimeout))
+		}
+		_, err := src.reader.Peek(1)
+		if err == nil {
+			s.setReadDeadline(src, time.Time{})
+			return nil
+		}
+		var netErr net.Error
+		if s.conf.IdleTimeout <= 0 || s.isClosing() || !errors.As(err, &netErr) || !netErr.Timeout() {
+			return err
+		}
+		//另一方向仍有数据时继续等待
+		if time.Since(time.Unix(0, atomic.LoadInt64(&s.lastActive))) >= s.conf.IdleTimeout {
+			s.closeWith(WsCloseGoingAway, "idle timeout")
+		}
+	}
+}
+
+func (s *websocketSession) setReadDeadline(peer *wsPeer, t time.Time) {
+	s.deadlineMux.Lock()
+	defer s.deadlineMux.Unlock()
+	if !s.closing {
+		peer.conn.SetReadDeadline(t)
+	}
+}
+
+func (s *websocketSession) isClosing() bool {
+	s.deadlineMux.Lock()
+	defer s.deadlineMux.Unlock()
+	return s.closing
+}
+
+// beginClose 进入关闭流程，CloseGrace 后强制断开两端
+func (s *websocketSession) beginClose() {
+	s.deadlineMux.Lock()
+	defer s.deadlineMux.Unlock()
+	if s.closing {
+		return
+	}
+	s.closing = true
+	deadline := time.Now().Add(s.conf.CloseGrace)
+	s.client.conn.SetDeadline(deadline)
+	s.backend.conn.SetDeadline(deadline)
+}
+
+// closeWith 网关主动关闭，向两端发送关闭帧
+func (s *websocketSession) closeWith(code int, reason string) {
+	s.closeOnce.Do(func() {
+		s.client.writeClose(code, reason, s.conf.CloseGrace)
+		s.backend.writeClose(code, reason, s.conf.CloseGrace)
+		s.beginClose()
+	})
+}
+
+// websocketSessions 当前活跃的 websocket 连接，网关退出时统一关闭
+var websocketSessions = &websocketSessionSet{sessions: map[*websocketSession]struct{}{}}
+
+type websocketSessionSet struct {
+	mux      sync.Mutex
+	sessions map[*websocketSession]struct{}
+	wg       sync.WaitGroup
+}
+
+func (m *websocketSessionSet) add(s *websocketSession) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.sessions[s] = struct{}{}
+	m.wg.Add(1)
+}
+
+func (m *websocketSessionSet) remove(s *websocketSession) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if _, ok := m.sessions[s]; ok {
+		delete(m.sessions, s)
+		m.wg.Done()
+	}
+}
+
+// CloseWebsocketSessions 网关退出时向所有 websocket 连接发送 1001 关闭帧
+func CloseWebsocketSessions() {
+	websocketSessions.mux.Lock()
+	sessions := make([]*websocketSession, 0, len(websocketSessions.sessions))
+	for s := range websocketSessions.sessions {
+		sessions = append(sessions, s)
+	}
+	websocketSessions.mux.Unlock()
+	for _, s := range sessions {
+		go s.closeWith(WsCloseGoingAway, "gateway shutdown")
+	}
+}
+
+// WaitWebsocketSessions 等待 websocket 连接全部断开，ctx 结束时返回其错误
+func WaitWebsocketSessions(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		websocketSessions.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
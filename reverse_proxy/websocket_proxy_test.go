@@ -0,0 +1,186 @@
+package reverse_proxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// newTestWsFrame 构造单帧，masked 时使用固定掩码
+func newTestWsFrame(opcode byte, fin bool, payload []byte, masked bool) []byte {
+	first := opcode
+	if fin {
+		first |= 0x80
+	}
+	frame := []byte{first}
+	maskBit := byte(0)
+	if masked {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	default:
+		frame = append(frame, maskBit|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(payload)))
+	}
+	body := append([]byte{}, payload...)
+	if masked {
+		mask := []byte{1, 2, 3, 4}
+		frame = append(frame, mask...)
+		for i := range body {
+			body[i] ^= mask[i%4]
+		}
+	}
+	return append(frame, body...)
+}
+
+type testWsSession struct {
+	clientApp  net.Conn //客户端一侧
+	backendApp net.Conn //下游一侧
+	done       chan struct{}
+}
+
+func newTestWsSession(conf WebsocketConf) *testWsSession {
+	clientApp, clientGw := net.Pipe()
+	backendApp, backendGw := net.Pipe()
+	if conf.CloseGrace <= 0 {
+		conf.CloseGrace = time.Second
+	}
+	if conf.WriteTimeout <= 0 {
+		conf.WriteTimeout = time.Second
+	}
+	s := newWebsocketSession(conf,
+		&wsPeer{conn: clientGw, reader: bufio.NewReader(clientGw)},
+		&wsPeer{conn: backendGw, reader: bufio.NewReader(backendGw), masked: true},
+	)
+	ts := &testWsSession{clientApp: clientApp, backendApp: backendApp, done: make(chan struct{})}
+	go func() {
+		s.serve()
+		close(ts.done)
+	}()
+	return ts
+}
+
+func (ts *testWsSession) close(t *testing.T) {
+	ts.clientApp.Close()
+	ts.backendApp.Close()
+	select {
+	case <-ts.done:
+	case <-time.After(3 * time.Second):
+		t.Fatal("websocket session not closed")
+	}
+}
+
+// readTestCloseCode 读取关闭帧并返回关闭码
+func readTestCloseCode(t *testing.T, conn net.Conn) int {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		t.Fatal(err)
+	}
+	if header[0] != 0x80|wsOpClose {
+		t.Fatalf("frame header = %#x, want close frame", header[0])
+	}
+	mask := []byte{0, 0, 0, 0}
+	if header[1]&0x80 != 0 {
+		if _, err := io.ReadFull(conn, mask); err != nil {
+			t.Fatal(err)
+		}
+	}
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		t.Fatal(err)
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+func TestWebsocketForwardFrames(t *testing.T) {
+	ts := newTestWsSession(WebsocketConf{})
+	defer ts.close(t)
+	for _, size := range []int{0, 125, 126, 300, 70000} {
+		frame := newTestWsFrame(wsOpBinary, true, bytes.Repeat([]byte{'x'}, size), true)
+		go ts.clientApp.Write(frame)
+		got := make([]byte, len(frame))
+		ts.backendApp.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := io.ReadFull(ts.backendApp, got); err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, frame) {
+			t.Fatalf("size %d: forwarded frame differs", size)
+		}
+	}
+	frame := newTestWsFrame(wsOpText, true, []byte("hello"), false)
+	go ts.backendApp.Write(frame)
+	got := make([]byte, len(frame))
+	ts.clientApp.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(ts.clientApp, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, frame) {
+		t.Fatal("backend frame differs")
+	}
+}
+
+func TestWebsocketMaxMessageSize(t *testing.T) {
+	ts := newTestWsSession(WebsocketConf{MaxMessageSize: 100})
+	defer ts.close(t)
+	first := newTestWsFrame(wsOpText, false, bytes.Repeat([]byte{'a'}, 60), true)
+	go ts.clientApp.Write(first)
+	ts.backendApp.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(ts.backendApp, make([]byte, len(first))); err != nil {
+		t.Fatal(err)
+	}
+	//分片累计超过限制
+	go ts.clientApp.Write(newTestWsFrame(wsOpContinuation, true, bytes.Repeat([]byte{'b'}, 60), true))
+	if code := readTestCloseCode(t, ts.clientApp); code != WsCloseMessageTooBig {
+		t.Errorf("client close code = %d, want %d", code, WsCloseMessageTooBig)
+	}
+	if code := readTestCloseCode(t, ts.backendApp); code != WsCloseMessageTooBig {
+		t.Errorf("backend close code = %d, want %d", code, WsCloseMessageTooBig)
+	}
+}
+
+func TestWebsocketWriteTimeout(t *testing.T) {
+	ts := newTestWsSession(WebsocketConf{WriteTimeout: 100 * time.Millisecond, CloseGrace: 100 * time.Millisecond})
+	go ts.clientApp.Write(newTestWsFrame(wsOpBinary, true, []byte("stalled"), true))
+	//下游不读取，写超时后两个转发协程都应退出
+	select {
+	case <-ts.done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("session still blocked on stalled peer")
+	}
+	ts.close(t)
+}
+
+func TestNewWsCloseFrame(t *testing.T) {
+	reason := "a" + strings.Repeat("网", 60)
+	frame := newWsCloseFrame(WsCloseGoingAway, reason, false)
+	length := int(frame[1])
+	if length > 125 || length != len(frame)-2 {
+		t.Fatalf("close payload length = %d, frame length = %d", length, len(frame))
+	}
+	if !utf8.Valid(frame[4:]) || !strings.HasPrefix(reason, string(frame[4:])) {
+		t.Errorf("truncated reason %q is not a utf-8 prefix", frame[4:])
+	}
+	if code := binary.BigEndian.Uint16(frame[2:]); code != WsCloseGoingAway {
+		t.Errorf("close code = %d", code)
+	}
+
+	masked := newWsCloseFrame(WsCloseGoingAway, "bye", true)
+	if masked[1] != 0x80|5 || len(masked) != 2+4+5 {
+		t.Errorf("masked close frame = %v", masked)
+	}
+}
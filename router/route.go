@@ -123,6 +123,17 @@ func InitRouter(middlewares ...gin.HandlerFunc) *gin.Engine {
 		controller.APPRegister(appRouter)
 	}
 
+	certRouter := router.Group("/cert")
+	certRouter.Use(
+		sessions.Sessions("mysession", store),
+		middleware.RecoveryMiddleware(),
+		middleware.RequestLog(),
+		middleware.SessionAuthMiddleware(),
+		middleware.TranslationMiddleware())
+	{
+		controller.CertRegister(certRouter)
+	}
+
 	dashRouter := router.Group("/dashboard")
 	dashRouter.Use(
 		sessions.Sessions("mysession", store),
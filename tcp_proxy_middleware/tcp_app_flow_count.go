@@ -0,0 +1,33 @@
+package tcp_proxy_middleware
+
+import (
+	"fmt"
+	"go-gateway/dao"
+	"go-gateway/public"
+)
+
+// TCPAppFlowCountMiddleware 客户端证书映射到租户时，按租户统计连接数并做日请求量限制
+func TCPAppFlowCountMiddleware() func(c *TcpSliceRouterContext) {
+	return func(c *TcpSliceRouterContext) {
+		appInterface := c.Get("app")
+		if appInterface == nil {
+			c.Next()
+			return
+		}
+		appInfo := appInterface.(*dao.App)
+
+		appCounter, err := public.FlowCounterHandler.GetCounter(public.FlowAppPrefix + appInfo.AppID)
+		if err != nil {
+			c.conn.Write([]byte(err.Error()))
+			c.Abort()
+			return
+		}
+		appCounter.Increase()
+		if appInfo.Qpd > 0 && appCounter.TotalCount > appInfo.Qpd {
+			c.conn.Write([]byte(fmt.Sprintf("租户日请求量限流 limit:%v current:%v", appInfo.Qpd, appCounter.TotalCount)))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
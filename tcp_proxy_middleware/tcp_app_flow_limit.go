@@ -0,0 +1,42 @@
+package tcp_proxy_middleware
+
+import (
+	"fmt"
+	"go-gateway/dao"
+	"go-gateway/public"
+	"strings"
+)
+
+// TCPAppFlowLimitMiddleware 客户端证书映射到租户时，按 “AppID + ClientIP” 维度限制每秒新建连接数
+func TCPAppFlowLimitMiddleware() func(c *TcpSliceRouterContext) {
+	return func(c *TcpSliceRouterContext) {
+		appInterface := c.Get("app")
+		if appInterface == nil {
+			c.Next()
+			return
+		}
+		appInfo := appInterface.(*dao.App)
+
+		splits := strings.Split(c.conn.RemoteAddr().String(), ":")
+		clientIP := ""
+		if len(splits) == 2 {
+			clientIP = splits[0]
+		}
+		if appInfo.Qps > 0 {
+			clientLimiter, err := public.FlowLimiterHandler.GetLimiter(
+				public.FlowAppPrefix+appInfo.AppID+"_"+clientIP,
+				float64(appInfo.Qps))
+			if err != nil {
+				c.conn.Write([]byte(err.Error()))
+				c.Abort()
+				return
+			}
+			if !clientLimiter.Allow() {
+				c.conn.Write([]byte(fmt.Sprintf("%v flow limit %v", clientIP, appInfo.Qps)))
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
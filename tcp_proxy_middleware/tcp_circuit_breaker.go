@@ -0,0 +1,27 @@
+package tcp_proxy_middleware
+
+import (
+	"go-gateway/dao"
+)
+
+// TCPCircuitBreakerMiddleware 服务熔断时写回降级内容并关闭连接
+func TCPCircuitBreakerMiddleware() func(c *TcpSliceRouterContext) {
+	return func(c *TcpSliceRouterContext) {
+		serverInterface := c.Get("service")
+		if serverInterface == nil {
+			c.conn.Write([]byte("get service empty"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		breaker := serviceDetail.GetServiceBreaker()
+		if breaker != nil && breaker.Blocked() {
+			_, body := serviceDetail.CircuitBreaker.GetFallback()
+			c.conn.Write([]byte(body))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,61 @@
+package tcp_proxy_middleware
+
+import (
+	"context"
+	"crypto/tls"
+	"go-gateway/dao"
+	"time"
+)
+
+// tlsHandshakeTimeout 客户端 tls 握手的最长时间
+const tlsHandshakeTimeout = 10 * time.Second
+
+// TCPClientCertAuthMiddleware 客户端证书认证
+// 服务开启客户端证书认证时监听端使用 tls 并要求客户端证书，这里完成握手后将证书映射为租户，
+// 之后与 http/grpc 的 jwt 租户一样参与统计及限流；开启权限时证书必须映射到租户
+func TCPClientCertAuthMiddleware() func(c *TcpSliceRouterContext) {
+	return func(c *TcpSliceRouterContext) {
+		serverInterface := c.Get("service")
+		if serverInterface == nil {
+			c.conn.Write([]byte("get service empty"))
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		tlsConn, ok := c.conn.(*tls.Conn)
+		if !ok {
+			if serviceDetail.AccessControl.ClientCertAuth == 1 {
+				c.conn.Write([]byte("client certificate required"))
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Ctx, tlsHandshakeTimeout)
+		err := tlsConn.HandshakeContext(ctx)
+		cancel()
+		if err != nil {
+			c.Abort()
+			return
+		}
+		state := tlsConn.ConnectionState()
+		clientCert := dao.GetVerifiedClientCert(&state)
+		if clientCert == nil && serviceDetail.AccessControl.ClientCertAuth == 1 {
+			c.conn.Write([]byte("client certificate required"))
+			c.Abort()
+			return
+		}
+		appInfo, ok := dao.AppManagerHandler.GetAppByCert(clientCert)
+		if !ok && serviceDetail.AccessControl.ClientCertAuth == 1 && serviceDetail.AccessControl.OpenAuth == 1 {
+			c.conn.Write([]byte("not match valid app"))
+			c.Abort()
+			return
+		}
+		if ok {
+			c.Set("app", appInfo)
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,147 @@
+package tcp_proxy_router
+
+import (
+	"context"
+	"crypto/tls"
+	"go-gateway/common/lib"
+	"go-gateway/dao"
+	"go-gateway/public"
+	"go-gateway/tcp_server"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sniPeekTimeout      = 10 * time.Second //读取客户端 ClientHello 的最长时间
+	sniHandshakeTimeout = 10 * time.Second //网关终止 tls 时客户端握手的最长时间
+)
+
+var (
+	sniRouteMap    = map[string]*sniRoute{} //服务名 -> 路由
+	sniNameMap     = map[string]*sniRoute{} //sni 域名 -> 路由
+	sniRouteLocker sync.RWMutex
+	sniServer      *tcp_server.TcpServer
+)
+
+// sniRoute 共享 tls 端口上按 sni 域名匹配到的服务，serviceDetail 为构建时使用的配置快照
+type sniRoute struct {
+	serviceDetail *dao.ServiceDetail
+	handler       tcp_server.TCPHandler
+	tlsConfig     *tls.Config //不为空时在网关终止 tls，否则原样透传给下游
+}
+
+// syncSniRoute 按当前 tcp 服务列表重建 sni 路由，配置未变化的服务沿用原路由
+func syncSniRoute(serviceMap map[string]*dao.ServiceDetail) {
+	sniRouteLocker.RLock()
+	oldRouteMap := sniRouteMap
+	sniRouteLocker.RUnlock()
+
+	routeMap := map[string]*sniRoute{}
+	nameMap := map[string]*sniRoute{}
+	for serviceName, serviceDetail := range serviceMap {
+		if len(serviceDetail.TCPRule.GetSniNameList()) == 0 {
+			continue
+		}
+		route, ok := oldRouteMap[serviceName]
+		if !ok || public.Obj2Json(route.serviceDetail) != public.Obj2Json(serviceDetail) {
+			handler, tlsConfig, err := newTcpServiceHandler(serviceDetail)
+			if err != nil {
+				log.Printf(" [ERROR] tcp_sni_route %v err:%v\n", serviceName, err)
+				continue
+			}
+			route = &sniRoute{
+				serviceDetail: serviceDetail,
+				handler:       handler,
+				tlsConfig:     tlsConfig,
+			}
+		}
+		routeMap[serviceName] = route
+		for _, name := range serviceDetail.TCPRule.GetSniNameList() {
+			if exists, ok := nameMap[name]; ok {
+				log.Printf(" [ERROR] tcp_sni_route %v conflict between %v and %v\n", name, exists.serviceDetail.Info.ServiceName, serviceName)
+				continue
+			}
+			nameMap[name] = route
+		}
+	}
+
+	sniRouteLocker.Lock()
+	defer sniRouteLocker.Unlock()
+	sniRouteMap = routeMap
+	sniNameMap = nameMap
+}
+
+// matchSniRoute 按 sni 域名查找服务，先精确匹配，再匹配 *. 通配符
+func matchSniRoute(serverName string) *sniRoute {
+	serverName = strings.ToLower(strings.TrimSuffix(serverName, "."))
+	sniRouteLocker.RLock()
+	defer sniRouteLocker.RUnlock()
+	if route, ok := sniNameMap[serverName]; ok {
+		return route
+	}
+	if index := strings.Index(serverName, "."); index > 0 {
+		if route, ok := sniNameMap["*"+serverName[index:]]; ok {
+			return route
+		}
+	}
+	return nil
+}
+
+// sniHandler 读取 ClientHello 中的 sni 域名后交给对应服务的中间件链处理
+type sniHandler struct{}
+
+func (h *sniHandler) ServeTCP(ctx context.Context, conn net.Conn) {
+	serverName, peekConn, err := tcp_server.PeekServerName(conn, sniPeekTimeout)
+	if err != nil {
+		log.Printf(" [ERROR] tcp_sni_proxy %v err:%v\n", conn.RemoteAddr(), err)
+		return
+	}
+	route := matchSniRoute(serverName)
+	if route == nil {
+		log.Printf(" [ERROR] tcp_sni_proxy %v no service for server name %v\n", conn.RemoteAddr(), serverName)
+		return
+	}
+	if route.tlsConfig != nil {
+		//握手在首次读取时才进行且没有超时，这里先完成握手，避免客户端不握手一直占用连接
+		tlsConn := tls.Server(peekConn, route.tlsConfig)
+		handshakeCtx, cancel := context.WithTimeout(ctx, sniHandshakeTimeout)
+		err := tlsConn.HandshakeContext(handshakeCtx)
+		cancel()
+		if err != nil {
+			log.Printf(" [ERROR] tcp_sni_proxy %v tls handshake err:%v\n", conn.RemoteAddr(), err)
+			return
+		}
+		peekConn = tlsConn
+	}
+	route.handler.ServeTCP(context.WithValue(ctx, "service", route.serviceDetail), peekConn)
+}
+
+// tcpSniServerRun 开启共享 tls 端口，未配置 proxy.tcp.sni_addr 时不开启
+func tcpSniServerRun() {
+	addr := lib.GetStringConf("proxy.tcp.sni_addr")
+	if addr == "" {
+		return
+	}
+	sniServer = &tcp_server.TcpServer{
+		Addr:       addr,
+		Handler:    &sniHandler{},
+		ProxyProto: public.GetProxyProtoConfig("tcp"),
+	}
+	go func() {
+		log.Printf(" [INFO] tcp_sni_proxy_run %v\n", addr)
+		if err := sniServer.ListenAndServe(); err != nil && err != tcp_server.ErrServerClosed {
+			log.Printf(" [ERROR] tcp_sni_proxy_run %v err:%v\n", addr, err)
+		}
+	}()
+}
+
+func tcpSniServerStop() {
+	if sniServer == nil {
+		return
+	}
+	sniServer.Close()
+	log.Printf(" [INFO] tcp_sni_proxy_stop %v stopped\n", sniServer.Addr)
+}
@@ -0,0 +1,120 @@
+package tcp_proxy_router
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"go-gateway/dao"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// setTestSniRoutes 直接设置 sni 域名路由，返回后恢复
+func setTestSniRoutes(t *testing.T, nameMap map[string]*sniRoute) {
+	sniRouteLocker.Lock()
+	oldNameMap := sniNameMap
+	sniNameMap = nameMap
+	sniRouteLocker.Unlock()
+	t.Cleanup(func() {
+		sniRouteLocker.Lock()
+		sniNameMap = oldNameMap
+		sniRouteLocker.Unlock()
+	})
+}
+
+func newTestSniRoute(serviceName string) *sniRoute {
+	return &sniRoute{serviceDetail: &dao.ServiceDetail{Info: &dao.ServiceInfo{ServiceName: serviceName}}}
+}
+
+func TestMatchSniRoute(t *testing.T) {
+	exact := newTestSniRoute("exact")
+	wildcard := newTestSniRoute("wildcard")
+	setTestSniRoutes(t, map[string]*sniRoute{
+		"api.example.com": exact,
+		"*.example.com":   wildcard,
+	})
+	tests := []struct {
+		serverName string
+		want       *sniRoute
+	}{
+		{"api.example.com", exact},
+		{"API.Example.com.", exact},
+		{"web.example.com", wildcard},
+		{"a.b.example.com", nil}, //通配符只匹配一级
+		{"example.com", nil},
+		{"api.example.org", nil},
+	}
+	for _, test := range tests {
+		if got := matchSniRoute(test.serverName); got != test.want {
+			t.Errorf("matchSniRoute(%q) = %v, want %v", test.serverName, got, test.want)
+		}
+	}
+}
+
+type testTCPHandler struct {
+	conns chan net.Conn
+}
+
+func (h *testTCPHandler) ServeTCP(ctx context.Context, conn net.Conn) {
+	h.conns <- conn
+}
+
+func TestSniHandlerTerminatesTls(t *testing.T) {
+	handler := &testTCPHandler{conns: make(chan net.Conn, 1)}
+	route := newTestSniRoute("tls")
+	route.handler = handler
+	//客户端不校验证书，使用临时生成的自签名证书
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), DNSNames: []string{"tls.example.com"}, NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route.tlsConfig = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	setTestSniRoutes(t, map[string]*sniRoute{"tls.example.com": route})
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	go tls.Client(clientConn, &tls.Config{ServerName: "tls.example.com", InsecureSkipVerify: true}).Handshake()
+	go (&sniHandler{}).ServeTCP(context.Background(), serverConn)
+
+	select {
+	case conn := <-handler.conns:
+		tlsConn, ok := conn.(*tls.Conn)
+		if !ok {
+			t.Fatalf("conn type %T, want *tls.Conn", conn)
+		}
+		if !tlsConn.ConnectionState().HandshakeComplete {
+			t.Error("handshake not completed before handing off to service")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler not called")
+	}
+}
+
+func TestSniHandlerUnknownServerName(t *testing.T) {
+	handler := &testTCPHandler{conns: make(chan net.Conn, 1)}
+	route := newTestSniRoute("passthrough")
+	route.handler = handler
+	setTestSniRoutes(t, map[string]*sniRoute{"known.example.com": route})
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go tls.Client(clientConn, &tls.Config{ServerName: "unknown.example.com", InsecureSkipVerify: true}).Handshake()
+	(&sniHandler{}).ServeTCP(context.Background(), serverConn)
+
+	select {
+	case <-handler.conns:
+		t.Fatal("unknown server name routed to service")
+	default:
+	}
+}
@@ -2,16 +2,33 @@ package tcp_proxy_router
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"go-gateway/common/lib"
 	"go-gateway/dao"
+	"go-gateway/public"
 	"go-gateway/reverse_proxy"
 	"go-gateway/tcp_proxy_middleware"
 	"go-gateway/tcp_server"
 	"log"
 	"net"
+	"sync"
+	"time"
 )
 
-var tcpServerList = []*tcp_server.TcpServer{}
+// defaultDrainTimeout 服务下线或重启时，等待存量连接结束的最长时间
+const defaultDrainTimeout = 30
+
+var (
+	tcpServerMap    = map[string]*tcpServerItem{}
+	tcpServerLocker sync.Mutex
+)
+
+// tcpServerItem 正在运行的 tcp 服务监听，serviceDetail 为启动时使用的配置快照
+type tcpServerItem struct {
+	serviceDetail *dao.ServiceDetail
+	server        *tcp_server.TcpServer
+}
 
 type tcpHandler struct {
 }
@@ -20,51 +37,168 @@ func (t *tcpHandler) ServeTCP(ctx context.Context, src net.Conn) {
 	src.Write([]byte("tcpHandler\n"))
 }
 
+// tcpServerSupervisor 服务配置热加载后对比并调整 tcp 监听
+type tcpServerSupervisor struct{}
+
+func (t *tcpServerSupervisor) Update() {
+	TcpServerSync()
+}
+
 func TcpServerRun() {
-	serviceList := dao.ServiceManagerHandler.GetTcpServiceList()
-	for _, serviceItem := range serviceList {
-		tempItem := serviceItem
-		go func(serviceDetail *dao.ServiceDetail) {
-			addr := fmt.Sprintf(":%d", serviceDetail.TCPRule.Port)
-			rb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
-			if err != nil {
-				log.Fatalf(" [INFO] GetTcpLoadBalancer %v err:%v\n", addr, err)
-				return
-			}
+	TcpServerSync()
+	tcpSniServerRun()
+	dao.ServiceManagerHandler.Attach(&tcpServerSupervisor{})
+}
 
-			//构建路由及设置中间件
-			router := tcp_proxy_middleware.NewTcpSliceRouter()
-			router.Group("/").Use(
-				tcp_proxy_middleware.TCPFlowCountMiddleware(),
-				tcp_proxy_middleware.TCPFlowLimitMiddleware(),
-				tcp_proxy_middleware.TCPWhiteListMiddleware(),
-				tcp_proxy_middleware.TCPBlackListMiddleware(),
-			)
-
-			//构建回调handler
-			routerHandler := tcp_proxy_middleware.NewTcpSliceRouterHandler(
-				func(c *tcp_proxy_middleware.TcpSliceRouterContext) tcp_server.TCPHandler {
-					return reverse_proxy.NewTcpLoadBalanceReverseProxy(c, rb)
-				}, router)
-
-			baseCtx := context.WithValue(context.Background(), "service", serviceDetail)
-			tcpServer := &tcp_server.TcpServer{
-				Addr:    addr,
-				Handler: routerHandler,
-				BaseCtx: baseCtx,
+// TcpServerSync 对比运行中的监听与当前 tcp 服务列表：
+// 新增服务开启监听，删除服务排空后关闭，配置或端口变更的服务重新绑定，其余服务不受影响；
+// 同时更新共享 sni 端口的路由
+func TcpServerSync() {
+	tcpServerLocker.Lock()
+	defer tcpServerLocker.Unlock()
+
+	serviceMap := map[string]*dao.ServiceDetail{}
+	for _, serviceItem := range dao.ServiceManagerHandler.GetTcpServiceList() {
+		serviceMap[serviceItem.Info.ServiceName] = serviceItem
+	}
+
+	for serviceName, serverItem := range tcpServerMap {
+		serviceDetail, ok := serviceMap[serviceName]
+		if ok && public.Obj2Json(serviceDetail) == public.Obj2Json(serverItem.serviceDetail) {
+			continue
+		}
+		delete(tcpServerMap, serviceName)
+		drainTcpServer(serverItem.server)
+	}
+
+	for serviceName, serviceDetail := range serviceMap {
+		if _, ok := tcpServerMap[serviceName]; ok {
+			continue
+		}
+		//只通过共享 sni 端口接入的服务不单独监听
+		if serviceDetail.TCPRule.Port == 0 {
+			continue
+		}
+		if tcpServer := startTcpServer(serviceDetail); tcpServer != nil {
+			tcpServerMap[serviceName] = &tcpServerItem{
+				serviceDetail: serviceDetail,
+				server:        tcpServer,
 			}
-			tcpServerList = append(tcpServerList, tcpServer)
-			log.Printf(" [INFO] tcp_proxy_run %v\n", addr)
-			if err := tcpServer.ListenAndServe(); err != nil && err != tcp_server.ErrServerClosed {
-				log.Fatalf(" [INFO] tcp_proxy_run %v err:%v\n", addr, err)
+		}
+	}
+	syncSniRoute(serviceMap)
+}
+
+func startTcpServer(serviceDetail *dao.ServiceDetail) *tcp_server.TcpServer {
+	addr := fmt.Sprintf(":%d", serviceDetail.TCPRule.Port)
+	routerHandler, serverTLS, err := newTcpServiceHandler(serviceDetail)
+	if err != nil {
+		log.Printf(" [ERROR] tcp_proxy_run %v err:%v\n", addr, err)
+		return nil
+	}
+	baseCtx := context.WithValue(context.Background(), "service", serviceDetail)
+	tcpServer := &tcp_server.TcpServer{
+		Addr:       addr,
+		Handler:    routerHandler,
+		BaseCtx:    baseCtx,
+		TLSConfig:  serverTLS,
+		ProxyProto: public.GetProxyProtoConfig("tcp"),
+	}
+	go func() {
+		log.Printf(" [INFO] tcp_proxy_run %v\n", addr)
+		if err := tcpServer.ListenAndServe(); err != nil && err != tcp_server.ErrServerClosed {
+			//监听失败时移除记录，下次同步时重试
+			log.Printf(" [ERROR] tcp_proxy_run %v err:%v\n", addr, err)
+			tcpServerLocker.Lock()
+			defer tcpServerLocker.Unlock()
+			if serverItem, ok := tcpServerMap[serviceDetail.Info.ServiceName]; ok && serverItem.server == tcpServer {
+				delete(tcpServerMap, serviceDetail.Info.ServiceName)
 			}
-		}(tempItem)
+		}
+	}()
+	return tcpServer
+}
+
+// newTcpServiceHandler 构建服务的中间件链及反向代理，独立端口与共享 sni 端口共用；
+// 返回的 tls 配置不为空时在网关终止 tls
+func newTcpServiceHandler(serviceDetail *dao.ServiceDetail) (tcp_server.TCPHandler, *tls.Config, error) {
+	rb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
+	if err != nil {
+		return nil, nil, err
 	}
+
+	dialRetry := lib.GetIntConf("proxy.tcp.dial_retry")
+
+	//设置了下游 tls 参数时以 tls 连接下游
+	var upstreamTLS *tls.Config
+	if serviceDetail.LoadBalance.UseUpstreamTLS() {
+		if upstreamTLS, err = serviceDetail.LoadBalance.GetUpstreamTLSConfig(); err != nil {
+			return nil, nil, err
+		}
+	}
+	//开启客户端证书认证时要求客户端证书，仅终止 tls 时证书按 sni 从证书管理中选择
+	var serverTLS *tls.Config
+	if serviceDetail.AccessControl.ClientCertAuth == 1 {
+		if serverTLS, err = dao.GetClientAuthTLSConfig(); err != nil {
+			return nil, nil, err
+		}
+	} else if serviceDetail.TCPRule.NeedTls == 1 {
+		serverTLS = &tls.Config{
+			GetCertificate: dao.CertManagerHandler.GetCertificate,
+		}
+	}
+
+	//构建路由及设置中间件
+	router := tcp_proxy_middleware.NewTcpSliceRouter()
+	router.Group("/").Use(
+		tcp_proxy_middleware.TCPFlowCountMiddleware(),
+		tcp_proxy_middleware.TCPFlowLimitMiddleware(),
+		tcp_proxy_middleware.TCPClientCertAuthMiddleware(),
+		tcp_proxy_middleware.TCPAppFlowCountMiddleware(),
+		tcp_proxy_middleware.TCPAppFlowLimitMiddleware(),
+		tcp_proxy_middleware.TCPWhiteListMiddleware(),
+		tcp_proxy_middleware.TCPBlackListMiddleware(),
+		tcp_proxy_middleware.TCPCircuitBreakerMiddleware(),
+	)
+
+	//构建回调handler
+	routerHandler := tcp_proxy_middleware.NewTcpSliceRouterHandler(
+		func(c *tcp_proxy_middleware.TcpSliceRouterContext) tcp_server.TCPHandler {
+			proxy := reverse_proxy.NewTcpLoadBalanceReverseProxy(c, rb, dialRetry)
+			proxy.TLSConfig = upstreamTLS
+			proxy.ProxyProtocolVersion = serviceDetail.TCPRule.ProxyProtocol
+			return proxy
+		}, router)
+	return routerHandler, serverTLS, nil
 }
 
-func TcpServerStop() {
-	for _, tcpServer := range tcpServerList {
-		tcpServer.Close()
+// drainTcpServer 立即关闭监听以释放端口，存量连接在后台排空
+func drainTcpServer(tcpServer *tcp_server.TcpServer) {
+	tcpServer.Close()
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout())
+		defer cancel()
+		if err := tcpServer.Shutdown(ctx); err != nil {
+			log.Printf(" [ERROR] tcp_proxy_drain %v err:%v\n", tcpServer.Addr, err)
+		}
 		log.Printf(" [INFO] tcp_proxy_stop %v stopped\n", tcpServer.Addr)
+	}()
+}
+
+func drainTimeout() time.Duration {
+	timeout := lib.GetIntConf("proxy.reload.drain_timeout")
+	if timeout <= 0 {
+		timeout = defaultDrainTimeout
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+func TcpServerStop() {
+	tcpServerLocker.Lock()
+	defer tcpServerLocker.Unlock()
+	for _, serverItem := range tcpServerMap {
+		serverItem.server.Close()
+		log.Printf(" [INFO] tcp_proxy_stop %v stopped\n", serverItem.server.Addr)
 	}
+	tcpSniServerStop()
 }
@@ -0,0 +1,302 @@
+package tcp_server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	proxyProtoV1Prefix  = []byte("PROXY ")
+	proxyProtoV2Sig     = []byte("\r\n\r\n\x00\r\nQUIT\n")
+	errProxyProtoHeader = errors.New("tcp: invalid proxy protocol header")
+)
+
+// proxyProtoV1MaxLen v1 协议头最大长度，包含结尾的 \r\n
+const proxyProtoV1MaxLen = 107
+
+// ProxyProtoConfig 监听端解析 PROXY 协议头的配置
+type ProxyProtoConfig struct {
+	TrustedList   []string      //允许携带协议头的来源 ip 或网段，为空时不信任任何来源
+	HeaderTimeout time.Duration //读取协议头的超时时间
+}
+
+// trusted 来自可信来源的连接必须携带协议头，其余来源不解析，避免伪造客户端地址
+func (conf *ProxyProtoConfig) trusted(addr net.Addr) bool {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, item := range conf.TrustedList {
+		if strings.Contains(item, "/") {
+			if _, ipNet, err := net.ParseCIDR(item); err == nil && ipNet.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if trustedIP := net.ParseIP(item); trustedIP != nil && trustedIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewProxyProtoListener 解析来源连接的 PROXY v1/v2 协议头，连接的 RemoteAddr 返回协议头中的客户端地址；
+// 协议头在首次读取或获取地址时解析，不阻塞 Accept
+func NewProxyProtoListener(l net.Listener, conf *ProxyProtoConfig) net.Listener {
+	return &proxyProtoListener{Listener: l, conf: conf}
+}
+
+type proxyProtoListener struct {
+	net.Listener
+	conf *ProxyProtoConfig
+}
+
+func (l *proxyProtoListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	if !l.conf.trusted(conn.RemoteAddr()) {
+		return conn, nil
+	}
+	return &proxyProtoConn{Conn: conn, conf: l.conf}, nil
+}
+
+type proxyProtoConn struct {
+	net.Conn
+	conf       *ProxyProtoConfig
+	once       sync.Once
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+	localAddr  net.Addr
+	err        error
+
+	deadlineMux  sync.Mutex
+	readDeadline time.Time //调用方设置的读超时，读取协议头后恢复
+}
+
+func (c *proxyProtoConn) SetDeadline(t time.Time) error {
+	c.deadlineMux.Lock()
+	defer c.deadlineMux.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *proxyProtoConn) SetReadDeadline(t time.Time) error {
+	c.deadlineMux.Lock()
+	defer c.deadlineMux.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *proxyProtoConn) Read(p []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(p)
+}
+
+func (c *proxyProtoConn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyProtoConn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.localAddr != nil {
+		return c.localAddr
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *proxyProtoConn) readHeader() {
+	if c.conf.HeaderTimeout > 0 {
+		c.deadlineMux.Lock()
+		deadline := time.Now().Add(c.conf.HeaderTimeout)
+		if !c.readDeadline.IsZero() && c.readDeadline.Before(deadline) {
+			deadline = c.readDeadline
+		}
+		c.Conn.SetReadDeadline(deadline)
+		c.deadlineMux.Unlock()
+		//恢复调用方(如 http server)设置的读超时
+		defer func() {
+			c.deadlineMux.Lock()
+			c.Conn.SetReadDeadline(c.readDeadline)
+			c.deadlineMux.Unlock()
+		}()
+	}
+	c.reader = bufio.NewReader(c.Conn)
+	c.remoteAddr, c.localAddr, c.err = ReadProxyHeader(c.reader)
+	if c.err != nil {
+		c.err = fmt.Errorf("tcp: read proxy protocol header from %v: %v", c.Conn.RemoteAddr(), c.err)
+	}
+}
+
+// ReadProxyHeader 读取 PROXY v1/v2 协议头，返回客户端地址及原始目标地址；
+// LOCAL 命令或 UNKNOWN 协议时返回 nil 地址，由调用方使用连接本身的地址
+func ReadProxyHeader(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	prefix, err := r.Peek(len(proxyProtoV1Prefix))
+	if err != nil {
+		return nil, nil, err
+	}
+	if bytes.Equal(prefix, proxyProtoV1Prefix) {
+		return readProxyHeaderV1(r)
+	}
+	sig, err := r.Peek(len(proxyProtoV2Sig))
+	if err != nil {
+		return nil, nil, err
+	}
+	if bytes.Equal(sig, proxyProtoV2Sig) {
+		return readProxyHeaderV2(r)
+	}
+	return nil, nil, errors.New("proxy protocol header required")
+}
+
+// readProxyHeaderV1 格式：PROXY TCP4 源地址 目标地址 源端口 目标端口\r\n
+func readProxyHeaderV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	line := make([]byte, 0, proxyProtoV1MaxLen)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= proxyProtoV1MaxLen {
+			return nil, nil, errProxyProtoHeader
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errProxyProtoHeader
+	}
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, errProxyProtoHeader
+	}
+	srcAddr, err := parseProxyAddrV1(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+	dstAddr, err := parseProxyAddrV1(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+	return srcAddr, dstAddr, nil
+}
+
+func parseProxyAddrV1(host, port string) (net.Addr, error) {
+	ip := net.ParseIP(host)
+	portNum, err := strconv.Atoi(port)
+	if ip == nil || err != nil || portNum < 0 || portNum > 65535 {
+		return nil, errProxyProtoHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: portNum}, nil
+}
+
+// readProxyHeaderV2 16字节固定头：签名(12) 版本及命令(1) 地址族及协议(1) 地址长度(2)，之后为地址及 TLV
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, nil, errProxyProtoHeader
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, nil, err
+	}
+	//LOCAL 命令为代理自身的探测连接，使用连接本身的地址
+	command := header[12] & 0x0f
+	if command == 0x0 {
+		return nil, nil, nil
+	}
+	if command != 0x1 {
+		return nil, nil, errProxyProtoHeader
+	}
+	switch header[13] {
+	case 0x11: //TCP over IPv4
+		if len(payload) < 12 {
+			return nil, nil, errProxyProtoHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))},
+			&net.TCPAddr{IP: net.IP(payload[4:8]), Port: int(binary.BigEndian.Uint16(payload[10:12]))}, nil
+	case 0x21: //TCP over IPv6
+		if len(payload) < 36 {
+			return nil, nil, errProxyProtoHeader
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))},
+			&net.TCPAddr{IP: net.IP(payload[16:32]), Port: int(binary.BigEndian.Uint16(payload[34:36]))}, nil
+	}
+	//其他地址族(如 unix socket)不支持，使用连接本身的地址
+	return nil, nil, nil
+}
+
+// WriteProxyHeader 向下游写入 PROXY 协议头，version 为 1 或 2；地址不是 tcp 地址时写入 UNKNOWN/LOCAL
+func WriteProxyHeader(w io.Writer, version int, src, dst net.Addr) error {
+	srcAddr, srcOk := src.(*net.TCPAddr)
+	dstAddr, dstOk := dst.(*net.TCPAddr)
+	ok := srcOk && dstOk
+	//源地址与目标地址需为同一地址族
+	if ok && (srcAddr.IP.To4() == nil) != (dstAddr.IP.To4() == nil) {
+		ok = false
+	}
+	switch version {
+	case 1:
+		header := "PROXY UNKNOWN\r\n"
+		if ok {
+			proto := "TCP4"
+			srcIP, dstIP := srcAddr.IP.To4(), dstAddr.IP.To4()
+			if srcIP == nil {
+				proto, srcIP, dstIP = "TCP6", srcAddr.IP.To16(), dstAddr.IP.To16()
+			}
+			header = fmt.Sprintf("PROXY %s %s %s %d %d\r\n", proto, srcIP, dstIP, srcAddr.Port, dstAddr.Port)
+		}
+		_, err := io.WriteString(w, header)
+		return err
+	case 2:
+		header := bytes.NewBuffer(append([]byte{}, proxyProtoV2Sig...))
+		if !ok {
+			header.Write([]byte{0x20, 0x00, 0x00, 0x00})
+			_, err := w.Write(header.Bytes())
+			return err
+		}
+		header.WriteByte(0x21)
+		srcIP, dstIP := srcAddr.IP.To4(), dstAddr.IP.To4()
+		if srcIP != nil {
+			header.Write([]byte{0x11, 0x00, 12})
+		} else {
+			srcIP, dstIP = srcAddr.IP.To16(), dstAddr.IP.To16()
+			header.Write([]byte{0x21, 0x00, 36})
+		}
+		header.Write(srcIP)
+		header.Write(dstIP)
+		binary.Write(header, binary.BigEndian, uint16(srcAddr.Port))
+		binary.Write(header, binary.BigEndian, uint16(dstAddr.Port))
+		_, err := w.Write(header.Bytes())
+		return err
+	}
+	return fmt.Errorf("tcp: unsupported proxy protocol version %d", version)
+}
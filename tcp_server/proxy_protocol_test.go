@@ -0,0 +1,205 @@
+package tcp_server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadProxyHeaderV1(t *testing.T) {
+	tests := []struct {
+		header  string
+		src     string
+		dst     string
+		wantErr bool
+	}{
+		{"PROXY TCP4 192.168.1.10 10.0.0.1 56324 443\r\n", "192.168.1.10:56324", "10.0.0.1:443", false},
+		{"PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", "[2001:db8::1]:56324", "[2001:db8::2]:443", false},
+		{"PROXY UNKNOWN\r\n", "", "", false},
+		{"PROXY UNKNOWN ffff::1 ffff::2 1 2\r\n", "", "", false},
+		{"PROXY TCP4 192.168.1.10 10.0.0.1 56324\r\n", "", "", true},
+		{"PROXY UDP4 192.168.1.10 10.0.0.1 56324 443\r\n", "", "", true},
+		{"PROXY TCP4 192.168.1.300 10.0.0.1 56324 443\r\n", "", "", true},
+		{"PROXY TCP4 192.168.1.10 10.0.0.1 56324 70000\r\n", "", "", true},
+		{"PROXY TCP4 192.168.1.10 10.0.0.1 56324 443\n", "", "", true},
+		{"PROXY TCP4 192.168.1.10 10.0.0.1 56324 443", "", "", true}, //截断，没有结尾
+		{"PROXY " + strings.Repeat("A", proxyProtoV1MaxLen) + "\r\n", "", "", true},
+		{"GET / HTTP/1.1\r\n\r\n", "", "", true},
+	}
+	for _, test := range tests {
+		src, dst, err := ReadProxyHeader(bufio.NewReader(strings.NewReader(test.header)))
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: err = %v, wantErr %v", test.header, err, test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+		if got := addrString(src); got != test.src {
+			t.Errorf("%q: src = %v, want %v", test.header, got, test.src)
+		}
+		if got := addrString(dst); got != test.dst {
+			t.Errorf("%q: dst = %v, want %v", test.header, got, test.dst)
+		}
+	}
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// newTestProxyHeaderV2 v2 协议头，payload 为地址部分
+func newTestProxyHeaderV2(versionCommand, family byte, payload []byte) []byte {
+	header := append([]byte{}, proxyProtoV2Sig...)
+	header = append(header, versionCommand, family, 0, 0)
+	binary.BigEndian.PutUint16(header[14:], uint16(len(payload)))
+	return append(header, payload...)
+}
+
+func TestReadProxyHeaderV2(t *testing.T) {
+	ipv4 := []byte{192, 168, 1, 10, 10, 0, 0, 1, 0xdc, 0x04, 0x01, 0xbb}
+	ipv6 := append(append(net.ParseIP("2001:db8::1").To16(), net.ParseIP("2001:db8::2").To16()...), 0xdc, 0x04, 0x01, 0xbb)
+	tests := []struct {
+		name    string
+		header  []byte
+		src     string
+		wantErr bool
+	}{
+		{"ipv4", newTestProxyHeaderV2(0x21, 0x11, ipv4), "192.168.1.10:56324", false},
+		{"ipv6", newTestProxyHeaderV2(0x21, 0x21, ipv6), "[2001:db8::1]:56324", false},
+		{"ipv4 with tlv", newTestProxyHeaderV2(0x21, 0x11, append(append([]byte{}, ipv4...), 0x04, 0x00, 0x01, 0x00)), "192.168.1.10:56324", false},
+		{"local", newTestProxyHeaderV2(0x20, 0x00, nil), "", false},
+		{"unix", newTestProxyHeaderV2(0x21, 0x31, make([]byte, 216)), "", false},
+		{"version 1", newTestProxyHeaderV2(0x11, 0x11, ipv4), "", true},
+		{"unknown command", newTestProxyHeaderV2(0x22, 0x11, ipv4), "", true},
+		{"short ipv4 address", newTestProxyHeaderV2(0x21, 0x11, ipv4[:8]), "", true},
+		{"truncated fixed header", newTestProxyHeaderV2(0x21, 0x11, ipv4)[:14], "", true},
+		{"truncated payload", newTestProxyHeaderV2(0x21, 0x11, ipv4)[:20], "", true},
+	}
+	for _, test := range tests {
+		src, _, err := ReadProxyHeader(bufio.NewReader(bytes.NewReader(test.header)))
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: err = %v, wantErr %v", test.name, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && addrString(src) != test.src {
+			t.Errorf("%v: src = %v, want %v", test.name, addrString(src), test.src)
+		}
+	}
+}
+
+func TestWriteProxyHeaderRoundTrip(t *testing.T) {
+	addrs := [][2]net.Addr{
+		{&net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 56324}, &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 443}},
+		{&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 56324}, &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443}},
+	}
+	for _, version := range []int{1, 2} {
+		for _, pair := range addrs {
+			buf := &bytes.Buffer{}
+			if err := WriteProxyHeader(buf, version, pair[0], pair[1]); err != nil {
+				t.Fatal(err)
+			}
+			buf.WriteString("payload")
+			reader := bufio.NewReader(buf)
+			src, dst, err := ReadProxyHeader(reader)
+			if err != nil {
+				t.Fatalf("v%d %v: %v", version, pair[0], err)
+			}
+			if src.String() != pair[0].String() || dst.String() != pair[1].String() {
+				t.Errorf("v%d: got %v -> %v, want %v -> %v", version, src, dst, pair[0], pair[1])
+			}
+			if rest, _ := io.ReadAll(reader); string(rest) != "payload" {
+				t.Errorf("v%d: data after header = %q", version, rest)
+			}
+		}
+	}
+}
+
+// acceptTestProxyConn 通过 PROXY 协议监听接受一个连接，客户端写入 data
+func acceptTestProxyConn(t *testing.T, conf *ProxyProtoConfig, data string) net.Conn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	pl := NewProxyProtoListener(l, conf)
+	go func() {
+		client, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return
+		}
+		t.Cleanup(func() { client.Close() })
+		client.Write([]byte(data))
+	}()
+	conn, err := pl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestProxyProtoListenerTrustedSource(t *testing.T) {
+	conf := &ProxyProtoConfig{TrustedList: []string{"127.0.0.0/8"}, HeaderTimeout: time.Second}
+	conn := acceptTestProxyConn(t, conf, "PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\nhello")
+	if got := conn.RemoteAddr().String(); got != "203.0.113.7:56324" {
+		t.Errorf("RemoteAddr = %v, want client address from header", got)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello" {
+		t.Errorf("read %q err %v", buf, err)
+	}
+}
+
+func TestProxyProtoListenerUntrustedSource(t *testing.T) {
+	for _, trustedList := range [][]string{nil, {"10.0.0.0/8", "192.168.1.10"}} {
+		conf := &ProxyProtoConfig{TrustedList: trustedList, HeaderTimeout: time.Second}
+		header := "PROXY TCP4 203.0.113.7 10.0.0.1 56324 443\r\n"
+		conn := acceptTestProxyConn(t, conf, header)
+		//不可信来源不解析协议头，伪造的地址不生效，协议头作为普通数据
+		if host, _, _ := net.SplitHostPort(conn.RemoteAddr().String()); host != "127.0.0.1" {
+			t.Errorf("trusted_list %v: RemoteAddr = %v, want real address", trustedList, conn.RemoteAddr())
+		}
+		buf := make([]byte, len(header))
+		if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != header {
+			t.Errorf("trusted_list %v: read %q err %v", trustedList, buf, err)
+		}
+	}
+}
+
+func TestProxyProtoConnMissingHeader(t *testing.T) {
+	conf := &ProxyProtoConfig{TrustedList: []string{"127.0.0.1"}, HeaderTimeout: time.Second}
+	conn := acceptTestProxyConn(t, conf, "GET / HTTP/1.1\r\n\r\n")
+	if _, err := conn.Read(make([]byte, 16)); err == nil {
+		t.Error("want error for trusted source without proxy header")
+	}
+}
+
+func TestProxyProtoConnRestoresReadDeadline(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	conn := &proxyProtoConn{Conn: server, conf: &ProxyProtoConfig{HeaderTimeout: 5 * time.Second}}
+	defer conn.Close()
+	go client.Write([]byte("PROXY UNKNOWN\r\n"))
+
+	//调用方设置的读超时在读取协议头后仍然生效
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	start := time.Now()
+	_, err := conn.Read(make([]byte, 1))
+	var netErr net.Error
+	if err == nil || !(errors.As(err, &netErr) && netErr.Timeout()) {
+		t.Fatalf("err = %v, want timeout", err)
+	}
+	if cost := time.Since(start); cost > 2*time.Second {
+		t.Errorf("read returned after %v, caller deadline lost", cost)
+	}
+}
@@ -62,6 +62,7 @@ func (c *conn) serve(ctx context.Context) {
 			fmt.Printf("tcp: panic serving %v: %v\n%s", c.remoteAddr, err, buf)
 		}
 		c.close()
+		c.server.trackConn(c, false)
 	}()
 
 	c.remoteAddr = c.rwc.RemoteAddr().String() // 客户端 IP/端口
@@ -2,6 +2,7 @@ package tcp_server
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"github.com/pkg/errors"
 	"net"
@@ -44,6 +45,9 @@ type TcpServer struct {
 	err     error
 	BaseCtx context.Context
 
+	TLSConfig  *tls.Config       //设置后在监听端完成 tls 握手，Handler 收到的是 *tls.Conn
+	ProxyProto *ProxyProtoConfig //设置后解析来源连接的 PROXY 协议头，先于 tls 握手
+
 	WriteTimeout     time.Duration
 	ReadTimeout      time.Duration
 	KeepAliveTimeout time.Duration
@@ -52,6 +56,7 @@ type TcpServer struct {
 	inShutdown int32
 	doneChan   chan struct{}      // 用于通知关闭
 	l          *onceCloseListener // 包装监听器，确保只关闭一次
+	activeConn map[*conn]struct{} // 正在处理的连接，Shutdown 时用于排空
 }
 
 func (s *TcpServer) shuttingDown() bool {
@@ -74,13 +79,26 @@ func (srv *TcpServer) ListenAndServe() error {
 	if err != nil {
 		return err
 	}
-	return srv.Serve(tcpKeepAliveListener{ln.(*net.TCPListener)})
+	var l net.Listener = tcpKeepAliveListener{ln.(*net.TCPListener)}
+	if srv.ProxyProto != nil {
+		l = NewProxyProtoListener(l, srv.ProxyProto)
+	}
+	if srv.TLSConfig != nil {
+		l = tls.NewListener(l, srv.TLSConfig)
+	}
+	return srv.Serve(l)
 }
 
 // Serve 负责工作委派
 func (srv *TcpServer) Serve(l net.Listener) error {
+	srv.mu.Lock()
 	srv.l = &onceCloseListener{Listener: l}
+	srv.mu.Unlock()
 	defer srv.l.Close()
+	//Serve 启动前已被关闭
+	if srv.shuttingDown() {
+		return ErrServerClosed
+	}
 
 	if srv.BaseCtx == nil {
 		srv.BaseCtx = context.Background()
@@ -102,22 +120,87 @@ func (srv *TcpServer) Serve(l net.Listener) error {
 		c := srv.newConn(rw)
 		go c.serve(ctx)
 	}
-	return nil
 }
 
+// Close 立即关闭监听，已建立的连接不受影响
 func (srv *TcpServer) Close() error {
 	atomic.StoreInt32(&srv.inShutdown, 1)
-	close(srv.doneChan) //关闭channel
-	srv.l.Close()       //执行listener关闭
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.closeDoneChanLocked() //关闭channel
+	if srv.l != nil {
+		srv.l.Close() //执行listener关闭
+	}
 	return nil
 }
 
+// shutdownPollInterval Shutdown 检查连接是否排空的间隔
+const shutdownPollInterval = 500 * time.Millisecond
+
+// Shutdown 优雅关闭：先关闭监听不再接收新连接，再等待已建立的连接处理完毕；
+// ctx 到期后仍未结束的连接会被强制关闭
+func (srv *TcpServer) Shutdown(ctx context.Context) error {
+	srv.Close()
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if srv.activeConnNum() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			srv.closeActiveConn()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (srv *TcpServer) closeDoneChanLocked() {
+	if srv.doneChan == nil {
+		srv.doneChan = make(chan struct{})
+	}
+	select {
+	case <-srv.doneChan:
+	default:
+		close(srv.doneChan)
+	}
+}
+
+func (srv *TcpServer) trackConn(c *conn, add bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.activeConn == nil {
+		srv.activeConn = make(map[*conn]struct{})
+	}
+	if add {
+		srv.activeConn[c] = struct{}{}
+	} else {
+		delete(srv.activeConn, c)
+	}
+}
+
+func (srv *TcpServer) activeConnNum() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.activeConn)
+}
+
+func (srv *TcpServer) closeActiveConn() {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	for c := range srv.activeConn {
+		c.close()
+	}
+}
+
 // newConn 设置连接参数 timeout / keepalive
 func (srv *TcpServer) newConn(rwc net.Conn) *conn {
 	c := &conn{
 		server: srv,
 		rwc:    rwc,
 	}
+	srv.trackConn(c, true)
 
 	if d := c.server.ReadTimeout; d != 0 {
 		c.rwc.SetReadDeadline(time.Now().Add(d))
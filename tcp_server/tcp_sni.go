@@ -0,0 +1,61 @@
+package tcp_server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+var errSniffDone = errors.New("tcp: client hello sniffed")
+
+// PeekServerName 读取 tls ClientHello 中的 sni 域名，不完成握手；
+// 返回的连接会先重放已读取的数据，可原样转发给下游或继续在网关完成 tls 握手
+func PeekServerName(conn net.Conn, timeout time.Duration) (string, net.Conn, error) {
+	if timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		defer conn.SetReadDeadline(time.Time{})
+	}
+	buf := &bytes.Buffer{}
+	serverName := ""
+	err := tls.Server(&sniffConn{Conn: conn, reader: io.TeeReader(conn, buf)}, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, errSniffDone
+		},
+	}).Handshake()
+	peekConn := &prefixConn{Conn: conn, reader: io.MultiReader(buf, conn)}
+	if serverName == "" {
+		if err == nil || err == errSniffDone {
+			err = errors.New("tcp: client hello without server name")
+		}
+		return "", peekConn, err
+	}
+	return serverName, peekConn, nil
+}
+
+// sniffConn 只读取客户端数据，握手过程中的写入(如 alert)不会发给客户端
+type sniffConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *sniffConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *sniffConn) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+// prefixConn 先读取已缓存的数据，再读取原连接
+type prefixConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *prefixConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
@@ -0,0 +1,106 @@
+package tcp_server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+// newTestCertificate 测试用的自签名证书
+func newTestCertificate(t *testing.T, dnsName string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{dnsName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestPeekServerNameReplaysClientHello(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	clientErr := make(chan error, 1)
+	go func() {
+		client := tls.Client(clientConn, &tls.Config{ServerName: "api.example.com", InsecureSkipVerify: true})
+		if err := client.Handshake(); err != nil {
+			clientErr <- err
+			return
+		}
+		_, err := client.Write([]byte("ping"))
+		clientErr <- err
+	}()
+
+	serverName, peekConn, err := PeekServerName(serverConn, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if serverName != "api.example.com" {
+		t.Fatalf("serverName = %q", serverName)
+	}
+	//已读取的 ClientHello 重放后可以继续完成握手
+	server := tls.Server(peekConn, &tls.Config{Certificates: []tls.Certificate{newTestCertificate(t, "api.example.com")}})
+	buf := make([]byte, 4)
+	server.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := server.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "ping" {
+		t.Errorf("read %q after handshake", buf)
+	}
+	if err := <-clientErr; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPeekServerNameWithoutSni(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	//ServerName 为 ip 时 ClientHello 不带 sni
+	go tls.Client(clientConn, &tls.Config{ServerName: "127.0.0.1", InsecureSkipVerify: true}).Handshake()
+
+	if _, _, err := PeekServerName(serverConn, time.Second); err == nil {
+		t.Fatal("want error for client hello without server name")
+	}
+}
+
+func TestPeekServerNameNotTls(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	go clientConn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+
+	if _, _, err := PeekServerName(serverConn, time.Second); err == nil {
+		t.Fatal("want error for plain text client")
+	}
+}
+
+func TestPeekServerNameTimeout(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+
+	start := time.Now()
+	if _, _, err := PeekServerName(serverConn, 100*time.Millisecond); err == nil {
+		t.Fatal("want timeout error for silent client")
+	}
+	if cost := time.Since(start); cost > time.Second {
+		t.Errorf("peek returned after %v", cost)
+	}
+}
@@ -0,0 +1,38 @@
+package udp_proxy_middleware
+
+import (
+	"go-gateway/dao"
+	"go-gateway/public"
+	"log"
+	"strings"
+)
+
+func UDPBlackListMiddleware() func(c *UdpSliceRouterContext) {
+	return func(c *UdpSliceRouterContext) {
+		serverInterface := c.Get("service")
+		if serverInterface == nil {
+			log.Printf(" [ERROR] udp_black_list get service empty\n")
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		whileIpList := []string{}
+		if serviceDetail.AccessControl.WhiteList != "" {
+			whileIpList = strings.Split(serviceDetail.AccessControl.WhiteList, ",")
+		}
+
+		blackIpList := []string{}
+		if serviceDetail.AccessControl.BlackList != "" {
+			blackIpList = strings.Split(serviceDetail.AccessControl.BlackList, ",")
+		}
+
+		if serviceDetail.AccessControl.OpenAuth == 1 && len(whileIpList) == 0 && len(blackIpList) > 0 {
+			if public.InStringSlice(blackIpList, c.ClientIP()) {
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
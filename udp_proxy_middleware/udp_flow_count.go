@@ -0,0 +1,38 @@
+package udp_proxy_middleware
+
+import (
+	"go-gateway/dao"
+	"go-gateway/public"
+	"log"
+)
+
+// UDPFlowCountMiddleware 每个新会话计为一次请求
+func UDPFlowCountMiddleware() func(c *UdpSliceRouterContext) {
+	return func(c *UdpSliceRouterContext) {
+		serverInterface := c.Get("service")
+		if serverInterface == nil {
+			log.Printf(" [ERROR] udp_flow_count get service empty\n")
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		//统计项 1 全站 2 服务
+		totalCounter, err := public.FlowCounterHandler.GetCounter(public.FlowTotal)
+		if err != nil {
+			log.Printf(" [ERROR] udp_flow_count err:%v\n", err)
+			c.Abort()
+			return
+		}
+		totalCounter.Increase()
+
+		serviceCounter, err := public.FlowCounterHandler.GetCounter(public.FlowServicePrefix + serviceDetail.Info.ServiceName)
+		if err != nil {
+			log.Printf(" [ERROR] udp_flow_count err:%v\n", err)
+			c.Abort()
+			return
+		}
+		serviceCounter.Increase()
+		c.Next()
+	}
+}
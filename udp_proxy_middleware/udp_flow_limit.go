@@ -0,0 +1,52 @@
+package udp_proxy_middleware
+
+import (
+	"go-gateway/dao"
+	"go-gateway/public"
+	"log"
+)
+
+// UDPFlowLimitMiddleware 按服务及客户端 ip 限制每秒新建会话数，超限的数据报直接丢弃
+func UDPFlowLimitMiddleware() func(c *UdpSliceRouterContext) {
+	return func(c *UdpSliceRouterContext) {
+		serverInterface := c.Get("service")
+		if serverInterface == nil {
+			log.Printf(" [ERROR] udp_flow_limit get service empty\n")
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		if serviceDetail.AccessControl.ServiceFlowLimit != 0 {
+			serviceLimiter, err := public.FlowLimiterHandler.GetLimiter(
+				public.FlowServicePrefix+serviceDetail.Info.ServiceName,
+				float64(serviceDetail.AccessControl.ServiceFlowLimit))
+			if err != nil {
+				log.Printf(" [ERROR] udp_flow_limit err:%v\n", err)
+				c.Abort()
+				return
+			}
+			if !serviceLimiter.Allow() {
+				c.Abort()
+				return
+			}
+		}
+
+		clientIP := c.ClientIP()
+		if serviceDetail.AccessControl.ClientIPFlowLimit > 0 {
+			clientLimiter, err := public.FlowLimiterHandler.GetLimiter(
+				public.FlowServicePrefix+serviceDetail.Info.ServiceName+"_"+clientIP,
+				float64(serviceDetail.AccessControl.ClientIPFlowLimit))
+			if err != nil {
+				log.Printf(" [ERROR] udp_flow_limit err:%v\n", err)
+				c.Abort()
+				return
+			}
+			if !clientLimiter.Allow() {
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,148 @@
+package udp_proxy_middleware
+
+import (
+	"context"
+	"go-gateway/udp_server"
+	"math"
+	"net"
+)
+
+const abortIndex int8 = math.MaxInt8 / 2 //最多 63 个中间件
+
+// sessionHandlerKey 会话通过中间件后保存的下游处理器
+var sessionHandlerKey = &struct{ name string }{"udp-session-handler"}
+
+type UdpHandlerFunc func(*UdpSliceRouterContext)
+
+// router 结构体
+type UdpSliceRouter struct {
+	groups []*UdpSliceGroup
+}
+
+// group 结构体
+type UdpSliceGroup struct {
+	*UdpSliceRouter
+	path     string
+	handlers []UdpHandlerFunc
+}
+
+// router上下文，中间件只在会话的首个数据报时执行，与 tcp 按连接执行一致
+type UdpSliceRouterContext struct {
+	session *udp_server.Session
+	packet  []byte
+	Ctx     context.Context
+	*UdpSliceGroup
+	index int8
+}
+
+func newUdpSliceRouterContext(session *udp_server.Session, packet []byte, r *UdpSliceRouter, ctx context.Context) *UdpSliceRouterContext {
+	newUdpSliceGroup := &UdpSliceGroup{}
+	*newUdpSliceGroup = *r.groups[0] //浅拷贝数组指针,只会使用第一个分组
+	c := &UdpSliceRouterContext{session: session, packet: packet, UdpSliceGroup: newUdpSliceGroup, Ctx: ctx}
+	c.Reset()
+	return c
+}
+
+func (c *UdpSliceRouterContext) Get(key interface{}) interface{} {
+	return c.Ctx.Value(key)
+}
+
+func (c *UdpSliceRouterContext) Set(key, val interface{}) {
+	c.Ctx = context.WithValue(c.Ctx, key, val)
+}
+
+// ClientIP 会话的客户端 ip
+func (c *UdpSliceRouterContext) ClientIP() string {
+	if addr, ok := c.session.RemoteAddr().(*net.UDPAddr); ok {
+		return addr.IP.String()
+	}
+	return ""
+}
+
+type UdpSliceRouterHandler struct {
+	coreFunc func(*UdpSliceRouterContext) udp_server.UDPHandler
+	router   *UdpSliceRouter
+}
+
+// ServeUDP 新会话先经过中间件，通过后创建下游处理器并保存在会话中，后续数据报直接转发；
+// 被中间件拒绝的会话立即关闭，客户端再次发送时重新校验
+func (w *UdpSliceRouterHandler) ServeUDP(ctx context.Context, session *udp_server.Session, packet []byte) {
+	if handler, ok := session.Value(sessionHandlerKey).(udp_server.UDPHandler); ok {
+		handler.ServeUDP(ctx, session, packet)
+		return
+	}
+	c := newUdpSliceRouterContext(session, packet, w.router, ctx)
+	//会话并发处理，限制容量使 append 总是复制，不修改分组共享的中间件数组
+	c.handlers = append(c.handlers[:len(c.handlers):len(c.handlers)], func(c *UdpSliceRouterContext) {
+		handler := w.coreFunc(c)
+		session.SetValue(sessionHandlerKey, handler)
+		handler.ServeUDP(ctx, session, packet)
+	})
+	c.Reset()
+	c.Next()
+	if c.IsAborted() {
+		session.Close()
+	}
+}
+
+func NewUdpSliceRouterHandler(coreFunc func(*UdpSliceRouterContext) udp_server.UDPHandler, router *UdpSliceRouter) *UdpSliceRouterHandler {
+	return &UdpSliceRouterHandler{
+		coreFunc: coreFunc,
+		router:   router,
+	}
+}
+
+// 构造 router
+func NewUdpSliceRouter() *UdpSliceRouter {
+	return &UdpSliceRouter{}
+}
+
+// 创建 Group
+func (g *UdpSliceRouter) Group(path string) *UdpSliceGroup {
+	if path != "/" {
+		panic("only accept path=/")
+	}
+	return &UdpSliceGroup{
+		UdpSliceRouter: g,
+		path:           path,
+	}
+}
+
+// 构造回调方法
+func (g *UdpSliceGroup) Use(middlewares ...UdpHandlerFunc) *UdpSliceGroup {
+	g.handlers = append(g.handlers, middlewares...)
+	existsFlag := false
+	for _, oldGroup := range g.UdpSliceRouter.groups {
+		if oldGroup == g {
+			existsFlag = true
+		}
+	}
+	if !existsFlag {
+		g.UdpSliceRouter.groups = append(g.UdpSliceRouter.groups, g)
+	}
+	return g
+}
+
+// 从最先加入中间件开始回调
+func (c *UdpSliceRouterContext) Next() {
+	c.index++
+	for c.index < int8(len(c.handlers)) {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+// 跳出中间件方法
+func (c *UdpSliceRouterContext) Abort() {
+	c.index = abortIndex
+}
+
+// 是否跳过了回调
+func (c *UdpSliceRouterContext) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// 重置回调
+func (c *UdpSliceRouterContext) Reset() {
+	c.index = -1
+}
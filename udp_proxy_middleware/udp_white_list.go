@@ -0,0 +1,32 @@
+package udp_proxy_middleware
+
+import (
+	"go-gateway/dao"
+	"go-gateway/public"
+	"log"
+	"strings"
+)
+
+func UDPWhiteListMiddleware() func(c *UdpSliceRouterContext) {
+	return func(c *UdpSliceRouterContext) {
+		serverInterface := c.Get("service")
+		if serverInterface == nil {
+			log.Printf(" [ERROR] udp_white_list get service empty\n")
+			c.Abort()
+			return
+		}
+		serviceDetail := serverInterface.(*dao.ServiceDetail)
+
+		iplist := []string{}
+		if serviceDetail.AccessControl.WhiteList != "" {
+			iplist = strings.Split(serviceDetail.AccessControl.WhiteList, ",")
+		}
+		if serviceDetail.AccessControl.OpenAuth == 1 && len(iplist) > 0 {
+			if !public.InStringSlice(iplist, c.ClientIP()) {
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,137 @@
+package udp_proxy_router
+
+import (
+	"context"
+	"fmt"
+	"go-gateway/common/lib"
+	"go-gateway/dao"
+	"go-gateway/public"
+	"go-gateway/reverse_proxy"
+	"go-gateway/udp_proxy_middleware"
+	"go-gateway/udp_server"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	udpServerMap    = map[string]*udpServerItem{}
+	udpServerLocker sync.Mutex
+)
+
+// udpServerItem 正在运行的 udp 服务监听，serviceDetail 为启动时使用的配置快照
+type udpServerItem struct {
+	serviceDetail *dao.ServiceDetail
+	server        *udp_server.UdpServer
+}
+
+// udpServerSupervisor 服务配置热加载后对比并调整 udp 监听
+type udpServerSupervisor struct{}
+
+func (u *udpServerSupervisor) Update() {
+	UdpServerSync()
+}
+
+func UdpServerRun() {
+	UdpServerSync()
+	dao.ServiceManagerHandler.Attach(&udpServerSupervisor{})
+}
+
+// UdpServerSync 对比运行中的监听与当前 udp 服务列表：
+// 新增服务开启监听，删除或配置变更的服务关闭监听及全部会话，变更的服务重新绑定，其余服务不受影响
+func UdpServerSync() {
+	udpServerLocker.Lock()
+	defer udpServerLocker.Unlock()
+
+	serviceMap := map[string]*dao.ServiceDetail{}
+	for _, serviceItem := range dao.ServiceManagerHandler.GetUdpServiceList() {
+		serviceMap[serviceItem.Info.ServiceName] = serviceItem
+	}
+
+	//udp 无连接可排空，先关闭旧监听，端口不变时新监听才能绑定
+	for serviceName, serverItem := range udpServerMap {
+		serviceDetail, ok := serviceMap[serviceName]
+		if ok && public.Obj2Json(serviceDetail) == public.Obj2Json(serverItem.serviceDetail) {
+			continue
+		}
+		delete(udpServerMap, serviceName)
+		serverItem.server.Close()
+		log.Printf(" [INFO] udp_proxy_stop %v stopped\n", serverItem.server.Addr)
+	}
+
+	for serviceName, serviceDetail := range serviceMap {
+		if _, ok := udpServerMap[serviceName]; ok {
+			continue
+		}
+		if udpServer := startUdpServer(serviceDetail); udpServer != nil {
+			udpServerMap[serviceName] = &udpServerItem{
+				serviceDetail: serviceDetail,
+				server:        udpServer,
+			}
+		}
+	}
+}
+
+func startUdpServer(serviceDetail *dao.ServiceDetail) *udp_server.UdpServer {
+	addr := fmt.Sprintf(":%d", serviceDetail.UDPRule.Port)
+	rb, err := dao.LoadBalancerHandler.GetLoadBalancer(serviceDetail)
+	if err != nil {
+		log.Printf(" [ERROR] GetUdpLoadBalancer %v err:%v\n", addr, err)
+		return nil
+	}
+
+	//构建路由及设置中间件，中间件在会话建立时执行
+	router := udp_proxy_middleware.NewUdpSliceRouter()
+	router.Group("/").Use(
+		udp_proxy_middleware.UDPFlowCountMiddleware(),
+		udp_proxy_middleware.UDPFlowLimitMiddleware(),
+		udp_proxy_middleware.UDPWhiteListMiddleware(),
+		udp_proxy_middleware.UDPBlackListMiddleware(),
+	)
+
+	//构建回调handler
+	routerHandler := udp_proxy_middleware.NewUdpSliceRouterHandler(
+		func(c *udp_proxy_middleware.UdpSliceRouterContext) udp_server.UDPHandler {
+			return reverse_proxy.NewUdpLoadBalanceReverseProxy(c, rb)
+		}, router)
+
+	baseCtx := context.WithValue(context.Background(), "service", serviceDetail)
+	udpServer := &udp_server.UdpServer{
+		Addr:        addr,
+		Handler:     routerHandler,
+		BaseCtx:     baseCtx,
+		IdleTimeout: idleTimeout(serviceDetail),
+		MaxSessions: lib.GetIntConf("proxy.udp.max_sessions"),
+	}
+	go func() {
+		log.Printf(" [INFO] udp_proxy_run %v\n", addr)
+		if err := udpServer.ListenAndServe(); err != nil && err != udp_server.ErrServerClosed {
+			//监听失败时移除记录，下次同步时重试
+			log.Printf(" [ERROR] udp_proxy_run %v err:%v\n", addr, err)
+			udpServerLocker.Lock()
+			defer udpServerLocker.Unlock()
+			if serverItem, ok := udpServerMap[serviceDetail.Info.ServiceName]; ok && serverItem.server == udpServer {
+				delete(udpServerMap, serviceDetail.Info.ServiceName)
+			}
+		}
+	}()
+	return udpServer
+}
+
+// idleTimeout 服务未设置时使用全局配置 proxy.udp.idle_timeout
+func idleTimeout(serviceDetail *dao.ServiceDetail) time.Duration {
+	timeout := serviceDetail.UDPRule.IdleTimeout
+	if timeout <= 0 {
+		timeout = lib.GetIntConf("proxy.udp.idle_timeout")
+	}
+	return time.Duration(timeout) * time.Second
+}
+
+func UdpServerStop() {
+	udpServerLocker.Lock()
+	defer udpServerLocker.Unlock()
+	for _, serverItem := range udpServerMap {
+		serverItem.server.Close()
+		log.Printf(" [INFO] udp_proxy_stop %v stopped\n", serverItem.server.Addr)
+	}
+}
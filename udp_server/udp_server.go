@@ -0,0 +1,336 @@
+package udp_server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrServerClosed = errors.New("udp: Server closed")
+
+	// ServerContextKey 用于在 context 里保存 server 指针
+	ServerContextKey = &contextKey{"udp-server"}
+)
+
+const (
+	// maxPacketSize 单个数据报的最大长度
+	maxPacketSize = 64 * 1024
+
+	defaultIdleTimeout = 60 * time.Second
+	defaultMaxSessions = 10000
+
+	// sessionQueueSize 单个会话待处理的数据报数，处理不过来时丢弃新到的数据报
+	sessionQueueSize = 128
+)
+
+type contextKey struct {
+	name string
+}
+
+func (k *contextKey) String() string {
+	return "udp_server context value " + k.name
+}
+
+// UDPHandler 处理客户端会话收到的数据报，同一会话的数据报按到达顺序依次调用，不同会话并发调用
+type UDPHandler interface {
+	ServeUDP(ctx context.Context, session *Session, packet []byte)
+}
+
+// UdpServer 按客户端地址维护会话，会话空闲超时后关闭
+type UdpServer struct {
+	Addr        string
+	Handler     UDPHandler
+	BaseCtx     context.Context
+	IdleTimeout time.Duration //双向均无数据超过该时长后关闭会话
+	MaxSessions int           //最大会话数，达到后丢弃新客户端的数据报，0为使用默认值
+
+	mu         sync.Mutex
+	inShutdown int32
+	conn       *net.UDPConn
+	sessions   map[string]*Session
+	doneChan   chan struct{}
+}
+
+func (srv *UdpServer) shuttingDown() bool {
+	return atomic.LoadInt32(&srv.inShutdown) != 0
+}
+
+func (srv *UdpServer) maxSessions() int {
+	if srv.MaxSessions > 0 {
+		return srv.MaxSessions
+	}
+	return defaultMaxSessions
+}
+
+func (srv *UdpServer) idleTimeout() time.Duration {
+	if srv.IdleTimeout > 0 {
+		return srv.IdleTimeout
+	}
+	return defaultIdleTimeout
+}
+
+// ListenAndServe 启动监听 UDP 服务器
+func (srv *UdpServer) ListenAndServe() error {
+	if srv.shuttingDown() {
+		return ErrServerClosed
+	}
+	if srv.Addr == "" {
+		return errors.New("need addr")
+	}
+	addr, err := net.ResolveUDPAddr("udp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(conn)
+}
+
+// Serve 读取数据报并交给对应会话处理
+func (srv *UdpServer) Serve(conn *net.UDPConn) error {
+	srv.mu.Lock()
+	if srv.shuttingDown() {
+		srv.mu.Unlock()
+		conn.Close()
+		return ErrServerClosed
+	}
+	srv.conn = conn
+	srv.sessions = map[string]*Session{}
+	srv.doneChan = make(chan struct{})
+	srv.mu.Unlock()
+	defer srv.Close()
+
+	if srv.BaseCtx == nil {
+		srv.BaseCtx = context.Background()
+	}
+	ctx := context.WithValue(srv.BaseCtx, ServerContextKey, srv)
+	go srv.reapIdleSessions()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if srv.shuttingDown() {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		session := srv.getSession(ctx, addr)
+		if session == nil {
+			continue
+		}
+		packet := make([]byte, n)
+		copy(packet, buf[:n])
+		session.Touch()
+		session.enqueue(packet)
+	}
+}
+
+// getSession 获取或创建客户端会话，会话数达到上限时返回 nil；
+// 新会话单独启动协程处理数据报，慢会话不阻塞监听的读取及其他会话
+func (srv *UdpServer) getSession(ctx context.Context, addr *net.UDPAddr) *Session {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	key := addr.String()
+	if session, ok := srv.sessions[key]; ok {
+		return session
+	}
+	//源地址可伪造，不限制会话数时会耗尽内存及下游连接的文件描述符
+	if len(srv.sessions) >= srv.maxSessions() {
+		return nil
+	}
+	session := &Session{
+		server:     srv,
+		remoteAddr: addr,
+		values:     map[interface{}]interface{}{},
+		packets:    make(chan []byte, sessionQueueSize),
+		done:       make(chan struct{}),
+	}
+	srv.sessions[key] = session
+	go session.serve(ctx)
+	return session
+}
+
+func (srv *UdpServer) removeSession(session *Session) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	key := session.remoteAddr.String()
+	if srv.sessions[key] == session {
+		delete(srv.sessions, key)
+	}
+}
+
+// reapIdleSessions 定期关闭空闲超时的会话
+func (srv *UdpServer) reapIdleSessions() {
+	timeout := srv.idleTimeout()
+	interval := timeout / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-srv.doneChan:
+			return
+		case <-ticker.C:
+		}
+		idleList := []*Session{}
+		srv.mu.Lock()
+		for _, session := range srv.sessions {
+			if time.Since(session.lastActiveTime()) > timeout {
+				idleList = append(idleList, session)
+			}
+		}
+		srv.mu.Unlock()
+		for _, session := range idleList {
+			session.Close()
+		}
+	}
+}
+
+// SessionNum 当前会话数
+func (srv *UdpServer) SessionNum() int {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	return len(srv.sessions)
+}
+
+// Close 关闭监听及全部会话
+func (srv *UdpServer) Close() error {
+	atomic.StoreInt32(&srv.inShutdown, 1)
+	srv.mu.Lock()
+	var err error
+	if srv.conn != nil {
+		err = srv.conn.Close()
+	}
+	if srv.doneChan != nil {
+		select {
+		case <-srv.doneChan:
+		default:
+			close(srv.doneChan)
+		}
+	}
+	sessions := srv.sessions
+	srv.sessions = map[string]*Session{}
+	srv.mu.Unlock()
+	for _, session := range sessions {
+		session.Close()
+	}
+	return err
+}
+
+// Session 单个客户端地址的会话，Close 时依次执行 OnClose 注册的回调
+type Session struct {
+	server     *UdpServer
+	remoteAddr *net.UDPAddr
+	lastActive int64
+
+	mu      sync.Mutex
+	values  map[interface{}]interface{}
+	onClose []func()
+	closed  bool
+	packets chan []byte //待处理的数据报
+	done    chan struct{}
+}
+
+// serve 按到达顺序处理会话的数据报，会话关闭后退出
+func (s *Session) serve(ctx context.Context) {
+	for {
+		select {
+		case <-s.done:
+			return
+		case packet := <-s.packets:
+			s.server.Handler.ServeUDP(ctx, s, packet)
+		}
+	}
+}
+
+// enqueue 数据报加入会话队列，队列已满时丢弃，与 udp 丢包语义一致
+func (s *Session) enqueue(packet []byte) bool {
+	select {
+	case s.packets <- packet:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Session) RemoteAddr() net.Addr {
+	return s.remoteAddr
+}
+
+func (s *Session) LocalAddr() net.Addr {
+	return s.server.conn.LocalAddr()
+}
+
+// WriteToClient 向客户端回写数据报，同时刷新会话活跃时间
+func (s *Session) WriteToClient(packet []byte) (int, error) {
+	s.Touch()
+	return s.server.conn.WriteToUDP(packet, s.remoteAddr)
+}
+
+func (s *Session) Touch() {
+	atomic.StoreInt64(&s.lastActive, time.Now().UnixNano())
+}
+
+func (s *Session) lastActiveTime() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.lastActive))
+}
+
+func (s *Session) Value(key interface{}) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+func (s *Session) SetValue(key, val interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = val
+}
+
+// OnClose 注册会话关闭时的回调，会话已关闭时立即执行
+func (s *Session) OnClose(f func()) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		f()
+		return
+	}
+	s.onClose = append(s.onClose, f)
+	s.mu.Unlock()
+}
+
+// Done 会话关闭后返回的 channel 被关闭
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Close 关闭会话，之后该客户端的数据报会创建新的会话
+func (s *Session) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	onClose := s.onClose
+	s.onClose = nil
+	close(s.done)
+	s.mu.Unlock()
+
+	s.server.removeSession(s)
+	for _, f := range onClose {
+		f()
+	}
+}
@@ -0,0 +1,81 @@
+package udp_server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// testUDPHandler 回写收到的数据报，block 不为空时内容为 stuck 的数据报阻塞在 block 上
+type testUDPHandler struct {
+	block chan struct{}
+}
+
+func (h *testUDPHandler) ServeUDP(ctx context.Context, session *Session, packet []byte) {
+	if h.block != nil && string(packet) == "stuck" {
+		<-h.block
+	}
+	session.WriteToClient(packet)
+}
+
+func startTestUdpServer(t *testing.T, srv *UdpServer) string {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(conn)
+	t.Cleanup(func() { srv.Close() })
+	return conn.LocalAddr().String()
+}
+
+func dialTestUdp(t *testing.T, addr string) *net.UDPConn {
+	raddr, _ := net.ResolveUDPAddr("udp", addr)
+	conn, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// echoTestUdp 发送数据报并等待回包，超时返回 false
+func echoTestUdp(conn *net.UDPConn, data string, timeout time.Duration) bool {
+	conn.Write([]byte(data))
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	return err == nil && string(buf[:n]) == data
+}
+
+func TestUdpServerMaxSessions(t *testing.T) {
+	srv := &UdpServer{Handler: &testUDPHandler{}, MaxSessions: 2}
+	addr := startTestUdpServer(t, srv)
+	first, second, third := dialTestUdp(t, addr), dialTestUdp(t, addr), dialTestUdp(t, addr)
+	if !echoTestUdp(first, "a", time.Second) || !echoTestUdp(second, "b", time.Second) {
+		t.Fatal("sessions under limit not served")
+	}
+	if echoTestUdp(third, "c", 200*time.Millisecond) {
+		t.Fatal("session over limit served")
+	}
+	if num := srv.SessionNum(); num != 2 {
+		t.Errorf("SessionNum = %d, want 2", num)
+	}
+	//已有会话不受影响
+	if !echoTestUdp(first, "a2", time.Second) {
+		t.Error("existing session not served after limit reached")
+	}
+}
+
+func TestUdpServerSlowSessionDoesNotBlockOthers(t *testing.T) {
+	handler := &testUDPHandler{block: make(chan struct{})}
+	srv := &UdpServer{Handler: handler}
+	addr := startTestUdpServer(t, srv)
+	slow, fast := dialTestUdp(t, addr), dialTestUdp(t, addr)
+	defer close(handler.block)
+
+	slow.Write([]byte("stuck"))
+	if !echoTestUdp(fast, "fast", time.Second) {
+		t.Fatal("fast session blocked by slow session")
+	}
+}